@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/witnz/witnz/internal/allowance"
+	"github.com/witnz/witnz/internal/cdc"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+var (
+	allowanceTable     string
+	allowanceRecord    string
+	allowanceOperation string
+	allowanceOldHash   string
+	allowanceExpires   time.Duration
+	allowanceApprover  string
+	allowanceKeyFile   string
+	allowanceReason    string
+	allowanceOutput    string
+	allowanceJSON      bool
+)
+
+func init() {
+	allowanceSignCmd.Flags().StringVar(&allowanceTable, "table", "", "protected table name")
+	allowanceSignCmd.Flags().StringVar(&allowanceRecord, "id", "", "primary key of the record")
+	allowanceSignCmd.Flags().StringVar(&allowanceOperation, "operation", "", "UPDATE or DELETE")
+	allowanceSignCmd.Flags().StringVar(&allowanceOldHash, "old-hash", "", "recorded hash of the row before the change (see 'witnz forensics')")
+	allowanceSignCmd.Flags().DurationVar(&allowanceExpires, "expires", 24*time.Hour, "how long the allowance stays valid")
+	allowanceSignCmd.Flags().StringVar(&allowanceApprover, "approver", "", "approver name as configured in allowances.approvers")
+	allowanceSignCmd.Flags().StringVar(&allowanceKeyFile, "key-file", "", "file containing the approver's hex-encoded private key")
+	allowanceSignCmd.Flags().StringVar(&allowanceReason, "reason", "", "reason recorded with the allowance")
+	allowanceSignCmd.Flags().StringVarP(&allowanceOutput, "output", "o", "", "write the signed allowance to a file")
+	for _, name := range []string{"table", "id", "operation", "old-hash", "approver", "key-file"} {
+		_ = allowanceSignCmd.MarkFlagRequired(name)
+	}
+
+	allowanceCmd.PersistentFlags().StringVar(&adminAddr, "admin-addr", "", "admin API address (default: admin.bind_addr from config)")
+	allowanceListCmd.Flags().BoolVar(&allowanceJSON, "json", false, "print allowances as JSON")
+
+	allowanceCmd.AddCommand(allowanceKeygenCmd)
+	allowanceCmd.AddCommand(allowanceSignCmd)
+	allowanceCmd.AddCommand(allowanceSubmitCmd)
+	allowanceCmd.AddCommand(allowanceListCmd)
+}
+
+var allowanceCmd = &cobra.Command{
+	Use:   "allowance",
+	Short: "Pre-approve UPDATE or DELETE operations",
+	Long: `Create, sign and submit allowances that authorize a single UPDATE or DELETE
+on a protected record. A matching change is recorded as an approved amendment
+instead of being reported as tampering.`,
+}
+
+var allowanceKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate an approver key pair",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		publicKey, privateKey, err := allowance.GenerateKey()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Public key:  %s\n", publicKey)
+		fmt.Printf("Private key: %s\n", privateKey)
+		fmt.Println("\nAdd the public key to allowances.approvers on every node and keep the private key offline.")
+		return nil
+	},
+}
+
+var allowanceSignCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "Create and sign an allowance",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		operation := cdc.OperationType(strings.ToUpper(allowanceOperation))
+		if operation != cdc.OperationUpdate && operation != cdc.OperationDelete {
+			return fmt.Errorf("operation must be UPDATE or DELETE")
+		}
+
+		keyData, err := os.ReadFile(allowanceKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read key file: %w", err)
+		}
+
+		now := time.Now()
+		a := &storage.Allowance{
+			ID:           allowance.NewID(now),
+			TableName:    allowanceTable,
+			RecordID:     allowanceRecord,
+			Operation:    string(operation),
+			ExpectedHash: allowanceOldHash,
+			ExpiresAt:    now.Add(allowanceExpires).UTC(),
+			Approver:     allowanceApprover,
+			Reason:       allowanceReason,
+		}
+
+		if err := allowance.Sign(a, strings.TrimSpace(string(keyData))); err != nil {
+			return err
+		}
+
+		if allowanceOutput == "" {
+			return printJSON(a)
+		}
+
+		data, err := json.MarshalIndent(a, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode allowance: %w", err)
+		}
+		if err := os.WriteFile(allowanceOutput, data, 0600); err != nil {
+			return fmt.Errorf("failed to write allowance: %w", err)
+		}
+
+		fmt.Printf("Allowance %s written to %s\n", a.ID, allowanceOutput)
+		return nil
+	},
+}
+
+var allowanceSubmitCmd = &cobra.Command{
+	Use:   "submit <file>",
+	Short: "Submit a signed allowance to the cluster leader",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read allowance: %w", err)
+		}
+
+		var a storage.Allowance
+		if err := json.Unmarshal(data, &a); err != nil {
+			return fmt.Errorf("failed to parse allowance: %w", err)
+		}
+
+		client, err := newAdminClient()
+		if err != nil {
+			return err
+		}
+
+		submitted, err := client.SubmitAllowance(&a)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Allowance %s accepted: %s on %s id=%s until %s\n",
+			submitted.ID, submitted.Operation, submitted.TableName, submitted.RecordID,
+			submitted.ExpiresAt.Format(time.RFC3339))
+		return nil
+	},
+}
+
+var allowanceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List allowances",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAdminClient()
+		if err != nil {
+			return err
+		}
+
+		allowances, err := client.ListAllowances()
+		if err != nil {
+			return err
+		}
+
+		if allowanceJSON {
+			return printJSON(allowances)
+		}
+
+		if len(allowances) == 0 {
+			fmt.Println("No allowances")
+			return nil
+		}
+
+		now := time.Now()
+		for _, a := range allowances {
+			state := "pending"
+			switch {
+			case a.Consumed():
+				state = "used"
+			case !now.Before(a.ExpiresAt):
+				state = "expired"
+			}
+			fmt.Printf("%s  %-7s  %-6s  %s id=%s  approver=%s  expires=%s\n",
+				a.ID, state, a.Operation, a.TableName, a.RecordID, a.Approver,
+				a.ExpiresAt.Format(time.RFC3339))
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/witnz/witnz/internal/config"
+	"github.com/witnz/witnz/internal/preflight"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+const (
+	preflightTimeout = 30 * time.Second
+	peerDialTimeout  = 3 * time.Second
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that the source databases and the Raft peers are ready for witnz",
+	Long: `Check the database settings, privileges and protected tables that change
+data capture and verification rely on, and that every Raft peer can be
+reached. Each problem is printed with the SQL or step that fixes it; the
+command exits non-zero if any check fails.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath, err := findConfigFile()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Using config file: %s\n", configPath)
+
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), preflightTimeout)
+		defer cancel()
+
+		var checks []preflight.Check
+		tables := preflightTables(ctx, cfg)
+		for _, source := range cfg.AllSources() {
+			fmt.Printf("\n%s:\n", sourceHeading(source))
+			sourceChecks := checkSource(ctx, cfg, source, sourceTables(tables, source.Name))
+			printChecks(sourceChecks)
+			checks = append(checks, sourceChecks...)
+		}
+
+		if len(cfg.Node.PeerAddrs) > 0 {
+			fmt.Println("\nRaft peers:")
+			peers := preflight.CheckPeers(cfg.Node.PeerAddrs, peerDialTimeout)
+			printChecks(peers)
+			checks = append(checks, peers...)
+		}
+
+		fmt.Println()
+		if failed := preflight.Failures(checks); failed > 0 {
+			return fmt.Errorf("%d checks failed", failed)
+		}
+		fmt.Println("All checks passed")
+		return nil
+	},
+}
+
+// preflightTables returns the tables to check. Table patterns are resolved
+// when PostgreSQL can be reached; otherwise the connection check reports the
+// problem.
+func preflightTables(ctx context.Context, cfg *config.Config) []storage.ProtectedTable {
+	tables, err := resolveTables(ctx, cfg)
+	if err != nil {
+		tables = protectedTables(cfg, nil)
+	}
+	return tables
+}
+
+// checkSource runs the readiness checks of the database of one source
+func checkSource(ctx context.Context, cfg *config.Config, source config.SourceConfig, tables []string) []preflight.Check {
+	if source.Database.IsMySQL() {
+		return preflight.CheckMySQL(ctx, replicationConfig(cfg, source), tables)
+	}
+	return preflight.CheckPostgres(ctx, replicationConfig(cfg, source), tables)
+}
+
+// databaseName names the kind of database of a source
+func databaseName(source config.SourceConfig) string {
+	if source.Database.IsMySQL() {
+		return "MySQL"
+	}
+	return "PostgreSQL"
+}
+
+// sourceHeading titles the checks of one source
+func sourceHeading(source config.SourceConfig) string {
+	if source.Name == "" {
+		return databaseName(source)
+	}
+	return fmt.Sprintf("%s source %s", databaseName(source), source.Name)
+}
+
+func printChecks(checks []preflight.Check) {
+	for _, check := range checks {
+		symbol := "✅"
+		switch check.Status {
+		case preflight.StatusWarn:
+			symbol = "⚠️ "
+		case preflight.StatusFail:
+			symbol = "❌"
+		}
+
+		fmt.Printf("  %s %s: %s\n", symbol, check.Name, check.Message)
+		if check.Remediation != "" {
+			fmt.Printf("       fix: %s\n", check.Remediation)
+		}
+	}
+}
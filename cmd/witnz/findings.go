@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/witnz/witnz/internal/admin"
+	"github.com/witnz/witnz/internal/config"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+var (
+	adminAddr      string
+	findingsStatus string
+	findingsJSON   bool
+	findingsActor  string
+	findingsNote   string
+)
+
+func init() {
+	findingsCmd.PersistentFlags().StringVar(&adminAddr, "admin-addr", "", "admin API address (default: admin.bind_addr from config)")
+	findingsListCmd.Flags().StringVar(&findingsStatus, "status", "", "filter by status (open, acknowledged, resolved)")
+	findingsListCmd.Flags().BoolVar(&findingsJSON, "json", false, "print findings as JSON")
+	findingsShowCmd.Flags().BoolVar(&findingsJSON, "json", false, "print the finding as JSON")
+
+	for _, cmd := range []*cobra.Command{findingsAckCmd, findingsResolveCmd} {
+		cmd.Flags().StringVar(&findingsActor, "actor", os.Getenv("USER"), "person performing the action")
+		cmd.Flags().StringVar(&findingsNote, "note", "", "note recorded with the action")
+	}
+
+	findingsCmd.AddCommand(findingsListCmd)
+	findingsCmd.AddCommand(findingsShowCmd)
+	findingsCmd.AddCommand(findingsAckCmd)
+	findingsCmd.AddCommand(findingsResolveCmd)
+}
+
+// newAdminClient connects to the admin API of the node described by the config file
+func newAdminClient() (*admin.Client, error) {
+	configPath, err := findConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	return adminClientForConfig(cfg)
+}
+
+func adminClientForConfig(cfg *config.Config) (*admin.Client, error) {
+	addr := adminAddr
+	if addr == "" {
+		addr = cfg.Admin.BindAddr
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("admin API address not configured (set admin.bind_addr or --admin-addr)")
+	}
+
+	return admin.NewClient(addr, cfg.Admin.Token), nil
+}
+
+var findingsCmd = &cobra.Command{
+	Use:   "findings",
+	Short: "Manage tamper findings",
+	Long:  `List, inspect, acknowledge and resolve tamper findings recorded by a running node.`,
+}
+
+var findingsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List findings",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAdminClient()
+		if err != nil {
+			return err
+		}
+
+		findings, err := client.ListFindings(storage.FindingStatus(findingsStatus))
+		if err != nil {
+			return err
+		}
+
+		if findingsJSON {
+			return printJSON(findings)
+		}
+
+		if len(findings) == 0 {
+			fmt.Println("No findings")
+			return nil
+		}
+
+		for _, f := range findings {
+			record := f.RecordID
+			if record == "" {
+				record = "-"
+			}
+			fmt.Printf("%s  %-12s  %-9s  %-6s  %s id=%s\n",
+				f.ID, f.Status, f.Kind, f.DetectedBy, f.TableName, record)
+		}
+
+		return nil
+	},
+}
+
+var findingsShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a finding",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAdminClient()
+		if err != nil {
+			return err
+		}
+
+		finding, err := client.GetFinding(args[0])
+		if err != nil {
+			return err
+		}
+
+		if findingsJSON {
+			return printJSON(finding)
+		}
+
+		printFinding(finding)
+		return nil
+	},
+}
+
+var findingsAckCmd = &cobra.Command{
+	Use:   "ack <id>",
+	Short: "Acknowledge a finding",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateFindingStatus(args[0], storage.FindingAcknowledged)
+	},
+}
+
+var findingsResolveCmd = &cobra.Command{
+	Use:   "resolve <id>",
+	Short: "Resolve a finding",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateFindingStatus(args[0], storage.FindingResolved)
+	},
+}
+
+func updateFindingStatus(id string, status storage.FindingStatus) error {
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+
+	finding, err := client.UpdateFindingStatus(id, status, findingsActor, findingsNote)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Finding %s is now %s\n", finding.ID, finding.Status)
+	return nil
+}
+
+func printFinding(f *storage.Finding) {
+	fmt.Printf("ID: %s\n", f.ID)
+	fmt.Printf("Status: %s\n", f.Status)
+	fmt.Printf("Table: %s\n", f.TableName)
+	if f.RecordID != "" {
+		fmt.Printf("Record ID: %s\n", f.RecordID)
+	}
+	fmt.Printf("Kind: %s\n", f.Kind)
+	fmt.Printf("Detected by: %s on %s\n", f.DetectedBy, f.NodeID)
+	fmt.Printf("Detected at: %s\n", f.DetectedAt.Format(time.RFC3339))
+	if f.LSN != 0 {
+		fmt.Printf("LSN: %d\n", f.LSN)
+	}
+	if f.ExpectedHash != "" {
+		fmt.Printf("Expected hash: %s\n", f.ExpectedHash)
+	}
+	if f.ActualHash != "" {
+		fmt.Printf("Actual hash: %s\n", f.ActualHash)
+	}
+	if f.Details != "" {
+		fmt.Printf("Details: %s\n", f.Details)
+	}
+	if f.StatusBy != "" {
+		fmt.Printf("Last action: %s by %s at %s\n", f.Status, f.StatusBy, f.UpdatedAt.Format(time.RFC3339))
+	}
+	if f.StatusNote != "" {
+		fmt.Printf("Note: %s\n", f.StatusNote)
+	}
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/witnz/witnz/internal/config"
+	"github.com/witnz/witnz/internal/forensics"
+	"github.com/witnz/witnz/internal/hash"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+var (
+	forensicsJSON   bool
+	forensicsOutput string
+)
+
+func init() {
+	forensicsCmd.Flags().BoolVar(&forensicsJSON, "json", false, "print the report as JSON")
+	forensicsCmd.Flags().StringVarP(&forensicsOutput, "output", "o", "", "write the JSON report to a file")
+}
+
+var forensicsCmd = &cobra.Command{
+	Use:   "forensics <table> <id>",
+	Short: "Show original and current values of a protected record",
+	Long: `Show the row image recorded at insert time, the current row in PostgreSQL
+and a column-level diff. Original values are only available for tables
+configured with store_row_images.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tableName, recordID := args[0], args[1]
+
+		configPath, err := findConfigFile()
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		if err := hash.Initialize(cfg.Hash.Algorithm); err != nil {
+			return fmt.Errorf("failed to initialize hash algorithm: %w", err)
+		}
+
+		var rowCipher *forensics.Cipher
+		if cfg.Forensics.EncryptionKey != "" {
+			rowCipher, err = forensics.NewCipher(cfg.Forensics.EncryptionKey)
+			if err != nil {
+				return fmt.Errorf("failed to initialize row image cipher: %w", err)
+			}
+		}
+
+		dbPath := filepath.Join(cfg.Node.DataDir, "witnz.db")
+		store, err := storage.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("failed to open storage: %w", err)
+		}
+		defer store.Close()
+
+		merkleVerifier := newMerkleVerifier(cfg, store)
+
+		report, err := merkleVerifier.ForensicReport(context.Background(), tableName, recordID, rowCipher)
+		if err != nil {
+			return err
+		}
+
+		if forensicsOutput != "" {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode report: %w", err)
+			}
+			if err := os.WriteFile(forensicsOutput, data, 0600); err != nil {
+				return fmt.Errorf("failed to write report: %w", err)
+			}
+			fmt.Printf("Forensics report written to %s\n", forensicsOutput)
+			return nil
+		}
+
+		if forensicsJSON {
+			return printJSON(report)
+		}
+
+		printForensicsReport(report)
+		return nil
+	},
+}
+
+func printForensicsReport(report *forensics.Report) {
+	fmt.Printf("Table: %s\n", report.TableName)
+	fmt.Printf("Record ID: %s\n", report.RecordID)
+	fmt.Printf("Status: %s\n", report.Status)
+	fmt.Printf("Recorded: seq=%d at %s\n", report.SequenceNum, report.RecordedAt.Format(time.RFC3339))
+	fmt.Printf("Expected hash: %s\n", report.ExpectedHash)
+	if report.ActualHash != "" {
+		fmt.Printf("Actual hash:   %s\n", report.ActualHash)
+	}
+
+	if report.Original == nil {
+		fmt.Printf("\nNo row image recorded (enable store_row_images for this table)\n")
+	} else {
+		fmt.Printf("\nOriginal values:\n")
+		printRow(report.Original)
+	}
+
+	if report.Current == nil {
+		fmt.Printf("\nCurrent values: record no longer exists\n")
+	} else {
+		fmt.Printf("\nCurrent values:\n")
+		printRow(report.Current)
+	}
+
+	if len(report.Changes) > 0 {
+		fmt.Printf("\nChanges:\n")
+		for _, change := range report.Changes {
+			switch change.Change {
+			case forensics.ChangeAdded:
+				fmt.Printf("  + %s: %s\n", change.Column, change.Current)
+			case forensics.ChangeRemoved:
+				fmt.Printf("  - %s: %s\n", change.Column, change.Original)
+			default:
+				fmt.Printf("  ~ %s: %s -> %s\n", change.Column, change.Original, change.Current)
+			}
+		}
+	}
+}
+
+func printRow(row map[string]string) {
+	columns := make([]string, 0, len(row))
+	for col := range row {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	for _, col := range columns {
+		fmt.Printf("  %s: %s\n", col, row[col])
+	}
+}
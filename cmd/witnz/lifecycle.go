@@ -0,0 +1,758 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/witnz/witnz/internal/admin"
+	"github.com/witnz/witnz/internal/alert"
+	"github.com/witnz/witnz/internal/cdc"
+	"github.com/witnz/witnz/internal/config"
+	"github.com/witnz/witnz/internal/consensus"
+	"github.com/witnz/witnz/internal/discovery"
+	"github.com/witnz/witnz/internal/hash"
+	"github.com/witnz/witnz/internal/logging"
+	"github.com/witnz/witnz/internal/storage"
+	"github.com/witnz/witnz/internal/verify"
+)
+
+var logger = logging.Logger("witnz")
+
+// runningNode holds the components started by 'witnz start' so they can be
+// reloaded and shut down in dependency order. Components that were never
+// started are left nil and skipped.
+type runningNode struct {
+	configPath string
+	cancel     context.CancelFunc
+	alerts     *alert.Manager
+
+	mu  sync.Mutex
+	cfg *config.Config
+
+	store *storage.Storage
+	// managers holds the CDC manager of each source, keyed by source name
+	managers    map[string]*cdc.Manager
+	raftNode    *consensus.Node
+	raftHandler *verify.RaftHashChainHandler
+	batcher     *consensus.Batcher
+	forwarder   *consensus.Forwarder
+	rotator     *consensus.LeadershipRotator
+	fence       *consensus.FollowerFence
+	verifier    *verify.MerkleVerifier
+	adminServer *admin.Server
+
+	// The protected tables currently in effect. In Raft mode they change
+	// only when a table set entry is applied, so every node switches at the
+	// same log index.
+	tablesMu     sync.Mutex
+	handler      *verify.HashChainHandler
+	tables       []storage.ProtectedTable
+	patterns     []string
+	publications map[string]*cdc.Manager
+
+	publicationMu sync.Mutex
+
+	shutdownOnce sync.Once
+	shutdownErr  error
+}
+
+// policyCheckTimeout bounds waiting for a leader to compare the config with
+// the cluster policy
+const policyCheckTimeout = 2 * time.Minute
+
+// publicationSyncTimeout bounds updating the publication after the protected
+// tables change
+const publicationSyncTimeout = 30 * time.Second
+
+// tableDiscoveryTimeout bounds listing the tables table patterns may match
+const tableDiscoveryTimeout = 30 * time.Second
+
+type shutdownStep struct {
+	name string
+	run  func(ctx context.Context) error
+	// teardown steps release what earlier steps use. They first wait for
+	// earlier steps that timed out, and are skipped while those still run.
+	teardown bool
+}
+
+// wait blocks until the node is asked to stop or is fenced. SIGHUP reloads
+// the configuration and keeps the node running.
+func (n *runningNode) wait(fenced <-chan time.Duration) error {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case sig := <-sigCh:
+			if sig != syscall.SIGHUP {
+				return nil
+			}
+			if _, err := n.reload(); err != nil {
+				logger.Error("Config reload failed", "error", err)
+			}
+		case lost := <-fenced:
+			return fmt.Errorf("node fenced after %v without a Raft leader", lost.Round(time.Second))
+		}
+	}
+}
+
+// reload re-reads the config file and applies the settings that can change
+// while the node runs. Other changes are reported and take effect on restart.
+func (n *runningNode) reload() (*admin.ReloadResult, error) {
+	cfg, err := config.Load(n.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	n.mu.Lock()
+	previous := n.cfg
+	n.cfg = cfg
+	n.mu.Unlock()
+
+	n.alerts.Configure(cfg.Alerts.Enabled, cfg.Alerts.SlackWebhook)
+	if err := configureLogging(cfg); err != nil {
+		return nil, err
+	}
+
+	restart := restartRequired(previous, cfg)
+	for _, section := range restart {
+		logger.Warn("Config change takes effect after a restart", "section", section)
+	}
+
+	if _, err := n.switchTables(cfg); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Configuration reloaded", "path", n.configPath)
+	return &admin.ReloadResult{Tables: n.tableNames(), RestartRequired: restart}, nil
+}
+
+// configureLogging applies the logging section of cfg. Every record carries
+// the node ID so logs of a cluster can be merged.
+func configureLogging(cfg *config.Config) error {
+	return logging.Configure(logging.Options{
+		Format:     cfg.Logging.Format,
+		Level:      cfg.Logging.Level,
+		Subsystems: cfg.Logging.Subsystems,
+		Attrs:      []slog.Attr{slog.String("node_id", cfg.Node.ID)},
+	})
+}
+
+// replicationConfig returns the logical replication settings of this node
+// for one source
+func replicationConfig(cfg *config.Config, source config.SourceConfig) *cdc.ReplicationConfig {
+	return &cdc.ReplicationConfig{
+		Host:            source.Database.Host,
+		Port:            source.Database.Port,
+		Database:        source.Database.Database,
+		User:            source.Database.User,
+		Password:        source.Database.Password,
+		SlotName:        source.SlotName(cfg.Node.ID),
+		PublicationName: source.PublicationName(),
+		Source:          source.Name,
+	}
+}
+
+// newManager returns the change data capture manager of one source, which
+// reads the binary log of MySQL sources and logical replication otherwise
+func newManager(cfg *config.Config, source config.SourceConfig) *cdc.Manager {
+	manager := cdc.NewManager(replicationConfig(cfg, source))
+	if source.Database.IsMySQL() {
+		manager.SetSource(cdc.MySQLSource())
+	}
+	return manager
+}
+
+// newMerkleVerifier returns a verifier that reaches the database of every
+// source
+func newMerkleVerifier(cfg *config.Config, store *storage.Storage) *verify.MerkleVerifier {
+	var defaultConnStr string
+	if cfg.Database.Host != "" {
+		defaultConnStr = cfg.Database.ConnectionString()
+	}
+
+	verifier := verify.NewMerkleVerifier(store, defaultConnStr)
+	for _, source := range cfg.Sources {
+		verifier.SetSource(source.Name, source.Database.ConnectionString())
+	}
+	for _, source := range cfg.AllSources() {
+		if source.Database.IsMySQL() {
+			verifier.SetDialer(source.Name, verify.DialMySQL)
+		}
+	}
+	return verifier
+}
+
+// sourceLabel names a source in messages
+func sourceLabel(source config.SourceConfig) string {
+	if source.Name == "" {
+		return "default"
+	}
+	return source.Name
+}
+
+// resolveTables returns the table set described by the config, listing the
+// tables of a source only when it has table patterns
+func resolveTables(ctx context.Context, cfg *config.Config) ([]storage.ProtectedTable, error) {
+	if len(cfg.TablePatterns()) == 0 {
+		return protectedTables(cfg, nil), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tableDiscoveryTimeout)
+	defer cancel()
+
+	found := make(map[string][]discovery.Table)
+	for _, source := range cfg.AllSources() {
+		if !slices.ContainsFunc(source.ProtectedTables, func(t config.ProtectedTableConfig) bool { return t.Pattern != "" }) {
+			continue
+		}
+		tables, err := discovery.ListTables(ctx, source.Database.ConnectionString())
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve table patterns of source %s: %w", sourceLabel(source), err)
+		}
+		found[source.Name] = tables
+	}
+
+	return protectedTables(cfg, found), nil
+}
+
+// protectedTables returns the table set described by the config, with the
+// patterns of each source resolved against the tables found in it. Named
+// tables come first, then matches in config order; a table matched more
+// than once keeps its first settings. Matched tables outside the search path
+// are named schema.table.
+func protectedTables(cfg *config.Config, found map[string][]discovery.Table) []storage.ProtectedTable {
+	sources := cfg.AllSources()
+	tables := make([]storage.ProtectedTable, 0, len(cfg.ProtectedTables))
+	seen := make(map[string]bool)
+	for _, source := range sources {
+		for _, table := range source.ProtectedTables {
+			name := storage.QualifiedTable(source.Name, table.Name)
+			if table.Name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			tables = append(tables, storage.ProtectedTable{
+				Name:           name,
+				VerifyInterval: table.VerifyInterval,
+				StoreRowImages: table.StoreRowImages,
+			})
+		}
+	}
+
+	for _, source := range sources {
+		for _, table := range source.ProtectedTables {
+			if table.Pattern == "" {
+				continue
+			}
+			pattern, err := discovery.ParsePattern(table.Pattern)
+			if err != nil {
+				continue
+			}
+
+			for _, match := range discovery.Resolve(pattern, found[source.Name]) {
+				// A table on the search path may also be named schema.table
+				name := storage.QualifiedTable(source.Name, match.Reference())
+				if seen[name] || seen[storage.QualifiedTable(source.Name, match.String())] {
+					continue
+				}
+				seen[name] = true
+				tables = append(tables, storage.ProtectedTable{
+					Name:           name,
+					Pattern:        storage.QualifiedTable(source.Name, table.Pattern),
+					VerifyInterval: table.VerifyInterval,
+					StoreRowImages: table.StoreRowImages,
+				})
+			}
+		}
+	}
+
+	return tables
+}
+
+// sourceTables returns the names, in their own database, of the tables that
+// belong to source
+func sourceTables(tables []storage.ProtectedTable, source string) []string {
+	var names []string
+	for _, table := range tables {
+		if tableSource, name := storage.SplitTable(table.Name); tableSource == source {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// clusterPolicy returns the settings of cfg that every node of a cluster
+// must share
+func clusterPolicy(cfg *config.Config) *storage.ClusterPolicy {
+	policy := &storage.ClusterPolicy{
+		HashAlgorithm:   cfg.Hash.Algorithm,
+		EncodingVersion: hash.EncodingVersion,
+		ExcludedColumns: hash.ExcludedColumns(),
+		Tables:          []string{},
+		Patterns:        cfg.TablePatterns(),
+	}
+	for _, source := range cfg.AllSources() {
+		for _, table := range source.ProtectedTables {
+			if table.Name != "" {
+				policy.Tables = append(policy.Tables, storage.QualifiedTable(source.Name, table.Name))
+			}
+		}
+	}
+	policy.Normalize()
+	return policy
+}
+
+// switchTables moves the node to the protected tables of cfg and returns
+// the tables that were added. In Raft mode the new set is proposed to the
+// cluster and takes effect when applied.
+func (n *runningNode) switchTables(cfg *config.Config) ([]storage.ProtectedTable, error) {
+	tables, err := resolveTables(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	patterns := cfg.TablePatterns()
+
+	n.tablesMu.Lock()
+	unchanged := reflect.DeepEqual(n.tables, tables) && reflect.DeepEqual(n.patterns, patterns)
+	current := make(map[string]bool, len(n.tables))
+	for _, table := range n.tables {
+		current[table.Name] = true
+	}
+	n.tablesMu.Unlock()
+	if unchanged {
+		return nil, nil
+	}
+
+	if err := checkTables(cfg, tables); err != nil {
+		return nil, err
+	}
+
+	if n.raftNode == nil {
+		err = n.applyTables(tables)
+	} else if err = n.raftNode.ProposeTableSet(tables, patterns); err != nil {
+		return nil, fmt.Errorf("failed to replicate protected tables: %w", err)
+	}
+
+	n.tablesMu.Lock()
+	n.patterns = patterns
+	n.tablesMu.Unlock()
+
+	var added []storage.ProtectedTable
+	for _, table := range tables {
+		if !current[table.Name] {
+			added = append(added, table)
+		}
+	}
+	return added, err
+}
+
+// watchTables resolves the table patterns every interval so tables created
+// later are protected without a reload. In Raft mode only the leader looks,
+// and the set it proposes reaches every node.
+func (n *runningNode) watchTables(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.discoverTables()
+		}
+	}
+}
+
+func (n *runningNode) discoverTables() {
+	n.mu.Lock()
+	cfg := n.cfg
+	n.mu.Unlock()
+
+	if len(cfg.TablePatterns()) == 0 {
+		return
+	}
+	if n.raftNode != nil && !n.raftNode.IsLeader() {
+		return
+	}
+
+	added, err := n.switchTables(cfg)
+	if err != nil {
+		logger.Error("Table discovery failed", "error", err)
+		return
+	}
+
+	for _, table := range added {
+		if table.Pattern == "" {
+			continue
+		}
+		logger.Info("Discovered table, now protected", "table", table.Name, "pattern", table.Pattern)
+		_ = n.alerts.SendSystemAlert(
+			"New Table Protected",
+			fmt.Sprintf("Table %s matches pattern %s and is now protected; a baseline checkpoint records its current contents", table.Name, table.Pattern),
+			"good",
+		)
+	}
+}
+
+// checkTables rejects a table set before it is replicated, so a bad config
+// on one node cannot break table protection on every node
+func checkTables(cfg *config.Config, tables []storage.ProtectedTable) error {
+	for _, table := range tables {
+		if table.VerifyInterval != "" {
+			if _, err := time.ParseDuration(table.VerifyInterval); err != nil {
+				return fmt.Errorf("invalid verify_interval for %s: %w", table.Name, err)
+			}
+		}
+		if table.StoreRowImages && cfg.Forensics.EncryptionKey == "" {
+			return fmt.Errorf("table %s stores row images but no encryption key is configured", table.Name)
+		}
+	}
+	return nil
+}
+
+// ObserveTableSet applies a table set replicated through Raft
+func (n *runningNode) ObserveTableSet(tables []storage.ProtectedTable) {
+	if err := n.applyTables(tables); err != nil {
+		logger.Error("Failed to apply protected tables", "error", err)
+	}
+}
+
+// applyTables adds and removes tables on the handler and the verifier so
+// they match tables, then updates the publication. Tables whose settings
+// changed are removed and added again.
+func (n *runningNode) applyTables(tables []storage.ProtectedTable) error {
+	n.tablesMu.Lock()
+	defer n.tablesMu.Unlock()
+
+	current := make(map[string]storage.ProtectedTable, len(n.tables))
+	for _, table := range n.tables {
+		current[table.Name] = table
+	}
+	next := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		next[table.Name] = true
+	}
+
+	for _, table := range n.tables {
+		if !next[table.Name] {
+			logger.Info("No longer protecting table", "table", table.Name)
+			n.removeTable(table.Name)
+		}
+	}
+
+	var errs []error
+	applied := make([]storage.ProtectedTable, 0, len(tables))
+	for _, table := range tables {
+		if old, ok := current[table.Name]; ok && old == table {
+			applied = append(applied, table)
+			continue
+		}
+		if err := n.addTable(table); err != nil {
+			errs = append(errs, fmt.Errorf("failed to add table %s: %w", table.Name, err))
+			continue
+		}
+		applied = append(applied, table)
+	}
+	n.tables = applied
+
+	if n.publications != nil {
+		go n.syncPublications()
+	}
+
+	return errors.Join(errs...)
+}
+
+func (n *runningNode) addTable(table storage.ProtectedTable) error {
+	// Changed settings replace the table's previous configuration
+	n.removeTable(table.Name)
+
+	logger.Info("Protecting table", "table", table.Name)
+	if err := n.handler.AddTable(&verify.TableConfig{
+		Name:           table.Name,
+		StoreRowImages: table.StoreRowImages,
+	}); err != nil {
+		return err
+	}
+
+	if n.verifier == nil {
+		return nil
+	}
+	if err := n.verifier.AddTable(&verify.TableConfig{
+		Name:           table.Name,
+		VerifyInterval: table.VerifyInterval,
+	}); err != nil {
+		n.handler.RemoveTable(table.Name)
+		return err
+	}
+	return nil
+}
+
+func (n *runningNode) removeTable(name string) {
+	n.handler.RemoveTable(name)
+	if n.verifier != nil {
+		n.verifier.RemoveTable(name)
+	}
+}
+
+// watchPublications keeps the publication of every source in step with the
+// protected tables once the CDC managers have created them
+func (n *runningNode) watchPublications(managers map[string]*cdc.Manager) {
+	n.tablesMu.Lock()
+	n.publications = managers
+	n.tablesMu.Unlock()
+
+	n.syncPublications()
+}
+
+// syncPublications updates each source's publication to its current
+// tables. Updates are serialized so a slow update cannot overwrite a newer
+// table set.
+func (n *runningNode) syncPublications() {
+	n.publicationMu.Lock()
+	defer n.publicationMu.Unlock()
+
+	n.tablesMu.Lock()
+	managers := n.publications
+	tables := n.tables
+	n.tablesMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), publicationSyncTimeout)
+	defer cancel()
+
+	for source, manager := range managers {
+		if err := manager.SyncPublication(ctx, sourceTables(tables, source)); err != nil {
+			logger.Error("Failed to update publication", "source", source, "error", err)
+		}
+	}
+}
+
+// cdcHealthy reports whether the replication stream of every source is
+// receiving without errors
+func (n *runningNode) cdcHealthy() bool {
+	for _, manager := range n.managers {
+		if !manager.Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *runningNode) tableNames() []string {
+	n.tablesMu.Lock()
+	defer n.tablesMu.Unlock()
+	return tableNames(n.tables)
+}
+
+func tableNames(tables []storage.ProtectedTable) []string {
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = table.Name
+	}
+	return names
+}
+
+// restartRequired lists the config sections that changed but are only read
+// at startup
+func restartRequired(previous, cfg *config.Config) []string {
+	sections := []struct {
+		name      string
+		old, curr interface{}
+	}{
+		{"database", previous.Database, cfg.Database},
+		{"sources", sourceSettings(previous), sourceSettings(cfg)},
+		{"node", previous.Node, cfg.Node},
+		{"raft", previous.Raft, cfg.Raft},
+		{"hash", previous.Hash, cfg.Hash},
+		{"table_discovery", previous.TableDiscovery, cfg.TableDiscovery},
+		{"watchdog", previous.Watchdog, cfg.Watchdog},
+		{"forensics", previous.Forensics, cfg.Forensics},
+		{"admin", previous.Admin, cfg.Admin},
+		{"allowances", previous.Allowances, cfg.Allowances},
+	}
+
+	var changed []string
+	for _, section := range sections {
+		if !reflect.DeepEqual(section.old, section.curr) {
+			changed = append(changed, section.name)
+		}
+	}
+	return changed
+}
+
+// sourceSettings returns the sources of cfg without their protected tables,
+// which are applied on reload
+func sourceSettings(cfg *config.Config) []config.SourceConfig {
+	sources := make([]config.SourceConfig, len(cfg.Sources))
+	for i, source := range cfg.Sources {
+		source.ProtectedTables = nil
+		sources[i] = source
+	}
+	return sources
+}
+
+// shutdown stops the node in dependency order: CDC intake first so no new
+// changes arrive, then pending proposals are flushed and the final LSN is
+// acknowledged, leadership is handed off, and only then are the verifier,
+// Raft and the stores stopped. Each step is bounded by shutdown.step_timeout;
+// a step that fails or times out is reported and the next step still runs,
+// but Raft and the stores are only torn down once timed-out steps returned.
+func (n *runningNode) shutdown() error {
+	n.shutdownOnce.Do(func() {
+		n.mu.Lock()
+		timeout := n.cfg.Shutdown.StepTimeoutDuration()
+		n.mu.Unlock()
+
+		n.shutdownErr = runShutdown(n.shutdownSteps(), timeout)
+		n.cancel()
+	})
+	return n.shutdownErr
+}
+
+func (n *runningNode) shutdownSteps() []shutdownStep {
+	return []shutdownStep{
+		{name: "stop CDC intake", run: func(ctx context.Context) error {
+			if n.rotator != nil {
+				n.rotator.Stop()
+			}
+			if n.fence != nil {
+				n.fence.Stop()
+			}
+			var errs []error
+			for source, manager := range n.managers {
+				if err := manager.Drain(ctx); err != nil {
+					errs = append(errs, fmt.Errorf("source %s: %w", source, err))
+				}
+			}
+			return errors.Join(errs...)
+		}},
+		{name: "flush pending proposals", run: func(ctx context.Context) error {
+			var flushErr error
+			if n.raftHandler != nil {
+				flushErr = n.raftHandler.Flush(ctx)
+			}
+			// Report whatever was acknowledged even if the flush failed
+			errs := []error{flushErr}
+			for source, manager := range n.managers {
+				if err := manager.Close(ctx); err != nil {
+					errs = append(errs, fmt.Errorf("source %s: %w", source, err))
+					continue
+				}
+				logger.Info("Acknowledged WAL", "source", source, "lsn", manager.AcknowledgedLSN().String())
+			}
+			return errors.Join(errs...)
+		}},
+		{name: "transfer leadership", run: func(ctx context.Context) error {
+			if n.raftNode == nil {
+				return nil
+			}
+			return n.raftNode.HandOffLeadership(ctx)
+		}},
+		{name: "stop verifier", run: func(ctx context.Context) error {
+			if n.verifier == nil {
+				return nil
+			}
+			return n.verifier.Stop(ctx)
+		}},
+		{name: "stop admin API", run: func(ctx context.Context) error {
+			if n.adminServer == nil {
+				return nil
+			}
+			return n.adminServer.Stop(ctx)
+		}},
+		{name: "shut down Raft", teardown: true, run: func(ctx context.Context) error {
+			if n.batcher != nil {
+				n.batcher.Stop()
+			}
+			if n.forwarder != nil {
+				n.forwarder.Stop()
+			}
+			if n.raftNode == nil {
+				return nil
+			}
+			return n.raftNode.Stop()
+		}},
+		{name: "close stores", teardown: true, run: func(ctx context.Context) error {
+			if n.store == nil {
+				return nil
+			}
+			return n.store.Close()
+		}},
+	}
+}
+
+func runShutdown(steps []shutdownStep, timeout time.Duration) error {
+	var errs []error
+	// running holds the steps that timed out and have not returned yet
+	running := make(map[string]chan error)
+
+	for _, step := range steps {
+		var err error
+		if step.teardown {
+			err = awaitSteps(running, timeout)
+		}
+		if err == nil {
+			err = runStep(step, timeout, running)
+		}
+
+		if err != nil {
+			logger.Error("Shutdown step failed", "step", step.name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", step.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// runStep runs a step until it returns or timeout passes. A step that times
+// out has its context cancelled and is added to running.
+func runStep(step shutdownStep, timeout time.Duration, running map[string]chan error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- step.run(ctx) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		running[step.name] = done
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+}
+
+// awaitSteps waits up to timeout for the steps that timed out earlier. It
+// fails when some are still running, since tearing down what they use
+// could corrupt it.
+func awaitSteps(running map[string]chan error, timeout time.Duration) error {
+	deadline := time.After(timeout)
+
+	for name, done := range running {
+		select {
+		case <-done:
+			delete(running, name)
+		case <-deadline:
+			var names []string
+			for name := range running {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("skipped, still waiting for %s", strings.Join(names, ", "))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/witnz/witnz/internal/config"
+	"github.com/witnz/witnz/internal/discovery"
+)
+
+func TestProtectedTables(t *testing.T) {
+	cfg := &config.Config{
+		Database: config.DatabaseConfig{Host: "localhost"},
+		ProtectedTables: []config.ProtectedTableConfig{
+			{Name: "audit_log"},
+			{Pattern: "audit.*"},
+			{Pattern: "*_log"},
+		},
+		Sources: []config.SourceConfig{{
+			Name:            "billing",
+			ProtectedTables: []config.ProtectedTableConfig{{Pattern: "ledger.*"}},
+		}},
+	}
+	found := map[string][]discovery.Table{
+		"": {
+			{Schema: "audit", Name: "events", Visible: false},
+			{Schema: "public", Name: "access_log", Visible: true},
+			{Schema: "public", Name: "audit_log", Visible: true},
+		},
+		"billing": {
+			{Schema: "ledger", Name: "entries", Visible: false},
+		},
+	}
+
+	var names []string
+	for _, table := range protectedTables(cfg, found) {
+		names = append(names, table.Name)
+	}
+	expected := "audit_log,audit.events,access_log,billing/ledger.entries"
+	if strings.Join(names, ",") != expected {
+		t.Errorf("Expected tables %s, got %v", expected, names)
+	}
+}
+
+func TestShutdownSteps(t *testing.T) {
+	n := &runningNode{}
+	steps := n.shutdownSteps()
+
+	var names []string
+	for _, step := range steps {
+		names = append(names, step.name)
+	}
+	expected := []string{
+		"stop CDC intake",
+		"flush pending proposals",
+		"transfer leadership",
+		"stop verifier",
+		"stop admin API",
+		"shut down Raft",
+		"close stores",
+	}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected steps %v, got %v", expected, names)
+	}
+
+	for _, step := range steps {
+		teardown := step.name == "shut down Raft" || step.name == "close stores"
+		if step.teardown != teardown {
+			t.Errorf("Expected %s teardown=%v, got %v", step.name, teardown, step.teardown)
+		}
+	}
+
+	// A node that never started anything shuts down cleanly
+	if err := runShutdown(steps, time.Second); err != nil {
+		t.Errorf("Expected an empty node to shut down cleanly, got %v", err)
+	}
+}
+
+func TestRunShutdown(t *testing.T) {
+	// recorder records the order in which steps start and finish
+	type recorder struct {
+		mu     sync.Mutex
+		events []string
+	}
+	record := func(r *recorder, event string) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.events = append(r.events, event)
+	}
+
+	t.Run("Order", func(t *testing.T) {
+		r := &recorder{}
+		var steps []shutdownStep
+		for _, name := range []string{"first", "second", "third"} {
+			name := name
+			steps = append(steps, shutdownStep{name: name, run: func(context.Context) error {
+				record(r, name)
+				return nil
+			}})
+		}
+
+		if err := runShutdown(steps, time.Second); err != nil {
+			t.Fatalf("runShutdown failed: %v", err)
+		}
+		if strings.Join(r.events, ",") != "first,second,third" {
+			t.Errorf("Expected steps in order, got %v", r.events)
+		}
+	})
+
+	t.Run("FailureContinues", func(t *testing.T) {
+		r := &recorder{}
+		steps := []shutdownStep{
+			{name: "failing", run: func(context.Context) error { return fmt.Errorf("boom") }},
+			{name: "next", run: func(context.Context) error {
+				record(r, "next")
+				return nil
+			}},
+		}
+
+		err := runShutdown(steps, time.Second)
+		if err == nil || !strings.Contains(err.Error(), "failing: boom") {
+			t.Errorf("Expected the failed step to be reported, got %v", err)
+		}
+		if len(r.events) != 1 {
+			t.Errorf("Expected the next step to run, got %v", r.events)
+		}
+	})
+
+	t.Run("TeardownWaitsForTimedOutStep", func(t *testing.T) {
+		r := &recorder{}
+		steps := []shutdownStep{
+			{name: "slow", run: func(ctx context.Context) error {
+				<-ctx.Done()
+				// Cleaning up after cancellation outlasts the step deadline
+				time.Sleep(30 * time.Millisecond)
+				record(r, "slow done")
+				return ctx.Err()
+			}},
+			{name: "independent", run: func(context.Context) error {
+				record(r, "independent")
+				return nil
+			}},
+			{name: "close stores", teardown: true, run: func(context.Context) error {
+				record(r, "close stores")
+				return nil
+			}},
+		}
+
+		err := runShutdown(steps, 100*time.Millisecond)
+		if err == nil || !strings.Contains(err.Error(), "slow: timed out") {
+			t.Errorf("Expected the slow step to time out, got %v", err)
+		}
+		if strings.Join(r.events, ",") != "independent,slow done,close stores" {
+			t.Errorf("Expected teardown to wait for the timed out step, got %v", r.events)
+		}
+	})
+
+	t.Run("TeardownSkippedWhileStepRuns", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		closed := false
+		steps := []shutdownStep{
+			{name: "stuck", run: func(context.Context) error {
+				<-release
+				return nil
+			}},
+			{name: "close stores", teardown: true, run: func(context.Context) error {
+				closed = true
+				return nil
+			}},
+		}
+
+		err := runShutdown(steps, 20*time.Millisecond)
+		if err == nil || !strings.Contains(err.Error(), "close stores: skipped, still waiting for stuck") {
+			t.Errorf("Expected teardown to be skipped, got %v", err)
+		}
+		if closed {
+			t.Error("Expected stores to stay open while a step still uses them")
+		}
+	})
+}
@@ -2,18 +2,25 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/signal"
 	"path/filepath"
-	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/witnz/witnz/internal/admin"
+	"github.com/witnz/witnz/internal/alert"
+	"github.com/witnz/witnz/internal/allowance"
 	"github.com/witnz/witnz/internal/cdc"
 	"github.com/witnz/witnz/internal/config"
 	"github.com/witnz/witnz/internal/consensus"
+	"github.com/witnz/witnz/internal/forensics"
 	"github.com/witnz/witnz/internal/hash"
+	"github.com/witnz/witnz/internal/logging"
+	"github.com/witnz/witnz/internal/preflight"
+	"github.com/witnz/witnz/internal/signing"
 	"github.com/witnz/witnz/internal/storage"
 	"github.com/witnz/witnz/internal/verify"
 )
@@ -31,11 +38,18 @@ var rootCmd = &cobra.Command{
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file path (default: config/witnz.yaml or witnz.yaml)")
+	statusCmd.Flags().StringVar(&adminAddr, "admin-addr", "", "admin API address (default: admin.bind_addr from config)")
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(startCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(forensicsCmd)
+	rootCmd.AddCommand(findingsCmd)
+	rootCmd.AddCommand(allowanceCmd)
+	rootCmd.AddCommand(nodeKeyCmd)
+	rootCmd.AddCommand(reloadCmd)
+	rootCmd.AddCommand(doctorCmd)
 }
 
 func findConfigFile() (string, error) {
@@ -72,7 +86,7 @@ var versionCmd = &cobra.Command{
 
 var initCmd = &cobra.Command{
 	Use:   "init",
-	Short: "Initialize witnz node",
+	Short: "Initialize witnz node, its publication and replication slot",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		configPath, err := findConfigFile()
 		if err != nil {
@@ -104,6 +118,36 @@ var initCmd = &cobra.Command{
 		fmt.Printf("Data directory: %s\n", cfg.Node.DataDir)
 		fmt.Printf("Database path: %s\n", dbPath)
 
+		ctx, cancel := context.WithTimeout(context.Background(), preflightTimeout)
+		defer cancel()
+
+		// Refuse to create anything a source database could not use
+		sources := cfg.AllSources()
+		tables := preflightTables(ctx, cfg)
+		var checks []preflight.Check
+		for _, source := range sources {
+			fmt.Printf("\nChecking %s:\n", sourceHeading(source))
+			sourceChecks := checkSource(ctx, cfg, source, sourceTables(tables, source.Name))
+			printChecks(sourceChecks)
+			checks = append(checks, sourceChecks...)
+		}
+		if failed := preflight.Failures(checks); failed > 0 {
+			return fmt.Errorf("%d checks failed, fix them and run 'witnz init' again", failed)
+		}
+
+		fmt.Println()
+		for _, source := range sources {
+			if err := newManager(cfg, source).Prepare(ctx); err != nil {
+				return fmt.Errorf("failed to prepare change data capture for source %s: %w", sourceLabel(source), err)
+			}
+			if source.Database.IsMySQL() {
+				fmt.Printf("Binary log of source %s is ready\n", sourceLabel(source))
+				continue
+			}
+			replication := replicationConfig(cfg, source)
+			fmt.Printf("Publication %s and replication slot %s are ready\n", replication.PublicationName, replication.SlotName)
+		}
+
 		return nil
 	},
 }
@@ -116,21 +160,23 @@ var startCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		fmt.Printf("Using config file: %s\n", configPath)
 
 		cfg, err := config.Load(configPath)
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
+		if err := configureLogging(cfg); err != nil {
+			return err
+		}
 
 		if err := hash.Initialize(cfg.Hash.Algorithm); err != nil {
 			return fmt.Errorf("failed to initialize hash algorithm: %w", err)
 		}
 
-		fmt.Printf("Starting witnz node: %s\n", cfg.Node.ID)
-		fmt.Printf("Hash algorithm: %s\n", cfg.Hash.Algorithm)
-		fmt.Printf("Connecting to PostgreSQL: %s:%d/%s\n",
-			cfg.Database.Host, cfg.Database.Port, cfg.Database.Database)
+		logger.Info("Starting witnz node",
+			"config", configPath,
+			"hash_algorithm", cfg.Hash.Algorithm,
+			"sources", len(cfg.AllSources()))
 
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
@@ -140,33 +186,113 @@ var startCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("failed to initialize storage: %w", err)
 		}
-		defer store.Close()
 
 		var raftNode *consensus.Node
 		var handler cdc.EventHandler
 
+		alertManager := alert.NewManager(cfg.Alerts.Enabled, cfg.Alerts.SlackWebhook)
+
+		// Components register here as they start; shutdown stops them in
+		// dependency order rather than in reverse start order
+		node := &runningNode{
+			configPath: configPath,
+			cancel:     cancel,
+			alerts:     alertManager,
+			cfg:        cfg,
+			store:      store,
+		}
+		defer node.shutdown()
+		findingRecorder := verify.NewFindingRecorder(store, cfg.Node.ID)
+
+		// Every CDC connect is checked for a restored or replaced database
+		identityGuard := verify.NewIdentityGuard(store, cfg.Node.ID, findingRecorder, alertManager)
+
 		baseHandler := verify.NewHashChainHandler(store)
+		baseHandler.SetAlertManager(alertManager)
+		baseHandler.SetFindingRecorder(findingRecorder)
 
-		for _, tableConfig := range cfg.ProtectedTables {
-			fmt.Printf("Protecting table: %s\n", tableConfig.Name)
+		if cfg.Forensics.EncryptionKey != "" {
+			rowCipher, err := forensics.NewCipher(cfg.Forensics.EncryptionKey)
+			if err != nil {
+				return fmt.Errorf("failed to initialize row image cipher: %w", err)
+			}
+			baseHandler.SetRowImageCipher(rowCipher)
+		}
 
-			verifyConfig := &verify.TableConfig{
-				Name: tableConfig.Name,
+		var allowanceRegistry *verify.AllowanceRegistry
+		var approvers consensus.AllowanceVerifier
+		if len(cfg.Allowances.Approvers) > 0 {
+			verifier, err := allowance.NewVerifier(cfg.Allowances.ApproverKeys())
+			if err != nil {
+				return fmt.Errorf("failed to load allowance approvers: %w", err)
 			}
+			allowanceRegistry = verify.NewAllowanceRegistry(store, verifier)
+			baseHandler.SetAllowanceRegistry(allowanceRegistry)
+			approvers = verifier
+		}
 
-			if err := baseHandler.AddTable(verifyConfig); err != nil {
-				return fmt.Errorf("failed to add table %s: %w", tableConfig.Name, err)
+		var signer *signing.Signer
+		var keyRing *signing.KeyRing
+		if len(cfg.Node.PublicKeys) > 0 {
+			signer, keyRing, err = loadNodeSigning(cfg)
+			if err != nil {
+				return err
 			}
+			logger.Info("Signing enabled", "pinned_keys", len(cfg.Node.PublicKeys), "quorum", keyRing.Quorum())
 		}
 
-		if len(cfg.Node.PeerAddrs) > 0 || cfg.Node.Bootstrap {
-			fmt.Println("Starting Raft consensus...")
+		merkleVerifier := newMerkleVerifier(cfg, store)
+
+		merkleVerifier.SetFindingRecorder(findingRecorder)
+		merkleVerifier.SetAlertManager(alertManager)
+		if signer != nil {
+			merkleVerifier.SetSigner(signer, keyRing)
+		}
+
+		// Tables are added before Raft starts so table set entries replayed
+		// from the log apply on top of them
+		raftMode := len(cfg.Node.PeerAddrs) > 0 || cfg.Node.Bootstrap
+		node.handler = baseHandler
+		node.verifier = merkleVerifier
+
+		tables, err := resolveTables(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		node.patterns = cfg.TablePatterns()
+		if err := node.applyTables(tables); err != nil {
+			return fmt.Errorf("invalid table configuration: %w", err)
+		}
+
+		// Each source replicates through its own slot and publication
+		node.managers = make(map[string]*cdc.Manager)
+		for _, source := range cfg.AllSources() {
+			logger.Info("Monitoring "+databaseName(source),
+				"source", sourceLabel(source),
+				"database", fmt.Sprintf("%s:%d/%s", source.Database.Host, source.Database.Port, source.Database.Database))
+			node.managers[source.Name] = newManager(cfg, source)
+		}
+
+		if raftMode {
+			logger.Info("Starting Raft consensus", "bind_addr", cfg.Node.BindAddr, "peers", len(cfg.Node.PeerAddrs))
 			raftConfig := &consensus.NodeConfig{
 				NodeID:    cfg.Node.ID,
 				BindAddr:  cfg.Node.BindAddr,
 				DataDir:   cfg.Node.DataDir,
 				Bootstrap: cfg.Node.Bootstrap,
 				PeerAddrs: cfg.Node.PeerAddrs,
+				Signer:    signer,
+				KeyRing:   keyRing,
+				Approvers: approvers,
+
+				HeartbeatTimeout:  cfg.Raft.HeartbeatTimeoutDuration(),
+				ElectionTimeout:   cfg.Raft.ElectionTimeoutDuration(),
+				SnapshotInterval:  cfg.Raft.SnapshotIntervalDuration(),
+				SnapshotThreshold: cfg.Raft.SnapshotThreshold,
+				TrailingLogs:      cfg.Raft.TrailingLogs,
+				RetainSnapshots:   cfg.Raft.RetainSnapshots,
+				MaxAppendEntries:  cfg.Raft.MaxAppendEntries,
+				CatchUpLag:        cfg.Raft.CatchUpLag,
 			}
 
 			raftNode, err = consensus.NewNode(raftConfig, store)
@@ -174,87 +300,155 @@ var startCmd = &cobra.Command{
 				return fmt.Errorf("failed to create raft node: %w", err)
 			}
 
+			// Followers cross-check every hash the leader proposes against
+			// the hash they computed from their own WAL stream
+			witness := verify.NewWitness(findingRecorder, alertManager)
+			witness.SetRaftNode(raftNode)
+			raftNode.SetHashEntryObserver(witness)
+			raftNode.SetTableSetObserver(node)
+
+			// Hash entries are grouped into batched proposals, and followers
+			// forward what they observe so the leader records every change
+			// even when its own CDC stream misses it
+			batcher := consensus.NewBatcher(raftNode, cfg.Raft.BatchMaxEntries, cfg.Raft.BatchWindowDuration())
+			forwarder := consensus.NewForwarder(raftNode, cfg.Raft.BatchMaxEntries, cfg.Raft.BatchWindowDuration())
+			node.batcher = batcher
+			node.forwarder = forwarder
+
+			raftHandler := verify.NewRaftHashChainHandler(baseHandler, raftNode)
+			raftHandler.SetWitness(witness)
+			raftHandler.SetBatcher(batcher)
+			raftHandler.SetForwarder(forwarder)
+			raftHandler.SetCatchUp(raftNode.CaughtUp)
+			raftNode.SetForwardHandler(raftHandler)
+			// Reported to the leader, which rotates leadership to nodes
+			// whose own CDC stream is healthy
+			raftNode.SetCDCHealth(node.cdcHealthy)
+			node.raftHandler = raftHandler
+
 			if err := raftNode.Start(ctx); err != nil {
 				return fmt.Errorf("failed to start raft node: %w", err)
 			}
-			defer raftNode.Stop()
+			node.raftNode = raftNode
+
+			// Nodes whose hash settings or tables differ from the rest of
+			// the cluster would record hashes no other node agrees with
+			policyCtx, cancelPolicy := context.WithTimeout(ctx, policyCheckTimeout)
+			err = raftNode.CheckClusterPolicy(policyCtx, clusterPolicy(cfg))
+			cancelPolicy()
+			if err != nil {
+				return fmt.Errorf("refusing to start: %w", err)
+			}
 
-			fmt.Printf("Raft node started, leader: %s\n", raftNode.Leader())
+			logger.Info("Raft node started", "leader", raftNode.Leader())
 
-			// Synchronize hash chain from leader if this node is a follower
-			// This ensures restarted nodes receive hash entries created while they were offline
-			if err := raftNode.SyncHashChainFromLeader(ctx); err != nil {
-				return fmt.Errorf("failed to sync hash chain from leader: %w", err)
+			// A restarted node replays the entries it missed from the leader;
+			// verification and WAL acks wait until its applied index catches up
+			if !raftNode.IsCaughtUp() {
+				logger.Info("Catching up with the Raft leader, see 'witnz status' for progress")
 			}
 
-			handler = verify.NewRaftHashChainHandler(baseHandler, raftNode)
+			findingRecorder.SetRaftNode(raftNode)
+			identityGuard.SetRaftNode(raftNode)
+			if allowanceRegistry != nil {
+				allowanceRegistry.SetRaftNode(raftNode)
+			}
+			handler = raftHandler
 		} else {
-			fmt.Println("Running in single-node mode (no Raft)")
+			logger.Info("Running in single-node mode (no Raft)")
 			handler = baseHandler
 		}
 
-		cdcConfig := &cdc.ReplicationConfig{
-			Host:            cfg.Database.Host,
-			Port:            cfg.Database.Port,
-			Database:        cfg.Database.Database,
-			User:            cfg.Database.User,
-			Password:        cfg.Database.Password,
-			SlotName:        fmt.Sprintf("witnz_%s", cfg.Node.ID),
-			PublicationName: "witnz_publication",
-		}
-
-		manager := cdc.NewManager(cdcConfig)
-		manager.AddHandler(handler)
+		for source, manager := range node.managers {
+			manager.AddHandler(handler)
+			manager.SetAlertManager(alertManager)
+			manager.SetIdentityObserver(identityGuard)
+			manager.SetDetectorObserver(findingRecorder)
 
-		fmt.Println("Initializing CDC manager...")
-		if err := manager.Initialize(ctx); err != nil {
-			return fmt.Errorf("failed to initialize CDC manager: %w", err)
+			logger.Info("Initializing CDC manager", "source", source)
+			if err := manager.Initialize(ctx); err != nil {
+				return fmt.Errorf("failed to initialize CDC manager for source %q: %w", source, err)
+			}
 		}
+		node.watchPublications(node.managers)
 
-		fmt.Println("Starting replication...")
-		if err := manager.Start(ctx); err != nil {
-			return fmt.Errorf("failed to start CDC manager: %w", err)
+		for source, manager := range node.managers {
+			logger.Info("Starting replication", "source", source)
+			if err := manager.Start(ctx); err != nil {
+				return fmt.Errorf("failed to start CDC manager for source %q: %w", source, err)
+			}
+			manager.StartWatchdog(ctx, cfg.Watchdog.IntervalDuration())
 		}
 
-		dbConnStr := fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s sslmode=disable",
-			cfg.Database.Host, cfg.Database.Port, cfg.Database.Database,
-			cfg.Database.User, cfg.Database.Password)
-		merkleVerifier := verify.NewMerkleVerifier(store, dbConnStr)
-
+		var fenced chan time.Duration
 		if raftNode != nil {
-			merkleVerifier.SetRaftNode(raftNode)
-		}
+			if interval := cfg.Raft.LeadershipTransferDuration(); interval > 0 {
+				rotator := consensus.NewLeadershipRotator(raftNode, interval, logging.Logger("consensus"))
+				go rotator.Start(ctx)
+				node.rotator = rotator
+				logger.Info("Leadership rotation enabled", "interval", interval)
+			}
 
-		for _, tableConfig := range cfg.ProtectedTables {
-			if err := merkleVerifier.AddTable(&verify.TableConfig{
-				Name:           tableConfig.Name,
-				VerifyInterval: tableConfig.VerifyInterval,
-			}); err != nil {
-				return fmt.Errorf("invalid table configuration: %w", err)
+			// A node cut off from the leader stops processing CDC instead
+			// of acknowledging WAL and verifying against a stale hash chain
+			if cfg.Raft.FollowerAutoShutdown {
+				fenced = make(chan time.Duration, 1)
+				fence := consensus.NewFollowerFence(raftNode, cfg.Raft.FollowerAutoShutdownTimeoutDuration(), func(lost time.Duration) {
+					_ = alertManager.SendSystemAlert(
+						"Node Fenced",
+						fmt.Sprintf("Node %s had no Raft leader for %v and is shutting down", cfg.Node.ID, lost.Round(time.Second)),
+						"danger",
+					)
+					fenced <- lost
+				}, logging.Logger("consensus"))
+				go fence.Start(ctx)
+				node.fence = fence
 			}
 		}
 
+		if raftNode != nil {
+			merkleVerifier.SetRaftNode(raftNode)
+			merkleVerifier.SetCatchUp(raftNode.CaughtUp)
+		}
+
 		if err := merkleVerifier.Start(ctx); err != nil {
 			return fmt.Errorf("failed to start Merkle verifier: %w", err)
 		}
-		defer merkleVerifier.Stop()
 
-		fmt.Println("Witnz node is running. Press Ctrl+C to stop.")
+		// Patterns may also be added by a reload, so discovery always runs
+		interval := cfg.TableDiscovery.IntervalDuration()
+		go node.watchTables(ctx, interval)
+		if len(cfg.TablePatterns()) > 0 {
+			logger.Info("Table discovery enabled", "interval", interval)
+		}
 
-		sigCh := make(chan os.Signal, 1)
-		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-		<-sigCh
+		if cfg.Admin.BindAddr != "" {
+			adminServer := admin.NewServer(cfg.Admin.BindAddr, cfg.Admin.Token, store, findingRecorder)
+			if raftNode != nil {
+				adminServer.SetRaftNode(raftNode)
+			}
+			if allowanceRegistry != nil {
+				adminServer.SetAllowanceRegistry(allowanceRegistry)
+			}
+			adminServer.SetReloadFunc(node.reload)
+			if err := adminServer.Start(); err != nil {
+				return fmt.Errorf("failed to start admin API: %w", err)
+			}
+			node.adminServer = adminServer
+			logger.Info("Admin API listening", "addr", adminServer.Addr())
+		}
+
+		logger.Info("Witnz node is running. Press Ctrl+C to stop, or send SIGHUP to reload the config.")
 
-		fmt.Println("\nShutting down...")
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer shutdownCancel()
+		fenceErr := node.wait(fenced)
 
-		if err := manager.Stop(shutdownCtx); err != nil {
-			return fmt.Errorf("failed to stop CDC manager: %w", err)
+		logger.Info("Shutting down")
+		if err := node.shutdown(); err != nil {
+			return errors.Join(fenceErr, fmt.Errorf("shutdown incomplete: %w", err))
 		}
 
-		fmt.Println("Witnz node stopped")
-		return nil
+		logger.Info("Witnz node stopped")
+		return fenceErr
 	},
 }
 
@@ -262,8 +456,8 @@ var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Display node status",
 	Long: `Display node status including protected tables and hash chain state.
-Note: Raft cluster state cannot be shown via this command as it would conflict with running nodes.
-Use 'docker-compose logs' or check application logs to see Raft leader election status.`,
+Raft state and catch-up progress are read from the admin API of the running
+node when it is configured.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		configPath, err := findConfigFile()
 		if err != nil {
@@ -279,13 +473,6 @@ Use 'docker-compose logs' or check application logs to see Raft leader election
 			return fmt.Errorf("failed to initialize hash algorithm: %w", err)
 		}
 
-		dbPath := filepath.Join(cfg.Node.DataDir, "witnz.db")
-		store, err := storage.New(dbPath)
-		if err != nil {
-			return fmt.Errorf("failed to open storage: %w", err)
-		}
-		defer store.Close()
-
 		fmt.Printf("Node ID: %s\n", cfg.Node.ID)
 		fmt.Printf("Data Directory: %s\n", cfg.Node.DataDir)
 		fmt.Printf("Bind Address: %s\n", cfg.Node.BindAddr)
@@ -293,47 +480,118 @@ Use 'docker-compose logs' or check application logs to see Raft leader election
 		if len(cfg.Node.PeerAddrs) > 0 || cfg.Node.Bootstrap {
 			fmt.Printf("Cluster Mode: Raft (bootstrap: %v)\n", cfg.Node.Bootstrap)
 			fmt.Printf("Peers: %d configured\n", len(cfg.Node.PeerAddrs))
-			fmt.Printf("\nNote: To check Raft leader status, use 'docker-compose logs' and look for:\n")
-			fmt.Printf("  - 'entering leader state' (this node is leader)\n")
-			fmt.Printf("  - 'entering follower state' (this node is follower)\n")
+			printClusterStatus(cfg)
 		} else {
 			fmt.Printf("Cluster Mode: single-node (no Raft)\n")
 		}
 
-		fmt.Printf("\nProtected Tables:\n")
-
-		for _, tableConfig := range cfg.ProtectedTables {
-			fmt.Printf("  - %s\n", tableConfig.Name)
+		dbPath := filepath.Join(cfg.Node.DataDir, "witnz.db")
+		store, err := storage.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("failed to open storage: %w", err)
+		}
+		defer store.Close()
 
-			latest, err := store.GetLatestHashEntry(tableConfig.Name)
-			if err == nil {
-				fmt.Printf("    Latest sequence: %d\n", latest.SequenceNum)
-				fmt.Printf("    Latest data hash: %s...\n", latest.DataHash[:16])
-				fmt.Printf("    Timestamp: %s\n", latest.Timestamp.Format(time.RFC3339))
+		policy, err := store.GetClusterPolicy()
+		if err != nil {
+			return fmt.Errorf("failed to read cluster policy: %w", err)
+		}
+		if policy != nil {
+			fmt.Printf("\nCluster Policy: %.12s\n", policy.Digest())
+			if diff := policy.Diff(clusterPolicy(cfg)); len(diff) > 0 {
+				fmt.Printf("  ⚠️  Local config differs:\n")
+				for _, line := range diff {
+					fmt.Printf("    %s\n", line)
+				}
 			} else {
-				fmt.Printf("    No entries yet\n")
+				fmt.Printf("  Local config matches\n")
 			}
+		}
 
-			checkpoint, err := store.GetLatestMerkleCheckpoint(tableConfig.Name)
-			if err == nil {
-				fmt.Printf("    Latest checkpoint: seq=%d, records=%d\n",
-					checkpoint.SequenceNum, checkpoint.RecordCount)
-			}
+		tables, err := store.GetProtectedTables()
+		if err != nil {
+			return fmt.Errorf("failed to read protected tables: %w", err)
+		}
+		if tables == nil {
+			tables = protectedTables(cfg, nil)
 		}
 
-		totalEntries := 0
-		for _, tableConfig := range cfg.ProtectedTables {
-			entries, err := store.GetAllHashEntries(tableConfig.Name)
-			if err == nil {
-				totalEntries += len(entries)
+		printTableStatus(os.Stdout, store, tables)
+		return nil
+	},
+}
+
+// printTableStatus shows the hash chain and checkpoint state of each
+// protected table
+func printTableStatus(w io.Writer, store *storage.Storage, tables []storage.ProtectedTable) {
+	fmt.Fprintf(w, "\nProtected Tables:\n")
+
+	for _, table := range tables {
+		fmt.Fprintf(w, "  - %s\n", table.Name)
+
+		latest, err := store.GetLatestHashEntry(table.Name)
+		if err == nil {
+			fmt.Fprintf(w, "    Latest sequence: %d\n", latest.SequenceNum)
+			// Approved deletes carry no data hash
+			if latest.DataHash != "" {
+				fmt.Fprintf(w, "    Latest data hash: %.16s...\n", latest.DataHash)
+			}
+			if latest.AllowanceID != "" {
+				fmt.Fprintf(w, "    Latest amendment: %s of %s (allowance %s)\n",
+					latest.OperationType, latest.RecordID, latest.AllowanceID)
 			}
+			fmt.Fprintf(w, "    Timestamp: %s\n", latest.Timestamp.Format(time.RFC3339))
+		} else {
+			fmt.Fprintf(w, "    No entries yet\n")
 		}
 
-		fmt.Printf("\nStorage Statistics:\n")
-		fmt.Printf("  Total hash entries: %d\n", totalEntries)
+		checkpoint, err := store.GetLatestMerkleCheckpoint(table.Name)
+		if err == nil {
+			fmt.Fprintf(w, "    Latest checkpoint: seq=%d, records=%d, signatures=%d\n",
+				checkpoint.SequenceNum, checkpoint.RecordCount, len(checkpoint.Signatures))
+		}
+	}
 
-		return nil
-	},
+	totalEntries := 0
+	for _, table := range tables {
+		entries, err := store.GetAllHashEntries(table.Name)
+		if err == nil {
+			totalEntries += len(entries)
+		}
+	}
+
+	fmt.Fprintf(w, "\nStorage Statistics:\n")
+	fmt.Fprintf(w, "  Total hash entries: %d\n", totalEntries)
+}
+
+// printClusterStatus shows the Raft state reported by the running node
+func printClusterStatus(cfg *config.Config) {
+	client, err := adminClientForConfig(cfg)
+	if err != nil {
+		fmt.Printf("Raft state: unavailable (%v)\n", err)
+		return
+	}
+
+	status, err := client.Status()
+	if err != nil {
+		fmt.Printf("Raft state: unavailable (%v)\n", err)
+		return
+	}
+	if status.Cluster == nil {
+		fmt.Printf("Raft state: node is running in %s mode\n", status.Mode)
+		return
+	}
+
+	cluster := status.Cluster
+	fmt.Printf("Raft state: %s (leader: %s)\n", cluster.State, cluster.Leader)
+	if cluster.CaughtUp {
+		fmt.Printf("Catch-up: caught up (applied index %d)\n", cluster.AppliedIndex)
+	} else if cluster.TargetIndex == 0 {
+		fmt.Printf("Catch-up: catching up, waiting for the leader's commit index (applied index %d)\n", cluster.AppliedIndex)
+	} else {
+		fmt.Printf("Catch-up: catching up, applied %d of %d (%.0f%%)\n",
+			cluster.AppliedIndex, cluster.TargetIndex, cluster.Progress()*100)
+	}
 }
 
 var verifyCmd = &cobra.Command{
@@ -364,19 +622,14 @@ var verifyCmd = &cobra.Command{
 
 		ctx := context.Background()
 
-		dbConnStr := fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s sslmode=disable",
-			cfg.Database.Host, cfg.Database.Port, cfg.Database.Database,
-			cfg.Database.User, cfg.Database.Password)
-
-		merkleVerifier := verify.NewMerkleVerifier(store, dbConnStr)
+		merkleVerifier := newMerkleVerifier(cfg, store)
 
 		tablesToVerify := []string{}
 		if len(args) > 0 {
 			tablesToVerify = append(tablesToVerify, args[0])
 		} else {
-			for _, tc := range cfg.ProtectedTables {
-				tablesToVerify = append(tablesToVerify, tc.Name)
-			}
+			tables := protectedTables(cfg, nil)
+			tablesToVerify = tableNames(tables)
 		}
 
 		for _, table := range tablesToVerify {
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/witnz/witnz/internal/storage"
+)
+
+func TestPrintTableStatus(t *testing.T) {
+	store, err := storage.New(filepath.Join(t.TempDir(), "witnz.db"))
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now()
+	if _, err := store.AppendHashEntry(&storage.HashEntry{
+		TableName:     "audit_log",
+		DataHash:      "0123456789abcdef0123456789abcdef",
+		Timestamp:     now,
+		OperationType: "INSERT",
+		RecordID:      "map[id:1]",
+	}); err != nil {
+		t.Fatalf("Failed to append insert: %v", err)
+	}
+
+	t.Run("Insert", func(t *testing.T) {
+		var out bytes.Buffer
+		printTableStatus(&out, store, []storage.ProtectedTable{{Name: "audit_log"}})
+		if !strings.Contains(out.String(), "Latest data hash: 0123456789abcdef...") {
+			t.Errorf("Expected the truncated data hash, got:\n%s", out.String())
+		}
+	})
+
+	t.Run("DeleteAmendment", func(t *testing.T) {
+		if err := store.SaveAllowance(&storage.Allowance{
+			ID:        "allow-1",
+			TableName: "audit_log",
+			RecordID:  "map[id:1]",
+			Operation: "DELETE",
+			ExpiresAt: now.Add(time.Hour),
+		}); err != nil {
+			t.Fatalf("Failed to save allowance: %v", err)
+		}
+		// Approved deletes are recorded without a data hash
+		if _, err := store.AppendHashEntry(&storage.HashEntry{
+			TableName:     "audit_log",
+			Timestamp:     now,
+			OperationType: "DELETE",
+			RecordID:      "map[id:1]",
+			AllowanceID:   "allow-1",
+		}); err != nil {
+			t.Fatalf("Failed to append amendment: %v", err)
+		}
+
+		var out bytes.Buffer
+		printTableStatus(&out, store, []storage.ProtectedTable{{Name: "audit_log"}})
+		if !strings.Contains(out.String(), "Latest amendment: DELETE of map[id:1] (allowance allow-1)") {
+			t.Errorf("Expected the amendment to be shown, got:\n%s", out.String())
+		}
+		if strings.Contains(out.String(), "Latest data hash") {
+			t.Errorf("Expected no data hash for a delete, got:\n%s", out.String())
+		}
+		if !strings.Contains(out.String(), "Total hash entries: 2") {
+			t.Errorf("Expected 2 hash entries, got:\n%s", out.String())
+		}
+	})
+}
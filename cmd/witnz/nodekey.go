@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/witnz/witnz/internal/config"
+	"github.com/witnz/witnz/internal/signing"
+)
+
+var nodeKeyCmd = &cobra.Command{
+	Use:   "node-key",
+	Short: "Print this node's public signing key",
+	Long: `Print the Ed25519 public key of this node, generating the key on first use.
+Pin the key under node.public_keys on every node to enable signed log entries
+and quorum-signed checkpoints.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath, err := findConfigFile()
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		key, err := signing.LoadOrCreateKey(cfg.Node.KeyPath())
+		if err != nil {
+			return err
+		}
+
+		signer := signing.NewSigner(cfg.Node.ID, key)
+		fmt.Printf("%s: %s\n", cfg.Node.ID, signer.PublicKey())
+		return nil
+	},
+}
+
+// loadNodeSigning loads the node key and checks it against the pinned keys
+func loadNodeSigning(cfg *config.Config) (*signing.Signer, *signing.KeyRing, error) {
+	key, err := signing.LoadOrCreateKey(cfg.Node.KeyPath())
+	if err != nil {
+		return nil, nil, err
+	}
+	signer := signing.NewSigner(cfg.Node.ID, key)
+
+	publicKeys := cfg.Node.PublicKeyMap()
+	if !strings.EqualFold(publicKeys[cfg.Node.ID], signer.PublicKey()) {
+		return nil, nil, fmt.Errorf("node key %s does not match the public key pinned for %s (run 'witnz node-key')",
+			cfg.Node.KeyPath(), cfg.Node.ID)
+	}
+
+	keyRing, err := signing.NewKeyRing(publicKeys)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load pinned node keys: %w", err)
+	}
+
+	return signer, keyRing, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	reloadCmd.Flags().StringVar(&adminAddr, "admin-addr", "", "admin API address (default: admin.bind_addr from config)")
+}
+
+var reloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Reload the config of a running node",
+	Long: `Ask a running node to re-read its config file, the same as sending it SIGHUP.
+Changes to protected_tables are applied without a restart; in a Raft cluster
+the new table set is replicated so every node switches at the same log index.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAdminClient()
+		if err != nil {
+			return err
+		}
+
+		result, err := client.Reload()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Configuration reloaded, protecting: %s\n", strings.Join(result.Tables, ", "))
+		for _, section := range result.RestartRequired {
+			fmt.Printf("⚠️  Changes to %s take effect after a restart\n", section)
+		}
+		return nil
+	},
+}
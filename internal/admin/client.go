@@ -0,0 +1,166 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/witnz/witnz/internal/storage"
+)
+
+// Client talks to the admin API of a running witnz node
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+func NewClient(addr, token string) *Client {
+	baseURL := addr
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = "http://" + baseURL
+	}
+
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Status returns the node's mode and, for Raft nodes, catch-up progress
+func (c *Client) Status() (*NodeStatus, error) {
+	var status NodeStatus
+	if err := c.do(http.MethodGet, "/v1/status", nil, &status); err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// Reload asks the node to re-read its config file and apply protected table
+// changes
+func (c *Client) Reload() (*ReloadResult, error) {
+	var result ReloadResult
+	if err := c.do(http.MethodPost, "/v1/reload", nil, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) ListFindings(status storage.FindingStatus) ([]*storage.Finding, error) {
+	path := "/v1/findings"
+	if status != "" {
+		path += "?status=" + url.QueryEscape(string(status))
+	}
+
+	var findings []*storage.Finding
+	if err := c.do(http.MethodGet, path, nil, &findings); err != nil {
+		return nil, err
+	}
+
+	return findings, nil
+}
+
+func (c *Client) GetFinding(id string) (*storage.Finding, error) {
+	var finding storage.Finding
+	if err := c.do(http.MethodGet, "/v1/findings/"+url.PathEscape(id), nil, &finding); err != nil {
+		return nil, err
+	}
+
+	return &finding, nil
+}
+
+// UpdateFindingStatus acknowledges or resolves a finding
+func (c *Client) UpdateFindingStatus(id string, status storage.FindingStatus, actor, note string) (*storage.Finding, error) {
+	var action string
+	switch status {
+	case storage.FindingAcknowledged:
+		action = "ack"
+	case storage.FindingResolved:
+		action = "resolve"
+	default:
+		return nil, fmt.Errorf("unsupported finding status: %s", status)
+	}
+
+	req := statusRequest{Actor: actor, Note: note}
+
+	var finding storage.Finding
+	if err := c.do(http.MethodPost, "/v1/findings/"+url.PathEscape(id)+"/"+action, req, &finding); err != nil {
+		return nil, err
+	}
+
+	return &finding, nil
+}
+
+func (c *Client) ListAllowances() ([]*storage.Allowance, error) {
+	var allowances []*storage.Allowance
+	if err := c.do(http.MethodGet, "/v1/allowances", nil, &allowances); err != nil {
+		return nil, err
+	}
+
+	return allowances, nil
+}
+
+// SubmitAllowance sends a signed allowance to the node for replication
+func (c *Client) SubmitAllowance(allowance *storage.Allowance) (*storage.Allowance, error) {
+	var submitted storage.Allowance
+	if err := c.do(http.MethodPost, "/v1/allowances", allowance, &submitted); err != nil {
+		return nil, err
+	}
+
+	return &submitted, nil
+}
+
+func (c *Client) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach admin API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var errResp errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err == nil && errResp.Error != "" {
+			return fmt.Errorf("admin API error (%d): %s", resp.StatusCode, errResp.Error)
+		}
+		return fmt.Errorf("admin API returned status %d", resp.StatusCode)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
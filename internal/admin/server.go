@@ -0,0 +1,269 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/witnz/witnz/internal/consensus"
+	"github.com/witnz/witnz/internal/logging"
+	"github.com/witnz/witnz/internal/storage"
+	"github.com/witnz/witnz/internal/verify"
+)
+
+var logger = logging.Logger("admin")
+
+// ClusterStatusSource reports the Raft state of the node
+type ClusterStatusSource interface {
+	ClusterStatus() consensus.ClusterStatus
+}
+
+// Server exposes node administration endpoints over HTTP
+type Server struct {
+	addr       string
+	token      string
+	storage    *storage.Storage
+	findings   *verify.FindingRecorder
+	allowances *verify.AllowanceRegistry
+	cluster    ClusterStatusSource
+	reload     func() (*ReloadResult, error)
+	httpServer *http.Server
+	listener   net.Listener
+}
+
+type statusRequest struct {
+	Actor string `json:"actor"`
+	Note  string `json:"note"`
+}
+
+// NodeStatus is the response of the status endpoint. Cluster is nil for a
+// single-node deployment.
+type NodeStatus struct {
+	Mode    string                   `json:"mode"`
+	Cluster *consensus.ClusterStatus `json:"cluster,omitempty"`
+}
+
+// ReloadResult is the response of the reload endpoint. RestartRequired lists
+// the changed config sections that only take effect after a restart.
+type ReloadResult struct {
+	Tables          []string `json:"tables"`
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewServer(addr, token string, store *storage.Storage, findings *verify.FindingRecorder) *Server {
+	s := &Server{
+		addr:     addr,
+		token:    token,
+		storage:  store,
+		findings: findings,
+	}
+
+	s.httpServer = &http.Server{
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return s
+}
+
+// SetAllowanceRegistry enables the allowance endpoints
+func (s *Server) SetAllowanceRegistry(r *verify.AllowanceRegistry) {
+	s.allowances = r
+}
+
+// SetRaftNode reports the node's Raft state and catch-up progress on the
+// status endpoint
+func (s *Server) SetRaftNode(node ClusterStatusSource) {
+	s.cluster = node
+}
+
+// SetReloadFunc enables the reload endpoint, which re-reads the config file
+// like SIGHUP does
+func (s *Server) SetReloadFunc(fn func() (*ReloadResult, error)) {
+	s.reload = fn
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", s.handleStatus)
+	mux.HandleFunc("POST /v1/reload", s.handleReload)
+	mux.HandleFunc("GET /v1/findings", s.handleListFindings)
+	mux.HandleFunc("GET /v1/findings/{id}", s.handleGetFinding)
+	mux.HandleFunc("POST /v1/findings/{id}/ack", s.handleFindingStatus(storage.FindingAcknowledged))
+	mux.HandleFunc("POST /v1/findings/{id}/resolve", s.handleFindingStatus(storage.FindingResolved))
+	mux.HandleFunc("GET /v1/allowances", s.handleListAllowances)
+	mux.HandleFunc("POST /v1/allowances", s.handleSubmitAllowance)
+	return s.authenticate(mux)
+}
+
+// Start begins serving in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	s.listener = listener
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Admin API server stopped", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return s.addr
+	}
+	return s.listener.Addr().String()
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			expected := "Bearer " + s.token
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+				writeError(w, http.StatusUnauthorized, fmt.Errorf("unauthorized"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if s.cluster == nil {
+		writeJSON(w, http.StatusOK, NodeStatus{Mode: "single-node"})
+		return
+	}
+
+	cluster := s.cluster.ClusterStatus()
+	writeJSON(w, http.StatusOK, NodeStatus{Mode: "raft", Cluster: &cluster})
+}
+
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if s.reload == nil {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("config reload not available"))
+		return
+	}
+
+	result, err := s.reload()
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleListFindings(w http.ResponseWriter, r *http.Request) {
+	status := storage.FindingStatus(r.URL.Query().Get("status"))
+
+	findings, err := s.storage.ListFindings(status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, findings)
+}
+
+func (s *Server) handleGetFinding(w http.ResponseWriter, r *http.Request) {
+	finding, err := s.storage.GetFinding(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, finding)
+}
+
+func (s *Server) handleFindingStatus(status storage.FindingStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		var req statusRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		if req.Actor == "" {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("actor is required"))
+			return
+		}
+
+		if _, err := s.storage.GetFinding(id); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+
+		if err := s.findings.UpdateStatus(id, status, req.Actor, req.Note); err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+
+		finding, err := s.storage.GetFinding(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, finding)
+	}
+}
+
+func (s *Server) handleListAllowances(w http.ResponseWriter, r *http.Request) {
+	allowances, err := s.storage.ListAllowances()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, allowances)
+}
+
+func (s *Server) handleSubmitAllowance(w http.ResponseWriter, r *http.Request) {
+	if s.allowances == nil {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("no allowance approvers configured"))
+		return
+	}
+
+	var allowance storage.Allowance
+	if err := json.NewDecoder(r.Body).Decode(&allowance); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	if err := s.allowances.Submit(&allowance); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, &allowance)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,225 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/witnz/witnz/internal/consensus"
+	"github.com/witnz/witnz/internal/storage"
+	"github.com/witnz/witnz/internal/verify"
+)
+
+func newTestServer(t *testing.T, token string) (*storage.Storage, *httptest.Server) {
+	tmpfile, err := os.CreateTemp("", "witnz-admin-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	t.Cleanup(func() { os.Remove(tmpfile.Name()) })
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	server := NewServer("127.0.0.1:0", token, store, verify.NewFindingRecorder(store, "node1"))
+	ts := httptest.NewServer(server.routes())
+	t.Cleanup(ts.Close)
+
+	return store, ts
+}
+
+func TestFindingsAPI(t *testing.T) {
+	store, ts := newTestServer(t, "secret")
+
+	finding := &storage.Finding{
+		ID:         "20250101000000-abcd",
+		TableName:  "audit_log",
+		RecordID:   "1",
+		Kind:       storage.FindingModified,
+		DetectedBy: storage.DetectedByMerkle,
+		DetectedAt: time.Now(),
+		Status:     storage.FindingOpen,
+	}
+	if err := store.SaveFinding(finding); err != nil {
+		t.Fatalf("SaveFinding failed: %v", err)
+	}
+
+	client := NewClient(ts.URL, "secret")
+
+	t.Run("List", func(t *testing.T) {
+		findings, err := client.ListFindings(storage.FindingOpen)
+		if err != nil {
+			t.Fatalf("ListFindings failed: %v", err)
+		}
+		if len(findings) != 1 || findings[0].ID != finding.ID {
+			t.Fatalf("Unexpected findings: %+v", findings)
+		}
+	})
+
+	t.Run("Show", func(t *testing.T) {
+		got, err := client.GetFinding(finding.ID)
+		if err != nil {
+			t.Fatalf("GetFinding failed: %v", err)
+		}
+		if got.Kind != storage.FindingModified {
+			t.Errorf("Expected kind modified, got %s", got.Kind)
+		}
+
+		if _, err := client.GetFinding("missing"); err == nil {
+			t.Error("GetFinding should fail for unknown ID")
+		}
+	})
+
+	t.Run("AckAndResolve", func(t *testing.T) {
+		acked, err := client.UpdateFindingStatus(finding.ID, storage.FindingAcknowledged, "alice", "investigating")
+		if err != nil {
+			t.Fatalf("ack failed: %v", err)
+		}
+		if acked.Status != storage.FindingAcknowledged || acked.StatusBy != "alice" {
+			t.Errorf("Unexpected state after ack: %s by %s", acked.Status, acked.StatusBy)
+		}
+
+		resolved, err := client.UpdateFindingStatus(finding.ID, storage.FindingResolved, "alice", "")
+		if err != nil {
+			t.Fatalf("resolve failed: %v", err)
+		}
+		if resolved.Status != storage.FindingResolved {
+			t.Errorf("Expected resolved, got %s", resolved.Status)
+		}
+
+		if _, err := client.UpdateFindingStatus(finding.ID, storage.FindingAcknowledged, "bob", ""); err == nil {
+			t.Error("Resolved finding should not be acknowledged again")
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		if _, err := NewClient(ts.URL, "wrong").ListFindings(""); err == nil {
+			t.Error("Request with wrong token should fail")
+		}
+
+		resp, err := http.Get(ts.URL + "/v1/findings")
+		if err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %d", resp.StatusCode)
+		}
+	})
+}
+
+type fakeCluster struct {
+	status consensus.ClusterStatus
+}
+
+func (c *fakeCluster) ClusterStatus() consensus.ClusterStatus {
+	return c.status
+}
+
+func TestStatusAPI(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-admin-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	server := NewServer("127.0.0.1:0", "", store, verify.NewFindingRecorder(store, "node1"))
+	ts := httptest.NewServer(server.routes())
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "")
+
+	t.Run("SingleNode", func(t *testing.T) {
+		status, err := client.Status()
+		if err != nil {
+			t.Fatalf("Status failed: %v", err)
+		}
+		if status.Mode != "single-node" || status.Cluster != nil {
+			t.Errorf("Expected single-node status, got %+v", status)
+		}
+	})
+
+	t.Run("CatchingUp", func(t *testing.T) {
+		server.SetRaftNode(&fakeCluster{status: consensus.ClusterStatus{
+			NodeID:       "node2",
+			State:        "Follower",
+			AppliedIndex: 25,
+			TargetIndex:  100,
+		}})
+
+		status, err := client.Status()
+		if err != nil {
+			t.Fatalf("Status failed: %v", err)
+		}
+		if status.Mode != "raft" || status.Cluster == nil {
+			t.Fatalf("Expected raft status, got %+v", status)
+		}
+		if status.Cluster.CaughtUp || status.Cluster.Progress() != 0.25 {
+			t.Errorf("Expected catch-up at 25%%, got %+v", status.Cluster)
+		}
+	})
+}
+
+func TestReloadAPI(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-admin-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	server := NewServer("127.0.0.1:0", "", store, verify.NewFindingRecorder(store, "node1"))
+	ts := httptest.NewServer(server.routes())
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "")
+
+	t.Run("Unavailable", func(t *testing.T) {
+		if _, err := client.Reload(); err == nil {
+			t.Error("Expected reload to fail without a reload function")
+		}
+	})
+
+	t.Run("Reloaded", func(t *testing.T) {
+		server.SetReloadFunc(func() (*ReloadResult, error) {
+			return &ReloadResult{Tables: []string{"audit_log", "payments"}, RestartRequired: []string{"raft"}}, nil
+		})
+
+		result, err := client.Reload()
+		if err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+		if len(result.Tables) != 2 || len(result.RestartRequired) != 1 {
+			t.Errorf("Expected reloaded tables and restart-required sections, got %+v", result)
+		}
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		server.SetReloadFunc(func() (*ReloadResult, error) {
+			return nil, fmt.Errorf("invalid config")
+		})
+
+		if _, err := client.Reload(); err == nil {
+			t.Error("Expected reload error to be returned")
+		}
+	})
+}
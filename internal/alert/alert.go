@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -13,6 +15,7 @@ type HTTPClient interface {
 }
 
 type Manager struct {
+	mu           sync.RWMutex
 	enabled      bool
 	slackWebhook string
 	httpClient   HTTPClient
@@ -53,8 +56,22 @@ func NewManagerWithClient(enabled bool, slackWebhook string, client HTTPClient)
 	}
 }
 
+// Configure replaces the alert settings, e.g. after a config reload
+func (m *Manager) Configure(enabled bool, slackWebhook string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enabled = enabled
+	m.slackWebhook = slackWebhook
+}
+
+func (m *Manager) active() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.enabled && m.slackWebhook != ""
+}
+
 func (m *Manager) SendTamperAlert(tableName, operation, recordID, details string) error {
-	if !m.enabled || m.slackWebhook == "" {
+	if !m.active() {
 		return nil
 	}
 
@@ -80,7 +97,7 @@ func (m *Manager) SendTamperAlert(tableName, operation, recordID, details string
 }
 
 func (m *Manager) SendHashChainBrokenAlert(tableName string, sequenceNum uint64, expectedHash, actualHash string) error {
-	if !m.enabled || m.slackWebhook == "" {
+	if !m.active() {
 		return nil
 	}
 
@@ -105,8 +122,66 @@ func (m *Manager) SendHashChainBrokenAlert(tableName string, sequenceNum uint64,
 	return m.sendSlackMessage(msg)
 }
 
+// SendLeaderDivergenceAlert reports a hash proposed by the leader that differs
+// from the hash this node computed from its own WAL stream
+func (m *Manager) SendLeaderDivergenceAlert(tableName, recordID string, lsn uint64, leader, leaderHash, localHash string) error {
+	if !m.active() {
+		return nil
+	}
+
+	msg := slackMessage{
+		Text: "🚨 *LEADER DIVERGENCE DETECTED*",
+		Attachments: []slackAttachment{
+			{
+				Color: "danger",
+				Title: "Leader-proposed hash differs from local observation",
+				Fields: []slackField{
+					{Title: "Table", Value: tableName, Short: true},
+					{Title: "Record ID", Value: recordID, Short: true},
+					{Title: "LSN", Value: fmt.Sprintf("%d", lsn), Short: true},
+					{Title: "Leader", Value: leader, Short: true},
+					{Title: "Leader Hash", Value: leaderHash, Short: false},
+					{Title: "Local Hash", Value: localHash, Short: false},
+				},
+				Footer: "Witnz Tamper Detection",
+				Ts:     time.Now().Unix(),
+			},
+		},
+	}
+
+	return m.sendSlackMessage(msg)
+}
+
+// SendDatabaseRollbackAlert reports a source database that was replaced,
+// restored or rolled back, with one line per change that gave it away
+func (m *Manager) SendDatabaseRollbackAlert(source, systemID string, timeline int32, changes []string) error {
+	if !m.active() {
+		return nil
+	}
+
+	msg := slackMessage{
+		Text: "🚨 *DATABASE ROLLBACK OR REPLACEMENT DETECTED*",
+		Attachments: []slackAttachment{
+			{
+				Color: "danger",
+				Title: "Source database no longer matches recorded history",
+				Fields: []slackField{
+					{Title: "Source", Value: source, Short: true},
+					{Title: "System ID", Value: systemID, Short: true},
+					{Title: "Timeline", Value: fmt.Sprintf("%d", timeline), Short: true},
+					{Title: "Changes", Value: strings.Join(changes, "\n"), Short: false},
+				},
+				Footer: "Witnz Tamper Detection",
+				Ts:     time.Now().Unix(),
+			},
+		},
+	}
+
+	return m.sendSlackMessage(msg)
+}
+
 func (m *Manager) SendSystemAlert(title, message, severity string) error {
-	if !m.enabled || m.slackWebhook == "" {
+	if !m.active() {
 		return nil
 	}
 
@@ -141,7 +216,11 @@ func (m *Manager) sendSlackMessage(msg slackMessage) error {
 		return fmt.Errorf("failed to marshal slack message: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, m.slackWebhook, bytes.NewBuffer(payload))
+	m.mu.RLock()
+	webhook := m.slackWebhook
+	m.mu.RUnlock()
+
+	req, err := http.NewRequest(http.MethodPost, webhook, bytes.NewBuffer(payload))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
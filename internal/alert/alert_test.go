@@ -101,6 +101,32 @@ func TestSendHashChainBrokenAlert_Disabled(t *testing.T) {
 	}
 }
 
+func TestSendLeaderDivergenceAlert_Success(t *testing.T) {
+	mock := &mockHTTPClient{statusCode: http.StatusOK}
+	m := NewManagerWithClient(true, "https://hooks.slack.com/test", mock)
+
+	err := m.SendLeaderDivergenceAlert("audit_logs", "7", 1234, "node1:7000", "abc123", "xyz789")
+	if err != nil {
+		t.Errorf("expected nil error, got: %v", err)
+	}
+	if mock.lastReq == nil {
+		t.Fatal("expected request to be made")
+	}
+}
+
+func TestSendDatabaseRollbackAlert_Success(t *testing.T) {
+	mock := &mockHTTPClient{statusCode: http.StatusOK}
+	m := NewManagerWithClient(true, "https://hooks.slack.com/test", mock)
+
+	err := m.SendDatabaseRollbackAlert("default", "7300000000000000001", 2, []string{"timeline switched from 1 to 2"})
+	if err != nil {
+		t.Errorf("expected nil error, got: %v", err)
+	}
+	if mock.lastReq == nil {
+		t.Fatal("expected request to be made")
+	}
+}
+
 func TestSendSystemAlert_Success(t *testing.T) {
 	mock := &mockHTTPClient{statusCode: http.StatusOK}
 	m := NewManagerWithClient(true, "https://hooks.slack.com/test", mock)
@@ -113,3 +139,17 @@ func TestSendSystemAlert_Success(t *testing.T) {
 		t.Fatal("expected request to be made")
 	}
 }
+
+func TestConfigure(t *testing.T) {
+	mock := &mockHTTPClient{statusCode: http.StatusOK}
+	m := NewManagerWithClient(false, "", mock)
+
+	m.Configure(true, "https://hooks.slack.com/reloaded")
+
+	if err := m.SendSystemAlert("Reload", "config reloaded", "good"); err != nil {
+		t.Errorf("expected nil error, got: %v", err)
+	}
+	if mock.lastReq == nil || mock.lastReq.URL.String() != "https://hooks.slack.com/reloaded" {
+		t.Error("expected alert to be sent to the reloaded webhook")
+	}
+}
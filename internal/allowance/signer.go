@@ -0,0 +1,132 @@
+package allowance
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/witnz/witnz/internal/cdc"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+const payloadVersion = "witnz-allowance-v1"
+
+// GenerateKey returns a new hex-encoded Ed25519 key pair for an approver
+func GenerateKey() (publicKey, privateKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	return hex.EncodeToString(pub), hex.EncodeToString(priv), nil
+}
+
+// NewID returns a time-ordered identifier for a new allowance
+func NewID(now time.Time) string {
+	suffix := make([]byte, 6)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("al-%s-%s", now.UTC().Format("20060102150405"), hex.EncodeToString(suffix))
+}
+
+// SigningPayload returns the canonical bytes covered by an allowance signature
+func SigningPayload(a *storage.Allowance) []byte {
+	fields := []string{
+		payloadVersion,
+		a.ID,
+		a.TableName,
+		a.RecordID,
+		a.Operation,
+		a.ExpectedHash,
+		a.ExpiresAt.UTC().Format(time.RFC3339Nano),
+		a.Approver,
+		a.Reason,
+	}
+	return []byte(strings.Join(fields, "\n"))
+}
+
+// Sign signs the allowance with a hex-encoded Ed25519 private key
+func Sign(a *storage.Allowance, privateKey string) error {
+	key, err := hex.DecodeString(privateKey)
+	if err != nil {
+		return fmt.Errorf("invalid private key: %w", err)
+	}
+	if len(key) != ed25519.PrivateKeySize {
+		return fmt.Errorf("private key must be %d bytes, got %d", ed25519.PrivateKeySize, len(key))
+	}
+
+	signature := ed25519.Sign(ed25519.PrivateKey(key), SigningPayload(a))
+	a.Signature = base64.StdEncoding.EncodeToString(signature)
+	return nil
+}
+
+// Verifier checks allowances against the public keys of trusted approvers
+type Verifier struct {
+	keys map[string]ed25519.PublicKey
+}
+
+// NewVerifier creates a verifier from approver names mapped to hex-encoded
+// Ed25519 public keys
+func NewVerifier(approvers map[string]string) (*Verifier, error) {
+	keys := make(map[string]ed25519.PublicKey, len(approvers))
+
+	for name, publicKey := range approvers {
+		key, err := hex.DecodeString(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key for approver %s: %w", name, err)
+		}
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("public key for approver %s must be %d bytes, got %d", name, ed25519.PublicKeySize, len(key))
+		}
+		keys[name] = ed25519.PublicKey(key)
+	}
+
+	return &Verifier{keys: keys}, nil
+}
+
+// Verify checks that the allowance is complete, unexpired and signed by a
+// trusted approver
+func (v *Verifier) Verify(a *storage.Allowance, now time.Time) error {
+	if err := v.VerifySignature(a); err != nil {
+		return err
+	}
+	if !now.Before(a.ExpiresAt) {
+		return fmt.Errorf("allowance %s expired at %s", a.ID, a.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// VerifySignature checks that the allowance is complete and signed by a
+// trusted approver, without checking expiry. Its result does not depend on
+// the clock, so every replica applying the allowance reaches the same one.
+func (v *Verifier) VerifySignature(a *storage.Allowance) error {
+	if a.ID == "" || a.TableName == "" || a.RecordID == "" {
+		return fmt.Errorf("allowance must name an id, table and record")
+	}
+	op := cdc.OperationType(a.Operation)
+	if op != cdc.OperationUpdate && op != cdc.OperationDelete {
+		return fmt.Errorf("unsupported allowance operation: %s", a.Operation)
+	}
+	if a.ExpectedHash == "" {
+		return fmt.Errorf("allowance must include the expected old-row hash")
+	}
+
+	key, ok := v.keys[a.Approver]
+	if !ok {
+		return fmt.Errorf("unknown approver: %s", a.Approver)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(a.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	if !ed25519.Verify(key, SigningPayload(a), signature) {
+		return fmt.Errorf("invalid signature on allowance %s", a.ID)
+	}
+
+	return nil
+}
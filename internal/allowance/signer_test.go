@@ -0,0 +1,133 @@
+package allowance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/witnz/witnz/internal/storage"
+)
+
+func newSignedAllowance(t *testing.T) (*storage.Allowance, *Verifier) {
+	publicKey, privateKey, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+
+	verifier, err := NewVerifier(map[string]string{"compliance": publicKey})
+	if err != nil {
+		t.Fatalf("NewVerifier failed: %v", err)
+	}
+
+	a := &storage.Allowance{
+		ID:           NewID(time.Now()),
+		TableName:    "audit_log",
+		RecordID:     "42",
+		Operation:    "DELETE",
+		ExpectedHash: "abc123",
+		ExpiresAt:    time.Now().Add(time.Hour),
+		Approver:     "compliance",
+		Reason:       "GDPR erasure request",
+	}
+
+	if err := Sign(a, privateKey); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	return a, verifier
+}
+
+func TestSignAndVerify(t *testing.T) {
+	a, verifier := newSignedAllowance(t)
+
+	if err := verifier.Verify(a, time.Now()); err != nil {
+		t.Errorf("Expected valid allowance, got: %v", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(a *storage.Allowance)
+		now    time.Time
+	}{
+		{
+			name:   "modified record",
+			modify: func(a *storage.Allowance) { a.RecordID = "43" },
+		},
+		{
+			name:   "modified operation",
+			modify: func(a *storage.Allowance) { a.Operation = "UPDATE" },
+		},
+		{
+			name:   "unknown approver",
+			modify: func(a *storage.Allowance) { a.Approver = "mallory" },
+		},
+		{
+			name:   "unsupported operation",
+			modify: func(a *storage.Allowance) { a.Operation = "TRUNCATE" },
+		},
+		{
+			name:   "expired",
+			modify: func(a *storage.Allowance) {},
+			now:    time.Now().Add(2 * time.Hour),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, verifier := newSignedAllowance(t)
+			tt.modify(a)
+
+			now := tt.now
+			if now.IsZero() {
+				now = time.Now()
+			}
+
+			if err := verifier.Verify(a, now); err == nil {
+				t.Error("Expected verification to fail")
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	publicKey, privateKey, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	verifier, err := NewVerifier(map[string]string{"compliance": publicKey})
+	if err != nil {
+		t.Fatalf("NewVerifier failed: %v", err)
+	}
+
+	// Expiry is left to the node matching the allowance against a change
+	a := &storage.Allowance{
+		ID:           NewID(time.Now()),
+		TableName:    "audit_log",
+		RecordID:     "42",
+		Operation:    "DELETE",
+		ExpectedHash: "abc123",
+		ExpiresAt:    time.Now().Add(-time.Hour),
+		Approver:     "compliance",
+	}
+	if err := Sign(a, privateKey); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if err := verifier.VerifySignature(a); err != nil {
+		t.Errorf("Expected an expired allowance to pass the signature check, got %v", err)
+	}
+
+	a.Approver = "mallory"
+	if err := verifier.VerifySignature(a); err == nil {
+		t.Error("Expected an unknown approver to be rejected")
+	}
+}
+
+func TestNewVerifierInvalidKey(t *testing.T) {
+	if _, err := NewVerifier(map[string]string{"compliance": "not-hex"}); err == nil {
+		t.Error("Expected error for invalid public key")
+	}
+	if _, err := NewVerifier(map[string]string{"compliance": "abcd"}); err == nil {
+		t.Error("Expected error for short public key")
+	}
+}
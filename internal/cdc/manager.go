@@ -2,26 +2,48 @@ package cdc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jackc/pglogrepl"
 	"github.com/jackc/pgx/v5"
 	"github.com/witnz/witnz/internal/alert"
+	"github.com/witnz/witnz/internal/discovery"
+	"github.com/witnz/witnz/internal/logging"
+	"github.com/witnz/witnz/internal/storage"
 )
 
+var logger = logging.Logger("cdc")
+
 type Manager struct {
 	config       *ReplicationConfig
-	client       *ReplicationClient
+	newSource    SourceFactory
+	client       Source
 	handlers     []EventHandler
 	mu           sync.RWMutex
 	currentLSN   pglogrepl.LSN
 	running      bool
 	stopCh       chan struct{}
+	cancel       context.CancelFunc
 	wg           sync.WaitGroup
 	alertManager *alert.Manager
+	identities   IdentityObserver
+	detector     DetectorObserver
+	// watchMu serializes publication updates with watchdog checks. watched
+	// are the tables the publication was last synced to.
+	watchMu  sync.Mutex
+	watched  []string
+	watchdog *watchdog
+	// schemaTables are the protected tables named schema.table, whose
+	// changes are reported under that name
+	schemaTables map[string]bool
+	// healthy is set while the replication stream is receiving without errors
+	healthy atomic.Bool
 }
 
 func NewManager(config *ReplicationConfig) *Manager {
@@ -29,6 +51,7 @@ func NewManager(config *ReplicationConfig) *Manager {
 		config:   config,
 		handlers: make([]EventHandler, 0),
 		stopCh:   make(chan struct{}),
+		watchdog: newWatchdog(config.Source),
 	}
 }
 
@@ -44,17 +67,56 @@ func (m *Manager) SetAlertManager(am *alert.Manager) {
 	m.alertManager = am
 }
 
+// SetIdentityObserver sets the observer told which database CDC connected
+// to, at startup and on every reconnect
+func (m *Manager) SetIdentityObserver(observer IdentityObserver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.identities = observer
+}
+
+// SetDetectorObserver sets the observer told about tampering with the
+// publication, the slot or the protected tables
+func (m *Manager) SetDetectorObserver(observer DetectorObserver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.detector = observer
+}
+
+// SetSource streams changes from another database than PostgreSQL.
+// Publications are only managed for PostgreSQL sources.
+func (m *Manager) SetSource(newSource SourceFactory) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.newSource = newSource
+}
+
+// source creates the Source changes are streamed from, and reports whether
+// it is PostgreSQL
+func (m *Manager) source(handler EventHandler) (Source, bool) {
+	m.mu.RLock()
+	newSource := m.newSource
+	m.mu.RUnlock()
+	if newSource == nil {
+		return PostgresSource(m.config, handler), true
+	}
+	return newSource(m.config, handler), false
+}
+
 func (m *Manager) Initialize(ctx context.Context) error {
-	if err := m.createPublicationIfNotExists(ctx); err != nil {
-		return fmt.Errorf("failed to create publication: %w", err)
+	client, postgres := m.source(m)
+	if postgres {
+		if err := m.createPublicationIfNotExists(ctx); err != nil {
+			return fmt.Errorf("failed to create publication: %w", err)
+		}
 	}
 
-	client := NewReplicationClient(m.config, m)
 	if err := client.Connect(ctx); err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
+	m.identify(ctx, client, 0)
 
-	if err := client.CreateSlotIfNotExists(ctx); err != nil {
+	if err := client.Retain(ctx, true); err != nil {
 		client.Close(ctx)
 		return fmt.Errorf("failed to create slot: %w", err)
 	}
@@ -63,6 +125,25 @@ func (m *Manager) Initialize(ctx context.Context) error {
 	return nil
 }
 
+// Prepare creates the publication and the replication slot if they do not
+// exist yet, so PostgreSQL retains WAL from this point on. Unlike Initialize
+// it keeps an existing slot.
+func (m *Manager) Prepare(ctx context.Context) error {
+	client, postgres := m.source(nil)
+	if postgres {
+		if err := m.createPublicationIfNotExists(ctx); err != nil {
+			return fmt.Errorf("failed to create publication: %w", err)
+		}
+	}
+
+	if err := client.Connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer client.Close(ctx)
+
+	return client.Retain(ctx, false)
+}
+
 func (m *Manager) Start(ctx context.Context) error {
 	if m.running {
 		return fmt.Errorf("manager already running")
@@ -72,31 +153,128 @@ func (m *Manager) Start(ctx context.Context) error {
 		return fmt.Errorf("manager not initialized")
 	}
 
-	if err := m.client.StartReplication(ctx, m.currentLSN); err != nil {
+	if err := m.client.Stream(ctx, uint64(m.currentLSN)); err != nil {
 		return fmt.Errorf("failed to start replication: %w", err)
 	}
 
+	receiveCtx, cancel := context.WithCancel(ctx)
+	m.cancel = cancel
 	m.running = true
+	m.healthy.Store(true)
 	m.wg.Add(1)
 
-	go m.receiveLoop(ctx)
+	go m.receiveLoop(receiveCtx)
 
 	return nil
 }
 
 func (m *Manager) Stop(ctx context.Context) error {
+	if err := m.Drain(ctx); err != nil {
+		return err
+	}
+	return m.Close(ctx)
+}
+
+// Drain stops receiving changes but keeps the replication connection open,
+// so transactions already handed to the handlers can still be acknowledged
+// with Close
+func (m *Manager) Drain(ctx context.Context) error {
 	if !m.running {
 		return nil
 	}
 
 	close(m.stopCh)
-	m.wg.Wait()
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for replication to stop: %w", ctx.Err())
+	}
+
 	m.running = false
+	m.healthy.Store(false)
+	return nil
+}
 
-	if m.client != nil {
-		return m.client.Close(ctx)
+// Close reports the last acknowledged position to the source and closes
+// the replication connection
+func (m *Manager) Close(ctx context.Context) error {
+	if m.client == nil {
+		return nil
 	}
 
+	if err := m.client.Flush(ctx); err != nil {
+		m.client.Close(ctx)
+		return fmt.Errorf("failed to report final LSN: %w", err)
+	}
+
+	return m.client.Close(ctx)
+}
+
+// AcknowledgedLSN returns the position last reported to PostgreSQL as durable
+func (m *Manager) AcknowledgedLSN() pglogrepl.LSN {
+	m.mu.RLock()
+	client := m.client
+	m.mu.RUnlock()
+	if client == nil {
+		return 0
+	}
+	return pglogrepl.LSN(client.AcknowledgedPosition())
+}
+
+// identify passes the identity of the database a source connected to to
+// the identity observer. Failing to identify it does not stop replication.
+func (m *Manager) identify(ctx context.Context, client Source, acknowledged uint64) {
+	m.mu.RLock()
+	observer := m.identities
+	m.mu.RUnlock()
+
+	identifier, ok := client.(Identifier)
+	if observer == nil || !ok {
+		return
+	}
+
+	identity, err := identifier.Identify(ctx)
+	if err != nil {
+		logger.Warn("Failed to identify source database", "source", m.config.Source, "error", err)
+		return
+	}
+	identity.Source = m.config.Source
+	if err := observer.ObserveIdentity(identity, acknowledged); err != nil {
+		logger.Error("Failed to check source database identity", "source", m.config.Source, "error", err)
+	}
+}
+
+// reconnect replaces a lost replication connection and resumes streaming
+// after the last acknowledged position. Unacknowledged transactions are
+// streamed again.
+func (m *Manager) reconnect(ctx context.Context) error {
+	acknowledged := m.client.AcknowledgedPosition()
+	_ = m.client.Close(ctx)
+
+	client, _ := m.source(m)
+	if err := client.Connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	m.identify(ctx, client, acknowledged)
+
+	if err := client.Stream(ctx, acknowledged); err != nil {
+		client.Close(ctx)
+		return fmt.Errorf("failed to start replication: %w", err)
+	}
+
+	m.mu.Lock()
+	m.client = client
+	m.mu.Unlock()
+
+	logger.Info("Reconnected replication", "source", m.config.Source, "lsn", pglogrepl.LSN(acknowledged).String())
 	return nil
 }
 
@@ -113,9 +291,16 @@ func (m *Manager) receiveLoop(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		default:
-			if err := m.client.ReceiveMessage(ctx); err != nil {
+			if err := m.client.Receive(ctx); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+
 				if tamperingErr, ok := err.(TamperingDetector); ok {
-					fmt.Printf("🚨 SECURITY ALERT: %v\n", err)
+					logger.Error("Tampering detected",
+						"table", tamperingErr.GetTableName(),
+						"operation", tamperingErr.GetOperation(),
+						"error", err)
 
 					m.mu.RLock()
 					if m.alertManager != nil {
@@ -131,8 +316,9 @@ func (m *Manager) receiveLoop(ctx context.Context) {
 					continue
 				}
 
-				fmt.Printf("Error receiving message: %v\n", err)
+				logger.Error("Failed to receive replication message", "error", err, "attempt", errorCount+1)
 				errorCount++
+				m.healthy.Store(false)
 
 				backoff := time.Duration(math.Pow(2, float64(errorCount))) * time.Second
 				if backoff > maxBackoff {
@@ -156,17 +342,62 @@ func (m *Manager) receiveLoop(ctx context.Context) {
 				case <-ctx.Done():
 					return
 				}
+
+				if errors.Is(err, ErrConnectionLost) {
+					if err := m.reconnect(ctx); err != nil {
+						logger.Error("Failed to reconnect replication", "error", err, "attempt", errorCount)
+					}
+				}
 			} else {
 				errorCount = 0
+				m.healthy.Store(true)
+
+				if m.replayRequested() {
+					logger.Warn("Replaying transactions that were not recorded",
+						"source", m.config.Source,
+						"lsn", m.AcknowledgedLSN().String())
+					if err := m.reconnect(ctx); err != nil {
+						logger.Error("Failed to replay replication", "source", m.config.Source, "error", err)
+					}
+				}
 			}
 		}
 	}
 }
 
+// replayRequested asks every Replayer handler whether this source has to
+// be streamed again from the acknowledged position
+func (m *Manager) replayRequested() bool {
+	m.mu.RLock()
+	handlers := make([]EventHandler, len(m.handlers))
+	copy(handlers, m.handlers)
+	m.mu.RUnlock()
+
+	requested := false
+	for _, handler := range handlers {
+		if replayer, ok := handler.(Replayer); ok && replayer.ReplayRequested(m.config.Source) {
+			requested = true
+		}
+	}
+	return requested
+}
+
+// Healthy reports whether replication is running and the last receive
+// succeeded
+func (m *Manager) Healthy() bool {
+	return m.healthy.Load()
+}
+
 func (m *Manager) HandleChange(event *ChangeEvent) error {
 	m.mu.RLock()
 	handlers := make([]EventHandler, len(m.handlers))
 	copy(handlers, m.handlers)
+	if event.Schema != "" {
+		source, table := storage.SplitTable(event.TableName)
+		if name := event.Schema + storage.SchemaSeparator + table; m.schemaTables[name] {
+			event.TableName = storage.QualifiedTable(source, name)
+		}
+	}
 	m.mu.RUnlock()
 
 	for _, handler := range handlers {
@@ -181,7 +412,43 @@ func (m *Manager) HandleChange(event *ChangeEvent) error {
 	return nil
 }
 
-func (m *Manager) createPublicationIfNotExists(ctx context.Context) error {
+// HandleCommit forwards a transaction boundary to every handler that tracks
+// commits and calls ack once all of them have acknowledged it
+func (m *Manager) HandleCommit(commit *CommitEvent, ack func()) error {
+	commit.Source = m.config.Source
+
+	m.mu.RLock()
+	var handlers []CommitHandler
+	for _, handler := range m.handlers {
+		if ch, ok := handler.(CommitHandler); ok {
+			handlers = append(handlers, ch)
+		}
+	}
+	m.mu.RUnlock()
+
+	if len(handlers) == 0 {
+		ack()
+		return nil
+	}
+
+	var pending atomic.Int32
+	pending.Store(int32(len(handlers)))
+	handlerAck := func() {
+		if pending.Add(-1) == 0 {
+			ack()
+		}
+	}
+
+	for _, handler := range handlers {
+		if err := handler.HandleCommit(commit, handlerAck); err != nil {
+			return fmt.Errorf("commit handler failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (m *Manager) connect(ctx context.Context) (*pgx.Conn, error) {
 	connString := fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s",
 		m.config.Host,
 		m.config.Port,
@@ -192,33 +459,121 @@ func (m *Manager) createPublicationIfNotExists(ctx context.Context) error {
 
 	conn, err := pgx.Connect(ctx, connString)
 	if err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	return conn, nil
+}
+
+// createPublicationIfNotExists creates the publication with
+// publish_via_partition_root, so changes to a partition arrive under the
+// name of its partitioned parent. An existing publication is switched to it.
+func (m *Manager) createPublicationIfNotExists(ctx context.Context) error {
+	conn, err := m.connect(ctx)
+	if err != nil {
+		return err
 	}
 	defer conn.Close(ctx)
 
-	var exists bool
+	publication := pgx.Identifier{m.config.PublicationName}.Sanitize()
+
+	var viaRoot bool
 	err = conn.QueryRow(ctx,
-		"SELECT EXISTS (SELECT 1 FROM pg_publication WHERE pubname = $1)",
+		"SELECT pubviaroot FROM pg_publication WHERE pubname = $1",
 		m.config.PublicationName,
-	).Scan(&exists)
+	).Scan(&viaRoot)
 
+	if errors.Is(err, pgx.ErrNoRows) {
+		_, err = conn.Exec(ctx,
+			fmt.Sprintf("CREATE PUBLICATION %s FOR ALL TABLES WITH (publish_via_partition_root = true)", publication),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create publication: %w", err)
+		}
+		logger.Info("Created publication", "publication", m.config.PublicationName)
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to check publication: %w", err)
 	}
 
-	if !exists {
+	if !viaRoot {
 		_, err = conn.Exec(ctx,
-			fmt.Sprintf("CREATE PUBLICATION %s FOR ALL TABLES", m.config.PublicationName),
+			fmt.Sprintf("ALTER PUBLICATION %s SET (publish_via_partition_root = true)", publication),
 		)
 		if err != nil {
-			return fmt.Errorf("failed to create publication: %w", err)
+			return fmt.Errorf("failed to enable publish_via_partition_root: %w", err)
 		}
-		fmt.Printf("Created publication: %s\n", m.config.PublicationName)
+		logger.Info("Enabled publish_via_partition_root", "publication", m.config.PublicationName)
 	}
 
 	return nil
 }
 
+// SyncPublication makes a publication limited to specific tables publish
+// exactly the given tables. A publication created FOR ALL TABLES already
+// covers every table and is left unchanged, as are sources other than
+// PostgreSQL. Changes to tables named schema.table are reported under that
+// name from now on, even if the publication cannot be updated.
+func (m *Manager) SyncPublication(ctx context.Context, tables []string) error {
+	schemaTables := make(map[string]bool)
+	for _, table := range tables {
+		if schema, _ := storage.SplitSchema(table); schema != "" {
+			schemaTables[table] = true
+		}
+	}
+
+	m.mu.Lock()
+	postgres := m.newSource == nil
+	m.schemaTables = schemaTables
+	m.mu.Unlock()
+	if !postgres {
+		return nil
+	}
+
+	m.watchMu.Lock()
+	defer m.watchMu.Unlock()
+
+	conn, err := m.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	var allTables bool
+	err = conn.QueryRow(ctx,
+		"SELECT puballtables FROM pg_publication WHERE pubname = $1",
+		m.config.PublicationName,
+	).Scan(&allTables)
+	if err != nil {
+		return fmt.Errorf("failed to check publication: %w", err)
+	}
+	if allTables {
+		m.watched = tables
+		return nil
+	}
+	if len(tables) == 0 {
+		return fmt.Errorf("publication %s must include at least one table", m.config.PublicationName)
+	}
+
+	_, err = conn.Exec(ctx, publicationSetTableSQL(m.config.PublicationName, tables))
+	if err != nil {
+		return fmt.Errorf("failed to update publication: %w", err)
+	}
+	m.watched = tables
+
+	logger.Info("Updated publication", "publication", m.config.PublicationName, "tables", tables)
+	return nil
+}
+
+func publicationSetTableSQL(publication string, tables []string) string {
+	quoted := make([]string, len(tables))
+	for i, table := range tables {
+		quoted[i] = discovery.Identifier(table).Sanitize()
+	}
+	return fmt.Sprintf("ALTER PUBLICATION %s SET TABLE %s",
+		pgx.Identifier{publication}.Sanitize(), strings.Join(quoted, ", "))
+}
+
 func (m *Manager) SetLSN(lsn pglogrepl.LSN) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
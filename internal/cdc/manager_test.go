@@ -2,9 +2,13 @@ package cdc
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jackc/pglogrepl"
+	"github.com/witnz/witnz/internal/storage"
 )
 
 func TestNewManager(t *testing.T) {
@@ -72,6 +76,39 @@ func TestManagerHandleChange(t *testing.T) {
 	}
 }
 
+func TestManagerHandleChangeSchemaTable(t *testing.T) {
+	manager := NewManager(&ReplicationConfig{Source: "billing"})
+	manager.SetSource(func(*ReplicationConfig, EventHandler) Source { return &fakeSource{} })
+
+	handler := &mockHandler{events: make([]*ChangeEvent, 0)}
+	manager.AddHandler(handler)
+
+	if err := manager.SyncPublication(context.Background(), []string{"audit_log", "audit.events"}); err != nil {
+		t.Fatalf("SyncPublication failed: %v", err)
+	}
+
+	events := []*ChangeEvent{
+		{TableName: "billing/events", Schema: "audit", Operation: OperationInsert},
+		{TableName: "billing/events", Schema: "public", Operation: OperationInsert},
+		{TableName: "billing/audit_log", Schema: "public", Operation: OperationInsert},
+	}
+	for _, event := range events {
+		if err := manager.HandleChange(event); err != nil {
+			t.Fatalf("HandleChange failed: %v", err)
+		}
+	}
+
+	expected := []string{"billing/audit.events", "billing/events", "billing/audit_log"}
+	if len(handler.events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d", len(expected), len(handler.events))
+	}
+	for i, event := range handler.events {
+		if event.TableName != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], event.TableName)
+		}
+	}
+}
+
 func TestManagerLSN(t *testing.T) {
 	manager := NewManager(&ReplicationConfig{})
 
@@ -102,6 +139,9 @@ func TestManagerStopWhenNotRunning(t *testing.T) {
 	if err != nil {
 		t.Errorf("Stop should not fail when not running: %v", err)
 	}
+	if manager.Healthy() {
+		t.Error("Manager should not report a healthy stream when not running")
+	}
 }
 
 func TestManagerHandleChangeWithMultipleHandlers(t *testing.T) {
@@ -149,3 +189,369 @@ func TestManagerHandleChangeWithNoHandlers(t *testing.T) {
 		t.Errorf("HandleChange with no handlers should not error: %v", err)
 	}
 }
+
+type commitHandler struct {
+	mockHandler
+	acks []func()
+}
+
+func (h *commitHandler) HandleCommit(commit *CommitEvent, ack func()) error {
+	h.acks = append(h.acks, ack)
+	return nil
+}
+
+func TestManagerHandleCommit(t *testing.T) {
+	t.Run("NoCommitHandlers", func(t *testing.T) {
+		manager := NewManager(&ReplicationConfig{})
+		manager.AddHandler(&mockHandler{})
+
+		acked := false
+		if err := manager.HandleCommit(&CommitEvent{EndLSN: 100}, func() { acked = true }); err != nil {
+			t.Fatalf("HandleCommit failed: %v", err)
+		}
+		if !acked {
+			t.Error("Expected commit to be acknowledged immediately")
+		}
+	})
+
+	t.Run("WaitsForEveryHandler", func(t *testing.T) {
+		manager := NewManager(&ReplicationConfig{})
+		handler1 := &commitHandler{}
+		handler2 := &commitHandler{}
+		manager.AddHandler(handler1)
+		manager.AddHandler(&mockHandler{})
+		manager.AddHandler(handler2)
+
+		acked := 0
+		if err := manager.HandleCommit(&CommitEvent{EndLSN: 100}, func() { acked++ }); err != nil {
+			t.Fatalf("HandleCommit failed: %v", err)
+		}
+
+		handler1.acks[0]()
+		if acked != 0 {
+			t.Fatal("Expected commit to wait for the second handler")
+		}
+
+		handler2.acks[0]()
+		if acked != 1 {
+			t.Errorf("Expected commit to be acknowledged once, got %d", acked)
+		}
+	})
+}
+
+func TestReplicationClientAcknowledge(t *testing.T) {
+	client := NewReplicationClient(&ReplicationConfig{}, nil)
+
+	client.Acknowledge(200)
+	client.Acknowledge(100)
+
+	if got := client.AcknowledgedLSN(); got != 200 {
+		t.Errorf("Expected acknowledged LSN to stay at 200, got %d", got)
+	}
+}
+
+func TestReplicationClientSource(t *testing.T) {
+	for source, want := range map[string]string{"": "audit_log", "billing": "billing/audit_log"} {
+		handler := &mockHandler{}
+		client := NewReplicationClient(&ReplicationConfig{Source: source}, handler)
+		client.relations[1] = &pglogrepl.RelationMessage{RelationID: 1, RelationName: "audit_log"}
+
+		if err := client.handleTruncate(&pglogrepl.TruncateMessage{RelationIDs: []uint32{1}}, 100); err != nil {
+			t.Fatalf("handleTruncate failed: %v", err)
+		}
+		if len(handler.events) != 1 || handler.events[0].TableName != want {
+			t.Errorf("Expected event for %s, got %+v", want, handler.events)
+		}
+	}
+}
+
+func TestPublicationSetTableSQL(t *testing.T) {
+	got := publicationSetTableSQL("witnz_publication", []string{"audit_log", "payments", "audit.events"})
+	want := `ALTER PUBLICATION "witnz_publication" SET TABLE "audit_log", "payments", "audit"."events"`
+	if got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+// fakeSource streams a fixed list of changes, each in its own transaction
+type fakeSource struct {
+	handler   EventHandler
+	changes   []*ChangeEvent
+	delivered chan struct{}
+	discarded bool
+	start     uint64
+	acked     uint64
+	flushed   uint64
+	// lost makes Receive fail as if the connection dropped once every
+	// change was delivered
+	lost     bool
+	identity *storage.DatabaseIdentity
+}
+
+func (s *fakeSource) Connect(context.Context) error { return nil }
+
+func (s *fakeSource) Retain(_ context.Context, discard bool) error {
+	s.discarded = discard
+	return nil
+}
+
+func (s *fakeSource) Stream(_ context.Context, position uint64) error {
+	s.start = position
+	s.acked = position
+	return nil
+}
+
+func (s *fakeSource) Receive(ctx context.Context) error {
+	if len(s.changes) == 0 {
+		if s.lost {
+			s.lost = false
+			return fmt.Errorf("%w: EOF", ErrConnectionLost)
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	change := s.changes[0]
+	s.changes = s.changes[1:]
+
+	if err := s.handler.HandleChange(change); err != nil {
+		return err
+	}
+	commit := &CommitEvent{EndLSN: change.LSN}
+	ack := func() { s.acked = commit.EndLSN }
+	if handler, ok := s.handler.(CommitHandler); ok {
+		if err := handler.HandleCommit(commit, ack); err != nil {
+			return err
+		}
+	} else {
+		ack()
+	}
+	s.delivered <- struct{}{}
+	return nil
+}
+
+func (s *fakeSource) AcknowledgedPosition() uint64 { return s.acked }
+
+func (s *fakeSource) Flush(context.Context) error {
+	s.flushed = s.acked
+	return nil
+}
+
+func (s *fakeSource) Close(context.Context) error { return nil }
+
+func (s *fakeSource) Identify(context.Context) (*storage.DatabaseIdentity, error) {
+	identity := *s.identity
+	return &identity, nil
+}
+
+// identityRecorder is an IdentityObserver that keeps what it was told
+type identityRecorder struct {
+	mu           sync.Mutex
+	identities   []*storage.DatabaseIdentity
+	acknowledged []uint64
+}
+
+func (r *identityRecorder) ObserveIdentity(identity *storage.DatabaseIdentity, acknowledged uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.identities = append(r.identities, identity)
+	r.acknowledged = append(r.acknowledged, acknowledged)
+	return nil
+}
+
+func TestManagerSource(t *testing.T) {
+	source := &fakeSource{
+		identity: &storage.DatabaseIdentity{SystemID: "7300000000000000001", Timeline: 1},
+		changes: []*ChangeEvent{
+			{TableName: "audit_log", Operation: OperationInsert, LSN: 110},
+			{TableName: "audit_log", Operation: OperationDelete, LSN: 120},
+		},
+		delivered: make(chan struct{}, 2),
+	}
+
+	manager := NewManager(&ReplicationConfig{})
+	manager.SetSource(func(_ *ReplicationConfig, handler EventHandler) Source {
+		source.handler = handler
+		return source
+	})
+	handler := &mockHandler{events: make([]*ChangeEvent, 0)}
+	manager.AddHandler(handler)
+	manager.SetLSN(100)
+
+	ctx := context.Background()
+	if err := manager.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if !source.discarded {
+		t.Error("Expected Initialize to discard changes retained by an earlier session")
+	}
+	if err := manager.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-source.delivered:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for changes")
+		}
+	}
+
+	if err := manager.Stop(ctx); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if source.start != 100 {
+		t.Errorf("Expected streaming to start at 100, got %d", source.start)
+	}
+	if len(handler.events) != 2 || handler.events[1].Operation != OperationDelete {
+		t.Errorf("Expected both changes to reach the handler, got %d", len(handler.events))
+	}
+	if source.flushed != 120 || manager.AcknowledgedLSN() != 120 {
+		t.Errorf("Expected position 120 to be flushed, got %d", source.flushed)
+	}
+	if err := manager.SyncPublication(ctx, nil); err != nil {
+		t.Errorf("Expected publications to be left alone for other sources, got %v", err)
+	}
+}
+
+func TestManagerReconnect(t *testing.T) {
+	first := &fakeSource{
+		identity:  &storage.DatabaseIdentity{SystemID: "7300000000000000001", Timeline: 1, XLogPos: 100},
+		changes:   []*ChangeEvent{{TableName: "audit_log", Operation: OperationInsert, LSN: 110}},
+		delivered: make(chan struct{}, 1),
+		lost:      true,
+	}
+	second := &fakeSource{
+		identity:  &storage.DatabaseIdentity{SystemID: "7300000000000000002", Timeline: 1, XLogPos: 50},
+		changes:   []*ChangeEvent{{TableName: "audit_log", Operation: OperationInsert, LSN: 60}},
+		delivered: make(chan struct{}, 1),
+	}
+	sources := []*fakeSource{first, second}
+
+	manager := NewManager(&ReplicationConfig{Source: "billing"})
+	manager.SetSource(func(_ *ReplicationConfig, handler EventHandler) Source {
+		source := sources[0]
+		sources = sources[1:]
+		source.handler = handler
+		return source
+	})
+	observer := &identityRecorder{}
+	manager.SetIdentityObserver(observer)
+	manager.AddHandler(&mockHandler{events: make([]*ChangeEvent, 0)})
+
+	ctx := context.Background()
+	if err := manager.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if err := manager.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	select {
+	case <-first.delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the first change")
+	}
+	select {
+	case <-second.delivered:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the change after reconnecting")
+	}
+	if err := manager.Stop(ctx); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	if second.start != 110 {
+		t.Errorf("Expected streaming to resume at 110, got %d", second.start)
+	}
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	if len(observer.identities) != 2 {
+		t.Fatalf("Expected identity checks at startup and on reconnect, got %d", len(observer.identities))
+	}
+	reconnected := observer.identities[1]
+	if reconnected.Source != "billing" || reconnected.SystemID != "7300000000000000002" || observer.acknowledged[1] != 110 {
+		t.Errorf("Expected the new database identity with acknowledged LSN 110, got %+v at %d", reconnected, observer.acknowledged[1])
+	}
+}
+
+// replayHandler fails to record the first transaction and asks for a replay
+type replayHandler struct {
+	mu       sync.Mutex
+	failed   bool
+	replayed bool
+	sources  []string
+}
+
+func (h *replayHandler) HandleChange(*ChangeEvent) error { return nil }
+
+func (h *replayHandler) HandleCommit(commit *CommitEvent, ack func()) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sources = append(h.sources, commit.Source)
+	if !h.failed {
+		h.failed = true
+		return nil
+	}
+	ack()
+	return nil
+}
+
+func (h *replayHandler) ReplayRequested(string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.failed || h.replayed {
+		return false
+	}
+	h.replayed = true
+	return true
+}
+
+func TestManagerReplay(t *testing.T) {
+	first := &fakeSource{
+		changes:   []*ChangeEvent{{TableName: "audit_log", Operation: OperationInsert, LSN: 110}},
+		delivered: make(chan struct{}, 1),
+	}
+	second := &fakeSource{
+		changes:   []*ChangeEvent{{TableName: "audit_log", Operation: OperationInsert, LSN: 110}},
+		delivered: make(chan struct{}, 1),
+	}
+	sources := []*fakeSource{first, second}
+
+	manager := NewManager(&ReplicationConfig{Source: "billing"})
+	manager.SetSource(func(_ *ReplicationConfig, handler EventHandler) Source {
+		source := sources[0]
+		sources = sources[1:]
+		source.handler = handler
+		return source
+	})
+	handler := &replayHandler{}
+	manager.AddHandler(handler)
+
+	ctx := context.Background()
+	if err := manager.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if err := manager.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	select {
+	case <-second.delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the replayed transaction")
+	}
+	if err := manager.Stop(ctx); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	if second.start != 0 || second.acked != 110 {
+		t.Errorf("Expected the replay to start at 0 and ack 110, got start %d ack %d", second.start, second.acked)
+	}
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if len(handler.sources) != 2 || handler.sources[0] != "billing" {
+		t.Errorf("Expected two commits from billing, got %v", handler.sources)
+	}
+}
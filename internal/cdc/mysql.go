@@ -0,0 +1,469 @@
+package cdc
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/witnz/witnz/internal/mysql"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+const (
+	// mysqlHeartbeat is how often an idle server sends a heartbeat event
+	mysqlHeartbeat = 5 * time.Second
+	// mysqlReceiveTimeout is how long Receive waits before it considers
+	// the connection lost. It is several heartbeats long.
+	mysqlReceiveTimeout = 30 * time.Second
+)
+
+// MySQLSource returns the SourceFactory of a MySQL database. Positions are
+// binary log coordinates packed by mysql.Position. MySQL resumes a replica
+// by GTID rather than by position, so the clients a Manager creates share a
+// log of the GTIDs executed up to every commit position that is not
+// acknowledged yet.
+func MySQLSource() SourceFactory {
+	log := &gtidLog{}
+	return func(config *ReplicationConfig, handler EventHandler) Source {
+		return newMySQLClient(config, handler, log)
+	}
+}
+
+// gtidLog maps commit positions to the GTID set executed up to them
+type gtidLog struct {
+	mu sync.Mutex
+	// start is the set the stream starts from before anything is
+	// acknowledged
+	start mysql.GTIDSet
+	sets  map[uint64]mysql.GTIDSet
+}
+
+// reset starts the log over at the current end of the binary log
+func (l *gtidLog) reset(executed mysql.GTIDSet) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.start = executed
+	l.sets = make(map[uint64]mysql.GTIDSet)
+}
+
+func (l *gtidLog) record(position uint64, executed mysql.GTIDSet) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sets == nil {
+		l.sets = make(map[uint64]mysql.GTIDSet)
+	}
+	l.sets[position] = executed
+}
+
+// lookup returns the set to resume from after position, or from the start
+// when it is 0
+func (l *gtidLog) lookup(position uint64) (mysql.GTIDSet, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if position == 0 {
+		return l.start, l.start != nil
+	}
+	executed, ok := l.sets[position]
+	return executed, ok
+}
+
+// release forgets the positions before an acknowledged one, which will
+// never be resumed from again
+func (l *gtidLog) release(position uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for p := range l.sets {
+		if p < position {
+			delete(l.sets, p)
+		}
+	}
+}
+
+// MySQLClient streams row changes from the binary log of a MySQL server.
+// The server must log full row images with their metadata and assign GTIDs;
+// mysql.BinlogSettings lists the settings.
+type MySQLClient struct {
+	config  *ReplicationConfig
+	handler EventHandler
+	log     *gtidLog
+	conn    *mysql.Conn
+	parser  *mysql.BinlogParser
+	// file is the sequence number of the binary log file being read
+	file uint32
+	// executed is the GTID set up to the last commit read, gtid the
+	// transaction being read
+	executed      mysql.GTIDSet
+	gtid          *mysql.GTIDBody
+	inTransaction bool
+	closed        bool
+	ackedPos      atomic.Uint64
+}
+
+func newMySQLClient(config *ReplicationConfig, handler EventHandler, log *gtidLog) *MySQLClient {
+	return &MySQLClient{
+		config:  config,
+		handler: handler,
+		log:     log,
+	}
+}
+
+func (c *MySQLClient) Connect(ctx context.Context) error {
+	conn, err := mysql.Connect(ctx, &mysql.Config{
+		Host:     c.config.Host,
+		Port:     c.config.Port,
+		Database: c.config.Database,
+		User:     c.config.User,
+		Password: c.config.Password,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.conn = conn
+	return nil
+}
+
+// serverID is the replica server_id the client registers with. It is
+// derived from the slot name, which is unique per node, because a server
+// disconnects a replica when another one registers with the same id.
+func (c *MySQLClient) serverID() uint32 {
+	if id := crc32.ChecksumIEEE([]byte(c.config.SlotName)); id != 0 {
+		return id
+	}
+	return 1
+}
+
+// checkSettings fails unless the binary log is configured as witnz needs
+func (c *MySQLClient) checkSettings(ctx context.Context) error {
+	variables, err := c.conn.Variables(ctx, mysql.SettingNames()...)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, setting := range mysql.BinlogSettings {
+		if err := setting.Check(variables); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("binary log is not configured for witnz: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Retain checks the binary log settings. MySQL cannot be told to keep
+// binary logs for a replica, so changes are only retained for
+// binlog_expire_logs_seconds. With discard set streaming starts at the
+// current end of the binary log, so changes made while witnz was down
+// are not replayed as legitimate.
+func (c *MySQLClient) Retain(ctx context.Context, discard bool) error {
+	if c.conn == nil {
+		return fmt.Errorf("not connected")
+	}
+	if err := c.checkSettings(ctx); err != nil {
+		return err
+	}
+
+	if !discard {
+		variables, err := c.conn.Variables(ctx, "binlog_expire_logs_seconds")
+		if err != nil {
+			return err
+		}
+		logger.Info("MySQL keeps binary logs for a limited time only",
+			"source", c.config.Source,
+			"binlog_expire_logs_seconds", variables["binlog_expire_logs_seconds"])
+		return nil
+	}
+
+	status, err := c.conn.BinlogStatus(ctx)
+	if err != nil {
+		return err
+	}
+	c.log.reset(status.Executed)
+
+	logger.Info("Streaming binary log from its current end", "source", c.config.Source,
+		"file", status.File, "position", status.Position, "gtids", status.Executed.String())
+	return nil
+}
+
+// Stream starts a binlog dump that skips the GTIDs executed up to position
+func (c *MySQLClient) Stream(ctx context.Context, position uint64) error {
+	if c.conn == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	executed, ok := c.log.lookup(position)
+	if !ok {
+		return fmt.Errorf("no GTID set recorded for binary log position %d", position)
+	}
+
+	res, err := c.conn.Query(ctx, "SELECT @@global.binlog_checksum")
+	if err != nil {
+		return fmt.Errorf("failed to read binlog_checksum: %w", err)
+	}
+	checksum, _ := res.Value(0, "@@global.binlog_checksum")
+
+	if err := c.conn.StartBinlogDump(ctx, c.serverID(), executed, mysqlHeartbeat); err != nil {
+		return fmt.Errorf("failed to start binlog dump: %w", err)
+	}
+
+	c.parser = mysql.NewBinlogParser(checksum)
+	c.executed = executed.Clone()
+	if position != 0 {
+		c.Acknowledge(position)
+	}
+	return nil
+}
+
+// Acknowledge records that every change up to position is durable. The
+// position only moves forward.
+func (c *MySQLClient) Acknowledge(position uint64) {
+	for {
+		current := c.ackedPos.Load()
+		if position <= current {
+			return
+		}
+		if c.ackedPos.CompareAndSwap(current, position) {
+			c.log.release(position)
+			return
+		}
+	}
+}
+
+func (c *MySQLClient) AcknowledgedPosition() uint64 {
+	return c.ackedPos.Load()
+}
+
+// Receive reads and handles one event. Every error reading or decoding an
+// event ends the dump, so it wraps ErrConnectionLost and streaming resumes
+// from the acknowledged position on a new connection.
+func (c *MySQLClient) Receive(ctx context.Context) error {
+	if c.conn == nil || c.parser == nil {
+		return fmt.Errorf("not streaming")
+	}
+	if c.closed {
+		return fmt.Errorf("%w: binlog dump ended", ErrConnectionLost)
+	}
+
+	receiveCtx, cancel := context.WithTimeout(ctx, mysqlReceiveTimeout)
+	defer cancel()
+
+	data, err := c.conn.ReadEvent(receiveCtx)
+	if err == nil {
+		var event *mysql.Event
+		if event, err = c.parser.Parse(data); err == nil {
+			return c.handleEvent(event)
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	c.closed = true
+	if mysql.IsError(err, mysql.ErrSourceFatal) {
+		return fmt.Errorf("%w: binary logs needed to resume are gone, changes may have been missed: %w", ErrConnectionLost, err)
+	}
+	return fmt.Errorf("%w: %w", ErrConnectionLost, err)
+}
+
+func (c *MySQLClient) handleEvent(event *mysql.Event) error {
+	switch body := event.Body.(type) {
+	case *mysql.RotateBody:
+		file, err := mysql.FileSequence(body.File)
+		if err != nil {
+			return err
+		}
+		c.file = file
+
+	case *mysql.GTIDBody:
+		c.gtid = body
+		c.inTransaction = false
+
+	case *mysql.QueryBody:
+		return c.handleQuery(event, body)
+
+	case *mysql.XIDBody:
+		return c.handleCommit(event)
+
+	case *mysql.RowsBody:
+		return c.handleRows(event, body)
+	}
+
+	return nil
+}
+
+// handleQuery follows the statements logged as text. Inside a transaction
+// only COMMIT and ROLLBACK matter; outside one the statement is DDL, which
+// commits on its own.
+func (c *MySQLClient) handleQuery(event *mysql.Event, body *mysql.QueryBody) error {
+	statement := strings.ToUpper(strings.TrimSpace(body.Query))
+	if statement == "BEGIN" {
+		c.inTransaction = true
+		return nil
+	}
+	if c.inTransaction && statement != "COMMIT" && !strings.HasPrefix(statement, "ROLLBACK") {
+		return nil
+	}
+
+	var firstErr error
+	if schema, table, ok := truncatedTable(body); ok {
+		firstErr = c.emit(&ChangeEvent{
+			Operation: OperationTruncate,
+			LSN:       mysql.Position(c.file, event.Header.Start()),
+		}, schema, table)
+	}
+	if err := c.handleCommit(event); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// truncatedTable returns the table of a TRUNCATE statement
+func truncatedTable(body *mysql.QueryBody) (schema, table string, ok bool) {
+	fields := strings.Fields(body.Query)
+	if len(fields) < 2 || !strings.EqualFold(fields[0], "TRUNCATE") {
+		return "", "", false
+	}
+	name := fields[1]
+	if strings.EqualFold(name, "TABLE") && len(fields) > 2 {
+		name = fields[2]
+	}
+	name = strings.ReplaceAll(name, "`", "")
+	if schema, table, found := strings.Cut(name, "."); found {
+		return schema, table, true
+	}
+	return body.Schema, name, true
+}
+
+// handleCommit ends the transaction of the last GTID. The GTID set up to
+// it is recorded before the handler can acknowledge it.
+func (c *MySQLClient) handleCommit(event *mysql.Event) error {
+	commit := &CommitEvent{
+		CommitLSN: mysql.Position(c.file, event.Header.Start()),
+		EndLSN:    mysql.Position(c.file, event.Header.LogPos),
+		Timestamp: event.Header.Timestamp,
+	}
+	if c.gtid != nil {
+		// GTID numbers outgrow transaction IDs only after four billion
+		// transactions of one server
+		commit.TransactionID = uint32(c.gtid.Transaction)
+		c.executed.Add(c.gtid.UUID, c.gtid.Transaction)
+	}
+	c.gtid = nil
+	c.inTransaction = false
+	c.log.record(commit.EndLSN, c.executed.Clone())
+
+	ack := func() { c.Acknowledge(commit.EndLSN) }
+
+	if handler, ok := c.handler.(CommitHandler); ok {
+		return handler.HandleCommit(commit, ack)
+	}
+
+	ack()
+	return nil
+}
+
+// handleRows emits one change per row. The position of a change is where
+// its row starts in the binary log, so every row has its own. Every row is
+// reported before the first handler error is returned.
+func (c *MySQLClient) handleRows(event *mysql.Event, body *mysql.RowsBody) error {
+	operation := OperationInsert
+	switch body.Operation {
+	case mysql.UpdateRowsEventV2:
+		operation = OperationUpdate
+	case mysql.DeleteRowsEventV2:
+		operation = OperationDelete
+	}
+
+	var firstErr error
+	for _, row := range body.Rows {
+		change := &ChangeEvent{
+			Operation: operation,
+			NewData:   row.After,
+			OldData:   row.Before,
+			LSN:       mysql.Position(c.file, event.Header.Start()+row.Offset),
+		}
+		image := row.After
+		if image == nil {
+			image = row.Before
+		}
+		change.PrimaryKey = make(map[string]interface{}, len(body.Table.PrimaryKey))
+		for _, index := range body.Table.PrimaryKey {
+			name := body.Table.Columns[index].Name
+			change.PrimaryKey[name] = image[name]
+		}
+
+		if err := c.emit(change, body.Table.Schema, body.Table.Table); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// emit names the table of a change and passes it to the handler. Tables of
+// the configured database are named like PostgreSQL tables, with the
+// database as their schema. Tables of other databases are named
+// database.table.
+func (c *MySQLClient) emit(event *ChangeEvent, schema, table string) error {
+	if schema == c.config.Database {
+		event.TableName = storage.QualifiedTable(c.config.Source, table)
+		event.Schema = schema
+	} else {
+		event.TableName = storage.QualifiedTable(c.config.Source, schema+storage.SchemaSeparator+table)
+	}
+	event.Timestamp = time.Now()
+	if c.gtid != nil {
+		event.TransactionID = uint32(c.gtid.Transaction)
+	}
+
+	if c.handler != nil {
+		return c.handler.HandleChange(event)
+	}
+	return nil
+}
+
+// Identify returns the server_uuid as the system ID and the current end of
+// the binary log as the position. MySQL has no timelines.
+func (c *MySQLClient) Identify(ctx context.Context) (*storage.DatabaseIdentity, error) {
+	if c.conn == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	res, err := c.conn.Query(ctx, "SELECT @@server_uuid")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server_uuid: %w", err)
+	}
+	uuid, _ := res.Value(0, "@@server_uuid")
+
+	status, err := c.conn.BinlogStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	file, err := mysql.FileSequence(status.File)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storage.DatabaseIdentity{
+		Source:   c.config.Source,
+		SystemID: uuid,
+		XLogPos:  mysql.Position(file, status.Position),
+	}, nil
+}
+
+// Flush does nothing: MySQL does not track what a replica has applied
+func (c *MySQLClient) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (c *MySQLClient) Close(ctx context.Context) error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package cdc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/witnz/witnz/internal/mysql"
+)
+
+const mysqlTestUUID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+// transactionHandler records changes and commits and leaves the
+// acknowledgements to the test
+type transactionHandler struct {
+	mockHandler
+	commits []*CommitEvent
+	acks    []func()
+}
+
+func (h *transactionHandler) HandleCommit(commit *CommitEvent, ack func()) error {
+	h.commits = append(h.commits, commit)
+	h.acks = append(h.acks, ack)
+	return nil
+}
+
+func mysqlEvent(start, end uint32, body interface{}) *mysql.Event {
+	return &mysql.Event{
+		Header: mysql.EventHeader{EventSize: end - start, LogPos: end, Timestamp: time.Unix(1700000000, 0)},
+		Body:   body,
+	}
+}
+
+var ordersTable = &mysql.TableMap{
+	Schema:     "shop",
+	Table:      "orders",
+	Columns:    []mysql.ColumnInfo{{Name: "id"}, {Name: "amount"}},
+	PrimaryKey: []int{0},
+}
+
+func newTestMySQLClient(t *testing.T, handler EventHandler) *MySQLClient {
+	t.Helper()
+	start, err := mysql.ParseGTIDSet(mysqlTestUUID + ":1-9")
+	if err != nil {
+		t.Fatalf("ParseGTIDSet failed: %v", err)
+	}
+
+	log := &gtidLog{}
+	log.reset(start)
+	client := newMySQLClient(&ReplicationConfig{Source: "orders-db", Database: "shop", SlotName: "witnz_node1"}, handler, log)
+	client.executed = start.Clone()
+	return client
+}
+
+func TestMySQLClientTransaction(t *testing.T) {
+	handler := &transactionHandler{}
+	client := newTestMySQLClient(t, handler)
+
+	events := []*mysql.Event{
+		{Body: &mysql.RotateBody{File: "binlog.000003", Position: 4}},
+		mysqlEvent(400, 479, &mysql.GTIDBody{UUID: mysqlTestUUID, Transaction: 10}),
+		mysqlEvent(479, 550, &mysql.QueryBody{Schema: "shop", Query: "BEGIN"}),
+		mysqlEvent(550, 700, &mysql.RowsBody{
+			Table:     ordersTable,
+			Operation: mysql.WriteRowsEventV2,
+			Rows: []mysql.Row{
+				{Offset: 32, After: map[string]interface{}{"id": int64(5), "amount": "1.00"}},
+				{Offset: 60, After: map[string]interface{}{"id": int64(6), "amount": "2.00"}},
+			},
+		}),
+		mysqlEvent(700, 800, &mysql.RowsBody{
+			Table:     &mysql.TableMap{Schema: "audit", Table: "events", Columns: []mysql.ColumnInfo{{Name: "id"}}, PrimaryKey: []int{0}},
+			Operation: mysql.DeleteRowsEventV2,
+			Rows:      []mysql.Row{{Offset: 32, Before: map[string]interface{}{"id": int64(1)}}},
+		}),
+		mysqlEvent(800, 831, &mysql.XIDBody{XID: 77}),
+	}
+	for _, event := range events {
+		if err := client.handleEvent(event); err != nil {
+			t.Fatalf("handleEvent failed: %v", err)
+		}
+	}
+
+	t.Run("Changes", func(t *testing.T) {
+		if len(handler.events) != 3 {
+			t.Fatalf("Expected 3 changes, got %d", len(handler.events))
+		}
+
+		first := handler.events[0]
+		if first.TableName != "orders-db/orders" || first.Schema != "shop" || first.Operation != OperationInsert {
+			t.Errorf("Expected insert into orders-db/orders in shop, got %s into %s in %s", first.Operation, first.TableName, first.Schema)
+		}
+		if first.LSN != mysql.Position(3, 582) || handler.events[1].LSN != mysql.Position(3, 610) {
+			t.Errorf("Expected row positions 3/582 and 3/610, got %d and %d", first.LSN, handler.events[1].LSN)
+		}
+		if first.PrimaryKey["id"] != int64(5) || len(first.PrimaryKey) != 1 {
+			t.Errorf("Expected primary key id 5, got %v", first.PrimaryKey)
+		}
+		if first.TransactionID != 10 {
+			t.Errorf("Expected transaction 10, got %d", first.TransactionID)
+		}
+
+		other := handler.events[2]
+		if other.TableName != "orders-db/audit.events" || other.Schema != "" || other.Operation != OperationDelete {
+			t.Errorf("Expected delete from orders-db/audit.events, got %s from %s in %q", other.Operation, other.TableName, other.Schema)
+		}
+		if other.PrimaryKey["id"] != int64(1) {
+			t.Errorf("Expected primary key of the old row, got %v", other.PrimaryKey)
+		}
+	})
+
+	t.Run("Commit", func(t *testing.T) {
+		if len(handler.commits) != 1 {
+			t.Fatalf("Expected 1 commit, got %d", len(handler.commits))
+		}
+		commit := handler.commits[0]
+		if commit.CommitLSN != mysql.Position(3, 800) || commit.EndLSN != mysql.Position(3, 831) {
+			t.Errorf("Expected commit 3/800 to 3/831, got %d to %d", commit.CommitLSN, commit.EndLSN)
+		}
+
+		executed, ok := client.log.lookup(commit.EndLSN)
+		if !ok {
+			t.Fatal("Expected the GTID set of the commit to be recorded")
+		}
+		if executed.String() != mysqlTestUUID+":1-10" {
+			t.Errorf("Expected %s:1-10, got %s", mysqlTestUUID, executed)
+		}
+	})
+
+	t.Run("Acknowledge", func(t *testing.T) {
+		if client.AcknowledgedPosition() != 0 {
+			t.Errorf("Expected nothing acknowledged before the handler acks, got %d", client.AcknowledgedPosition())
+		}
+
+		handler.acks[0]()
+		if client.AcknowledgedPosition() != mysql.Position(3, 831) {
+			t.Errorf("Expected 3/831 acknowledged, got %d", client.AcknowledgedPosition())
+		}
+		if _, ok := client.log.lookup(mysql.Position(3, 831)); !ok {
+			t.Error("Expected the acknowledged position to stay resumable")
+		}
+		if start, ok := client.log.lookup(0); !ok || start.String() != mysqlTestUUID+":1-9" {
+			t.Errorf("Expected the start set to be kept, got %v", start)
+		}
+	})
+}
+
+func TestMySQLClientQueries(t *testing.T) {
+	t.Run("Truncate", func(t *testing.T) {
+		handler := &transactionHandler{}
+		client := newTestMySQLClient(t, handler)
+		client.file = 4
+
+		_ = client.handleEvent(mysqlEvent(100, 179, &mysql.GTIDBody{UUID: mysqlTestUUID, Transaction: 10}))
+		err := client.handleEvent(mysqlEvent(179, 260, &mysql.QueryBody{Schema: "shop", Query: "TRUNCATE TABLE `orders`"}))
+		if err != nil {
+			t.Fatalf("handleEvent failed: %v", err)
+		}
+
+		if len(handler.events) != 1 || handler.events[0].Operation != OperationTruncate {
+			t.Fatalf("Expected a truncate, got %v", handler.events)
+		}
+		if handler.events[0].TableName != "orders-db/orders" || handler.events[0].LSN != mysql.Position(4, 179) {
+			t.Errorf("Expected orders-db/orders at 4/179, got %s at %d", handler.events[0].TableName, handler.events[0].LSN)
+		}
+		if len(handler.commits) != 1 || handler.commits[0].EndLSN != mysql.Position(4, 260) {
+			t.Errorf("Expected the statement to commit at 4/260, got %v", handler.commits)
+		}
+	})
+
+	t.Run("OtherDatabase", func(t *testing.T) {
+		schema, table, ok := truncatedTable(&mysql.QueryBody{Schema: "shop", Query: "truncate audit.events"})
+		if !ok || schema != "audit" || table != "events" {
+			t.Errorf("Expected audit.events, got %s.%s", schema, table)
+		}
+		if _, _, ok := truncatedTable(&mysql.QueryBody{Query: "ALTER TABLE orders ADD COLUMN note TEXT"}); ok {
+			t.Error("Expected ALTER TABLE not to be a truncate")
+		}
+	})
+
+	t.Run("StatementsInsideTransaction", func(t *testing.T) {
+		handler := &transactionHandler{}
+		client := newTestMySQLClient(t, handler)
+
+		for _, event := range []*mysql.Event{
+			mysqlEvent(100, 179, &mysql.GTIDBody{UUID: mysqlTestUUID, Transaction: 10}),
+			mysqlEvent(179, 250, &mysql.QueryBody{Query: "BEGIN"}),
+			mysqlEvent(250, 300, &mysql.QueryBody{Query: "SAVEPOINT `s1`"}),
+		} {
+			if err := client.handleEvent(event); err != nil {
+				t.Fatalf("handleEvent failed: %v", err)
+			}
+		}
+		if len(handler.commits) != 0 {
+			t.Fatalf("Expected no commit inside the transaction, got %d", len(handler.commits))
+		}
+
+		if err := client.handleEvent(mysqlEvent(300, 350, &mysql.QueryBody{Query: "COMMIT"})); err != nil {
+			t.Fatalf("handleEvent failed: %v", err)
+		}
+		if len(handler.commits) != 1 {
+			t.Errorf("Expected COMMIT to end the transaction, got %d commits", len(handler.commits))
+		}
+	})
+}
+
+func TestGTIDLog(t *testing.T) {
+	log := &gtidLog{}
+	if _, ok := log.lookup(0); ok {
+		t.Error("Expected no start before reset")
+	}
+
+	start, _ := mysql.ParseGTIDSet(mysqlTestUUID + ":1-9")
+	log.reset(start)
+	for i := uint64(1); i <= 3; i++ {
+		executed := start.Clone()
+		executed.Add(mysqlTestUUID, 9+i)
+		log.record(i*100, executed)
+	}
+
+	log.release(200)
+	if _, ok := log.lookup(100); ok {
+		t.Error("Expected positions before the acknowledged one to be released")
+	}
+	for _, position := range []uint64{200, 300} {
+		if _, ok := log.lookup(position); !ok {
+			t.Errorf("Expected position %d to be kept", position)
+		}
+	}
+
+	log.reset(start)
+	if _, ok := log.lookup(300); ok {
+		t.Error("Expected reset to drop recorded positions")
+	}
+}
+
+func TestMySQLServerID(t *testing.T) {
+	first := newMySQLClient(&ReplicationConfig{SlotName: "witnz_node1"}, nil, &gtidLog{})
+	second := newMySQLClient(&ReplicationConfig{SlotName: "witnz_node2"}, nil, &gtidLog{})
+
+	if first.serverID() == 0 || first.serverID() == second.serverID() {
+		t.Errorf("Expected distinct non-zero server ids, got %d and %d", first.serverID(), second.serverID())
+	}
+	if first.serverID() != newMySQLClient(&ReplicationConfig{SlotName: "witnz_node1"}, nil, &gtidLog{}).serverID() {
+		t.Error("Expected the server id to be stable across restarts")
+	}
+}
+
+func TestMySQLSourceSharesGTIDLog(t *testing.T) {
+	factory := MySQLSource()
+	first := factory(&ReplicationConfig{}, nil).(*MySQLClient)
+	second := factory(&ReplicationConfig{}, nil).(*MySQLClient)
+
+	if first.log != second.log {
+		t.Error("Expected clients of one source to share the GTID log")
+	}
+	if other := MySQLSource()(&ReplicationConfig{}, nil).(*MySQLClient); other.log == first.log {
+		t.Error("Expected separate sources to keep separate GTID logs")
+	}
+}
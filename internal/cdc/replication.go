@@ -2,17 +2,23 @@ package cdc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/jackc/pglogrepl"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgproto3"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/witnz/witnz/internal/storage"
 )
 
 const (
 	OutputPlugin = "pgoutput"
+
+	// duplicateObject is the SQLSTATE returned for a slot that already exists
+	duplicateObject = "42710"
 )
 
 type ReplicationConfig struct {
@@ -23,6 +29,9 @@ type ReplicationConfig struct {
 	Password        string
 	SlotName        string
 	PublicationName string
+	// Source qualifies the table names of change events. It is empty for
+	// the default source, whose tables keep their plain names.
+	Source string
 }
 
 type ReplicationClient struct {
@@ -31,6 +40,10 @@ type ReplicationClient struct {
 	relations map[uint32]*pglogrepl.RelationMessage
 	typeMap   *pgtype.Map
 	handler   EventHandler
+	xid       uint32
+	// ackedLSN is the highest position whose changes are durable. It is the
+	// only position reported back to PostgreSQL.
+	ackedLSN atomic.Uint64
 }
 
 func NewReplicationClient(config *ReplicationConfig, handler EventHandler) *ReplicationClient {
@@ -70,9 +83,32 @@ func (rc *ReplicationClient) CreateSlotIfNotExists(ctx context.Context) error {
 	err := pglogrepl.DropReplicationSlot(ctx, rc.conn, rc.config.SlotName, pglogrepl.DropReplicationSlotOptions{})
 	if err != nil {
 		// Ignore error if slot doesn't exist (first time startup)
-		fmt.Printf("Dropped existing replication slot (may not exist): %v\n", err)
+		logger.Debug("No replication slot to drop", "slot", rc.config.SlotName, "error", err)
 	} else {
-		fmt.Printf("Dropped existing replication slot %s to discard pending WAL\n", rc.config.SlotName)
+		logger.Info("Dropped existing replication slot to discard pending WAL", "slot", rc.config.SlotName)
+	}
+
+	result, err := pglogrepl.CreateReplicationSlot(
+		ctx,
+		rc.conn,
+		rc.config.SlotName,
+		OutputPlugin,
+		pglogrepl.CreateReplicationSlotOptions{},
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create replication slot: %w", err)
+	}
+
+	logger.Info("Created replication slot", "slot", result.SlotName, "lsn", result.ConsistentPoint)
+	return nil
+}
+
+// EnsureSlot creates the replication slot unless it already exists. Unlike
+// CreateSlotIfNotExists it keeps an existing slot and its pending WAL.
+func (rc *ReplicationClient) EnsureSlot(ctx context.Context) error {
+	if rc.conn == nil {
+		return fmt.Errorf("not connected")
 	}
 
 	result, err := pglogrepl.CreateReplicationSlot(
@@ -83,11 +119,16 @@ func (rc *ReplicationClient) CreateSlotIfNotExists(ctx context.Context) error {
 		pglogrepl.CreateReplicationSlotOptions{},
 	)
 
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == duplicateObject {
+		logger.Info("Replication slot already exists", "slot", rc.config.SlotName)
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create replication slot: %w", err)
 	}
 
-	fmt.Printf("Created replication slot %s at LSN %s\n", result.SlotName, result.ConsistentPoint)
+	logger.Info("Created replication slot", "slot", result.SlotName, "lsn", result.ConsistentPoint)
 	return nil
 }
 
@@ -128,21 +169,39 @@ func (rc *ReplicationClient) StartReplication(ctx context.Context, startLSN pglo
 		return fmt.Errorf("failed to start replication: %w", err)
 	}
 
+	rc.Acknowledge(uint64(startLSN))
 	return nil
 }
 
+// Acknowledge records that every change up to lsn is durable. The position
+// only moves forward.
+func (rc *ReplicationClient) Acknowledge(lsn uint64) {
+	for {
+		current := rc.ackedLSN.Load()
+		if lsn <= current || rc.ackedLSN.CompareAndSwap(current, lsn) {
+			return
+		}
+	}
+}
+
+// AcknowledgedLSN returns the position last reported as durable
+func (rc *ReplicationClient) AcknowledgedLSN() pglogrepl.LSN {
+	return pglogrepl.LSN(rc.ackedLSN.Load())
+}
+
 func (rc *ReplicationClient) ReceiveMessage(ctx context.Context) error {
 	if rc.conn == nil {
 		return fmt.Errorf("not connected")
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	receiveCtx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 
-	msg, err := rc.conn.ReceiveMessage(ctx)
+	msg, err := rc.conn.ReceiveMessage(receiveCtx)
 	if err != nil {
 		if pgconn.Timeout(err) {
-			return nil
+			// Report progress while idle so the slot can release WAL
+			return rc.SendStandbyStatusUpdate(ctx, rc.AcknowledgedLSN())
 		}
 		return fmt.Errorf("receive message failed: %w", err)
 	}
@@ -176,8 +235,10 @@ func (rc *ReplicationClient) handleKeepalive(data []byte) error {
 		return fmt.Errorf("failed to parse keepalive: %w", err)
 	}
 
+	// Only acknowledge changes that are durable. Reporting ServerWALEnd
+	// would let PostgreSQL discard WAL that has not been replicated yet.
 	if pkm.ReplyRequested {
-		return rc.SendStandbyStatusUpdate(context.Background(), pkm.ServerWALEnd)
+		return rc.SendStandbyStatusUpdate(context.Background(), rc.AcknowledgedLSN())
 	}
 
 	return nil
@@ -189,10 +250,10 @@ func (rc *ReplicationClient) handleXLogData(data []byte) error {
 		return fmt.Errorf("failed to parse xlog data: %w", err)
 	}
 
-	return rc.processWALData(xld.WALData)
+	return rc.processWALData(xld.WALData, uint64(xld.WALStart))
 }
 
-func (rc *ReplicationClient) processWALData(walData []byte) error {
+func (rc *ReplicationClient) processWALData(walData []byte, lsn uint64) error {
 	logicalMsg, err := pglogrepl.Parse(walData)
 	if err != nil {
 		return fmt.Errorf("failed to parse logical replication message: %w", err)
@@ -202,19 +263,101 @@ func (rc *ReplicationClient) processWALData(walData []byte) error {
 	case *pglogrepl.RelationMessage:
 		rc.relations[msg.RelationID] = msg
 
+	case *pglogrepl.BeginMessage:
+		rc.xid = msg.Xid
+
+	case *pglogrepl.CommitMessage:
+		return rc.handleCommit(msg)
+
 	case *pglogrepl.InsertMessage:
-		return rc.handleInsert(msg)
+		return rc.handleInsert(msg, lsn)
 
 	case *pglogrepl.UpdateMessage:
-		return rc.handleUpdate(msg)
+		return rc.handleUpdate(msg, lsn)
 
 	case *pglogrepl.DeleteMessage:
-		return rc.handleDelete(msg)
+		return rc.handleDelete(msg, lsn)
+
+	case *pglogrepl.TruncateMessage:
+		return rc.handleTruncate(msg, lsn)
+	}
+
+	return nil
+}
+
+// handleCommit passes the transaction boundary to the handler. Handlers that
+// do not track commits have already processed every change of the
+// transaction, so its end is acknowledged right away.
+func (rc *ReplicationClient) handleCommit(msg *pglogrepl.CommitMessage) error {
+	commit := &CommitEvent{
+		TransactionID: rc.xid,
+		CommitLSN:     uint64(msg.CommitLSN),
+		EndLSN:        uint64(msg.TransactionEndLSN),
+		Timestamp:     msg.CommitTime,
 	}
+	rc.xid = 0
 
+	ack := func() { rc.Acknowledge(commit.EndLSN) }
+
+	if handler, ok := rc.handler.(CommitHandler); ok {
+		return handler.HandleCommit(commit, ack)
+	}
+
+	ack()
 	return nil
 }
 
+// Retain creates the replication slot. With discard set an existing slot
+// is dropped first, otherwise it is kept.
+func (rc *ReplicationClient) Retain(ctx context.Context, discard bool) error {
+	if discard {
+		return rc.CreateSlotIfNotExists(ctx)
+	}
+	return rc.EnsureSlot(ctx)
+}
+
+// Stream starts replication at an LSN
+func (rc *ReplicationClient) Stream(ctx context.Context, position uint64) error {
+	return rc.StartReplication(ctx, pglogrepl.LSN(position))
+}
+
+// Receive wraps ErrConnectionLost around errors that closed the connection
+func (rc *ReplicationClient) Receive(ctx context.Context) error {
+	err := rc.ReceiveMessage(ctx)
+	if err != nil && rc.conn != nil && rc.conn.IsClosed() {
+		return fmt.Errorf("%w: %w", ErrConnectionLost, err)
+	}
+	return err
+}
+
+// Identify runs IDENTIFY_SYSTEM
+func (rc *ReplicationClient) Identify(ctx context.Context) (*storage.DatabaseIdentity, error) {
+	if rc.conn == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	result, err := pglogrepl.IdentifySystem(ctx, rc.conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to identify system: %w", err)
+	}
+
+	return &storage.DatabaseIdentity{
+		Source:   rc.config.Source,
+		SystemID: result.SystemID,
+		Timeline: result.Timeline,
+		XLogPos:  uint64(result.XLogPos),
+	}, nil
+}
+
+func (rc *ReplicationClient) AcknowledgedPosition() uint64 {
+	return rc.ackedLSN.Load()
+}
+
+// Flush sends the acknowledged LSN in a standby status update
+func (rc *ReplicationClient) Flush(ctx context.Context) error {
+	return rc.SendStandbyStatusUpdate(ctx, rc.AcknowledgedLSN())
+}
+
 func (rc *ReplicationClient) SendStandbyStatusUpdate(ctx context.Context, lsn pglogrepl.LSN) error {
 	if rc.conn == nil {
 		return fmt.Errorf("not connected")
@@ -234,7 +377,12 @@ func (rc *ReplicationClient) Close(ctx context.Context) error {
 	return nil
 }
 
-func (rc *ReplicationClient) handleInsert(msg *pglogrepl.InsertMessage) error {
+// tableName returns the name change events of a relation are reported under
+func (rc *ReplicationClient) tableName(rel *pglogrepl.RelationMessage) string {
+	return storage.QualifiedTable(rc.config.Source, rel.RelationName)
+}
+
+func (rc *ReplicationClient) handleInsert(msg *pglogrepl.InsertMessage, lsn uint64) error {
 	rel, ok := rc.relations[msg.RelationID]
 	if !ok {
 		return fmt.Errorf("unknown relation ID: %d", msg.RelationID)
@@ -243,11 +391,14 @@ func (rc *ReplicationClient) handleInsert(msg *pglogrepl.InsertMessage) error {
 	values := rc.tupleToMap(rel, msg.Tuple)
 
 	event := &ChangeEvent{
-		TableName:  rel.RelationName,
-		Operation:  OperationInsert,
-		Timestamp:  time.Now(),
-		NewData:    values,
-		PrimaryKey: rc.extractPrimaryKey(rel, values),
+		TableName:     rc.tableName(rel),
+		Schema:        rel.Namespace,
+		Operation:     OperationInsert,
+		Timestamp:     time.Now(),
+		NewData:       values,
+		PrimaryKey:    rc.extractPrimaryKey(rel, values),
+		LSN:           lsn,
+		TransactionID: rc.xid,
 	}
 
 	if rc.handler != nil {
@@ -257,7 +408,7 @@ func (rc *ReplicationClient) handleInsert(msg *pglogrepl.InsertMessage) error {
 	return nil
 }
 
-func (rc *ReplicationClient) handleUpdate(msg *pglogrepl.UpdateMessage) error {
+func (rc *ReplicationClient) handleUpdate(msg *pglogrepl.UpdateMessage, lsn uint64) error {
 	rel, ok := rc.relations[msg.RelationID]
 	if !ok {
 		return fmt.Errorf("unknown relation ID: %d", msg.RelationID)
@@ -270,12 +421,15 @@ func (rc *ReplicationClient) handleUpdate(msg *pglogrepl.UpdateMessage) error {
 	}
 
 	event := &ChangeEvent{
-		TableName:  rel.RelationName,
-		Operation:  OperationUpdate,
-		Timestamp:  time.Now(),
-		NewData:    newValues,
-		OldData:    oldValues,
-		PrimaryKey: rc.extractPrimaryKey(rel, newValues),
+		TableName:     rc.tableName(rel),
+		Schema:        rel.Namespace,
+		Operation:     OperationUpdate,
+		Timestamp:     time.Now(),
+		NewData:       newValues,
+		OldData:       oldValues,
+		PrimaryKey:    rc.extractPrimaryKey(rel, newValues),
+		LSN:           lsn,
+		TransactionID: rc.xid,
 	}
 
 	if rc.handler != nil {
@@ -285,7 +439,7 @@ func (rc *ReplicationClient) handleUpdate(msg *pglogrepl.UpdateMessage) error {
 	return nil
 }
 
-func (rc *ReplicationClient) handleDelete(msg *pglogrepl.DeleteMessage) error {
+func (rc *ReplicationClient) handleDelete(msg *pglogrepl.DeleteMessage, lsn uint64) error {
 	rel, ok := rc.relations[msg.RelationID]
 	if !ok {
 		return fmt.Errorf("unknown relation ID: %d", msg.RelationID)
@@ -297,11 +451,14 @@ func (rc *ReplicationClient) handleDelete(msg *pglogrepl.DeleteMessage) error {
 	}
 
 	event := &ChangeEvent{
-		TableName:  rel.RelationName,
-		Operation:  OperationDelete,
-		Timestamp:  time.Now(),
-		OldData:    values,
-		PrimaryKey: rc.extractPrimaryKey(rel, values),
+		TableName:     rc.tableName(rel),
+		Schema:        rel.Namespace,
+		Operation:     OperationDelete,
+		Timestamp:     time.Now(),
+		OldData:       values,
+		PrimaryKey:    rc.extractPrimaryKey(rel, values),
+		LSN:           lsn,
+		TransactionID: rc.xid,
 	}
 
 	if rc.handler != nil {
@@ -311,6 +468,36 @@ func (rc *ReplicationClient) handleDelete(msg *pglogrepl.DeleteMessage) error {
 	return nil
 }
 
+// handleTruncate emits one event per truncated relation. Every relation is
+// reported before the first handler error is returned.
+func (rc *ReplicationClient) handleTruncate(msg *pglogrepl.TruncateMessage, lsn uint64) error {
+	var firstErr error
+
+	for _, relationID := range msg.RelationIDs {
+		rel, ok := rc.relations[relationID]
+		if !ok {
+			return fmt.Errorf("unknown relation ID: %d", relationID)
+		}
+
+		event := &ChangeEvent{
+			TableName:     rc.tableName(rel),
+			Schema:        rel.Namespace,
+			Operation:     OperationTruncate,
+			Timestamp:     time.Now(),
+			LSN:           lsn,
+			TransactionID: rc.xid,
+		}
+
+		if rc.handler != nil {
+			if err := rc.handler.HandleChange(event); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
 func (rc *ReplicationClient) tupleToMap(rel *pglogrepl.RelationMessage, tuple *pglogrepl.TupleData) map[string]interface{} {
 	values := make(map[string]interface{})
 
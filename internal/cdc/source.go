@@ -0,0 +1,59 @@
+package cdc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/witnz/witnz/internal/storage"
+)
+
+// ErrConnectionLost is wrapped by Receive errors after which the connection
+// is gone and the source has to be connected again
+var ErrConnectionLost = errors.New("replication connection lost")
+
+// Source streams the row changes of one database to an EventHandler.
+// Positions are opaque to the Manager; for PostgreSQL they are LSNs.
+// ReplicationClient is the PostgreSQL implementation, MySQLClient reads the
+// MySQL binary log.
+type Source interface {
+	// Connect opens the connection changes are streamed over
+	Connect(ctx context.Context) error
+	// Retain makes the database keep changes for this source from now on.
+	// With discard set, changes kept from an earlier session are dropped
+	// first so they cannot be replayed as legitimate.
+	Retain(ctx context.Context, discard bool) error
+	// Stream starts streaming changes after position, or from the first
+	// retained change when it is 0
+	Stream(ctx context.Context, position uint64) error
+	// Receive waits for the next message and passes its changes and commits
+	// to the handler
+	Receive(ctx context.Context) error
+	// AcknowledgedPosition returns the position whose changes are durable
+	AcknowledgedPosition() uint64
+	// Flush reports the acknowledged position to the database, so it can
+	// release the changes before it
+	Flush(ctx context.Context) error
+	Close(ctx context.Context) error
+}
+
+// Identifier is implemented by sources that can tell which database they
+// are connected to. It is called after Connect and before Stream.
+type Identifier interface {
+	Identify(ctx context.Context) (*storage.DatabaseIdentity, error)
+}
+
+// IdentityObserver is told the identity of the source database every time
+// the Manager connects, together with the position acknowledged before.
+// identity.Source is the name of the source.
+type IdentityObserver interface {
+	ObserveIdentity(identity *storage.DatabaseIdentity, acknowledged uint64) error
+}
+
+// SourceFactory creates the Source of a Manager. A nil handler is passed
+// when the source is only prepared.
+type SourceFactory func(config *ReplicationConfig, handler EventHandler) Source
+
+// PostgresSource is the SourceFactory of PostgreSQL databases
+func PostgresSource(config *ReplicationConfig, handler EventHandler) Source {
+	return NewReplicationClient(config, handler)
+}
@@ -7,13 +7,20 @@ import (
 type OperationType string
 
 const (
-	OperationInsert OperationType = "INSERT"
-	OperationUpdate OperationType = "UPDATE"
-	OperationDelete OperationType = "DELETE"
+	OperationInsert   OperationType = "INSERT"
+	OperationUpdate   OperationType = "UPDATE"
+	OperationDelete   OperationType = "DELETE"
+	OperationTruncate OperationType = "TRUNCATE"
 )
 
 type ChangeEvent struct {
-	TableName     string
+	// TableName is the plain name of the table, qualified by the source.
+	// The Manager qualifies it by Schema as well when the table is
+	// protected as schema.table.
+	TableName string
+	// Schema is the schema of the table in the source database, if it has
+	// schemas
+	Schema        string
 	Operation     OperationType
 	Timestamp     time.Time
 	NewData       map[string]interface{}
@@ -26,3 +33,32 @@ type ChangeEvent struct {
 type EventHandler interface {
 	HandleChange(event *ChangeEvent) error
 }
+
+// CommitEvent marks the end of a source transaction. EndLSN is the position
+// that may be acknowledged to PostgreSQL once the transaction is durable.
+type CommitEvent struct {
+	// Source is the name of the source the transaction was streamed from
+	Source        string
+	TransactionID uint32
+	CommitLSN     uint64
+	EndLSN        uint64
+	Timestamp     time.Time
+}
+
+// CommitHandler is implemented by handlers that finish processing changes
+// after HandleChange returns. HandleCommit must call ack once every change of
+// the transaction is durable; the replication slot is not advanced past the
+// transaction before that. Handlers that do not implement it are considered
+// done with a transaction when it commits.
+type CommitHandler interface {
+	HandleCommit(commit *CommitEvent, ack func()) error
+}
+
+// Replayer is implemented by commit handlers that can fail to record a
+// transaction after HandleCommit returned. The Manager asks after every
+// receive; when ReplayRequested returns true it reconnects and streams
+// again from the acknowledged position, so the failed transactions are
+// handled once more.
+type Replayer interface {
+	ReplayRequested(source string) bool
+}
@@ -0,0 +1,350 @@
+package cdc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5"
+	"github.com/witnz/witnz/internal/discovery"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+// Checks of the detector watchdog
+const (
+	CheckPublication     = "publication"
+	CheckSlot            = "slot"
+	CheckReplicaIdentity = "replica_identity"
+	CheckTableOID        = "table_oid"
+)
+
+// DetectorTamper is a change to the database objects CDC relies on that
+// lets changes to protected tables go unnoticed, e.g. a table dropped from
+// the publication or a slot advanced past unread WAL
+type DetectorTamper struct {
+	// Table is the qualified name of the affected table, or <source>/* when
+	// the slot or the whole publication is affected
+	Table   string
+	Check   string
+	Details string
+}
+
+func (t *DetectorTamper) key() string {
+	return t.Check + "\x00" + t.Table + "\x00" + t.Details
+}
+
+// DetectorObserver is told about every detector tamper the watchdog finds.
+// A tamper is reported once, and again only after it was fixed in between.
+type DetectorObserver interface {
+	ObserveDetectorTamper(tamper *DetectorTamper)
+}
+
+// detectorState is what the watchdog reads from PostgreSQL in one check
+type detectorState struct {
+	publicationExists bool
+	allTables         bool
+	viaRoot           bool
+	// publishes maps INSERT, UPDATE, DELETE and TRUNCATE to whether the
+	// publication publishes them
+	publishes map[string]bool
+	// members are the tables of a publication not created FOR ALL TABLES
+	members    map[string]bool
+	slotExists bool
+	// confirmedFlush is the slot's confirmed_flush_lsn
+	confirmedFlush uint64
+	// tables holds the protected tables that exist
+	tables map[string]tableState
+}
+
+type tableState struct {
+	oid             uint32
+	replicaIdentity string
+}
+
+// watchdog keeps the baseline of a source's detector objects between checks
+type watchdog struct {
+	source string
+	// slotFloor is the slot position seen at the first check. The slot may
+	// not move past it and the acknowledged position.
+	slotFloor uint64
+	slotSeen  bool
+	tables    map[string]tableState
+	reported  map[string]bool
+}
+
+func newWatchdog(source string) *watchdog {
+	return &watchdog{
+		source:   source,
+		tables:   make(map[string]tableState),
+		reported: make(map[string]bool),
+	}
+}
+
+var replicaIdentityNames = map[string]string{"d": "DEFAULT", "n": "NOTHING", "f": "FULL", "i": "USING INDEX"}
+
+func replicaIdentityName(identity string) string {
+	if name, ok := replicaIdentityNames[identity]; ok {
+		return name
+	}
+	return identity
+}
+
+// compare checks a state against the baseline and the protected tables and
+// returns every tamper found. The baseline follows the state, so a changed
+// table OID or replica identity is returned once.
+func (w *watchdog) compare(state *detectorState, publication, slot string, tables []string, acknowledged uint64) []*DetectorTamper {
+	var tampers []*DetectorTamper
+	wide := storage.QualifiedTable(w.source, "*")
+	add := func(table, check, format string, args ...interface{}) {
+		tampers = append(tampers, &DetectorTamper{Table: table, Check: check, Details: fmt.Sprintf(format, args...)})
+	}
+
+	if !state.publicationExists {
+		add(wide, CheckPublication, "publication %s was dropped", publication)
+	} else {
+		if !state.viaRoot {
+			add(wide, CheckPublication, "publication %s no longer publishes partition changes via the partition root", publication)
+		}
+		for _, operation := range []string{"INSERT", "UPDATE", "DELETE", "TRUNCATE"} {
+			if !state.publishes[operation] {
+				add(wide, CheckPublication, "publication %s no longer publishes %s", publication, operation)
+			}
+		}
+		if !state.allTables {
+			for _, table := range tables {
+				if _, exists := state.tables[table]; exists && !state.members[table] {
+					add(storage.QualifiedTable(w.source, table), CheckPublication, "table was removed from publication %s", publication)
+				}
+			}
+		}
+	}
+
+	if !state.slotExists {
+		add(wide, CheckSlot, "replication slot %s was dropped", slot)
+	} else {
+		if !w.slotSeen {
+			w.slotFloor = state.confirmedFlush
+			w.slotSeen = true
+		}
+		floor := max(w.slotFloor, acknowledged)
+		if state.confirmedFlush > floor {
+			add(wide, CheckSlot, "replication slot %s was advanced to %s past acknowledged position %s, skipping WAL that was never read",
+				slot, pglogrepl.LSN(state.confirmedFlush), pglogrepl.LSN(floor))
+			w.slotFloor = state.confirmedFlush
+		}
+	}
+
+	for _, table := range tables {
+		current, exists := state.tables[table]
+		if !exists {
+			// A dropped table is handled by discovery and verification; the
+			// baseline is kept so a recreated table is noticed
+			continue
+		}
+		qualified := storage.QualifiedTable(w.source, table)
+
+		previous, seen := w.tables[table]
+		if seen && previous.oid != current.oid {
+			add(qualified, CheckTableOID, "table was dropped and recreated (OID %d, previously %d)", current.oid, previous.oid)
+		}
+		if current.replicaIdentity == "n" {
+			if !seen || previous.replicaIdentity != "n" {
+				add(qualified, CheckReplicaIdentity, "replica identity is NOTHING, old rows of UPDATE and DELETE are not replicated")
+			}
+		} else if seen && previous.replicaIdentity != current.replicaIdentity {
+			add(qualified, CheckReplicaIdentity, "replica identity changed from %s to %s",
+				replicaIdentityName(previous.replicaIdentity), replicaIdentityName(current.replicaIdentity))
+		}
+		w.tables[table] = current
+	}
+
+	return tampers
+}
+
+// fresh returns the tampers not reported by the previous check. A tamper
+// that clears and comes back is reported again.
+func (w *watchdog) fresh(tampers []*DetectorTamper) []*DetectorTamper {
+	reported := make(map[string]bool, len(tampers))
+	var fresh []*DetectorTamper
+	for _, tamper := range tampers {
+		key := tamper.key()
+		if !w.reported[key] {
+			fresh = append(fresh, tamper)
+		}
+		reported[key] = true
+	}
+	w.reported = reported
+	return fresh
+}
+
+// readDetectorState reads the publication, slot and protected tables
+func readDetectorState(ctx context.Context, conn *pgx.Conn, publication, slot string, tables []string) (*detectorState, error) {
+	state := &detectorState{
+		publishes: make(map[string]bool),
+		members:   make(map[string]bool),
+		tables:    make(map[string]tableState),
+	}
+
+	var insert, update, del, truncate bool
+	err := conn.QueryRow(ctx,
+		"SELECT puballtables, pubviaroot, pubinsert, pubupdate, pubdelete, pubtruncate FROM pg_publication WHERE pubname = $1",
+		publication,
+	).Scan(&state.allTables, &state.viaRoot, &insert, &update, &del, &truncate)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return nil, fmt.Errorf("failed to check publication: %w", err)
+	default:
+		state.publicationExists = true
+		state.publishes = map[string]bool{"INSERT": insert, "UPDATE": update, "DELETE": del, "TRUNCATE": truncate}
+	}
+
+	if state.publicationExists && !state.allTables {
+		// Members are keyed by schema.table, and by their plain name when it
+		// resolves to them on the search path, to match either way a
+		// protected table is named
+		rows, err := conn.Query(ctx, `SELECT schemaname, tablename,
+			pg_table_is_visible(format('%I.%I', schemaname, tablename)::regclass)
+			FROM pg_publication_tables WHERE pubname = $1`, publication)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list publication tables: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var schema, table string
+			var visible bool
+			if err := rows.Scan(&schema, &table, &visible); err != nil {
+				return nil, fmt.Errorf("failed to list publication tables: %w", err)
+			}
+			state.members[schema+storage.SchemaSeparator+table] = true
+			if visible {
+				state.members[table] = true
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to list publication tables: %w", err)
+		}
+	}
+
+	var confirmedFlush string
+	err = conn.QueryRow(ctx,
+		"SELECT COALESCE(confirmed_flush_lsn, '0/0')::text FROM pg_replication_slots WHERE slot_name = $1",
+		slot,
+	).Scan(&confirmedFlush)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return nil, fmt.Errorf("failed to check replication slot: %w", err)
+	default:
+		lsn, err := pglogrepl.ParseLSN(confirmedFlush)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse confirmed_flush_lsn: %w", err)
+		}
+		state.slotExists = true
+		state.confirmedFlush = uint64(lsn)
+	}
+
+	for _, table := range tables {
+		var oid int64
+		var identity string
+		err := conn.QueryRow(ctx,
+			"SELECT oid::int8, relreplident::text FROM pg_class WHERE oid = to_regclass($1)",
+			discovery.Identifier(table).Sanitize(),
+		).Scan(&oid, &identity)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to check table %s: %w", table, err)
+		}
+		state.tables[table] = tableState{oid: uint32(oid), replicaIdentity: identity}
+	}
+
+	return state, nil
+}
+
+// StartWatchdog checks the publication, the replication slot and the
+// protected tables every interval until the manager is drained. The
+// protected tables are those last passed to SyncPublication. Sources other
+// than PostgreSQL are not checked.
+func (m *Manager) StartWatchdog(ctx context.Context, interval time.Duration) {
+	m.mu.RLock()
+	postgres := m.newSource == nil
+	m.mu.RUnlock()
+	if !postgres || interval <= 0 {
+		return
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.stopCh:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := m.checkDetector(ctx); err != nil && ctx.Err() == nil {
+					logger.Warn("Detector watchdog check failed", "source", m.config.Source, "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// checkDetector runs one watchdog check and reports new tampers
+func (m *Manager) checkDetector(ctx context.Context) error {
+	// Serialized with SyncPublication, so a table being added or removed
+	// is not mistaken for tampering
+	m.watchMu.Lock()
+	defer m.watchMu.Unlock()
+
+	conn, err := m.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	tables := append([]string(nil), m.watched...)
+	sort.Strings(tables)
+
+	state, err := readDetectorState(ctx, conn, m.config.PublicationName, m.config.SlotName, tables)
+	if err != nil {
+		return err
+	}
+
+	acknowledged := uint64(m.AcknowledgedLSN())
+	tampers := m.watchdog.fresh(m.watchdog.compare(state, m.config.PublicationName, m.config.SlotName, tables, acknowledged))
+
+	m.mu.RLock()
+	observer := m.detector
+	alerts := m.alertManager
+	m.mu.RUnlock()
+
+	for _, tamper := range tampers {
+		logger.Error("Detector tampering detected",
+			"table", tamper.Table,
+			"check", tamper.Check,
+			"details", tamper.Details)
+		if alerts != nil {
+			_ = alerts.SendSystemAlert(
+				"Detector Tampering Detected",
+				fmt.Sprintf("%s (%s): %s", tamper.Table, tamper.Check, tamper.Details),
+				"danger",
+			)
+		}
+		if observer != nil {
+			observer.ObserveDetectorTamper(tamper)
+		}
+	}
+
+	return nil
+}
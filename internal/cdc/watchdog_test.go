@@ -0,0 +1,142 @@
+package cdc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWatchdog(t *testing.T) {
+	tables := []string{"audit_log", "payments"}
+	healthy := func() *detectorState {
+		return &detectorState{
+			publicationExists: true,
+			viaRoot:           true,
+			publishes:         map[string]bool{"INSERT": true, "UPDATE": true, "DELETE": true, "TRUNCATE": true},
+			members:           map[string]bool{"audit_log": true, "payments": true},
+			slotExists:        true,
+			confirmedFlush:    1000,
+			tables: map[string]tableState{
+				"audit_log": {oid: 16384, replicaIdentity: "d"},
+				"payments":  {oid: 16390, replicaIdentity: "f"},
+			},
+		}
+	}
+	check := func(w *watchdog, state *detectorState, acknowledged uint64) []*DetectorTamper {
+		return w.fresh(w.compare(state, "witnz_billing_publication", "witnz_node1_billing", tables, acknowledged))
+	}
+
+	t.Run("Healthy", func(t *testing.T) {
+		w := newWatchdog("billing")
+		if tampers := check(w, healthy(), 0); len(tampers) != 0 {
+			t.Fatalf("Expected no tampers, got %v", tampers[0].Details)
+		}
+
+		// The slot follows acknowledged positions
+		state := healthy()
+		state.confirmedFlush = 1500
+		if tampers := check(w, state, 1500); len(tampers) != 0 {
+			t.Errorf("Expected no tampers, got %v", tampers[0].Details)
+		}
+	})
+
+	t.Run("Publication", func(t *testing.T) {
+		w := newWatchdog("billing")
+		check(w, healthy(), 0)
+
+		state := healthy()
+		delete(state.members, "audit_log")
+		state.publishes["UPDATE"] = false
+		tampers := check(w, state, 0)
+		if len(tampers) != 2 {
+			t.Fatalf("Expected 2 tampers, got %d", len(tampers))
+		}
+		if tampers[0].Table != "billing/*" || !strings.Contains(tampers[0].Details, "no longer publishes UPDATE") {
+			t.Errorf("Expected publication UPDATE tamper, got %+v", tampers[0])
+		}
+		if tampers[1].Table != "billing/audit_log" || tampers[1].Check != CheckPublication {
+			t.Errorf("Expected audit_log removed from publication, got %+v", tampers[1])
+		}
+
+		if again := check(w, state, 0); len(again) != 0 {
+			t.Errorf("Expected a standing tamper to be reported once, got %d", len(again))
+		}
+		check(w, healthy(), 0)
+		if again := check(w, state, 0); len(again) != 2 {
+			t.Errorf("Expected a tamper to be reported again after it was fixed, got %d", len(again))
+		}
+
+		dropped := healthy()
+		dropped.publicationExists = false
+		tampers = check(w, dropped, 0)
+		if len(tampers) != 1 || !strings.Contains(tampers[0].Details, "publication witnz_billing_publication was dropped") {
+			t.Errorf("Expected dropped publication, got %v", tampers)
+		}
+	})
+
+	t.Run("Slot", func(t *testing.T) {
+		w := newWatchdog("billing")
+		check(w, healthy(), 0)
+
+		advanced := healthy()
+		advanced.confirmedFlush = 5000
+		tampers := check(w, advanced, 1200)
+		if len(tampers) != 1 || tampers[0].Check != CheckSlot || !strings.Contains(tampers[0].Details, "advanced to 0/1388 past acknowledged position 0/4B0") {
+			t.Fatalf("Expected slot advance tamper, got %v", tampers)
+		}
+		if again := check(w, advanced, 1200); len(again) != 0 {
+			t.Errorf("Expected the advance to be reported once, got %d", len(again))
+		}
+
+		missing := healthy()
+		missing.slotExists = false
+		tampers = check(w, missing, 1200)
+		if len(tampers) != 1 || !strings.Contains(tampers[0].Details, "replication slot witnz_node1_billing was dropped") {
+			t.Errorf("Expected dropped slot, got %v", tampers)
+		}
+	})
+
+	t.Run("Tables", func(t *testing.T) {
+		w := newWatchdog("")
+		state := healthy()
+		state.allTables = true
+		state.members = nil
+		check(w, state, 0)
+
+		changed := healthy()
+		changed.allTables = true
+		changed.tables["audit_log"] = tableState{oid: 20000, replicaIdentity: "d"}
+		changed.tables["payments"] = tableState{oid: 16390, replicaIdentity: "n"}
+		tampers := check(w, changed, 0)
+		if len(tampers) != 2 {
+			t.Fatalf("Expected 2 tampers, got %d", len(tampers))
+		}
+		if tampers[0].Table != "audit_log" || tampers[0].Check != CheckTableOID {
+			t.Errorf("Expected recreated audit_log, got %+v", tampers[0])
+		}
+		if tampers[1].Table != "payments" || tampers[1].Check != CheckReplicaIdentity || !strings.Contains(tampers[1].Details, "NOTHING") {
+			t.Errorf("Expected payments replica identity NOTHING, got %+v", tampers[1])
+		}
+
+		identity := healthy()
+		identity.allTables = true
+		identity.tables["audit_log"] = tableState{oid: 20000, replicaIdentity: "d"}
+		identity.tables["payments"] = tableState{oid: 16390, replicaIdentity: "d"}
+		tampers = check(w, identity, 0)
+		if len(tampers) != 1 || !strings.Contains(tampers[0].Details, "changed from NOTHING to DEFAULT") {
+			t.Errorf("Expected replica identity change, got %v", tampers)
+		}
+
+		// A dropped table is left to discovery, and noticed if recreated
+		gone := healthy()
+		gone.allTables = true
+		delete(gone.tables, "audit_log")
+		gone.tables["payments"] = tableState{oid: 16390, replicaIdentity: "d"}
+		if tampers := check(w, gone, 0); len(tampers) != 0 {
+			t.Errorf("Expected no tampers for a dropped table, got %v", tampers[0].Details)
+		}
+		gone.tables["audit_log"] = tableState{oid: 20500, replicaIdentity: "d"}
+		if tampers := check(w, gone, 0); len(tampers) != 1 || tampers[0].Check != CheckTableOID {
+			t.Errorf("Expected recreated table to be reported, got %v", tampers)
+		}
+	})
+}
@@ -3,21 +3,38 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+	"github.com/witnz/witnz/internal/discovery"
+	"github.com/witnz/witnz/internal/logging"
+	"github.com/witnz/witnz/internal/storage"
 )
 
 type Config struct {
 	Database        DatabaseConfig         `mapstructure:"database"`
 	Node            NodeConfig             `mapstructure:"node"`
 	Raft            RaftConfig             `mapstructure:"raft"`
+	Shutdown        ShutdownConfig         `mapstructure:"shutdown"`
 	Hash            HashConfig             `mapstructure:"hash"`
 	ProtectedTables []ProtectedTableConfig `mapstructure:"protected_tables"`
+	Sources         []SourceConfig         `mapstructure:"sources"`
+	TableDiscovery  TableDiscoveryConfig   `mapstructure:"table_discovery"`
+	Watchdog        WatchdogConfig         `mapstructure:"watchdog"`
 	Alerts          AlertsConfig           `mapstructure:"alerts"`
+	Forensics       ForensicsConfig        `mapstructure:"forensics"`
+	Admin           AdminConfig            `mapstructure:"admin"`
+	Allowances      AllowancesConfig       `mapstructure:"allowances"`
+	Logging         LoggingConfig          `mapstructure:"logging"`
 }
 
 type DatabaseConfig struct {
+	// Type is "postgres" (the default) or "mysql"
+	Type     string `mapstructure:"type"`
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
 	Database string `mapstructure:"database"`
@@ -32,11 +49,176 @@ type NodeConfig struct {
 	Peers     []string          `mapstructure:"peers"`
 	Bootstrap bool              `mapstructure:"bootstrap"`
 	PeerAddrs map[string]string `mapstructure:"peer_addrs"`
+	// KeyFile holds this node's Ed25519 signing key (default: <data_dir>/node.key)
+	KeyFile string `mapstructure:"key_file"`
+	// PublicKeys pins the hex-encoded public key of every node, including
+	// this one. Setting it enables signed log entries and checkpoints. It is
+	// a list rather than a map because viper lowercases map keys, which
+	// would lose the case of node IDs.
+	PublicKeys []NodeKeyConfig `mapstructure:"public_keys"`
+}
+
+type NodeKeyConfig struct {
+	NodeID string `mapstructure:"node_id"`
+	// PublicKey is a hex-encoded Ed25519 public key
+	PublicKey string `mapstructure:"public_key"`
+}
+
+// PublicKeyMap returns node IDs mapped to their pinned public keys
+func (n *NodeConfig) PublicKeyMap() map[string]string {
+	keys := make(map[string]string, len(n.PublicKeys))
+	for _, key := range n.PublicKeys {
+		keys[key.NodeID] = key.PublicKey
+	}
+	return keys
+}
+
+// KeyPath returns the location of the node signing key
+func (n *NodeConfig) KeyPath() string {
+	if n.KeyFile != "" {
+		return n.KeyFile
+	}
+	return filepath.Join(n.DataDir, "node.key")
 }
 
 type RaftConfig struct {
 	LeadershipTransferInterval string `mapstructure:"leadership_transfer_interval"`
-	FollowerAutoShutdown       bool   `mapstructure:"follower_auto_shutdown"`
+	// FollowerAutoShutdown fences a node that has had no leader for longer
+	// than FollowerAutoShutdownTimeout
+	FollowerAutoShutdown        bool   `mapstructure:"follower_auto_shutdown"`
+	FollowerAutoShutdownTimeout string `mapstructure:"follower_auto_shutdown_timeout"`
+	// BatchMaxEntries and BatchWindow bound how many hash entries are grouped
+	// into one Raft proposal and how long an entry waits for its batch
+	BatchMaxEntries int    `mapstructure:"batch_max_entries"`
+	BatchWindow     string `mapstructure:"batch_window"`
+	// Timing and log compaction, for clusters whose links are slower than a
+	// LAN. Unset values keep the hashicorp/raft defaults.
+	HeartbeatTimeout  string `mapstructure:"heartbeat_timeout"`
+	ElectionTimeout   string `mapstructure:"election_timeout"`
+	SnapshotInterval  string `mapstructure:"snapshot_interval"`
+	SnapshotThreshold uint64 `mapstructure:"snapshot_threshold"`
+	TrailingLogs      uint64 `mapstructure:"trailing_logs"`
+	RetainSnapshots   int    `mapstructure:"retain_snapshots"`
+	MaxAppendEntries  int    `mapstructure:"max_append_entries"`
+	// CatchUpLag is how many entries a follower may trail the leader
+	// before verification and WAL acks are held back until it catches up
+	CatchUpLag uint64 `mapstructure:"catch_up_lag"`
+}
+
+const (
+	// defaultRaftTimeout is the hashicorp/raft default for both the heartbeat
+	// and election timeouts
+	defaultRaftTimeout = time.Second
+	// maxAppendEntries is the largest batch hashicorp/raft accepts
+	maxAppendEntries = 1024
+	// minRaftTimeout is the shortest timeout hashicorp/raft accepts
+	minRaftTimeout = 5 * time.Millisecond
+)
+
+// LeadershipTransferDuration returns the parsed rotation interval, or zero
+// when rotation is disabled
+func (r *RaftConfig) LeadershipTransferDuration() time.Duration {
+	d, _ := time.ParseDuration(r.LeadershipTransferInterval)
+	return d
+}
+
+// FollowerAutoShutdownTimeoutDuration returns the parsed leader loss timeout,
+// or zero when unset
+func (r *RaftConfig) FollowerAutoShutdownTimeoutDuration() time.Duration {
+	d, _ := time.ParseDuration(r.FollowerAutoShutdownTimeout)
+	return d
+}
+
+// BatchWindowDuration returns the parsed batch window, or zero when unset
+func (r *RaftConfig) BatchWindowDuration() time.Duration {
+	d, _ := time.ParseDuration(r.BatchWindow)
+	return d
+}
+
+// HeartbeatTimeoutDuration returns the parsed heartbeat timeout, or zero when unset
+func (r *RaftConfig) HeartbeatTimeoutDuration() time.Duration {
+	d, _ := time.ParseDuration(r.HeartbeatTimeout)
+	return d
+}
+
+// ElectionTimeoutDuration returns the parsed election timeout, or zero when unset
+func (r *RaftConfig) ElectionTimeoutDuration() time.Duration {
+	d, _ := time.ParseDuration(r.ElectionTimeout)
+	return d
+}
+
+// SnapshotIntervalDuration returns the parsed snapshot interval, or zero when unset
+func (r *RaftConfig) SnapshotIntervalDuration() time.Duration {
+	d, _ := time.ParseDuration(r.SnapshotInterval)
+	return d
+}
+
+func (r *RaftConfig) validate() error {
+	if r.BatchMaxEntries < 0 {
+		return fmt.Errorf("raft.batch_max_entries must not be negative")
+	}
+	if r.RetainSnapshots < 0 {
+		return fmt.Errorf("raft.retain_snapshots must not be negative")
+	}
+	if r.MaxAppendEntries < 0 || r.MaxAppendEntries > maxAppendEntries {
+		return fmt.Errorf("raft.max_append_entries must be 0 (default) or between 1 and %d", maxAppendEntries)
+	}
+
+	durations := []struct {
+		name  string
+		value string
+		min   time.Duration
+	}{
+		{"batch_window", r.BatchWindow, 0},
+		{"leadership_transfer_interval", r.LeadershipTransferInterval, 0},
+		{"follower_auto_shutdown_timeout", r.FollowerAutoShutdownTimeout, 0},
+		// The leader lease is derived as half the heartbeat timeout
+		{"heartbeat_timeout", r.HeartbeatTimeout, 2 * minRaftTimeout},
+		{"election_timeout", r.ElectionTimeout, minRaftTimeout},
+		{"snapshot_interval", r.SnapshotInterval, minRaftTimeout},
+	}
+	for _, field := range durations {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid raft.%s: %s", field.name, field.value)
+		}
+		if d < field.min {
+			return fmt.Errorf("raft.%s must be at least %v", field.name, field.min)
+		}
+	}
+
+	heartbeat := r.HeartbeatTimeoutDuration()
+	if heartbeat == 0 {
+		heartbeat = defaultRaftTimeout
+	}
+	election := r.ElectionTimeoutDuration()
+	if election == 0 {
+		election = defaultRaftTimeout
+	}
+	if election < heartbeat {
+		return fmt.Errorf("raft.election_timeout (%v) must not be shorter than raft.heartbeat_timeout (%v)", election, heartbeat)
+	}
+
+	return nil
+}
+
+type ShutdownConfig struct {
+	// StepTimeout bounds each stage of an ordered shutdown
+	StepTimeout string `mapstructure:"step_timeout"`
+}
+
+// DefaultShutdownStepTimeout bounds each shutdown stage when unset
+const DefaultShutdownStepTimeout = 10 * time.Second
+
+// StepTimeoutDuration returns the parsed step timeout, or the default when unset
+func (s *ShutdownConfig) StepTimeoutDuration() time.Duration {
+	if d, err := time.ParseDuration(s.StepTimeout); err == nil && d > 0 {
+		return d
+	}
+	return DefaultShutdownStepTimeout
 }
 
 type HashConfig struct {
@@ -44,8 +226,110 @@ type HashConfig struct {
 }
 
 type ProtectedTableConfig struct {
-	Name           string `mapstructure:"name"`
+	Name string `mapstructure:"name"`
+	// Pattern protects every table matching a glob such as "*_log" or
+	// "audit.*" instead of one named table. Tables created later are added
+	// when discovered.
+	Pattern        string `mapstructure:"pattern"`
 	VerifyInterval string `mapstructure:"verify_interval"`
+	StoreRowImages bool   `mapstructure:"store_row_images"`
+}
+
+// TablePatterns returns the patterns of every source in config order,
+// qualified by their source
+func (c *Config) TablePatterns() []string {
+	var patterns []string
+	for _, source := range c.AllSources() {
+		for _, table := range source.ProtectedTables {
+			if table.Pattern != "" {
+				patterns = append(patterns, storage.QualifiedTable(source.Name, table.Pattern))
+			}
+		}
+	}
+	return patterns
+}
+
+// SourceConfig is one PostgreSQL or MySQL database monitored by the cluster. Its
+// tables are stored, replicated and reported as "<name>/<table>".
+type SourceConfig struct {
+	Name     string         `mapstructure:"name"`
+	Database DatabaseConfig `mapstructure:"database"`
+	// Publication and Slot default to witnz_<name>_publication and
+	// witnz_<node id>_<name>
+	Publication     string                 `mapstructure:"publication"`
+	Slot            string                 `mapstructure:"slot"`
+	ProtectedTables []ProtectedTableConfig `mapstructure:"protected_tables"`
+}
+
+var validSourceName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// PublicationName returns the publication the source replicates through
+func (s *SourceConfig) PublicationName() string {
+	if s.Publication != "" {
+		return s.Publication
+	}
+	if s.Name == "" {
+		return "witnz_publication"
+	}
+	return fmt.Sprintf("witnz_%s_publication", s.Name)
+}
+
+// SlotName returns the replication slot of the source on a node
+func (s *SourceConfig) SlotName(nodeID string) string {
+	if s.Slot != "" {
+		return s.Slot
+	}
+	if s.Name == "" {
+		return fmt.Sprintf("witnz_%s", nodeID)
+	}
+	return fmt.Sprintf("witnz_%s_%s", nodeID, s.Name)
+}
+
+// AllSources returns every monitored database. The top-level database and
+// protected_tables, when set, form the default source: its name is empty and
+// its tables keep their plain names.
+func (c *Config) AllSources() []SourceConfig {
+	sources := make([]SourceConfig, 0, len(c.Sources)+1)
+	if c.Database.Host != "" {
+		sources = append(sources, SourceConfig{
+			Database:        c.Database,
+			ProtectedTables: c.ProtectedTables,
+		})
+	}
+	return append(sources, c.Sources...)
+}
+
+type TableDiscoveryConfig struct {
+	// Interval is how often table patterns are resolved again
+	Interval string `mapstructure:"interval"`
+}
+
+// DefaultTableDiscoveryInterval is how often patterns are resolved when unset
+const DefaultTableDiscoveryInterval = 5 * time.Minute
+
+// IntervalDuration returns the parsed discovery interval, or the default when unset
+func (d *TableDiscoveryConfig) IntervalDuration() time.Duration {
+	if interval, err := time.ParseDuration(d.Interval); err == nil && interval > 0 {
+		return interval
+	}
+	return DefaultTableDiscoveryInterval
+}
+
+type WatchdogConfig struct {
+	// Interval is how often the publication, the replication slot and the
+	// protected tables are checked for tampering
+	Interval string `mapstructure:"interval"`
+}
+
+// DefaultWatchdogInterval is how often the watchdog checks when unset
+const DefaultWatchdogInterval = time.Minute
+
+// IntervalDuration returns the parsed watchdog interval, or the default when unset
+func (w *WatchdogConfig) IntervalDuration() time.Duration {
+	if interval, err := time.ParseDuration(w.Interval); err == nil && interval > 0 {
+		return interval
+	}
+	return DefaultWatchdogInterval
 }
 
 type AlertsConfig struct {
@@ -53,6 +337,63 @@ type AlertsConfig struct {
 	SlackWebhook string `mapstructure:"slack_webhook"`
 }
 
+type ForensicsConfig struct {
+	// EncryptionKey is a hex-encoded 32-byte AES key used to encrypt row images
+	EncryptionKey string `mapstructure:"encryption_key"`
+}
+
+type AdminConfig struct {
+	// BindAddr enables the admin API when set (e.g. "127.0.0.1:7100")
+	BindAddr string `mapstructure:"bind_addr"`
+	Token    string `mapstructure:"token"`
+}
+
+type AllowancesConfig struct {
+	Approvers []ApproverConfig `mapstructure:"approvers"`
+}
+
+type ApproverConfig struct {
+	Name string `mapstructure:"name"`
+	// PublicKey is a hex-encoded Ed25519 public key
+	PublicKey string `mapstructure:"public_key"`
+}
+
+// ApproverKeys returns approver names mapped to their public keys
+func (a *AllowancesConfig) ApproverKeys() map[string]string {
+	keys := make(map[string]string, len(a.Approvers))
+	for _, approver := range a.Approvers {
+		keys[approver.Name] = approver.PublicKey
+	}
+	return keys
+}
+
+type LoggingConfig struct {
+	// Format is "text" (default) or "json"
+	Format string `mapstructure:"format"`
+	// Level is debug, info (default), warn or error
+	Level string `mapstructure:"level"`
+	// Subsystems overrides the level per subsystem, e.g. raft: warn
+	Subsystems map[string]string `mapstructure:"subsystems"`
+}
+
+func (l *LoggingConfig) validate() error {
+	if !logging.ValidFormat(l.Format) {
+		return fmt.Errorf("invalid logging.format: %s (valid options: text, json)", l.Format)
+	}
+	if _, err := logging.ParseLevel(l.Level); err != nil {
+		return fmt.Errorf("invalid logging.level: %s", l.Level)
+	}
+	for subsystem, level := range l.Subsystems {
+		if !slices.Contains(logging.Subsystems, subsystem) {
+			return fmt.Errorf("unknown logging subsystem: %s (valid options: %s)", subsystem, strings.Join(logging.Subsystems, ", "))
+		}
+		if _, err := logging.ParseLevel(level); err != nil {
+			return fmt.Errorf("invalid logging level for %s: %s", subsystem, level)
+		}
+	}
+	return nil
+}
+
 func Load(configPath string) (*Config, error) {
 	v := viper.New()
 
@@ -86,14 +427,12 @@ func Load(configPath string) (*Config, error) {
 }
 
 func (c *Config) Validate() error {
-	if c.Database.Host == "" {
-		return fmt.Errorf("database.host is required")
-	}
-	if c.Database.Database == "" {
-		return fmt.Errorf("database.database is required")
-	}
-	if c.Database.User == "" {
-		return fmt.Errorf("database.user is required")
+	if len(c.Sources) == 0 || c.Database != (DatabaseConfig{}) {
+		if err := c.Database.validate("database"); err != nil {
+			return err
+		}
+	} else if len(c.ProtectedTables) > 0 {
+		return fmt.Errorf("protected_tables requires database; list the tables of each source under sources")
 	}
 	if c.Node.ID == "" {
 		return fmt.Errorf("node.id is required")
@@ -122,9 +461,157 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid hash algorithm: %s (valid options: xxhash64, xxhash128, sha256, blake2b_256, blake3)", c.Hash.Algorithm)
 	}
 
+	if err := c.validateTables(c.ProtectedTables); err != nil {
+		return err
+	}
+	if err := c.Database.validatePatterns(c.ProtectedTables); err != nil {
+		return err
+	}
+	seenSources := make(map[string]bool)
+	for i := range c.Sources {
+		source := &c.Sources[i]
+		if !validSourceName.MatchString(source.Name) {
+			return fmt.Errorf("invalid source name %q: use lowercase letters, digits and underscores", source.Name)
+		}
+		if seenSources[source.Name] {
+			return fmt.Errorf("duplicate source: %s", source.Name)
+		}
+		seenSources[source.Name] = true
+		if err := source.Database.validate(fmt.Sprintf("sources.%s.database", source.Name)); err != nil {
+			return err
+		}
+		if err := c.validateTables(source.ProtectedTables); err != nil {
+			return fmt.Errorf("source %s: %w", source.Name, err)
+		}
+		if err := source.Database.validatePatterns(source.ProtectedTables); err != nil {
+			return fmt.Errorf("source %s: %w", source.Name, err)
+		}
+	}
+	if c.TableDiscovery.Interval != "" {
+		if d, err := time.ParseDuration(c.TableDiscovery.Interval); err != nil || d <= 0 {
+			return fmt.Errorf("invalid table_discovery.interval: %s", c.TableDiscovery.Interval)
+		}
+	}
+	if c.Watchdog.Interval != "" {
+		if d, err := time.ParseDuration(c.Watchdog.Interval); err != nil || d <= 0 {
+			return fmt.Errorf("invalid watchdog.interval: %s", c.Watchdog.Interval)
+		}
+	}
+
+	if len(c.Node.PublicKeys) > 0 {
+		// Node IDs that differ only in case are rejected, since peer_addrs
+		// keys are lowercased when the config is read
+		pinned := make(map[string]bool, len(c.Node.PublicKeys))
+		for _, key := range c.Node.PublicKeys {
+			if key.NodeID == "" || key.PublicKey == "" {
+				return fmt.Errorf("node.public_keys entries require node_id and public_key")
+			}
+			if pinned[strings.ToLower(key.NodeID)] {
+				return fmt.Errorf("duplicate node.public_keys entry: %s", key.NodeID)
+			}
+			pinned[strings.ToLower(key.NodeID)] = true
+		}
+		if _, ok := c.Node.PublicKeyMap()[c.Node.ID]; !ok {
+			return fmt.Errorf("node.public_keys must include this node (%s)", c.Node.ID)
+		}
+		for peerID := range c.Node.PeerAddrs {
+			if !pinned[strings.ToLower(peerID)] {
+				return fmt.Errorf("node.public_keys is missing peer %s", peerID)
+			}
+		}
+	}
+
+	if err := c.Raft.validate(); err != nil {
+		return err
+	}
+	if c.Shutdown.StepTimeout != "" {
+		if d, err := time.ParseDuration(c.Shutdown.StepTimeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid shutdown.step_timeout: %s", c.Shutdown.StepTimeout)
+		}
+	}
+
+	if err := c.Logging.validate(); err != nil {
+		return err
+	}
+
+	seenApprovers := make(map[string]bool)
+	for _, approver := range c.Allowances.Approvers {
+		if approver.Name == "" || approver.PublicKey == "" {
+			return fmt.Errorf("allowances.approvers entries require name and public_key")
+		}
+		if seenApprovers[approver.Name] {
+			return fmt.Errorf("duplicate allowance approver: %s", approver.Name)
+		}
+		seenApprovers[approver.Name] = true
+	}
+
+	return nil
+}
+
+func (d *DatabaseConfig) validate(section string) error {
+	if d.Type != "" && d.Type != DatabasePostgres && d.Type != DatabaseMySQL {
+		return fmt.Errorf("invalid %s.type: %s (valid options: postgres, mysql)", section, d.Type)
+	}
+	if d.Host == "" {
+		return fmt.Errorf("%s.host is required", section)
+	}
+	if d.Database == "" {
+		return fmt.Errorf("%s.database is required", section)
+	}
+	if d.User == "" {
+		return fmt.Errorf("%s.user is required", section)
+	}
+	return nil
+}
+
+// validatePatterns rejects table patterns on MySQL databases, which are
+// only resolved against the PostgreSQL catalog
+func (d *DatabaseConfig) validatePatterns(tables []ProtectedTableConfig) error {
+	if !d.IsMySQL() {
+		return nil
+	}
+	for _, table := range tables {
+		if table.Pattern != "" {
+			return fmt.Errorf("table pattern %s is not supported on MySQL; list the tables by name", table.Pattern)
+		}
+	}
 	return nil
 }
 
+// validateTables checks the protected_tables of one source
+func (c *Config) validateTables(tables []ProtectedTableConfig) error {
+	seenPatterns := make(map[string]bool)
+	for _, table := range tables {
+		if (table.Name == "") == (table.Pattern == "") {
+			return fmt.Errorf("protected_tables entries require exactly one of name or pattern")
+		}
+		if table.Pattern != "" {
+			if _, err := discovery.ParsePattern(table.Pattern); err != nil {
+				return err
+			}
+			if seenPatterns[table.Pattern] {
+				return fmt.Errorf("duplicate table pattern: %s", table.Pattern)
+			}
+			seenPatterns[table.Pattern] = true
+		}
+		if table.StoreRowImages && c.Forensics.EncryptionKey == "" {
+			return fmt.Errorf("forensics.encryption_key is required when store_row_images is enabled (table: %s%s)", table.Name, table.Pattern)
+		}
+	}
+	return nil
+}
+
+// Database types
+const (
+	DatabasePostgres = "postgres"
+	DatabaseMySQL    = "mysql"
+)
+
+// IsMySQL reports whether the database is read through the MySQL binary log
+func (d *DatabaseConfig) IsMySQL() bool {
+	return d.Type == DatabaseMySQL
+}
+
 func (d *DatabaseConfig) ConnectionString() string {
 	return fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s sslmode=disable",
 		d.Host, d.Port, d.Database, d.User, d.Password)
@@ -57,6 +57,50 @@ alerts:
 	}
 }
 
+func TestLoadMixedCaseNodeKeys(t *testing.T) {
+	configContent := `
+database:
+  host: localhost
+  database: testdb
+  user: testuser
+
+node:
+  id: Node-A
+  bind_addr: 0.0.0.0:7000
+  data_dir: /tmp/data
+  peer_addrs:
+    Node-B: node-b:7000
+  public_keys:
+    - node_id: Node-A
+      public_key: aa
+    - node_id: Node-B
+      public_key: bb
+`
+
+	tmpfile, err := os.CreateTemp("", "witnz-test-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := tmpfile.Write([]byte(configContent)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	keys := cfg.Node.PublicKeyMap()
+	if keys["Node-A"] != "aa" || keys["Node-B"] != "bb" {
+		t.Errorf("Expected keys pinned under their mixed-case node IDs, got %v", keys)
+	}
+}
+
 func TestValidate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -94,6 +138,495 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "row images without encryption key",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				ProtectedTables: []ProtectedTableConfig{
+					{Name: "audit_log", StoreRowImages: true},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "public keys missing peer",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:        "node1",
+					BindAddr:  "0.0.0.0:7000",
+					DataDir:   "/data",
+					PeerAddrs: map[string]string{"node2": "node2:7000"},
+					PublicKeys: []NodeKeyConfig{
+						{NodeID: "node1", PublicKey: "aa"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "public keys differing only in case",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+					PublicKeys: []NodeKeyConfig{
+						{NodeID: "node1", PublicKey: "aa"},
+						{NodeID: "Node1", PublicKey: "bb"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate allowance approver",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Allowances: AllowancesConfig{
+					Approvers: []ApproverConfig{
+						{Name: "compliance", PublicKey: "aa"},
+						{Name: "compliance", PublicKey: "bb"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid batch window",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Raft: RaftConfig{BatchWindow: "soon"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid leadership transfer interval",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Raft: RaftConfig{LeadershipTransferInterval: "-1h"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid watchdog interval",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Watchdog: WatchdogConfig{Interval: "0s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cross-region raft timing",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Raft: RaftConfig{HeartbeatTimeout: "3s", ElectionTimeout: "5s", SnapshotInterval: "5m", MaxAppendEntries: 256},
+			},
+			wantErr: false,
+		},
+		{
+			name: "heartbeat longer than default election timeout",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Raft: RaftConfig{HeartbeatTimeout: "3s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "max append entries too large",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Raft: RaftConfig{MaxAppendEntries: 4096},
+			},
+			wantErr: true,
+		},
+		{
+			name: "table patterns",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				ProtectedTables: []ProtectedTableConfig{
+					{Name: "payments"},
+					{Pattern: "*_log"},
+					{Pattern: "audit.*", VerifyInterval: "1h"},
+				},
+				TableDiscovery: TableDiscoveryConfig{Interval: "1m"},
+				Watchdog:       WatchdogConfig{Interval: "30s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "table with name and pattern",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				ProtectedTables: []ProtectedTableConfig{
+					{Name: "audit_log", Pattern: "*_log"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid table pattern",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				ProtectedTables: []ProtectedTableConfig{
+					{Pattern: "audit.[log"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "json logging with subsystem levels",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Logging: LoggingConfig{
+					Format:     "json",
+					Level:      "info",
+					Subsystems: map[string]string{"raft": "warn", "cdc": "debug"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid logging format",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Logging: LoggingConfig{Format: "xml"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown logging subsystem",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Logging: LoggingConfig{Subsystems: map[string]string{"postgres": "debug"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid subsystem level",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Logging: LoggingConfig{Subsystems: map[string]string{"raft": "verbose"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "sources without default database",
+			config: Config{
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Sources: []SourceConfig{
+					SourceConfig{
+						Name:            "billing",
+						Database:        DatabaseConfig{Host: "billing-db", Database: "billing", User: "witnz"},
+						ProtectedTables: []ProtectedTableConfig{{Name: "invoices"}, {Pattern: "*_log"}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "sources with default database",
+			config: Config{
+				Database: DatabaseConfig{
+					Host:     "localhost",
+					Database: "testdb",
+					User:     "testuser",
+				},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				ProtectedTables: []ProtectedTableConfig{{Name: "audit_log"}},
+				Sources: []SourceConfig{
+					SourceConfig{
+						Name:            "billing",
+						Database:        DatabaseConfig{Host: "billing-db", Database: "billing", User: "witnz"},
+						ProtectedTables: []ProtectedTableConfig{{Name: "invoices"}, {Pattern: "*_log"}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "protected tables without database",
+			config: Config{
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				ProtectedTables: []ProtectedTableConfig{{Name: "audit_log"}},
+				Sources: []SourceConfig{
+					SourceConfig{
+						Name:            "billing",
+						Database:        DatabaseConfig{Host: "billing-db", Database: "billing", User: "witnz"},
+						ProtectedTables: []ProtectedTableConfig{{Name: "invoices"}, {Pattern: "*_log"}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid source name",
+			config: Config{
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Sources: []SourceConfig{
+					{Name: "Billing-DB", Database: DatabaseConfig{Host: "billing-db", Database: "billing", User: "witnz"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate source",
+			config: Config{
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Sources: []SourceConfig{
+					SourceConfig{
+						Name:            "billing",
+						Database:        DatabaseConfig{Host: "billing-db", Database: "billing", User: "witnz"},
+						ProtectedTables: []ProtectedTableConfig{{Name: "invoices"}, {Pattern: "*_log"}},
+					},
+					SourceConfig{
+						Name:            "billing",
+						Database:        DatabaseConfig{Host: "billing-db", Database: "billing", User: "witnz"},
+						ProtectedTables: []ProtectedTableConfig{{Name: "invoices"}, {Pattern: "*_log"}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "source without host",
+			config: Config{
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Sources: []SourceConfig{
+					{Name: "billing", Database: DatabaseConfig{Database: "billing", User: "witnz"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "mysql source",
+			config: Config{
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Sources: []SourceConfig{
+					{
+						Name:            "shop",
+						Database:        DatabaseConfig{Type: "mysql", Host: "shop-db", Database: "shop", User: "witnz"},
+						ProtectedTables: []ProtectedTableConfig{{Name: "orders"}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown database type",
+			config: Config{
+				Database: DatabaseConfig{Type: "oracle", Host: "localhost", Database: "testdb", User: "testuser"},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "table pattern on mysql source",
+			config: Config{
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				Sources: []SourceConfig{
+					{
+						Name:            "shop",
+						Database:        DatabaseConfig{Type: "mysql", Host: "shop-db", Database: "shop", User: "witnz"},
+						ProtectedTables: []ProtectedTableConfig{{Pattern: "*_log"}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "table pattern on mysql database",
+			config: Config{
+				Database: DatabaseConfig{Type: "mysql", Host: "localhost", Database: "testdb", User: "testuser"},
+				Node: NodeConfig{
+					ID:       "node1",
+					BindAddr: "0.0.0.0:7000",
+					DataDir:  "/data",
+				},
+				ProtectedTables: []ProtectedTableConfig{{Pattern: "*_log"}},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -106,6 +639,47 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestAllSources(t *testing.T) {
+	config := Config{
+		Database:        DatabaseConfig{Host: "localhost", Database: "app", User: "witnz"},
+		Node:            NodeConfig{ID: "node1"},
+		ProtectedTables: []ProtectedTableConfig{{Name: "audit_log"}, {Pattern: "*_log"}},
+		Sources: []SourceConfig{
+			{Name: "billing", Database: DatabaseConfig{Host: "billing-db"}, ProtectedTables: []ProtectedTableConfig{{Pattern: "audit_*"}}},
+			{Name: "orders", Publication: "orders_pub", Slot: "orders_slot"},
+		},
+	}
+
+	sources := config.AllSources()
+	if len(sources) != 3 || sources[0].Name != "" || sources[1].Name != "billing" || sources[2].Name != "orders" {
+		t.Fatalf("Expected default, billing and orders sources, got %+v", sources)
+	}
+
+	for i, want := range []struct{ slot, publication string }{
+		{"witnz_node1", "witnz_publication"},
+		{"witnz_node1_billing", "witnz_billing_publication"},
+		{"orders_slot", "orders_pub"},
+	} {
+		if slot := sources[i].SlotName("node1"); slot != want.slot {
+			t.Errorf("Expected slot %s, got %s", want.slot, slot)
+		}
+		if publication := sources[i].PublicationName(); publication != want.publication {
+			t.Errorf("Expected publication %s, got %s", want.publication, publication)
+		}
+	}
+
+	patterns := config.TablePatterns()
+	if len(patterns) != 2 || patterns[0] != "*_log" || patterns[1] != "billing/audit_*" {
+		t.Errorf("Expected [*_log billing/audit_*], got %v", patterns)
+	}
+
+	config.Database = DatabaseConfig{}
+	config.ProtectedTables = nil
+	if sources := config.AllSources(); len(sources) != 2 {
+		t.Errorf("Expected no default source without database, got %+v", sources)
+	}
+}
+
 func TestConnectionString(t *testing.T) {
 	db := DatabaseConfig{
 		Host:     "localhost",
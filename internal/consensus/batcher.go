@@ -0,0 +1,238 @@
+package consensus
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/raft"
+)
+
+// Default batching limits. A batch is proposed when it reaches the entry
+// limit or when its oldest entry has waited for the batch window.
+const (
+	DefaultBatchMaxEntries = 512
+	DefaultBatchWindow     = 20 * time.Millisecond
+)
+
+// maxInflightBatches bounds the batches proposed but not yet applied. Once it
+// is reached, proposing blocks, which pushes back on the CDC stream.
+const maxInflightBatches = 64
+
+// ProposalCallback receives the sequence number assigned to a proposed hash
+// entry, or the error that kept it from being applied
+type ProposalCallback func(seq uint64, err error)
+
+// Batcher groups hash chain entries into a single Raft entry so that a bulk
+// load costs one consensus round per batch instead of one per row. Batches
+// are pipelined: a new batch is proposed while earlier ones are still being
+// replicated, and callbacks run in proposal order.
+type Batcher struct {
+	node       *Node
+	maxEntries int
+	window     time.Duration
+
+	mu        sync.Mutex
+	entries   []*LogEntry
+	callbacks []ProposalCallback
+	waiters   []func(error)
+	timer     *time.Timer
+	stopped   bool
+
+	inflight chan *inflightBatch
+	done     chan struct{}
+}
+
+type inflightBatch struct {
+	future    raft.ApplyFuture
+	err       error
+	callbacks []ProposalCallback
+	waiters   []func(error)
+}
+
+func NewBatcher(node *Node, maxEntries int, window time.Duration) *Batcher {
+	if maxEntries <= 0 {
+		maxEntries = DefaultBatchMaxEntries
+	}
+	if window <= 0 {
+		window = DefaultBatchWindow
+	}
+
+	b := &Batcher{
+		node:       node,
+		maxEntries: maxEntries,
+		window:     window,
+		inflight:   make(chan *inflightBatch, maxInflightBatches),
+		done:       make(chan struct{}),
+	}
+
+	go b.complete()
+
+	return b
+}
+
+// Propose queues a hash chain entry for the next batch. done is called from
+// the batcher's completion goroutine and must not block on the batcher.
+func (b *Batcher) Propose(entry *LogEntry, done ProposalCallback) error {
+	if entry.Type != LogEntryHashChain {
+		return fmt.Errorf("not a hash chain entry: %s", entry.Type)
+	}
+	if entry.Version == 0 {
+		entry.Version = LogEntryVersion
+	}
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		return fmt.Errorf("batcher stopped")
+	}
+
+	b.entries = append(b.entries, entry)
+	b.callbacks = append(b.callbacks, done)
+
+	if len(b.entries) >= b.maxEntries {
+		b.flushLocked()
+	} else if b.timer == nil {
+		b.timer = time.AfterFunc(b.window, b.onWindow)
+	}
+
+	return nil
+}
+
+// OnApplied registers fn to run once every entry proposed so far has been
+// applied, with the first error seen since the previous OnApplied callback.
+// An entry the FSM rejected counts as an error, so a transaction holding it
+// is not acknowledged. It does not cut the pending batch short; fn runs when
+// that batch completes.
+func (b *Batcher) OnApplied(fn func(error)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		fn(fmt.Errorf("batcher stopped"))
+		return
+	}
+
+	b.waiters = append(b.waiters, fn)
+
+	// With nothing pending, queue the callback behind the in-flight batches
+	if len(b.entries) == 0 {
+		b.flushLocked()
+	}
+}
+
+// Stop proposes any pending entries and waits until every batch has
+// completed
+func (b *Batcher) Stop() {
+	b.mu.Lock()
+	if b.stopped {
+		b.mu.Unlock()
+		<-b.done
+		return
+	}
+	b.flushLocked()
+	b.stopped = true
+	close(b.inflight)
+	b.mu.Unlock()
+
+	<-b.done
+}
+
+func (b *Batcher) onWindow() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.timer = nil
+	if !b.stopped {
+		b.flushLocked()
+	}
+}
+
+// flushLocked hands the pending entries to Raft. Holding the lock while
+// queueing keeps batches in proposal order.
+func (b *Batcher) flushLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	if len(b.entries) == 0 && len(b.waiters) == 0 {
+		return
+	}
+
+	batch := &inflightBatch{
+		callbacks: b.callbacks,
+		waiters:   b.waiters,
+	}
+
+	if len(b.entries) > 0 {
+		batch.future, batch.err = b.node.propose(&LogEntry{
+			Type:           LogEntryHashChainBatch,
+			Timestamp:      time.Now(),
+			HashChainBatch: &HashChainBatchPayload{Entries: b.entries},
+		})
+	}
+
+	b.entries = nil
+	b.callbacks = nil
+	b.waiters = nil
+
+	b.inflight <- batch
+}
+
+// complete waits for batches in proposal order and reports their results
+func (b *Batcher) complete() {
+	defer close(b.done)
+
+	var pendingErr error
+
+	for batch := range b.inflight {
+		err := batch.err
+		var results []HashEntryResult
+
+		if err == nil && batch.future != nil {
+			var resp interface{}
+			resp, err = applyResponse(batch.future)
+			if err == nil {
+				var ok bool
+				results, ok = resp.([]HashEntryResult)
+				if !ok || len(results) != len(batch.callbacks) {
+					err = fmt.Errorf("unexpected FSM response: %T", resp)
+				}
+			}
+		}
+
+		for i, callback := range batch.callbacks {
+			if callback == nil {
+				continue
+			}
+			if err != nil {
+				callback(0, err)
+				continue
+			}
+			callback(results[i].SequenceNum, results[i].Err)
+		}
+
+		if err == nil {
+			for _, result := range results {
+				if result.Err != nil {
+					err = fmt.Errorf("hash entry rejected: %w", result.Err)
+					break
+				}
+			}
+		}
+		if err != nil && pendingErr == nil {
+			pendingErr = err
+		}
+		if len(batch.waiters) > 0 {
+			for _, fn := range batch.waiters {
+				fn(pendingErr)
+			}
+			pendingErr = nil
+		}
+	}
+}
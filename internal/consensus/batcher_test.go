@@ -0,0 +1,194 @@
+package consensus
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/witnz/witnz/internal/storage"
+)
+
+func TestBatcher(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-batcher-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	node, err := NewNode(&NodeConfig{
+		NodeID:    "node1",
+		BindAddr:  "127.0.0.1:17101",
+		DataDir:   t.TempDir(),
+		Bootstrap: true,
+	}, store)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start node: %v", err)
+	}
+	defer node.Stop()
+
+	for i := 0; i < 50 && !node.IsLeader(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !node.IsLeader() {
+		t.Fatal("Node did not become leader")
+	}
+
+	batcher := NewBatcher(node, 100, 50*time.Millisecond)
+	defer batcher.Stop()
+
+	newEntry := func(i int, hash string) *LogEntry {
+		return &LogEntry{
+			Type:      LogEntryHashChain,
+			TableName: "audit_log",
+			Timestamp: time.Now(),
+			HashChain: &HashChainPayload{
+				DataHash:      hash,
+				OperationType: "INSERT",
+				RecordID:      fmt.Sprintf("%d", i),
+				LSN:           uint64(1000 + i),
+			},
+		}
+	}
+
+	t.Run("AppliesInOrder", func(t *testing.T) {
+		const count = 250
+
+		var mu sync.Mutex
+		seqs := make([]uint64, count)
+		applied := make(chan error, 1)
+
+		for i := 0; i < count; i++ {
+			i := i
+			err := batcher.Propose(newEntry(i, fmt.Sprintf("hash%d", i)), func(seq uint64, err error) {
+				if err != nil {
+					t.Errorf("Entry %d failed: %v", i, err)
+				}
+				mu.Lock()
+				seqs[i] = seq
+				mu.Unlock()
+			})
+			if err != nil {
+				t.Fatalf("Propose failed: %v", err)
+			}
+		}
+		batcher.OnApplied(func(err error) { applied <- err })
+
+		select {
+		case err := <-applied:
+			if err != nil {
+				t.Fatalf("Batch failed: %v", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Timed out waiting for batches")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for i, seq := range seqs {
+			if seq != uint64(i+1) {
+				t.Fatalf("Expected entry %d at sequence %d, got %d", i, i+1, seq)
+			}
+		}
+
+		latest, err := store.GetLatestHashEntry("audit_log")
+		if err != nil {
+			t.Fatalf("GetLatestHashEntry failed: %v", err)
+		}
+		if latest.SequenceNum != count {
+			t.Errorf("Expected latest sequence %d, got %d", count, latest.SequenceNum)
+		}
+
+		// Three batches of at most 100 entries instead of one Raft entry per row
+		if index := node.raft.AppliedIndex(); index > 10 {
+			t.Errorf("Expected a handful of Raft entries, got applied index %d", index)
+		}
+	})
+
+	t.Run("RejectsConflictingEntryOnly", func(t *testing.T) {
+		results := make(chan error, 2)
+		callback := func(seq uint64, err error) { results <- err }
+
+		if err := batcher.Propose(newEntry(1, "tampered"), callback); err != nil {
+			t.Fatalf("Propose failed: %v", err)
+		}
+		if err := batcher.Propose(newEntry(500, "hash500"), callback); err != nil {
+			t.Fatalf("Propose failed: %v", err)
+		}
+
+		applied := make(chan error, 1)
+		batcher.OnApplied(func(err error) { applied <- err })
+
+		if err := <-results; err == nil {
+			t.Error("Expected conflicting entry to be rejected")
+		}
+		if err := <-results; err != nil {
+			t.Errorf("Expected entry after the conflict to apply, got %v", err)
+		}
+		if err := <-applied; err == nil {
+			t.Error("Expected OnApplied to report the rejected entry")
+		}
+
+		if err := batcher.Propose(newEntry(501, "hash501"), nil); err != nil {
+			t.Fatalf("Propose failed: %v", err)
+		}
+		batcher.OnApplied(func(err error) { applied <- err })
+		if err := <-applied; err != nil {
+			t.Errorf("Expected the next batch to apply cleanly, got %v", err)
+		}
+	})
+
+	t.Run("InvalidEntry", func(t *testing.T) {
+		entry := newEntry(600, "")
+		if err := batcher.Propose(entry, nil); err == nil {
+			t.Error("Expected entry without a data hash to be rejected")
+		}
+	})
+}
+
+func TestBatcherNotLeader(t *testing.T) {
+	node, err := NewNode(&NodeConfig{NodeID: "node1"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+
+	batcher := NewBatcher(node, 10, time.Millisecond)
+
+	failed := make(chan error, 1)
+	entry := &LogEntry{
+		Type:      LogEntryHashChain,
+		TableName: "audit_log",
+		HashChain: &HashChainPayload{DataHash: "h", OperationType: "INSERT", RecordID: "1"},
+	}
+	if err := batcher.Propose(entry, func(seq uint64, err error) { failed <- err }); err != nil {
+		t.Fatalf("Propose failed: %v", err)
+	}
+
+	applied := make(chan error, 1)
+	batcher.OnApplied(func(err error) { applied <- err })
+
+	if err := <-failed; err == nil {
+		t.Error("Expected proposal on a non-leader to fail")
+	}
+	if err := <-applied; err == nil {
+		t.Error("Expected OnApplied to report the failed batch")
+	}
+
+	batcher.Stop()
+	if err := batcher.Propose(entry, nil); err == nil {
+		t.Error("Expected Propose to fail after Stop")
+	}
+}
@@ -0,0 +1,259 @@
+package consensus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/raft"
+)
+
+const (
+	// catchUpPollInterval is how often a catching-up node compares its
+	// applied index with the target index
+	catchUpPollInterval = 200 * time.Millisecond
+
+	barrierTimeout = 30 * time.Second
+
+	// lagCheckInterval is how often a caught-up follower compares its
+	// applied index with the leader's commit index
+	lagCheckInterval = 5 * time.Second
+
+	// DefaultCatchUpLag is how many entries a follower may fall behind
+	// before it is considered to be catching up again
+	DefaultCatchUpLag = 1000
+)
+
+// CommitIndexReply carries the leader's commit index
+type CommitIndexReply struct {
+	Index uint64 `json:"index"`
+}
+
+// ClusterStatus reports the node's Raft role and how far its FSM is behind
+// the leader
+type ClusterStatus struct {
+	NodeID       string `json:"node_id"`
+	State        string `json:"state"`
+	Leader       string `json:"leader,omitempty"`
+	CaughtUp     bool   `json:"caught_up"`
+	AppliedIndex uint64 `json:"applied_index"`
+	TargetIndex  uint64 `json:"target_index"`
+}
+
+// Progress returns the applied fraction of the catch-up target
+func (s ClusterStatus) Progress() float64 {
+	if s.CaughtUp || s.TargetIndex == 0 {
+		return 1
+	}
+	if s.AppliedIndex >= s.TargetIndex {
+		return 1
+	}
+	return float64(s.AppliedIndex) / float64(s.TargetIndex)
+}
+
+// CaughtUp returns a channel that is closed once the node has applied every
+// entry the leader had committed when the node started. A follower that
+// falls behind later gets a new open channel until it has caught up again,
+// so callers should not keep the channel across waits.
+func (n *Node) CaughtUp() <-chan struct{} {
+	n.caughtUpMu.Lock()
+	defer n.caughtUpMu.Unlock()
+	return n.caughtUp
+}
+
+// IsCaughtUp reports whether the node has finished catching up
+func (n *Node) IsCaughtUp() bool {
+	select {
+	case <-n.CaughtUp():
+		return true
+	default:
+		return false
+	}
+}
+
+// WaitForCatchUp blocks until the node has caught up with the leader
+func (n *Node) WaitForCatchUp(ctx context.Context) error {
+	for {
+		select {
+		case <-n.CaughtUp():
+			if n.IsCaughtUp() {
+				return nil
+			}
+		case <-n.stopCh:
+			return fmt.Errorf("node stopped before catching up")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// markCaughtUp opens the catch-up gate
+func (n *Node) markCaughtUp() {
+	n.caughtUpMu.Lock()
+	defer n.caughtUpMu.Unlock()
+
+	select {
+	case <-n.caughtUp:
+	default:
+		close(n.caughtUp)
+	}
+}
+
+// fallBehind closes the catch-up gate again until the node has applied
+// target
+func (n *Node) fallBehind(target uint64) {
+	n.caughtUpMu.Lock()
+	defer n.caughtUpMu.Unlock()
+
+	n.catchUpTarget.Store(target)
+	select {
+	case <-n.caughtUp:
+		n.caughtUp = make(chan struct{})
+	default:
+	}
+}
+
+// ClusterStatus returns the node's Raft state and catch-up progress
+func (n *Node) ClusterStatus() ClusterStatus {
+	status := ClusterStatus{
+		NodeID:      n.config.NodeID,
+		State:       "not initialized",
+		CaughtUp:    n.IsCaughtUp(),
+		TargetIndex: n.catchUpTarget.Load(),
+	}
+
+	if n.raft != nil {
+		status.State = n.raft.State().String()
+		status.Leader = n.Leader()
+		status.AppliedIndex = n.raft.AppliedIndex()
+	}
+
+	return status
+}
+
+// trackCatchUp waits until the FSM has applied everything committed before
+// the node started. A leader commits a barrier, which returns once all
+// preceding entries are applied; a follower asks the leader for its commit
+// index and waits for its own applied index to reach it. Afterwards it
+// keeps checking that a follower stays within CatchUpLag of the leader.
+func (n *Node) trackCatchUp() {
+	ticker := time.NewTicker(catchUpPollInterval)
+	defer ticker.Stop()
+
+	var target uint64
+	for {
+		index, err := n.fetchCatchUpTarget()
+		if err == nil {
+			target = index
+			break
+		}
+		logger.Debug("Waiting for catch-up target", "error", err)
+
+		select {
+		case <-ticker.C:
+		case <-n.stopCh:
+			return
+		}
+	}
+
+	n.catchUpTarget.Store(target)
+	logger.Info("Catching up with leader",
+		"applied_index", n.raft.AppliedIndex(),
+		"target_index", target)
+
+	if !n.waitForApplied(target) {
+		return
+	}
+	logger.Info("Caught up with leader", "applied_index", n.raft.AppliedIndex())
+	n.markCaughtUp()
+
+	n.watchLag()
+}
+
+// waitForApplied polls until the FSM has applied index. It returns false
+// when the node is stopped first.
+func (n *Node) waitForApplied(index uint64) bool {
+	ticker := time.NewTicker(catchUpPollInterval)
+	defer ticker.Stop()
+
+	for n.raft.AppliedIndex() < index {
+		select {
+		case <-ticker.C:
+		case <-n.stopCh:
+			return false
+		}
+	}
+	return true
+}
+
+// watchLag closes the catch-up gate again when a follower falls more than
+// CatchUpLag entries behind the leader, e.g. after a partition or on a slow
+// disk, and reopens it once the follower has caught up
+func (n *Node) watchLag() {
+	ticker := time.NewTicker(lagCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-n.stopCh:
+			return
+		}
+
+		if n.raft.State() == raft.Leader {
+			continue
+		}
+		var reply CommitIndexReply
+		if err := n.callLeader("CommitIndex", &Empty{}, &reply); err != nil {
+			logger.Debug("Failed to check lag behind leader", "error", err)
+			continue
+		}
+
+		applied := n.raft.AppliedIndex()
+		if !n.behind(reply.Index, applied) {
+			continue
+		}
+
+		n.fallBehind(reply.Index)
+		logger.Warn("Fell behind the leader, holding back verification and WAL acks until caught up",
+			"applied_index", applied,
+			"target_index", reply.Index)
+
+		if !n.waitForApplied(reply.Index) {
+			return
+		}
+		logger.Info("Caught up with leader again", "applied_index", n.raft.AppliedIndex())
+		n.markCaughtUp()
+	}
+}
+
+// behind reports whether applied trails the leader's commit index by more
+// than CatchUpLag entries
+func (n *Node) behind(commitIndex, applied uint64) bool {
+	return commitIndex > applied && commitIndex-applied > n.config.catchUpLag()
+}
+
+func (n *Node) fetchCatchUpTarget() (uint64, error) {
+	if n.raft.State() == raft.Leader {
+		if err := n.raft.Barrier(barrierTimeout).Error(); err != nil {
+			return 0, fmt.Errorf("failed to commit barrier: %w", err)
+		}
+		return n.raft.AppliedIndex(), nil
+	}
+
+	var reply CommitIndexReply
+	if err := n.callLeader("CommitIndex", &Empty{}, &reply); err != nil {
+		return 0, err
+	}
+
+	return reply.Index, nil
+}
+
+// commitIndex returns the leader's commit index. Followers refuse so a
+// catching-up node never takes a stale target from another follower.
+func (n *Node) commitIndex() (uint64, error) {
+	if !n.IsLeader() {
+		return 0, fmt.Errorf("not the leader")
+	}
+	return n.raft.CommitIndex(), nil
+}
@@ -0,0 +1,132 @@
+package consensus
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/witnz/witnz/internal/storage"
+)
+
+func TestCatchUp(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-catchup-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	node, err := NewNode(&NodeConfig{
+		NodeID:    "node1",
+		BindAddr:  "127.0.0.1:17201",
+		DataDir:   t.TempDir(),
+		Bootstrap: true,
+	}, store)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+
+	if node.IsCaughtUp() {
+		t.Error("Expected node to be catching up before Start")
+	}
+
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start node: %v", err)
+	}
+	defer node.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := node.WaitForCatchUp(ctx); err != nil {
+		t.Fatalf("WaitForCatchUp failed: %v", err)
+	}
+
+	status := node.ClusterStatus()
+	if !status.CaughtUp || status.State != "Leader" {
+		t.Errorf("Expected caught-up leader, got %+v", status)
+	}
+	if status.AppliedIndex < status.TargetIndex {
+		t.Errorf("Expected applied index %d to reach target %d", status.AppliedIndex, status.TargetIndex)
+	}
+}
+
+func TestCatchUpStopped(t *testing.T) {
+	node, err := NewNode(&NodeConfig{NodeID: "node1"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+
+	if err := node.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	if err := node.WaitForCatchUp(context.Background()); err == nil {
+		t.Error("Expected WaitForCatchUp to fail on a stopped node")
+	}
+}
+
+func TestCatchUpRearm(t *testing.T) {
+	node, err := NewNode(&NodeConfig{NodeID: "node1", CatchUpLag: 100}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+
+	node.markCaughtUp()
+	first := node.CaughtUp()
+	if !node.IsCaughtUp() {
+		t.Fatal("Expected node to be caught up")
+	}
+
+	t.Run("Lag", func(t *testing.T) {
+		if node.behind(1100, 1000) {
+			t.Error("Expected a lag of 100 entries to be tolerated")
+		}
+		if !node.behind(1101, 1000) {
+			t.Error("Expected a lag of 101 entries to re-arm the gate")
+		}
+		if node.behind(900, 1000) {
+			t.Error("Expected a node ahead of the commit index not to be behind")
+		}
+	})
+
+	t.Run("FallBehind", func(t *testing.T) {
+		node.fallBehind(2000)
+		if node.IsCaughtUp() {
+			t.Fatal("Expected the gate to close after falling behind")
+		}
+		if status := node.ClusterStatus(); status.CaughtUp || status.TargetIndex != 2000 {
+			t.Errorf("Expected status behind target 2000, got %+v", status)
+		}
+		if !isClosedChan(first) {
+			t.Error("Expected a channel handed out earlier to stay closed")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := node.WaitForCatchUp(ctx); err == nil {
+			t.Error("Expected WaitForCatchUp to block while behind")
+		}
+
+		node.markCaughtUp()
+		if err := node.WaitForCatchUp(context.Background()); err != nil {
+			t.Errorf("WaitForCatchUp failed: %v", err)
+		}
+	})
+}
+
+func isClosedChan(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
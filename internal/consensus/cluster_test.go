@@ -3,9 +3,11 @@ package consensus
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/witnz/witnz/internal/signing"
 	"github.com/witnz/witnz/internal/storage"
 )
 
@@ -154,18 +156,22 @@ func TestThreeNodeCluster(t *testing.T) {
 	entry := &LogEntry{
 		Type:      LogEntryHashChain,
 		TableName: "test_table",
-		Data: map[string]interface{}{
-			"sequence_num":   float64(1),
-			"data_hash":      "test_hash_123",
-			"operation_type": "INSERT",
-			"record_id":      "1",
+		HashChain: &HashChainPayload{
+			DataHash:      "test_hash_123",
+			OperationType: "INSERT",
+			RecordID:      "1",
+			LSN:           100,
 		},
 		Timestamp: time.Now(),
 	}
 
-	if err := leaderNode.ApplyLog(entry); err != nil {
+	seq, err := leaderNode.ApplyHashEntry(entry)
+	if err != nil {
 		t.Fatalf("Failed to apply log: %v", err)
 	}
+	if seq != 1 {
+		t.Errorf("Expected assigned sequence 1, got %d", seq)
+	}
 
 	time.Sleep(2 * time.Second)
 
@@ -329,3 +335,142 @@ func TestClusterLeaderElection(t *testing.T) {
 		t.Errorf("Expected exactly 1 leader, got %d", leaderCount)
 	}
 }
+
+func TestSignedClusterCheckpointQuorum(t *testing.T) {
+	ids := []string{"node1", "node2", "node3"}
+	addrs := map[string]string{
+		"node1": "127.0.0.1:19001",
+		"node2": "127.0.0.1:19002",
+		"node3": "127.0.0.1:19003",
+	}
+
+	signers := make(map[string]*signing.Signer)
+	publicKeys := make(map[string]string)
+	for _, id := range ids {
+		key, err := signing.LoadOrCreateKey(filepath.Join(t.TempDir(), "node.key"))
+		if err != nil {
+			t.Fatalf("Failed to create key for %s: %v", id, err)
+		}
+		signers[id] = signing.NewSigner(id, key)
+		publicKeys[id] = signers[id].PublicKey()
+	}
+
+	keyRing, err := signing.NewKeyRing(publicKeys)
+	if err != nil {
+		t.Fatalf("Failed to create key ring: %v", err)
+	}
+
+	nodes := make(map[string]*Node)
+	stores := make(map[string]*storage.Storage)
+	ctx := context.Background()
+
+	for i, id := range ids {
+		store, err := storage.New(filepath.Join(t.TempDir(), "witnz.db"))
+		if err != nil {
+			t.Fatalf("Failed to create storage for %s: %v", id, err)
+		}
+		defer store.Close()
+		stores[id] = store
+
+		peers := make(map[string]string)
+		for _, peer := range ids {
+			if peer != id {
+				peers[peer] = addrs[peer]
+			}
+		}
+
+		node, err := NewNode(&NodeConfig{
+			NodeID:    id,
+			BindAddr:  addrs[id],
+			DataDir:   t.TempDir(),
+			Bootstrap: i == 0,
+			PeerAddrs: peers,
+			Signer:    signers[id],
+			KeyRing:   keyRing,
+		}, store)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", id, err)
+		}
+		nodes[id] = node
+	}
+
+	if err := nodes["node1"].Start(ctx); err != nil {
+		t.Fatalf("Failed to start node1: %v", err)
+	}
+	defer nodes["node1"].Stop()
+
+	time.Sleep(2 * time.Second)
+
+	for _, id := range ids[1:] {
+		if err := nodes[id].Start(ctx); err != nil {
+			t.Fatalf("Failed to start %s: %v", id, err)
+		}
+		defer nodes[id].Stop()
+	}
+
+	time.Sleep(3 * time.Second)
+
+	var leaderID string
+	for _, id := range ids {
+		if nodes[id].IsLeader() {
+			leaderID = id
+		}
+	}
+	if leaderID == "" {
+		t.Fatal("No leader node found")
+	}
+
+	checkpoint := &storage.MerkleCheckpoint{
+		TableName:     "test_table",
+		SequenceNum:   5,
+		MerkleRoot:    "root_abc",
+		Timestamp:     time.Now(),
+		RecordCount:   5,
+		HashAlgorithm: "sha256",
+	}
+	checkpoint.Signatures = map[string]string{
+		leaderID: signers[leaderID].Sign(checkpoint.SigningPayload()),
+	}
+
+	if err := nodes[leaderID].ApplyCheckpoint(checkpoint); err != nil {
+		t.Fatalf("Failed to apply checkpoint: %v", err)
+	}
+
+	time.Sleep(time.Second)
+
+	for _, id := range ids {
+		if id == leaderID {
+			continue
+		}
+
+		sig := &storage.CheckpointSignature{
+			TableName:   checkpoint.TableName,
+			SequenceNum: checkpoint.SequenceNum,
+			MerkleRoot:  checkpoint.MerkleRoot,
+			NodeID:      id,
+			Signature:   signers[id].Sign(checkpoint.SigningPayload()),
+		}
+		if err := nodes[id].SubmitCheckpointSignature(sig); err != nil {
+			t.Fatalf("Follower %s failed to submit signature via cluster RPC: %v", id, err)
+		}
+
+		forged := *sig
+		forged.MerkleRoot = "forged_root"
+		if err := nodes[id].SubmitCheckpointSignature(&forged); err == nil {
+			t.Errorf("Expected signature over a different root to be rejected")
+		}
+		break
+	}
+
+	time.Sleep(time.Second)
+
+	for _, id := range ids {
+		stored, err := stores[id].GetMerkleCheckpoint("test_table", 5)
+		if err != nil {
+			t.Fatalf("Failed to get checkpoint from %s: %v", id, err)
+		}
+		if valid := keyRing.CountValid(stored.SigningPayload(), stored.Signatures); valid < keyRing.Quorum() {
+			t.Errorf("%s: expected quorum of %d signatures, got %d", id, keyRing.Quorum(), valid)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package consensus
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-msgpack/v2/codec"
+	"github.com/witnz/witnz/internal/discovery"
+	"github.com/witnz/witnz/internal/signing"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+// formatMsgpack prefixes log entries encoded as a msgpack SignedLogEntry.
+// Entries written by older versions are JSON and always start with '{'.
+const formatMsgpack byte = 0x01
+
+var msgpackHandle = &codec.MsgpackHandle{
+	WriteExt: true,
+}
+
+func encodeMsgpack(v interface{}) ([]byte, error) {
+	var out []byte
+	if err := codec.NewEncoderBytes(&out, msgpackHandle).Encode(v); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func decodeMsgpack(data []byte, v interface{}) error {
+	return codec.NewDecoderBytes(data, msgpackHandle).Decode(v)
+}
+
+// marshalLogEntry encodes an entry for the Raft log, signing it when a
+// signer is given
+func marshalLogEntry(entry *LogEntry, signer *signing.Signer) ([]byte, error) {
+	if entry.Version == 0 {
+		entry.Version = LogEntryVersion
+	}
+	if err := entry.Validate(); err != nil {
+		return nil, err
+	}
+
+	payload, err := encodeMsgpack(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal log entry: %w", err)
+	}
+
+	envelope := &SignedLogEntry{Payload: payload}
+	if signer != nil {
+		envelope.Signer = signer.NodeID()
+		envelope.Signature = signer.Sign(payload)
+	}
+
+	data, err := encodeMsgpack(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signed log entry: %w", err)
+	}
+
+	return append([]byte{formatMsgpack}, data...), nil
+}
+
+// unmarshalLogEntry decodes an entry from the Raft log, verifying its
+// signature when a key ring is given. Unsigned entries are rejected once a
+// key ring is configured.
+func unmarshalLogEntry(data []byte, keyRing *signing.KeyRing) (*LogEntry, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty log entry")
+	}
+
+	if data[0] != formatMsgpack {
+		return unmarshalLegacyLogEntry(data, keyRing)
+	}
+
+	var envelope SignedLogEntry
+	if err := decodeMsgpack(data[1:], &envelope); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal signed log entry: %w", err)
+	}
+
+	if err := verifyEnvelope(envelope.Payload, envelope.Signer, envelope.Signature, keyRing); err != nil {
+		return nil, err
+	}
+
+	var entry LogEntry
+	if err := decodeMsgpack(envelope.Payload, &entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal log entry: %w", err)
+	}
+
+	return &entry, nil
+}
+
+func verifyEnvelope(payload []byte, signer, signature string, keyRing *signing.KeyRing) error {
+	if keyRing == nil {
+		return nil
+	}
+	if signer == "" {
+		return fmt.Errorf("unsigned log entry")
+	}
+	if err := keyRing.Verify(signer, payload, signature); err != nil {
+		return fmt.Errorf("log entry signature check failed: %w", err)
+	}
+	return nil
+}
+
+// validPattern checks a table pattern, which may be qualified by a source
+func validPattern(pattern string) error {
+	_, unqualified := storage.SplitTable(pattern)
+	_, err := discovery.ParsePattern(unqualified)
+	return err
+}
+
+// Validate checks that the entry carries the payload its type requires
+func (e *LogEntry) Validate() error {
+	if e.Version < 1 || e.Version > LogEntryVersion {
+		return fmt.Errorf("unsupported log entry version %d", e.Version)
+	}
+
+	switch e.Type {
+	case LogEntryHashChain:
+		p := e.HashChain
+		if p == nil {
+			return fmt.Errorf("hash chain entry without payload")
+		}
+		if e.TableName == "" || p.RecordID == "" || p.OperationType == "" {
+			return fmt.Errorf("hash chain entry requires table, record ID and operation")
+		}
+		if p.DataHash == "" && p.AllowanceID == "" {
+			return fmt.Errorf("hash chain entry for %s record %s has no data hash", e.TableName, p.RecordID)
+		}
+		if len(p.RowImage) > 0 && p.RowImageID == "" {
+			return fmt.Errorf("row image without record ID")
+		}
+	case LogEntryHashChainBatch:
+		b := e.HashChainBatch
+		if b == nil || len(b.Entries) == 0 {
+			return fmt.Errorf("hash chain batch without entries")
+		}
+		for i, child := range b.Entries {
+			if child == nil || child.Type != LogEntryHashChain {
+				return fmt.Errorf("batch entry %d is not a hash chain entry", i)
+			}
+			if err := child.Validate(); err != nil {
+				return fmt.Errorf("batch entry %d: %w", i, err)
+			}
+			if child.HashChain.SequenceNum != 0 {
+				return fmt.Errorf("batch entry %d carries a sequence number", i)
+			}
+		}
+	case LogEntryCheckpoint:
+		c := e.Checkpoint
+		if c == nil {
+			return fmt.Errorf("checkpoint entry without payload")
+		}
+		if c.TableName == "" || c.MerkleRoot == "" {
+			return fmt.Errorf("checkpoint entry requires table and Merkle root")
+		}
+	case LogEntryCheckpointSignature:
+		s := e.CheckpointSignature
+		if s == nil {
+			return fmt.Errorf("checkpoint signature entry without payload")
+		}
+		if s.TableName == "" || s.MerkleRoot == "" || s.NodeID == "" || s.Signature == "" {
+			return fmt.Errorf("incomplete checkpoint signature")
+		}
+	case LogEntryFinding:
+		if e.Finding == nil || e.Finding.ID == "" {
+			return fmt.Errorf("finding entry without finding ID")
+		}
+	case LogEntryFindingStatus:
+		s := e.FindingStatus
+		if s == nil || s.ID == "" {
+			return fmt.Errorf("finding status entry without finding ID")
+		}
+		if s.Status != storage.FindingAcknowledged && s.Status != storage.FindingResolved {
+			return fmt.Errorf("invalid finding status %q", s.Status)
+		}
+	case LogEntryAllowance:
+		if e.Allowance == nil || e.Allowance.ID == "" {
+			return fmt.Errorf("allowance entry without allowance ID")
+		}
+	case LogEntryTableSet:
+		if e.TableSet == nil {
+			return fmt.Errorf("table set entry without payload")
+		}
+		seen := make(map[string]bool)
+		for _, table := range e.TableSet.Tables {
+			if !storage.ValidTableName(table.Name) {
+				return fmt.Errorf("invalid table name in table set: %q", table.Name)
+			}
+			if seen[table.Name] {
+				return fmt.Errorf("duplicate table in table set: %s", table.Name)
+			}
+			seen[table.Name] = true
+		}
+		for _, pattern := range e.TableSet.Patterns {
+			if err := validPattern(pattern); err != nil {
+				return err
+			}
+		}
+	case LogEntryClusterPolicy:
+		p := e.ClusterPolicy
+		if p == nil {
+			return fmt.Errorf("cluster policy entry without payload")
+		}
+		if p.HashAlgorithm == "" || p.EncodingVersion < 1 {
+			return fmt.Errorf("cluster policy requires hash algorithm and encoding version")
+		}
+		for _, name := range p.Tables {
+			if !storage.ValidTableName(name) {
+				return fmt.Errorf("invalid table name in cluster policy: %q", name)
+			}
+		}
+		for _, pattern := range p.Patterns {
+			if err := validPattern(pattern); err != nil {
+				return err
+			}
+		}
+	case LogEntryDatabaseIdentity:
+		id := e.DatabaseIdentity
+		if id == nil || id.SystemID == "" {
+			return fmt.Errorf("database identity entry without system identifier")
+		}
+	default:
+		return fmt.Errorf("unknown log entry type: %s", e.Type)
+	}
+
+	return nil
+}
+
+// legacyLogEntry is the JSON format written before typed payloads
+type legacyLogEntry struct {
+	Type      LogEntryType           `json:"type"`
+	TableName string                 `json:"table_name"`
+	Data      map[string]interface{} `json:"data"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+type legacySignedLogEntry struct {
+	Payload   json.RawMessage `json:"payload"`
+	Signer    string          `json:"signer"`
+	Signature string          `json:"signature"`
+}
+
+func unmarshalLegacyLogEntry(data []byte, keyRing *signing.KeyRing) (*LogEntry, error) {
+	var envelope legacySignedLogEntry
+	if err := json.Unmarshal(data, &envelope); err == nil && len(envelope.Payload) > 0 {
+		if err := verifyEnvelope(envelope.Payload, envelope.Signer, envelope.Signature, keyRing); err != nil {
+			return nil, err
+		}
+		data = envelope.Payload
+	} else if err := verifyEnvelope(nil, "", "", keyRing); err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var legacy legacyLogEntry
+	if err := decoder.Decode(&legacy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal log entry: %w", err)
+	}
+
+	return legacy.upgrade()
+}
+
+// upgrade converts a legacy entry to its typed form
+func (l *legacyLogEntry) upgrade() (*LogEntry, error) {
+	entry := &LogEntry{
+		Version:   1,
+		Type:      l.Type,
+		TableName: l.TableName,
+		Timestamp: l.Timestamp,
+	}
+
+	d := legacyData(l.Data)
+	var err error
+
+	switch l.Type {
+	case LogEntryHashChain:
+		p := &HashChainPayload{
+			DataHash:      d.string("data_hash"),
+			OperationType: d.string("operation_type"),
+			RecordID:      d.string("record_id"),
+			AllowanceID:   d.string("allowance_id"),
+			RowImageID:    d.string("row_image_id"),
+		}
+		if p.SequenceNum, err = d.uint("sequence_num"); err != nil {
+			return nil, err
+		}
+		if p.LSN, err = d.uint("lsn"); err != nil {
+			return nil, err
+		}
+		if encoded := d.string("row_image"); encoded != "" {
+			if p.RowImage, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+				return nil, fmt.Errorf("failed to decode row image: %w", err)
+			}
+		}
+		entry.HashChain = p
+	case LogEntryCheckpoint:
+		c := &storage.MerkleCheckpoint{
+			TableName:     l.TableName,
+			Timestamp:     l.Timestamp,
+			MerkleRoot:    d.string("merkle_root"),
+			HashAlgorithm: d.string("hash_algorithm"),
+			LeafMap:       d.stringMap("leaf_map"),
+			InternalNodes: d.stringMap("internal_nodes"),
+			Signatures:    d.stringMap("signatures"),
+		}
+		if c.SequenceNum, err = d.uint("sequence_num"); err != nil {
+			return nil, err
+		}
+		count, err := d.uint("record_count")
+		if err != nil {
+			return nil, err
+		}
+		c.RecordCount = int(count)
+		entry.Checkpoint = c
+	case LogEntryCheckpointSignature:
+		entry.CheckpointSignature = &storage.CheckpointSignature{}
+		err = d.object("signature", entry.CheckpointSignature)
+	case LogEntryFinding:
+		entry.Finding = &storage.Finding{}
+		err = d.object("finding", entry.Finding)
+	case LogEntryFindingStatus:
+		entry.FindingStatus = &FindingStatusPayload{
+			ID:     d.string("id"),
+			Status: storage.FindingStatus(d.string("status")),
+			Actor:  d.string("actor"),
+			Note:   d.string("note"),
+		}
+	case LogEntryAllowance:
+		entry.Allowance = &storage.Allowance{}
+		err = d.object("allowance", entry.Allowance)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// legacyData reads fields of a legacy entry without unchecked assertions
+type legacyData map[string]interface{}
+
+func (d legacyData) string(key string) string {
+	s, _ := d[key].(string)
+	return s
+}
+
+// uint accepts JSON numbers and decimal strings, which legacy entries used
+// for LSNs
+func (d legacyData) uint(key string) (uint64, error) {
+	switch v := d[key].(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		n, err := strconv.ParseUint(v.String(), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		return n, nil
+	case string:
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("invalid %s: unexpected %T", key, v)
+	}
+}
+
+func (d legacyData) stringMap(key string) map[string]string {
+	raw, ok := d[key].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	out := make(map[string]string, len(raw))
+	for k, v := range raw {
+		if s, ok := v.(string); ok {
+			out[k] = s
+		}
+	}
+	return out
+}
+
+func (d legacyData) object(key string, out interface{}) error {
+	raw, err := json.Marshal(d[key])
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", key, err)
+	}
+	return nil
+}
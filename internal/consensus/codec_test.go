@@ -0,0 +1,207 @@
+package consensus
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/witnz/witnz/internal/signing"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+func TestLogEntryCodec(t *testing.T) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		entry := &LogEntry{
+			Type:      LogEntryHashChain,
+			TableName: "audit_log",
+			Timestamp: time.Date(2025, 1, 1, 12, 0, 0, 123, time.UTC),
+			HashChain: &HashChainPayload{
+				DataHash:      "abc",
+				OperationType: "INSERT",
+				RecordID:      "map[id:1]",
+				LSN:           1<<63 + 1,
+				RowImage:      []byte{0x00, 0x01, 0xff},
+				RowImageID:    "1",
+			},
+		}
+
+		data, err := marshalLogEntry(entry, nil)
+		if err != nil {
+			t.Fatalf("marshalLogEntry failed: %v", err)
+		}
+		if data[0] != formatMsgpack {
+			t.Errorf("Expected format byte %#x, got %#x", formatMsgpack, data[0])
+		}
+
+		decoded, err := unmarshalLogEntry(data, nil)
+		if err != nil {
+			t.Fatalf("unmarshalLogEntry failed: %v", err)
+		}
+		if decoded.Version != LogEntryVersion {
+			t.Errorf("Expected version %d, got %d", LogEntryVersion, decoded.Version)
+		}
+		if decoded.HashChain.LSN != 1<<63+1 {
+			t.Errorf("Expected LSN %d, got %d", uint64(1<<63+1), decoded.HashChain.LSN)
+		}
+		if string(decoded.HashChain.RowImage) != string(entry.HashChain.RowImage) {
+			t.Errorf("Expected row image to round-trip, got %x", decoded.HashChain.RowImage)
+		}
+		if !decoded.Timestamp.Equal(entry.Timestamp) {
+			t.Errorf("Expected timestamp %v, got %v", entry.Timestamp, decoded.Timestamp)
+		}
+	})
+
+	t.Run("LegacyJSON", func(t *testing.T) {
+		legacy := []byte(`{"type":"checkpoint","table_name":"audit_log","timestamp":"2025-01-01T00:00:00Z",
+			"data":{"sequence_num":12,"merkle_root":"root","record_count":12,"hash_algorithm":"sha256",
+			"leaf_map":{"1":"h1"},"signatures":{"node1":"sig"}}}`)
+
+		entry, err := unmarshalLogEntry(legacy, nil)
+		if err != nil {
+			t.Fatalf("unmarshalLogEntry failed: %v", err)
+		}
+		if err := entry.Validate(); err != nil {
+			t.Fatalf("Validate failed: %v", err)
+		}
+		c := entry.Checkpoint
+		if c.SequenceNum != 12 || c.MerkleRoot != "root" || c.LeafMap["1"] != "h1" || c.Signatures["node1"] != "sig" {
+			t.Errorf("Unexpected legacy checkpoint: %+v", c)
+		}
+	})
+
+	t.Run("LegacyHashChain", func(t *testing.T) {
+		legacy := []byte(`{"type":"hash_chain","table_name":"audit_log","timestamp":"2025-01-01T00:00:00Z",
+			"data":{"sequence_num":3,"data_hash":"h","operation_type":"INSERT","record_id":"1","lsn":"18446744073709551615","row_image":"AAH/","row_image_id":"1"}}`)
+
+		entry, err := unmarshalLogEntry(legacy, nil)
+		if err != nil {
+			t.Fatalf("unmarshalLogEntry failed: %v", err)
+		}
+		p := entry.HashChain
+		if p.SequenceNum != 3 || p.LSN != 18446744073709551615 || len(p.RowImage) != 3 {
+			t.Errorf("Unexpected legacy hash chain payload: %+v", p)
+		}
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		cases := map[string][]byte{
+			"Empty":             {},
+			"Garbage":           {formatMsgpack, 0xc1, 0xff},
+			"NotJSON":           []byte("{not json"),
+			"BadSequence":       []byte(`{"type":"hash_chain","table_name":"t","data":{"sequence_num":"x","data_hash":"h","operation_type":"INSERT","record_id":"1"}}`),
+			"NegativeSequence":  []byte(`{"type":"checkpoint","table_name":"t","data":{"sequence_num":-1,"merkle_root":"r"}}`),
+			"WrongFindingShape": []byte(`{"type":"finding","data":{"finding":"oops"}}`),
+		}
+
+		for name, data := range cases {
+			if _, err := unmarshalLogEntry(data, nil); err == nil {
+				t.Errorf("%s: expected decode error", name)
+			}
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		cases := map[string]*LogEntry{
+			"UnknownType":    {Version: 1, Type: "bogus"},
+			"FutureVersion":  {Version: LogEntryVersion + 1, Type: LogEntryFinding, Finding: &storage.Finding{ID: "f"}},
+			"MissingPayload": {Version: 1, Type: LogEntryHashChain, TableName: "t"},
+			"MissingHash":    {Version: 1, Type: LogEntryHashChain, TableName: "t", HashChain: &HashChainPayload{RecordID: "1", OperationType: "INSERT"}},
+			"MissingRoot":    {Version: 1, Type: LogEntryCheckpoint, Checkpoint: &storage.MerkleCheckpoint{TableName: "t"}},
+			"EmptyBatch":     {Version: 1, Type: LogEntryHashChainBatch, HashChainBatch: &HashChainBatchPayload{}},
+			"BatchWithSequence": {Version: 1, Type: LogEntryHashChainBatch, HashChainBatch: &HashChainBatchPayload{Entries: []*LogEntry{
+				{Version: 1, Type: LogEntryHashChain, TableName: "t", HashChain: &HashChainPayload{SequenceNum: 1, DataHash: "h", OperationType: "INSERT", RecordID: "1"}},
+			}}},
+			"BadFindingStatus":     {Version: 1, Type: LogEntryFindingStatus, FindingStatus: &FindingStatusPayload{ID: "f", Status: storage.FindingOpen}},
+			"MissingTableSet":      {Version: 1, Type: LogEntryTableSet},
+			"InvalidTableName":     {Version: 1, Type: LogEntryTableSet, TableSet: &TableSetPayload{Tables: []storage.ProtectedTable{{Name: "users; DROP"}}}},
+			"DuplicateTable":       {Version: 1, Type: LogEntryTableSet, TableSet: &TableSetPayload{Tables: []storage.ProtectedTable{{Name: "t"}, {Name: "t"}}}},
+			"InvalidPattern":       {Version: 1, Type: LogEntryTableSet, TableSet: &TableSetPayload{Patterns: []string{"a.b.c"}}},
+			"InvalidSource":        {Version: 1, Type: LogEntryTableSet, TableSet: &TableSetPayload{Tables: []storage.ProtectedTable{{Name: "Billing/audit_log"}}}},
+			"InvalidSourcePattern": {Version: 1, Type: LogEntryTableSet, TableSet: &TableSetPayload{Patterns: []string{"billing/a.b.c"}}},
+			"PolicyNoVersion":      {Version: 1, Type: LogEntryClusterPolicy, ClusterPolicy: &storage.ClusterPolicy{HashAlgorithm: "sha256"}},
+			"PolicyBadTable":       {Version: 1, Type: LogEntryClusterPolicy, ClusterPolicy: &storage.ClusterPolicy{HashAlgorithm: "sha256", EncodingVersion: 1, Tables: []string{"a b"}}},
+			"IdentityNoSystemID":   {Version: 1, Type: LogEntryDatabaseIdentity, DatabaseIdentity: &storage.DatabaseIdentity{Timeline: 1}},
+		}
+
+		for name, entry := range cases {
+			if err := entry.Validate(); err == nil {
+				t.Errorf("%s: expected validation error", name)
+			}
+		}
+	})
+
+	t.Run("ValidateSourceTables", func(t *testing.T) {
+		entry := &LogEntry{Version: 1, Type: LogEntryTableSet, TableSet: &TableSetPayload{
+			Tables:   []storage.ProtectedTable{{Name: "audit_log"}, {Name: "billing/audit_log", Pattern: "billing/*_log"}},
+			Patterns: []string{"*_log", "billing/*_log"},
+		}}
+		if err := entry.Validate(); err != nil {
+			t.Errorf("Expected source-qualified tables to validate, got %v", err)
+		}
+	})
+}
+
+func TestFSMRejectsMalformedEntries(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-consensus-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	fsm := NewFSM(store)
+
+	// A bad entry is rejected on its own and later entries still apply
+	legacy := []byte(`{"type":"checkpoint","table_name":"t","data":{"sequence_num":1}}`)
+	if _, ok := fsm.Apply(&raft.Log{Data: legacy}).(error); !ok {
+		t.Error("Expected malformed checkpoint to be rejected")
+	}
+
+	valid := []byte(`{"type":"hash_chain","table_name":"t","timestamp":"2025-01-01T00:00:00Z","data":{"sequence_num":1,"data_hash":"h","operation_type":"INSERT","record_id":"1"}}`)
+	if err, ok := fsm.Apply(&raft.Log{Data: valid}).(error); ok {
+		t.Fatalf("Apply failed for legacy entry: %v", err)
+	}
+	if _, err := store.GetHashEntry("t", 1); err != nil {
+		t.Errorf("Expected legacy entry to be applied: %v", err)
+	}
+}
+
+func TestLegacySignedEntry(t *testing.T) {
+	key, err := signing.LoadOrCreateKey(filepath.Join(t.TempDir(), "node.key"))
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	signer := signing.NewSigner("node1", key)
+	ring, err := signing.NewKeyRing(map[string]string{"node1": signer.PublicKey()})
+	if err != nil {
+		t.Fatalf("Failed to create key ring: %v", err)
+	}
+
+	payload := []byte(`{"type":"finding","table_name":"t","timestamp":"2025-01-01T00:00:00Z","data":{"finding":{"id":"f-1","table_name":"t","kind":"delete"}}}`)
+	signed, _ := json.Marshal(map[string]interface{}{
+		"payload":   json.RawMessage(payload),
+		"signer":    "node1",
+		"signature": signer.Sign(payload),
+	})
+
+	entry, err := unmarshalLogEntry(signed, ring)
+	if err != nil {
+		t.Fatalf("unmarshalLogEntry failed: %v", err)
+	}
+	if entry.Finding == nil || entry.Finding.ID != "f-1" {
+		t.Errorf("Unexpected legacy finding: %+v", entry.Finding)
+	}
+
+	if _, err := unmarshalLogEntry(payload, ring); err == nil {
+		t.Error("Expected unsigned legacy entry to be rejected when a key ring is set")
+	}
+}
@@ -0,0 +1,95 @@
+package consensus
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// DefaultLeaderLossTimeout is how long a node may go without a known leader
+// before FollowerFence fences it
+const DefaultLeaderLossTimeout = 30 * time.Second
+
+// FollowerFence watches for a node that has lost contact with the leader.
+// Once no leader has been known for longer than the timeout, onFence is
+// called once and the fence stops watching. The caller is expected to stop
+// CDC processing and shut the node down, so a partitioned node neither keeps
+// acknowledging WAL nor reports findings from a stale hash chain.
+type FollowerFence struct {
+	node    *Node
+	timeout time.Duration
+	onFence func(lost time.Duration)
+	stopCh  chan struct{}
+	logger  *slog.Logger
+}
+
+func NewFollowerFence(node *Node, timeout time.Duration, onFence func(lost time.Duration), logger *slog.Logger) *FollowerFence {
+	if timeout <= 0 {
+		timeout = DefaultLeaderLossTimeout
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &FollowerFence{
+		node:    node,
+		timeout: timeout,
+		onFence: onFence,
+		stopCh:  make(chan struct{}),
+		logger:  logger,
+	}
+}
+
+// Start watches the node until it is fenced, Stop is called or ctx is done
+func (f *FollowerFence) Start(ctx context.Context) error {
+	if f.node.raft == nil {
+		return fmt.Errorf("raft not initialized")
+	}
+
+	ticker := time.NewTicker(f.timeout / 10)
+	defer ticker.Stop()
+
+	var lostSince time.Time
+
+	for {
+		select {
+		case <-ticker.C:
+			if f.hasLeader() {
+				if !lostSince.IsZero() {
+					f.logger.Info("Leader contact restored", "lost_for", time.Since(lostSince))
+				}
+				lostSince = time.Time{}
+				continue
+			}
+
+			if lostSince.IsZero() {
+				lostSince = time.Now()
+				f.logger.Warn("Lost contact with the leader", "fence_after", f.timeout)
+				continue
+			}
+
+			if lost := time.Since(lostSince); lost >= f.timeout {
+				f.logger.Error("No leader for too long, fencing this node", "lost_for", lost)
+				f.onFence(lost)
+				return nil
+			}
+		case <-f.stopCh:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (f *FollowerFence) Stop() {
+	close(f.stopCh)
+}
+
+func (f *FollowerFence) hasLeader() bool {
+	if f.node.IsLeader() {
+		return true
+	}
+	addr, _ := f.node.raft.LeaderWithID()
+	return addr != ""
+}
@@ -0,0 +1,82 @@
+package consensus
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/witnz/witnz/internal/storage"
+)
+
+func TestFollowerFence(t *testing.T) {
+	newNode := func(t *testing.T, addr string, bootstrap bool) *Node {
+		tmpfile, err := os.CreateTemp("", "witnz-fence-test-*.db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpfile.Close()
+		t.Cleanup(func() { os.Remove(tmpfile.Name()) })
+
+		store, err := storage.New(tmpfile.Name())
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+
+		node, err := NewNode(&NodeConfig{
+			NodeID:    "node1",
+			BindAddr:  addr,
+			DataDir:   t.TempDir(),
+			Bootstrap: bootstrap,
+		}, store)
+		if err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+		if err := node.Start(context.Background()); err != nil {
+			t.Fatalf("Failed to start node: %v", err)
+		}
+		t.Cleanup(func() { node.Stop() })
+
+		return node
+	}
+
+	t.Run("FencesWithoutLeader", func(t *testing.T) {
+		node := newNode(t, "127.0.0.1:17301", false)
+
+		fenced := make(chan time.Duration, 1)
+		fence := NewFollowerFence(node, 300*time.Millisecond, func(lost time.Duration) { fenced <- lost }, nil)
+
+		done := make(chan error, 1)
+		go func() { done <- fence.Start(context.Background()) }()
+
+		select {
+		case lost := <-fenced:
+			if lost < 300*time.Millisecond {
+				t.Errorf("Expected fence after 300ms without a leader, got %v", lost)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected node without a leader to be fenced")
+		}
+
+		if err := <-done; err != nil {
+			t.Errorf("Expected fence to stop cleanly, got %v", err)
+		}
+	})
+
+	t.Run("KeepsNodeWithLeader", func(t *testing.T) {
+		node := newNode(t, "127.0.0.1:17302", true)
+
+		fenced := make(chan time.Duration, 1)
+		fence := NewFollowerFence(node, 2*time.Second, func(lost time.Duration) { fenced <- lost }, nil)
+
+		go fence.Start(context.Background())
+		defer fence.Stop()
+
+		select {
+		case lost := <-fenced:
+			t.Errorf("Expected node with a leader not to be fenced, fenced after %v", lost)
+		case <-time.After(4 * time.Second):
+		}
+	})
+}
@@ -0,0 +1,290 @@
+package consensus
+
+import (
+	"errors"
+	"fmt"
+	"net/rpc"
+	"sync"
+	"time"
+)
+
+// maxForwardAttempts bounds how often a batch is resent while the leader is
+// unreachable or changing
+const maxForwardAttempts = 5
+
+// ForwardedEntries carries hash chain entries a follower observed on its own
+// CDC stream. Payload is the msgpack encoding of the entries, signed by the
+// forwarding node when signing is enabled.
+type ForwardedEntries struct {
+	NodeID    string `json:"node_id"`
+	Payload   []byte `json:"payload"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// ForwardReply reports how many forwarded entries the leader proposed; the
+// rest were already recorded
+type ForwardReply struct {
+	Proposed int `json:"proposed"`
+}
+
+// ForwardHandler proposes hash entries forwarded by followers. It returns
+// once the entries are applied and reports how many it proposed.
+type ForwardHandler interface {
+	HandleForwarded(from string, entries []*LogEntry) (int, error)
+}
+
+// SetForwardHandler sets the handler the leader uses for forwarded entries
+func (n *Node) SetForwardHandler(h ForwardHandler) {
+	n.forwardHandler = h
+}
+
+// ForwardHashEntries sends hash chain entries to the leader so they are
+// recorded even if the leader's own CDC stream missed them
+func (n *Node) ForwardHashEntries(entries []*LogEntry) (int, error) {
+	for _, entry := range entries {
+		if entry.Version == 0 {
+			entry.Version = LogEntryVersion
+		}
+	}
+
+	payload, err := encodeMsgpack(entries)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal forwarded entries: %w", err)
+	}
+
+	args := &ForwardedEntries{
+		NodeID:  n.config.NodeID,
+		Payload: payload,
+	}
+	if n.config.Signer != nil {
+		args.Signature = n.config.Signer.Sign(payload)
+	}
+
+	var reply ForwardReply
+	if err := n.callLeader("ForwardHashEntries", args, &reply); err != nil {
+		return 0, err
+	}
+
+	return reply.Proposed, nil
+}
+
+func (n *Node) receiveForwarded(args *ForwardedEntries) (int, error) {
+	if !n.IsLeader() {
+		return 0, fmt.Errorf("not the leader")
+	}
+	if n.forwardHandler == nil {
+		return 0, fmt.Errorf("forwarding not enabled on leader")
+	}
+
+	if n.config.KeyRing != nil {
+		if err := n.config.KeyRing.Verify(args.NodeID, args.Payload, args.Signature); err != nil {
+			return 0, fmt.Errorf("rejected forwarded entries: %w", err)
+		}
+	}
+
+	var entries []*LogEntry
+	if err := decodeMsgpack(args.Payload, &entries); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal forwarded entries: %w", err)
+	}
+
+	for i, entry := range entries {
+		if entry == nil || entry.Type != LogEntryHashChain {
+			return 0, fmt.Errorf("forwarded entry %d is not a hash chain entry", i)
+		}
+		if err := entry.Validate(); err != nil {
+			return 0, fmt.Errorf("forwarded entry %d: %w", i, err)
+		}
+		if entry.HashChain.SequenceNum != 0 {
+			return 0, fmt.Errorf("forwarded entry %d carries a sequence number", i)
+		}
+	}
+
+	return n.forwardHandler.HandleForwarded(args.NodeID, entries)
+}
+
+// Forwarder queues the entries a follower observes and sends them to the
+// leader in batches, in order. A batch that cannot be delivered is retried
+// before later batches are sent.
+type Forwarder struct {
+	node       *Node
+	maxEntries int
+	window     time.Duration
+	retryWait  time.Duration
+
+	mu      sync.Mutex
+	entries []*LogEntry
+	waiters []func(error)
+	timer   *time.Timer
+	stopped bool
+
+	queue  chan *forwardBatch
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+type forwardBatch struct {
+	entries []*LogEntry
+	waiters []func(error)
+}
+
+func NewForwarder(node *Node, maxEntries int, window time.Duration) *Forwarder {
+	if maxEntries <= 0 {
+		maxEntries = DefaultBatchMaxEntries
+	}
+	if window <= 0 {
+		window = DefaultBatchWindow
+	}
+
+	f := &Forwarder{
+		node:       node,
+		maxEntries: maxEntries,
+		window:     window,
+		retryWait:  time.Second,
+		queue:      make(chan *forwardBatch, maxInflightBatches),
+		stopCh:     make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	go f.run()
+
+	return f
+}
+
+// Forward queues an entry for the leader
+func (f *Forwarder) Forward(entry *LogEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.stopped {
+		return fmt.Errorf("forwarder stopped")
+	}
+
+	f.entries = append(f.entries, entry)
+
+	if len(f.entries) >= f.maxEntries {
+		f.flushLocked()
+	} else if f.timer == nil {
+		f.timer = time.AfterFunc(f.window, f.onWindow)
+	}
+
+	return nil
+}
+
+// OnForwarded registers fn to run once every entry queued so far has been
+// accepted by the leader, with the first error seen since the previous
+// OnForwarded callback
+func (f *Forwarder) OnForwarded(fn func(error)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.stopped {
+		fn(fmt.Errorf("forwarder stopped"))
+		return
+	}
+
+	f.waiters = append(f.waiters, fn)
+
+	if len(f.entries) == 0 {
+		f.flushLocked()
+	}
+}
+
+// Stop sends any queued entries and waits for the sender to finish. Retries
+// are abandoned once Stop is called.
+func (f *Forwarder) Stop() {
+	f.mu.Lock()
+	if f.stopped {
+		f.mu.Unlock()
+		<-f.done
+		return
+	}
+	f.flushLocked()
+	f.stopped = true
+	close(f.stopCh)
+	close(f.queue)
+	f.mu.Unlock()
+
+	<-f.done
+}
+
+func (f *Forwarder) onWindow() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.timer = nil
+	if !f.stopped {
+		f.flushLocked()
+	}
+}
+
+func (f *Forwarder) flushLocked() {
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+
+	if len(f.entries) == 0 && len(f.waiters) == 0 {
+		return
+	}
+
+	f.queue <- &forwardBatch{entries: f.entries, waiters: f.waiters}
+
+	f.entries = nil
+	f.waiters = nil
+}
+
+func (f *Forwarder) run() {
+	defer close(f.done)
+
+	var pendingErr error
+
+	for batch := range f.queue {
+		if len(batch.entries) > 0 {
+			if err := f.send(batch.entries); err != nil {
+				logger.Error("Failed to forward hash entries to leader",
+					"entries", len(batch.entries),
+					"error", err)
+				if pendingErr == nil {
+					pendingErr = err
+				}
+			}
+		}
+
+		if len(batch.waiters) > 0 {
+			for _, fn := range batch.waiters {
+				fn(pendingErr)
+			}
+			pendingErr = nil
+		}
+	}
+}
+
+// send delivers a batch, retrying while the leader is unreachable. Errors
+// returned by the leader itself are retried only when leadership moved.
+func (f *Forwarder) send(entries []*LogEntry) error {
+	var err error
+
+	for attempt := 1; attempt <= maxForwardAttempts; attempt++ {
+		var proposed int
+		proposed, err = f.node.ForwardHashEntries(entries)
+		if err == nil {
+			logger.Debug("Forwarded hash entries to leader",
+				"entries", len(entries),
+				"proposed", proposed)
+			return nil
+		}
+
+		var serverErr rpc.ServerError
+		if errors.As(err, &serverErr) && serverErr != "not the leader" {
+			return err
+		}
+
+		select {
+		case <-time.After(f.retryWait * time.Duration(attempt)):
+		case <-f.stopCh:
+			return err
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,300 @@
+package consensus
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/witnz/witnz/internal/signing"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+// batchForwardHandler proposes every forwarded entry through a batcher
+type batchForwardHandler struct {
+	batcher *Batcher
+}
+
+func (h *batchForwardHandler) HandleForwarded(from string, entries []*LogEntry) (int, error) {
+	for _, entry := range entries {
+		if err := h.batcher.Propose(entry, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	applied := make(chan error, 1)
+	h.batcher.OnApplied(func(err error) { applied <- err })
+	return len(entries), <-applied
+}
+
+func TestForwardHashEntries(t *testing.T) {
+	ids := []string{"node1", "node2"}
+	addrs := map[string]string{
+		"node1": "127.0.0.1:19101",
+		"node2": "127.0.0.1:19102",
+	}
+
+	signers := make(map[string]*signing.Signer)
+	publicKeys := make(map[string]string)
+	for _, id := range ids {
+		key, err := signing.LoadOrCreateKey(filepath.Join(t.TempDir(), "node.key"))
+		if err != nil {
+			t.Fatalf("Failed to create key for %s: %v", id, err)
+		}
+		signers[id] = signing.NewSigner(id, key)
+		publicKeys[id] = signers[id].PublicKey()
+	}
+
+	keyRing, err := signing.NewKeyRing(publicKeys)
+	if err != nil {
+		t.Fatalf("Failed to create key ring: %v", err)
+	}
+
+	nodes := make(map[string]*Node)
+	stores := make(map[string]*storage.Storage)
+	ctx := context.Background()
+
+	for i, id := range ids {
+		store, err := storage.New(filepath.Join(t.TempDir(), "witnz.db"))
+		if err != nil {
+			t.Fatalf("Failed to create storage for %s: %v", id, err)
+		}
+		defer store.Close()
+		stores[id] = store
+
+		peers := make(map[string]string)
+		for _, peer := range ids {
+			if peer != id {
+				peers[peer] = addrs[peer]
+			}
+		}
+
+		node, err := NewNode(&NodeConfig{
+			NodeID:    id,
+			BindAddr:  addrs[id],
+			DataDir:   t.TempDir(),
+			Bootstrap: i == 0,
+			PeerAddrs: peers,
+			Signer:    signers[id],
+			KeyRing:   keyRing,
+		}, store)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", id, err)
+		}
+
+		batcher := NewBatcher(node, 0, 0)
+		defer batcher.Stop()
+		node.SetForwardHandler(&batchForwardHandler{batcher: batcher})
+
+		nodes[id] = node
+	}
+
+	if err := nodes["node1"].Start(ctx); err != nil {
+		t.Fatalf("Failed to start node1: %v", err)
+	}
+	defer nodes["node1"].Stop()
+
+	time.Sleep(2 * time.Second)
+
+	if err := nodes["node2"].Start(ctx); err != nil {
+		t.Fatalf("Failed to start node2: %v", err)
+	}
+	defer nodes["node2"].Stop()
+
+	time.Sleep(3 * time.Second)
+
+	leaderID, followerID := "node1", "node2"
+	if nodes["node2"].IsLeader() {
+		leaderID, followerID = "node2", "node1"
+	}
+	if !nodes[leaderID].IsLeader() {
+		t.Fatal("No leader node found")
+	}
+
+	newEntry := func(lsn uint64) *LogEntry {
+		return &LogEntry{
+			Type:      LogEntryHashChain,
+			TableName: "audit_log",
+			Timestamp: time.Now(),
+			HashChain: &HashChainPayload{
+				DataHash:      "forwarded_hash",
+				OperationType: "INSERT",
+				RecordID:      "map[id:1]",
+				LSN:           lsn,
+			},
+		}
+	}
+
+	t.Run("FollowerForwardsToLeader", func(t *testing.T) {
+		proposed, err := nodes[followerID].ForwardHashEntries([]*LogEntry{newEntry(4096)})
+		if err != nil {
+			t.Fatalf("ForwardHashEntries failed: %v", err)
+		}
+		if proposed != 1 {
+			t.Errorf("Expected 1 proposed entry, got %d", proposed)
+		}
+
+		for _, id := range ids {
+			var entry *storage.HashEntry
+			for i := 0; i < 20 && entry == nil; i++ {
+				entry, _ = stores[id].GetHashEntryByLSN("audit_log", "map[id:1]", 4096)
+				time.Sleep(100 * time.Millisecond)
+			}
+			if entry == nil || entry.SequenceNum != 1 {
+				t.Errorf("Expected forwarded entry at sequence 1 on %s, got %+v", id, entry)
+			}
+		}
+	})
+
+	t.Run("Forwarder", func(t *testing.T) {
+		forwarder := NewForwarder(nodes[followerID], 0, 10*time.Millisecond)
+		defer forwarder.Stop()
+
+		if err := forwarder.Forward(newEntry(8192)); err != nil {
+			t.Fatalf("Forward failed: %v", err)
+		}
+
+		done := make(chan error, 1)
+		forwarder.OnForwarded(func(err error) { done <- err })
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Forwarding failed: %v", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Timed out waiting for forwarded entries")
+		}
+
+		if entry, _ := stores[leaderID].GetHashEntryByLSN("audit_log", "map[id:1]", 8192); entry == nil {
+			t.Error("Expected forwarded entry on the leader")
+		}
+	})
+
+	t.Run("RejectsForgedForward", func(t *testing.T) {
+		payload, err := encodeMsgpack([]*LogEntry{newEntry(12288)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = nodes[leaderID].receiveForwarded(&ForwardedEntries{
+			NodeID:    followerID,
+			Payload:   payload,
+			Signature: signers[leaderID].Sign(payload),
+		})
+		if err == nil {
+			t.Error("Expected entries signed with another node's key to be rejected")
+		}
+	})
+
+	t.Run("FollowerProposesTableSet", func(t *testing.T) {
+		tables := []storage.ProtectedTable{{Name: "audit_log"}, {Name: "payments", VerifyInterval: "1h"}}
+		if err := nodes[followerID].ProposeTableSet(tables, nil); err != nil {
+			t.Fatalf("ProposeTableSet failed: %v", err)
+		}
+
+		for _, id := range ids {
+			var stored []storage.ProtectedTable
+			for i := 0; i < 20 && len(stored) == 0; i++ {
+				stored, _ = stores[id].GetProtectedTables()
+				time.Sleep(100 * time.Millisecond)
+			}
+			if len(stored) != 2 || stored[1] != tables[1] {
+				t.Errorf("Expected table set %+v on %s, got %+v", tables, id, stored)
+			}
+		}
+
+		payload := []byte(`{"tables":[{"name":"audit_log"}]}`)
+		err := nodes[leaderID].receiveTableSet(&SignedProposal{
+			NodeID:    followerID,
+			Payload:   payload,
+			Signature: signers[leaderID].Sign(payload),
+		})
+		if err == nil {
+			t.Error("Expected a table set signed with another node's key to be rejected")
+		}
+	})
+
+	t.Run("ClusterPolicy", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		policy := &storage.ClusterPolicy{
+			HashAlgorithm:   "sha256",
+			EncodingVersion: 1,
+			Tables:          []string{"audit_log", "payments"},
+		}
+
+		// node1 bootstraps the cluster and commits the policy, through the
+		// leader if it does not lead
+		if err := nodes["node1"].CheckClusterPolicy(ctx, policy); err != nil {
+			t.Fatalf("CheckClusterPolicy failed on the bootstrap node: %v", err)
+		}
+		if err := nodes["node2"].CheckClusterPolicy(ctx, policy); err != nil {
+			t.Errorf("Expected a matching config to pass, got %v", err)
+		}
+
+		local := *policy
+		local.HashAlgorithm = "xxhash64"
+		err := nodes["node2"].CheckClusterPolicy(ctx, &local)
+		var mismatch *PolicyMismatchError
+		if !errors.As(err, &mismatch) || len(mismatch.Diff) != 1 {
+			t.Errorf("Expected a policy mismatch with one difference, got %v", err)
+		}
+	})
+
+	t.Run("FollowerCatchesUp", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		if err := nodes[followerID].WaitForCatchUp(ctx); err != nil {
+			t.Fatalf("WaitForCatchUp failed: %v", err)
+		}
+
+		status := nodes[followerID].ClusterStatus()
+		if !status.CaughtUp || status.TargetIndex == 0 || status.AppliedIndex < status.TargetIndex {
+			t.Errorf("Expected follower caught up with the leader, got %+v", status)
+		}
+
+		if _, err := nodes[followerID].commitIndex(); err == nil {
+			t.Error("Expected a follower to refuse commit index requests")
+		}
+	})
+
+	t.Run("RotationCandidates", func(t *testing.T) {
+		nodes[followerID].SetCDCHealth(func() bool { return true })
+
+		candidates := nodes[leaderID].transferCandidates(slog.Default())
+		if len(candidates) != 1 {
+			t.Fatalf("Expected 1 rotation candidate, got %d", len(candidates))
+		}
+
+		health := candidates[0].health
+		if health.NodeID != followerID || !health.CDCHealthy || !health.CaughtUp {
+			t.Errorf("Expected healthy caught-up %s, got %+v", followerID, health)
+		}
+		if target := chooseTransferTarget(candidates); target == nil || string(target.id) != followerID {
+			t.Errorf("Expected %s as rotation target, got %+v", followerID, target)
+		}
+	})
+
+	t.Run("FollowerRejectsForward", func(t *testing.T) {
+		if _, err := nodes[followerID].receiveForwarded(&ForwardedEntries{NodeID: leaderID}); err == nil {
+			t.Error("Expected a follower to refuse forwarded entries")
+		}
+	})
+	t.Run("HandOffLeadership", func(t *testing.T) {
+		if err := nodes[followerID].HandOffLeadership(context.Background()); err != nil {
+			t.Errorf("Expected hand-off on a follower to be a no-op, got %v", err)
+		}
+
+		if err := nodes[leaderID].HandOffLeadership(context.Background()); err != nil {
+			t.Fatalf("HandOffLeadership failed: %v", err)
+		}
+		if !nodes[followerID].IsLeader() {
+			t.Errorf("Expected %s to lead after the hand-off", followerID)
+		}
+	})
+}
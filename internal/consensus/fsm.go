@@ -1,145 +1,440 @@
 package consensus
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"sync"
 
 	"github.com/hashicorp/raft"
+	"github.com/witnz/witnz/internal/signing"
 	"github.com/witnz/witnz/internal/storage"
 )
 
 type FSM struct {
-	mu      sync.RWMutex
-	storage *storage.Storage
+	mu            sync.RWMutex
+	storage       *storage.Storage
+	keyRing       *signing.KeyRing
+	approvers     AllowanceVerifier
+	observer      HashEntryObserver
+	tableObserver TableSetObserver
+	// log carries the index and term of the entry being applied
+	log *slog.Logger
+}
+
+// HashEntryObserver is notified after a hash entry is applied
+type HashEntryObserver interface {
+	ObserveHashEntry(entry *storage.HashEntry)
+}
+
+// AllowanceVerifier checks that an allowance is signed by a trusted
+// approver. It must not depend on the clock, since every replica has to
+// reach the same result.
+type AllowanceVerifier interface {
+	VerifySignature(a *storage.Allowance) error
+}
+
+// TableSetObserver is notified when the replicated set of protected tables
+// changes, either by an applied entry or by a restored snapshot
+type TableSetObserver interface {
+	ObserveTableSet(tables []storage.ProtectedTable)
 }
 
 func NewFSM(store *storage.Storage) *FSM {
 	return &FSM{
 		storage: store,
+		log:     logger,
 	}
 }
 
+// SetKeyRing enables signature checks on applied entries. Once set, unsigned
+// entries and entries signed by unknown keys are rejected.
+func (f *FSM) SetKeyRing(ring *signing.KeyRing) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keyRing = ring
+}
+
+// SetAllowanceVerifier sets the check applied allowances must pass. Until it
+// is set, allowance entries are rejected.
+func (f *FSM) SetAllowanceVerifier(verifier AllowanceVerifier) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.approvers = verifier
+}
+
+// SetObserver registers an observer for applied hash entries
+func (f *FSM) SetObserver(observer HashEntryObserver) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.observer = observer
+}
+
+// SetTableSetObserver registers an observer for table set changes
+func (f *FSM) SetTableSetObserver(observer TableSetObserver) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tableObserver = observer
+}
+
 func (f *FSM) Apply(log *raft.Log) interface{} {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	var entry LogEntry
-	if err := json.Unmarshal(log.Data, &entry); err != nil {
-		return fmt.Errorf("failed to unmarshal log entry: %w", err)
+	f.log = logger.With("index", log.Index, "term", log.Term)
+
+	entry, err := unmarshalLogEntry(log.Data, f.keyRing)
+	if err == nil {
+		err = entry.Validate()
+	}
+	if err != nil {
+		f.log.Error("Rejected Raft log entry", "error", err)
+		return err
 	}
 
 	switch entry.Type {
 	case LogEntryHashChain:
-		return f.applyHashChain(&entry)
+		return f.applyHashChain(entry)
+	case LogEntryHashChainBatch:
+		return f.applyHashChainBatch(entry.HashChainBatch)
 	case LogEntryCheckpoint:
-		return f.applyCheckpoint(&entry)
+		return f.applyCheckpoint(entry)
+	case LogEntryCheckpointSignature:
+		return f.applyCheckpointSignature(entry.CheckpointSignature)
+	case LogEntryFinding:
+		return f.applyFinding(entry.Finding)
+	case LogEntryFindingStatus:
+		return f.applyFindingStatus(entry)
+	case LogEntryAllowance:
+		return f.applyAllowance(entry.Allowance)
+	case LogEntryTableSet:
+		return f.applyTableSet(log.Index, entry.TableSet)
+	case LogEntryClusterPolicy:
+		return f.applyClusterPolicy(entry.ClusterPolicy)
+	case LogEntryDatabaseIdentity:
+		return f.applyDatabaseIdentity(entry.DatabaseIdentity)
 	default:
 		return fmt.Errorf("unknown log entry type: %s", entry.Type)
 	}
 }
 
+// applyHashChain stores a hash entry and returns the sequence number it was
+// assigned. Sequence numbers are chosen here rather than by the proposer, so
+// every node assigns the same number regardless of leadership changes.
 func (f *FSM) applyHashChain(entry *LogEntry) interface{} {
-	dataHash := ""
-	if dh, ok := entry.Data["data_hash"].(string); ok {
-		dataHash = dh
-	}
-
-	if dataHash == "" {
-		slog.Warn("Received hash entry with empty data_hash",
-			"table", entry.TableName,
-			"sequence_num", entry.Data["sequence_num"])
-	}
+	p := entry.HashChain
 
 	hashEntry := &storage.HashEntry{
 		TableName:     entry.TableName,
-		SequenceNum:   uint64(entry.Data["sequence_num"].(float64)),
-		DataHash:      dataHash,
+		DataHash:      p.DataHash,
 		Timestamp:     entry.Timestamp,
-		OperationType: entry.Data["operation_type"].(string),
-		RecordID:      entry.Data["record_id"].(string),
+		OperationType: p.OperationType,
+		RecordID:      p.RecordID,
+		AllowanceID:   p.AllowanceID,
+		LSN:           p.LSN,
 	}
 
-	if err := f.storage.SaveHashEntry(hashEntry); err != nil {
+	var appended bool
+	var err error
+	if p.SequenceNum != 0 {
+		// Entries proposed by older leaders carry their own sequence number.
+		// They may not replace an entry already at that number.
+		hashEntry.SequenceNum = p.SequenceNum
+		appended, err = f.storage.PlaceHashEntry(hashEntry)
+	} else {
+		appended, err = f.storage.AppendHashEntry(hashEntry)
+	}
+	if err != nil {
 		return err
 	}
 
-	return nil
+	if !appended {
+		f.log.Debug("Skipped duplicate hash entry",
+			"table", entry.TableName,
+			"record_id", p.RecordID,
+			"lsn", p.LSN,
+			"seq", hashEntry.SequenceNum)
+		return hashEntry.SequenceNum
+	}
+
+	if len(p.RowImage) > 0 {
+		if err := f.storage.SaveRowImage(entry.TableName, p.RowImageID, p.RowImage); err != nil {
+			return err
+		}
+	}
+
+	if f.observer != nil {
+		f.observer.ObserveHashEntry(hashEntry)
+	}
+
+	return hashEntry.SequenceNum
 }
 
-func (f *FSM) applyCheckpoint(entry *LogEntry) interface{} {
-	checkpoint := &storage.MerkleCheckpoint{
-		TableName:     entry.TableName,
-		SequenceNum:   uint64(entry.Data["sequence_num"].(float64)),
-		MerkleRoot:    entry.Data["merkle_root"].(string),
-		Timestamp:     entry.Timestamp,
-		RecordCount:   int(entry.Data["record_count"].(float64)),
-		HashAlgorithm: entry.Data["hash_algorithm"].(string),
+// applyHashChainBatch appends every entry of a batch in one storage
+// transaction and returns a []HashEntryResult in batch order
+func (f *FSM) applyHashChainBatch(batch *HashChainBatchPayload) interface{} {
+	items := make([]*storage.HashEntryBatchItem, len(batch.Entries))
+	for i, entry := range batch.Entries {
+		p := entry.HashChain
+		items[i] = &storage.HashEntryBatchItem{
+			Entry: &storage.HashEntry{
+				TableName:     entry.TableName,
+				DataHash:      p.DataHash,
+				Timestamp:     entry.Timestamp,
+				OperationType: p.OperationType,
+				RecordID:      p.RecordID,
+				AllowanceID:   p.AllowanceID,
+				LSN:           p.LSN,
+			},
+			RowImageID: p.RowImageID,
+			RowImage:   p.RowImage,
+		}
 	}
 
-	// Decode leaf_map and internal_nodes if present
-	if leafMapData, ok := entry.Data["leaf_map"].(map[string]interface{}); ok {
-		checkpoint.LeafMap = make(map[string]string)
-		for k, v := range leafMapData {
-			if strVal, ok := v.(string); ok {
-				checkpoint.LeafMap[k] = strVal
-			}
+	if err := f.storage.AppendHashEntries(items); err != nil {
+		return fmt.Errorf("failed to apply hash chain batch: %w", err)
+	}
+
+	results := make([]HashEntryResult, len(items))
+	for i, item := range items {
+		results[i] = HashEntryResult{SequenceNum: item.Entry.SequenceNum, Err: item.Err}
+
+		if item.Err != nil {
+			f.log.Warn("Rejected hash entry in batch",
+				"table", item.Entry.TableName,
+				"record_id", item.Entry.RecordID,
+				"lsn", item.Entry.LSN,
+				"error", item.Err)
+			continue
+		}
+		if item.Appended && f.observer != nil {
+			f.observer.ObserveHashEntry(item.Entry)
 		}
 	}
 
-	if internalNodesData, ok := entry.Data["internal_nodes"].(map[string]interface{}); ok {
-		checkpoint.InternalNodes = make(map[string]string)
-		for k, v := range internalNodesData {
-			if strVal, ok := v.(string); ok {
-				checkpoint.InternalNodes[k] = strVal
+	return results
+}
+
+func (f *FSM) applyCheckpoint(entry *LogEntry) interface{} {
+	checkpoint := *entry.Checkpoint
+
+	if len(checkpoint.Signatures) > 0 {
+		signatures := make(map[string]string, len(checkpoint.Signatures))
+		payload := checkpoint.SigningPayload()
+		for nodeID, signature := range checkpoint.Signatures {
+			if f.keyRing != nil {
+				if err := f.keyRing.Verify(nodeID, payload, signature); err != nil {
+					f.log.Warn("Dropped invalid checkpoint signature",
+						"table", checkpoint.TableName,
+						"node", nodeID,
+						"error", err)
+					continue
+				}
 			}
+			signatures[nodeID] = signature
 		}
+		checkpoint.Signatures = signatures
 	}
 
-	if err := f.storage.SaveMerkleCheckpoint(checkpoint); err != nil {
-		slog.Error("Failed to save checkpoint via Raft",
-			"table", entry.TableName,
+	if err := f.storage.SaveMerkleCheckpoint(&checkpoint); err != nil {
+		f.log.Error("Failed to save checkpoint via Raft",
+			"table", checkpoint.TableName,
 			"error", err)
 		return err
 	}
 
-	slog.Info("Applied checkpoint from Raft",
-		"table", entry.TableName,
+	f.log.Info("Applied checkpoint from Raft",
+		"table", checkpoint.TableName,
 		"sequence_num", checkpoint.SequenceNum,
 		"record_count", checkpoint.RecordCount)
 
 	return nil
 }
 
+func (f *FSM) applyCheckpointSignature(sig *storage.CheckpointSignature) interface{} {
+	if f.keyRing != nil {
+		payload := storage.CheckpointSigningPayload(sig.TableName, sig.SequenceNum, sig.MerkleRoot)
+		if err := f.keyRing.Verify(sig.NodeID, payload, sig.Signature); err != nil {
+			return fmt.Errorf("rejected checkpoint signature: %w", err)
+		}
+	}
+
+	if err := f.storage.AddCheckpointSignature(sig); err != nil {
+		return err
+	}
+
+	f.log.Info("Applied checkpoint signature from Raft",
+		"table", sig.TableName,
+		"sequence_num", sig.SequenceNum,
+		"node", sig.NodeID)
+
+	return nil
+}
+
+func (f *FSM) applyFinding(finding *storage.Finding) interface{} {
+	if err := f.storage.SaveFinding(finding); err != nil {
+		return err
+	}
+
+	f.log.Info("Applied finding from Raft",
+		"id", finding.ID,
+		"table", finding.TableName,
+		"kind", finding.Kind)
+
+	return nil
+}
+
+func (f *FSM) applyFindingStatus(entry *LogEntry) interface{} {
+	s := entry.FindingStatus
+	return f.storage.UpdateFindingStatus(s.ID, s.Status, s.Actor, s.Note, entry.Timestamp)
+}
+
+func (f *FSM) applyAllowance(allowance *storage.Allowance) interface{} {
+	// Replayed entries must not reset an allowance that was already consumed
+	_, err := f.storage.GetAllowance(allowance.ID)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, storage.ErrAllowanceNotFound) {
+		return fmt.Errorf("failed to get allowance: %w", err)
+	}
+
+	// A leader must not be able to mint allowances on its own
+	if f.approvers == nil {
+		return fmt.Errorf("rejected allowance %s: no approvers configured", allowance.ID)
+	}
+	if err := f.approvers.VerifySignature(allowance); err != nil {
+		return fmt.Errorf("rejected allowance %s: %w", allowance.ID, err)
+	}
+
+	if err := f.storage.SaveAllowance(allowance); err != nil {
+		return err
+	}
+
+	f.log.Info("Applied allowance from Raft",
+		"id", allowance.ID,
+		"table", allowance.TableName,
+		"record_id", allowance.RecordID,
+		"operation", allowance.Operation,
+		"approver", allowance.Approver)
+
+	return nil
+}
+
+func (f *FSM) applyTableSet(index uint64, set *TableSetPayload) interface{} {
+	if err := f.storage.SaveProtectedTables(set.Tables); err != nil {
+		return err
+	}
+
+	// The policy follows the table set so nodes restarted later are checked
+	// against the tables the cluster actually protects
+	policy, err := f.storage.GetClusterPolicy()
+	if err != nil {
+		return err
+	}
+	if policy != nil {
+		policy.Tables = []string{}
+		for _, table := range set.Tables {
+			if table.Pattern == "" {
+				policy.Tables = append(policy.Tables, table.Name)
+			}
+		}
+		policy.Patterns = set.Patterns
+		policy.Normalize()
+		if err := f.storage.SaveClusterPolicy(policy); err != nil {
+			return err
+		}
+	}
+
+	f.log.Info("Applied protected table set from Raft", "tables", len(set.Tables))
+
+	if f.tableObserver != nil {
+		f.tableObserver.ObserveTableSet(set.Tables)
+	}
+
+	return nil
+}
+
+// applyClusterPolicy stores the first committed policy. A later policy is
+// only accepted if it matches, since changing the hash algorithm or encoding
+// would invalidate every recorded hash.
+func (f *FSM) applyClusterPolicy(policy *storage.ClusterPolicy) interface{} {
+	existing, err := f.storage.GetClusterPolicy()
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if existing.Digest() != policy.Digest() {
+			return fmt.Errorf("cluster policy already committed with digest %s", existing.Digest())
+		}
+		return nil
+	}
+
+	policy.Normalize()
+	if err := f.storage.SaveClusterPolicy(policy); err != nil {
+		return err
+	}
+
+	f.log.Info("Applied cluster policy from Raft",
+		"hash_algorithm", policy.HashAlgorithm,
+		"tables", len(policy.Tables),
+		"digest", policy.Digest())
+
+	return nil
+}
+
+// applyDatabaseIdentity records the identity a source database last
+// reported, which later connects are compared against
+func (f *FSM) applyDatabaseIdentity(identity *storage.DatabaseIdentity) interface{} {
+	if err := f.storage.SaveDatabaseIdentity(identity); err != nil {
+		return err
+	}
+
+	f.log.Debug("Applied database identity from Raft",
+		"source", identity.Source,
+		"system_id", identity.SystemID,
+		"timeline", identity.Timeline,
+		"xlogpos", storage.FormatLSN(identity.XLogPos))
+
+	return nil
+}
+
 func (f *FSM) Snapshot() (raft.FSMSnapshot, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 
+	// Open the read transaction now so the snapshot matches the applied
+	// index even though Persist runs concurrently with later entries
+	snapshot, err := f.storage.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
 	return &fsmSnapshot{
-		storage: f.storage,
+		snapshot: snapshot,
 	}, nil
 }
 
+// Restore replaces the local state with a snapshot received from the leader
 func (f *FSM) Restore(rc io.ReadCloser) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	defer rc.Close()
 
-	decoder := json.NewDecoder(rc)
-
-	var snapshot struct {
-		HashEntries []storage.HashEntry `json:"hash_entries"`
+	if err := f.storage.RestoreSnapshot(rc); err != nil {
+		return fmt.Errorf("failed to restore snapshot: %w", err)
 	}
 
-	if err := decoder.Decode(&snapshot); err != nil {
-		return fmt.Errorf("failed to decode snapshot: %w", err)
-	}
+	logger.Info("Restored state from Raft snapshot")
 
-	for _, entry := range snapshot.HashEntries {
-		if err := f.storage.SaveHashEntry(&entry); err != nil {
-			return fmt.Errorf("failed to restore hash entry: %w", err)
+	if f.tableObserver != nil {
+		tables, err := f.storage.GetProtectedTables()
+		if err != nil {
+			return err
+		}
+		if tables != nil {
+			f.tableObserver.ObserveTableSet(tables)
 		}
 	}
 
@@ -147,26 +442,13 @@ func (f *FSM) Restore(rc io.ReadCloser) error {
 }
 
 type fsmSnapshot struct {
-	storage *storage.Storage
+	snapshot *storage.Snapshot
 }
 
 func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
-	entries, err := s.storage.GetAllHashEntriesAllTables()
-	if err != nil {
-		sink.Cancel()
-		return fmt.Errorf("failed to get hash entries for snapshot: %w", err)
-	}
-
-	snapshot := struct {
-		HashEntries []storage.HashEntry `json:"hash_entries"`
-	}{
-		HashEntries: entries,
-	}
-
-	encoder := json.NewEncoder(sink)
-	if err := encoder.Encode(snapshot); err != nil {
+	if err := s.snapshot.Write(sink); err != nil {
 		sink.Cancel()
-		return fmt.Errorf("failed to encode snapshot: %w", err)
+		return fmt.Errorf("failed to write snapshot: %w", err)
 	}
 
 	if err := sink.Close(); err != nil {
@@ -177,4 +459,7 @@ func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
 }
 
 func (s *fsmSnapshot) Release() {
+	if err := s.snapshot.Close(); err != nil {
+		logger.Warn("Failed to release snapshot", "error", err)
+	}
 }
@@ -2,12 +2,17 @@ package consensus
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/raft"
+	"github.com/witnz/witnz/internal/allowance"
+	"github.com/witnz/witnz/internal/signing"
 	"github.com/witnz/witnz/internal/storage"
 )
 
@@ -30,16 +35,16 @@ func TestFSMApplyHashChain(t *testing.T) {
 	entry := &LogEntry{
 		Type:      LogEntryHashChain,
 		TableName: "test_table",
-		Data: map[string]interface{}{
-			"sequence_num":   float64(1),
-			"data_hash":      "test_hash",
-			"operation_type": "INSERT",
-			"record_id":      "1",
+		HashChain: &HashChainPayload{
+			DataHash:      "test_hash",
+			OperationType: "INSERT",
+			RecordID:      "1",
+			LSN:           100,
 		},
 		Timestamp: time.Now(),
 	}
 
-	data, err := json.Marshal(entry)
+	data, err := marshalLogEntry(entry, nil)
 	if err != nil {
 		t.Fatalf("Failed to marshal entry: %v", err)
 	}
@@ -49,8 +54,8 @@ func TestFSMApplyHashChain(t *testing.T) {
 	}
 
 	result := fsm.Apply(log)
-	if result != nil {
-		t.Errorf("Apply failed: %v", result)
+	if seq, ok := result.(uint64); !ok || seq != 1 {
+		t.Errorf("Expected assigned sequence 1, got %v", result)
 	}
 
 	retrieved, err := store.GetHashEntry("test_table", 1)
@@ -63,6 +68,486 @@ func TestFSMApplyHashChain(t *testing.T) {
 	}
 }
 
+func TestFSMSequenceAssignment(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-consensus-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	fsm := NewFSM(store)
+
+	apply := func(recordID, dataHash string, lsn int) interface{} {
+		entry := &LogEntry{
+			Type:      LogEntryHashChain,
+			TableName: "test_table",
+			HashChain: &HashChainPayload{
+				DataHash:      dataHash,
+				OperationType: "INSERT",
+				RecordID:      recordID,
+				LSN:           uint64(lsn),
+			},
+			Timestamp: time.Now(),
+		}
+		data, err := marshalLogEntry(entry, nil)
+		if err != nil {
+			t.Fatalf("Failed to marshal entry: %v", err)
+		}
+		return fsm.Apply(&raft.Log{Data: data})
+	}
+
+	t.Run("Sequential", func(t *testing.T) {
+		for i := 1; i <= 12; i++ {
+			result := apply(fmt.Sprintf("map[id:%d]", i), fmt.Sprintf("hash%d", i), 100+i)
+			if seq, ok := result.(uint64); !ok || seq != uint64(i) {
+				t.Fatalf("Expected sequence %d, got %v", i, result)
+			}
+		}
+	})
+
+	t.Run("DuplicateReturnsOriginalSequence", func(t *testing.T) {
+		result := apply("map[id:3]", "hash3", 103)
+		if seq, ok := result.(uint64); !ok || seq != 3 {
+			t.Errorf("Expected duplicate to return sequence 3, got %v", result)
+		}
+		if _, err := store.GetHashEntry("test_table", 13); err == nil {
+			t.Error("Duplicate entry should not be appended")
+		}
+	})
+
+	t.Run("ConflictRejected", func(t *testing.T) {
+		if _, ok := apply("map[id:3]", "forged", 103).(error); !ok {
+			t.Error("Expected conflicting hash for the same change to be rejected")
+		}
+		entry, err := store.GetHashEntry("test_table", 3)
+		if err != nil {
+			t.Fatalf("GetHashEntry failed: %v", err)
+		}
+		if entry.DataHash != "hash3" {
+			t.Errorf("Expected hash3 to be kept, got %s", entry.DataHash)
+		}
+	})
+
+	t.Run("Next", func(t *testing.T) {
+		result := apply("map[id:13]", "hash13", 200)
+		if seq, ok := result.(uint64); !ok || seq != 13 {
+			t.Errorf("Expected sequence 13, got %v", result)
+		}
+	})
+
+	t.Run("ProposedSequence", func(t *testing.T) {
+		applyAt := func(seq uint64, dataHash string) interface{} {
+			entry := &LogEntry{
+				Type:      LogEntryHashChain,
+				TableName: "test_table",
+				HashChain: &HashChainPayload{
+					DataHash:      dataHash,
+					OperationType: "INSERT",
+					RecordID:      "map[id:5]",
+					SequenceNum:   seq,
+				},
+				Timestamp: time.Now(),
+			}
+			data, err := marshalLogEntry(entry, nil)
+			if err != nil {
+				t.Fatalf("Failed to marshal entry: %v", err)
+			}
+			return fsm.Apply(&raft.Log{Data: data})
+		}
+
+		if seq, ok := applyAt(5, "hash5").(uint64); !ok || seq != 5 {
+			t.Errorf("Expected an identical entry to be a no-op at sequence 5, got %v", seq)
+		}
+		if _, ok := applyAt(5, "forged").(error); !ok {
+			t.Error("Expected a different hash at an existing sequence to be rejected")
+		}
+		entry, err := store.GetHashEntry("test_table", 5)
+		if err != nil {
+			t.Fatalf("GetHashEntry failed: %v", err)
+		}
+		if entry.DataHash != "hash5" {
+			t.Errorf("Expected hash5 to be kept, got %s", entry.DataHash)
+		}
+
+		if seq, ok := applyAt(20, "hash20").(uint64); !ok || seq != 20 {
+			t.Errorf("Expected a free sequence to be stored, got %v", seq)
+		}
+	})
+}
+
+func TestFSMApplyFinding(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-consensus-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	fsm := NewFSM(store)
+
+	finding := &storage.Finding{
+		ID:         "20250101000000-0001",
+		TableName:  "test_table",
+		RecordID:   "7",
+		Kind:       storage.FindingDelete,
+		DetectedBy: storage.DetectedByCDC,
+		NodeID:     "node1",
+		LSN:        42,
+		DetectedAt: time.Now(),
+		Status:     storage.FindingOpen,
+	}
+
+	entries := []*LogEntry{
+		{
+			Type:      LogEntryFinding,
+			TableName: finding.TableName,
+			Finding:   finding,
+			Timestamp: finding.DetectedAt,
+		},
+		{
+			Type: LogEntryFindingStatus,
+			FindingStatus: &FindingStatusPayload{
+				ID:     finding.ID,
+				Status: storage.FindingAcknowledged,
+				Actor:  "alice",
+				Note:   "investigating",
+			},
+			Timestamp: time.Now(),
+		},
+	}
+
+	for _, entry := range entries {
+		data, err := marshalLogEntry(entry, nil)
+		if err != nil {
+			t.Fatalf("Failed to marshal entry: %v", err)
+		}
+		if result := fsm.Apply(&raft.Log{Data: data}); result != nil {
+			t.Fatalf("Apply failed: %v", result)
+		}
+	}
+
+	retrieved, err := store.GetFinding(finding.ID)
+	if err != nil {
+		t.Fatalf("GetFinding failed: %v", err)
+	}
+
+	if retrieved.LSN != 42 || retrieved.Kind != storage.FindingDelete {
+		t.Errorf("Unexpected finding: lsn=%d kind=%s", retrieved.LSN, retrieved.Kind)
+	}
+	if retrieved.Status != storage.FindingAcknowledged || retrieved.StatusBy != "alice" {
+		t.Errorf("Expected acknowledged by alice, got %s by %s", retrieved.Status, retrieved.StatusBy)
+	}
+}
+
+func TestFSMApplyAmendment(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-consensus-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	approved, approvers := signedAllowance(t, time.Now().Add(time.Hour))
+	fsm := NewFSM(store)
+	fsm.SetAllowanceVerifier(approvers)
+
+	amendment := &LogEntry{
+		Type:      LogEntryHashChain,
+		TableName: "test_table",
+		HashChain: &HashChainPayload{
+			OperationType: "DELETE",
+			RecordID:      "map[id:1]",
+			AllowanceID:   "al-1",
+			LSN:           4096,
+		},
+		Timestamp: time.Now(),
+	}
+
+	entries := []*LogEntry{
+		{
+			Type:      LogEntryAllowance,
+			TableName: "test_table",
+			Allowance: approved,
+			Timestamp: time.Now(),
+		},
+		amendment,
+	}
+
+	for _, entry := range entries {
+		data, err := marshalLogEntry(entry, nil)
+		if err != nil {
+			t.Fatalf("Failed to marshal entry: %v", err)
+		}
+		if err, ok := fsm.Apply(&raft.Log{Data: data}).(error); ok {
+			t.Fatalf("Apply failed: %v", err)
+		}
+	}
+
+	consumed, err := store.GetAllowance("al-1")
+	if err != nil {
+		t.Fatalf("GetAllowance failed: %v", err)
+	}
+	if !consumed.Consumed() || consumed.ConsumedLSN != 4096 {
+		t.Errorf("Expected allowance consumed at LSN 4096, got %d", consumed.ConsumedLSN)
+	}
+
+	entry, err := store.GetHashEntry("test_table", 1)
+	if err != nil {
+		t.Fatalf("GetHashEntry failed: %v", err)
+	}
+	if entry.AllowanceID != "al-1" || entry.OperationType != "DELETE" {
+		t.Errorf("Unexpected amendment entry: %+v", entry)
+	}
+
+	data, _ := marshalLogEntry(amendment, nil)
+	if seq, ok := fsm.Apply(&raft.Log{Data: data}).(uint64); !ok || seq != 1 {
+		t.Errorf("Expected replayed amendment to resolve to sequence 1, got %v", seq)
+	}
+
+	amendment.HashChain.LSN = 8192
+	data, _ = marshalLogEntry(amendment, nil)
+	if _, ok := fsm.Apply(&raft.Log{Data: data}).(error); !ok {
+		t.Error("Expected a consumed allowance to reject a second amendment")
+	}
+}
+
+// signedAllowance returns an allowance expiring at expiresAt signed by the
+// compliance approver, and a verifier trusting that approver
+func signedAllowance(t *testing.T, expiresAt time.Time) (*storage.Allowance, *allowance.Verifier) {
+	publicKey, privateKey, err := allowance.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	verifier, err := allowance.NewVerifier(map[string]string{"compliance": publicKey})
+	if err != nil {
+		t.Fatalf("NewVerifier failed: %v", err)
+	}
+
+	a := &storage.Allowance{
+		ID:           "al-1",
+		TableName:    "test_table",
+		RecordID:     "1",
+		Operation:    "DELETE",
+		ExpectedHash: "abc",
+		ExpiresAt:    expiresAt,
+		Approver:     "compliance",
+	}
+	if err := allowance.Sign(a, privateKey); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	return a, verifier
+}
+
+func TestFSMApplyAllowanceSignature(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-consensus-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	apply := func(fsm *FSM, a *storage.Allowance) interface{} {
+		data, err := marshalLogEntry(&LogEntry{
+			Type:      LogEntryAllowance,
+			TableName: a.TableName,
+			Allowance: a,
+			Timestamp: time.Now(),
+		}, nil)
+		if err != nil {
+			t.Fatalf("Failed to marshal entry: %v", err)
+		}
+		return fsm.Apply(&raft.Log{Data: data})
+	}
+
+	// Expiry is not checked on apply, so replicas agree however their
+	// clocks differ
+	signed, approvers := signedAllowance(t, time.Now().Add(-time.Hour))
+
+	tests := map[string]struct {
+		modify func(a *storage.Allowance)
+		want   string
+	}{
+		"Unsigned":        {func(a *storage.Allowance) { a.Signature = "" }, "invalid signature"},
+		"UnknownApprover": {func(a *storage.Allowance) { a.Approver = "mallory" }, "unknown approver"},
+		"Forged":          {func(a *storage.Allowance) { a.RecordID = "2" }, "invalid signature"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := *signed
+			a.ID = "al-" + name
+			tt.modify(&a)
+
+			fsm := NewFSM(store)
+			fsm.SetAllowanceVerifier(approvers)
+			err, ok := apply(fsm, &a).(error)
+			if !ok || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected %q, got %v", tt.want, err)
+			}
+			if _, err := store.GetAllowance(a.ID); err == nil {
+				t.Error("Expected the allowance not to be stored")
+			}
+		})
+	}
+
+	t.Run("NoApprovers", func(t *testing.T) {
+		err, ok := apply(NewFSM(store), signed).(error)
+		if !ok || !strings.Contains(err.Error(), "no approvers configured") {
+			t.Errorf("Expected the allowance to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		fsm := NewFSM(store)
+		fsm.SetAllowanceVerifier(approvers)
+		if err, ok := apply(fsm, signed).(error); ok {
+			t.Fatalf("Expected a signed allowance to apply, got %v", err)
+		}
+		if _, err := store.GetAllowance(signed.ID); err != nil {
+			t.Errorf("Expected the allowance to be stored, got %v", err)
+		}
+	})
+}
+
+func TestFSMApplyAllowanceStorageError(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-consensus-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	fsm := NewFSM(store)
+
+	data, err := marshalLogEntry(&LogEntry{
+		Type:      LogEntryAllowance,
+		TableName: "test_table",
+		Allowance: &storage.Allowance{ID: "al-1", TableName: "test_table", RecordID: "1", Operation: "DELETE"},
+		Timestamp: time.Now(),
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to marshal entry: %v", err)
+	}
+
+	// A failed lookup must fail the apply rather than read as a new allowance
+	store.Close()
+	err, ok := fsm.Apply(&raft.Log{Data: data}).(error)
+	if !ok || !strings.Contains(err.Error(), "failed to get allowance") {
+		t.Errorf("Expected the lookup error to be returned, got %v", err)
+	}
+}
+
+func TestFSMSignedEntries(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-consensus-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	leaderKey, err := signing.LoadOrCreateKey(filepath.Join(t.TempDir(), "node.key"))
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	rogueKey, err := signing.LoadOrCreateKey(filepath.Join(t.TempDir(), "node.key"))
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	leader := signing.NewSigner("node1", leaderKey)
+	ring, err := signing.NewKeyRing(map[string]string{"node1": leader.PublicKey()})
+	if err != nil {
+		t.Fatalf("Failed to create key ring: %v", err)
+	}
+
+	fsm := NewFSM(store)
+	fsm.SetKeyRing(ring)
+
+	newEntry := func(seq int) *LogEntry {
+		return &LogEntry{
+			Type:      LogEntryHashChain,
+			TableName: "test_table",
+			HashChain: &HashChainPayload{
+				DataHash:      "hash",
+				OperationType: "INSERT",
+				RecordID:      fmt.Sprintf("%d", seq),
+				LSN:           uint64(seq),
+			},
+			Timestamp: time.Now(),
+		}
+	}
+
+	t.Run("SignedByPinnedKey", func(t *testing.T) {
+		node := &Node{config: &NodeConfig{Signer: leader}}
+		data, err := node.encodeLogEntry(newEntry(1))
+		if err != nil {
+			t.Fatalf("Failed to encode entry: %v", err)
+		}
+
+		if err, ok := fsm.Apply(&raft.Log{Data: data}).(error); ok {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		if _, err := store.GetHashEntry("test_table", 1); err != nil {
+			t.Errorf("Expected signed entry to be applied: %v", err)
+		}
+	})
+
+	t.Run("Unsigned", func(t *testing.T) {
+		data, _ := marshalLogEntry(newEntry(2), nil)
+		if _, ok := fsm.Apply(&raft.Log{Data: data}).(error); !ok {
+			t.Error("Expected unsigned entry to be rejected")
+		}
+	})
+
+	t.Run("SignedByUnknownKey", func(t *testing.T) {
+		node := &Node{config: &NodeConfig{Signer: signing.NewSigner("node1", rogueKey)}}
+		data, _ := node.encodeLogEntry(newEntry(3))
+		if _, ok := fsm.Apply(&raft.Log{Data: data}).(error); !ok {
+			t.Error("Expected entry signed with an unpinned key to be rejected")
+		}
+	})
+
+	for _, seq := range []uint64{2, 3} {
+		if _, err := store.GetHashEntry("test_table", seq); err == nil {
+			t.Errorf("Rejected entry %d should not be stored", seq)
+		}
+	}
+}
+
 func TestFSMSnapshot(t *testing.T) {
 	tmpfile, err := os.CreateTemp("", "witnz-consensus-test-*.db")
 	if err != nil {
@@ -83,6 +568,7 @@ func TestFSMSnapshot(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Snapshot failed: %v", err)
 	}
+	defer snapshot.Release()
 
 	if snapshot == nil {
 		t.Error("Snapshot should not be nil")
@@ -107,47 +593,63 @@ func TestFSMSnapshotPersistAndRestore(t *testing.T) {
 	testEntry := &LogEntry{
 		Type:      LogEntryHashChain,
 		TableName: "snapshot_test_table",
-		Data: map[string]interface{}{
-			"sequence_num":   float64(1),
-			"data_hash":      "snapshot_test_hash",
-			"operation_type": "INSERT",
-			"record_id":      "100",
+		HashChain: &HashChainPayload{
+			DataHash:      "snapshot_test_hash",
+			OperationType: "INSERT",
+			RecordID:      "100",
+			LSN:           100,
 		},
 		Timestamp: time.Now(),
 	}
 
-	data, _ := json.Marshal(testEntry)
+	data, _ := marshalLogEntry(testEntry, nil)
 	fsm.Apply(&raft.Log{Data: data})
 
+	checkpoint := &storage.MerkleCheckpoint{
+		TableName:   "snapshot_test_table",
+		SequenceNum: 1,
+		MerkleRoot:  "snapshot_root",
+		Signatures:  map[string]string{"node1": "sig"},
+	}
+	if err := store.SaveMerkleCheckpoint(checkpoint); err != nil {
+		t.Fatalf("SaveMerkleCheckpoint failed: %v", err)
+	}
+	if err := store.SaveFinding(&storage.Finding{ID: "f-1", TableName: "snapshot_test_table", Kind: storage.FindingDelete, Status: storage.FindingOpen}); err != nil {
+		t.Fatalf("SaveFinding failed: %v", err)
+	}
+
 	snapshot, err := fsm.Snapshot()
 	if err != nil {
 		t.Fatalf("Snapshot failed: %v", err)
 	}
 
+	// Entries applied after Snapshot must not leak into the persisted state
+	later := &LogEntry{
+		Type:      LogEntryHashChain,
+		TableName: "snapshot_test_table",
+		HashChain: &HashChainPayload{
+			DataHash:      "later_hash",
+			OperationType: "INSERT",
+			RecordID:      "101",
+			LSN:           200,
+		},
+		Timestamp: time.Now(),
+	}
+	data, _ = marshalLogEntry(later, nil)
+	done := make(chan interface{})
+	go func() { done <- fsm.Apply(&raft.Log{Data: data}) }()
+
 	var buf mockSnapshotSink
 	if err := snapshot.Persist(&buf); err != nil {
 		t.Fatalf("Persist failed: %v", err)
 	}
+	snapshot.Release()
+	<-done
 
 	if buf.Len() == 0 {
 		t.Fatal("Snapshot buffer should not be empty")
 	}
 
-	var snapshotData struct {
-		HashEntries []storage.HashEntry `json:"hash_entries"`
-	}
-	if err := json.Unmarshal(buf.Bytes(), &snapshotData); err != nil {
-		t.Fatalf("Failed to unmarshal snapshot: %v", err)
-	}
-
-	if len(snapshotData.HashEntries) != 1 {
-		t.Fatalf("Expected 1 hash entry in snapshot, got %d", len(snapshotData.HashEntries))
-	}
-
-	if snapshotData.HashEntries[0].DataHash != "snapshot_test_hash" {
-		t.Errorf("Expected data hash 'snapshot_test_hash', got '%s'", snapshotData.HashEntries[0].DataHash)
-	}
-
 	store.Close()
 
 	tmpfile2, _ := os.CreateTemp("", "witnz-consensus-restore-*.db")
@@ -157,6 +659,19 @@ func TestFSMSnapshotPersistAndRestore(t *testing.T) {
 	store2, _ := storage.New(tmpfile2.Name())
 	defer store2.Close()
 
+	stale := &storage.HashEntry{TableName: "stale_table", SequenceNum: 1, DataHash: "stale"}
+	if err := store2.SaveHashEntry(stale); err != nil {
+		t.Fatalf("SaveHashEntry failed: %v", err)
+	}
+	witnessed := &storage.Finding{ID: "w-1", Kind: storage.FindingLeaderDivergence, DetectedBy: storage.DetectedByWitness, Status: storage.FindingOpen}
+	if err := store2.SaveFinding(witnessed); err != nil {
+		t.Fatalf("SaveFinding failed: %v", err)
+	}
+	slot := &storage.Finding{ID: "s-1", TableName: "*", Kind: storage.FindingDetectorTamper, DetectedBy: storage.DetectedByWatchdog, Status: storage.FindingOpen, Local: true}
+	if err := store2.SaveFinding(slot); err != nil {
+		t.Fatalf("SaveFinding failed: %v", err)
+	}
+
 	fsm2 := NewFSM(store2)
 
 	if err := fsm2.Restore(&mockReadCloser{data: buf.Bytes()}); err != nil {
@@ -171,6 +686,76 @@ func TestFSMSnapshotPersistAndRestore(t *testing.T) {
 	if restored.DataHash != "snapshot_test_hash" {
 		t.Errorf("Expected restored data hash 'snapshot_test_hash', got '%s'", restored.DataHash)
 	}
+
+	if _, err := store2.GetHashEntry("snapshot_test_table", 2); err == nil {
+		t.Error("Entry applied after Snapshot should not be in the snapshot")
+	}
+
+	restoredCheckpoint, err := store2.GetLatestMerkleCheckpoint("snapshot_test_table")
+	if err != nil {
+		t.Fatalf("Failed to get restored checkpoint: %v", err)
+	}
+	if restoredCheckpoint.MerkleRoot != "snapshot_root" || restoredCheckpoint.Signatures["node1"] != "sig" {
+		t.Errorf("Unexpected restored checkpoint: %+v", restoredCheckpoint)
+	}
+
+	if _, err := store2.GetFinding("f-1"); err != nil {
+		t.Errorf("Expected finding to be restored: %v", err)
+	}
+	if _, err := store2.GetFinding("w-1"); err != nil {
+		t.Errorf("Expected local witness finding to be kept: %v", err)
+	}
+	if _, err := store2.GetFinding("s-1"); err != nil {
+		t.Errorf("Expected local slot finding to be kept: %v", err)
+	}
+	if _, err := store2.GetLatestHashEntry("stale_table"); err == nil {
+		t.Error("Expected stale state to be cleared by restore")
+	}
+
+	t.Run("CorruptSnapshot", func(t *testing.T) {
+		corrupt := append([]byte(nil), buf.Bytes()...)
+		corrupt = corrupt[:len(corrupt)-12]
+
+		if err := fsm2.Restore(&mockReadCloser{data: corrupt}); err == nil {
+			t.Fatal("Expected truncated snapshot to be rejected")
+		}
+		if _, err := store2.GetHashEntry("snapshot_test_table", 1); err != nil {
+			t.Errorf("Expected state to be unchanged after failed restore: %v", err)
+		}
+	})
+}
+
+func TestFSMRestoreLegacySnapshot(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-consensus-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	legacy, _ := json.Marshal(map[string]interface{}{
+		"hash_entries": []storage.HashEntry{
+			{TableName: "legacy_table", SequenceNum: 1, DataHash: "legacy_hash"},
+		},
+	})
+
+	if err := NewFSM(store).Restore(&mockReadCloser{data: legacy}); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	restored, err := store.GetHashEntry("legacy_table", 1)
+	if err != nil {
+		t.Fatalf("Failed to get restored entry: %v", err)
+	}
+	if restored.DataHash != "legacy_hash" {
+		t.Errorf("Expected legacy_hash, got %s", restored.DataHash)
+	}
 }
 
 type mockSnapshotSink struct {
@@ -221,3 +806,182 @@ func (m *mockReadCloser) Read(p []byte) (n int, err error) {
 func (m *mockReadCloser) Close() error {
 	return nil
 }
+
+type tableSetRecorder struct {
+	sets [][]storage.ProtectedTable
+}
+
+func (r *tableSetRecorder) ObserveTableSet(tables []storage.ProtectedTable) {
+	r.sets = append(r.sets, tables)
+}
+
+func TestFSMApplyTableSet(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-consensus-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	recorder := &tableSetRecorder{}
+	fsm := NewFSM(store)
+	fsm.SetTableSetObserver(recorder)
+
+	tables := []storage.ProtectedTable{
+		{Name: "audit_log", VerifyInterval: "30m"},
+		{Name: "payments"},
+	}
+	data, err := marshalLogEntry(&LogEntry{
+		Type:      LogEntryTableSet,
+		TableSet:  &TableSetPayload{Tables: tables},
+		Timestamp: time.Now(),
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to marshal entry: %v", err)
+	}
+
+	if result := fsm.Apply(&raft.Log{Index: 7, Data: data}); result != nil {
+		t.Fatalf("Expected table set to apply, got %v", result)
+	}
+
+	stored, err := store.GetProtectedTables()
+	if err != nil || len(stored) != 2 || stored[0] != tables[0] {
+		t.Errorf("Expected stored table set %+v, got %+v (err=%v)", tables, stored, err)
+	}
+	if len(recorder.sets) != 1 || len(recorder.sets[0]) != 2 {
+		t.Fatalf("Expected observer to see one table set, got %+v", recorder.sets)
+	}
+
+	t.Run("RestoreNotifiesObserver", func(t *testing.T) {
+		snapshot, err := fsm.Snapshot()
+		if err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+		sink := &mockSnapshotSink{}
+		if err := snapshot.Persist(sink); err != nil {
+			t.Fatalf("Persist failed: %v", err)
+		}
+		snapshot.Release()
+
+		store2, err := storage.New(filepath.Join(t.TempDir(), "witnz.db"))
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		defer store2.Close()
+
+		restored := &tableSetRecorder{}
+		fsm2 := NewFSM(store2)
+		fsm2.SetTableSetObserver(restored)
+
+		if err := fsm2.Restore(&mockReadCloser{data: sink.buf}); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		if len(restored.sets) != 1 || restored.sets[0][1].Name != "payments" {
+			t.Errorf("Expected restored table set to be observed, got %+v", restored.sets)
+		}
+	})
+}
+
+func TestFSMApplyClusterPolicy(t *testing.T) {
+	store, err := storage.New(filepath.Join(t.TempDir(), "witnz.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	fsm := NewFSM(store)
+
+	apply := func(entry *LogEntry) interface{} {
+		entry.Timestamp = time.Now()
+		data, err := marshalLogEntry(entry, nil)
+		if err != nil {
+			t.Fatalf("Failed to marshal entry: %v", err)
+		}
+		return fsm.Apply(&raft.Log{Data: data})
+	}
+	newPolicy := func(algorithm string) *storage.ClusterPolicy {
+		return &storage.ClusterPolicy{
+			HashAlgorithm:   algorithm,
+			EncodingVersion: 1,
+			Tables:          []string{"audit_log"},
+		}
+	}
+
+	if result := apply(&LogEntry{Type: LogEntryClusterPolicy, ClusterPolicy: newPolicy("sha256")}); result != nil {
+		t.Fatalf("Expected policy to apply, got %v", result)
+	}
+
+	t.Run("SamePolicy", func(t *testing.T) {
+		if result := apply(&LogEntry{Type: LogEntryClusterPolicy, ClusterPolicy: newPolicy("sha256")}); result != nil {
+			t.Errorf("Expected an identical policy to be accepted, got %v", result)
+		}
+	})
+
+	t.Run("ConflictingPolicy", func(t *testing.T) {
+		if _, ok := apply(&LogEntry{Type: LogEntryClusterPolicy, ClusterPolicy: newPolicy("xxhash64")}).(error); !ok {
+			t.Error("Expected a different policy to be rejected")
+		}
+		if policy, _ := store.GetClusterPolicy(); policy.HashAlgorithm != "sha256" {
+			t.Errorf("Expected the first policy to be kept, got %+v", policy)
+		}
+	})
+
+	t.Run("FollowsTableSet", func(t *testing.T) {
+		tables := []storage.ProtectedTable{{Name: "payments"}, {Name: "audit_log"}, {Name: "access_log", Pattern: "*_log"}}
+		set := &TableSetPayload{Tables: tables, Patterns: []string{"*_log"}}
+		if result := apply(&LogEntry{Type: LogEntryTableSet, TableSet: set}); result != nil {
+			t.Fatalf("Expected table set to apply, got %v", result)
+		}
+
+		policy, err := store.GetClusterPolicy()
+		if err != nil {
+			t.Fatalf("GetClusterPolicy failed: %v", err)
+		}
+		if len(policy.Tables) != 2 || policy.Tables[0] != "audit_log" || policy.Tables[1] != "payments" {
+			t.Errorf("Expected policy tables [audit_log payments], got %v", policy.Tables)
+		}
+		if len(policy.Patterns) != 1 || policy.Patterns[0] != "*_log" {
+			t.Errorf("Expected policy patterns [*_log], got %v", policy.Patterns)
+		}
+	})
+}
+
+func TestFSMApplyDatabaseIdentity(t *testing.T) {
+	store, err := storage.New(filepath.Join(t.TempDir(), "witnz.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	fsm := NewFSM(store)
+
+	identity := &storage.DatabaseIdentity{
+		Source:     "billing",
+		SystemID:   "7300000000000000001",
+		Timeline:   2,
+		XLogPos:    0x16B3748,
+		NodeID:     "node1",
+		ObservedAt: time.Now(),
+	}
+	data, err := marshalLogEntry(&LogEntry{Type: LogEntryDatabaseIdentity, DatabaseIdentity: identity, Timestamp: identity.ObservedAt}, nil)
+	if err != nil {
+		t.Fatalf("Failed to marshal entry: %v", err)
+	}
+	if result := fsm.Apply(&raft.Log{Data: data}); result != nil {
+		t.Fatalf("Expected identity to apply, got %v", result)
+	}
+
+	stored, err := store.GetDatabaseIdentity("billing")
+	if err != nil {
+		t.Fatalf("GetDatabaseIdentity failed: %v", err)
+	}
+	if stored == nil || stored.SystemID != identity.SystemID || stored.Timeline != 2 || stored.XLogPos != identity.XLogPos {
+		t.Errorf("Expected identity %+v, got %+v", identity, stored)
+	}
+}
@@ -4,11 +4,45 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sort"
 	"time"
 
 	"github.com/hashicorp/raft"
 )
 
+// NodeHealth is what a node reports about itself when the leader picks a
+// rotation target
+type NodeHealth struct {
+	NodeID       string `json:"node_id"`
+	CDCHealthy   bool   `json:"cdc_healthy"`
+	CaughtUp     bool   `json:"caught_up"`
+	AppliedIndex uint64 `json:"applied_index"`
+}
+
+// SetCDCHealth sets the check reported as this node's CDC stream health
+func (n *Node) SetCDCHealth(fn func() bool) {
+	n.cdcHealthy = fn
+}
+
+// Health returns this node's CDC health and replication progress
+func (n *Node) Health() NodeHealth {
+	health := NodeHealth{
+		NodeID:     n.config.NodeID,
+		CDCHealthy: n.cdcHealthy != nil && n.cdcHealthy(),
+		CaughtUp:   n.IsCaughtUp(),
+	}
+	if n.raft != nil {
+		health.AppliedIndex = n.raft.AppliedIndex()
+	}
+	return health
+}
+
+type transferCandidate struct {
+	id     raft.ServerID
+	addr   raft.ServerAddress
+	health NodeHealth
+}
+
 type LeadershipRotator struct {
 	node     *Node
 	interval time.Duration
@@ -66,19 +100,63 @@ func (r *LeadershipRotator) transferLeadership() error {
 		return nil
 	}
 
-	currentLeader := r.node.config.NodeID
+	return r.node.transferLeadership(context.Background(), r.logger)
+}
 
-	r.logger.Info("Initiating leadership transfer", "current_leader", currentLeader)
+// HandOffLeadership transfers leadership before this node shuts down. It does
+// nothing unless the node leads a cluster with other voters. It gives up
+// waiting for the transfer when ctx is done.
+func (n *Node) HandOffLeadership(ctx context.Context) error {
+	if !n.IsLeader() {
+		return nil
+	}
 
-	future := r.node.raft.LeadershipTransfer()
+	future := n.raft.GetConfiguration()
 	if err := future.Error(); err != nil {
+		return fmt.Errorf("failed to read cluster configuration: %w", err)
+	}
+
+	for _, server := range future.Configuration().Servers {
+		if server.ID != raft.ServerID(n.config.NodeID) && server.Suffrage == raft.Voter {
+			return n.transferLeadership(ctx, logger)
+		}
+	}
+
+	return nil
+}
+
+func (n *Node) transferLeadership(ctx context.Context, logger *slog.Logger) error {
+	currentLeader := n.config.NodeID
+
+	target := chooseTransferTarget(n.transferCandidates(logger))
+
+	var future raft.Future
+	if target != nil {
+		logger.Info("Initiating leadership transfer",
+			"current_leader", currentLeader,
+			"target", target.id,
+			"applied_index", target.health.AppliedIndex)
+		future = n.raft.LeadershipTransferToServer(target.id, target.addr)
+	} else {
+		// Rotating to a node with a stalled CDC stream still beats keeping
+		// one leader indefinitely; followers forward what they observe
+		logger.Warn("No follower with a healthy CDC stream, letting Raft pick the target",
+			"current_leader", currentLeader)
+		future = n.raft.LeadershipTransfer()
+	}
+
+	if err := futureError(ctx, future); err != nil {
 		return fmt.Errorf("leadership transfer failed: %w", err)
 	}
 
-	time.Sleep(500 * time.Millisecond)
+	select {
+	case <-time.After(500 * time.Millisecond):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 
-	newLeaderAddr := r.node.raft.Leader()
-	r.logger.Info("Leadership transferred successfully",
+	newLeaderAddr := n.raft.Leader()
+	logger.Info("Leadership transferred successfully",
 		"old_leader", currentLeader,
 		"new_leader", newLeaderAddr,
 	)
@@ -86,6 +164,70 @@ func (r *LeadershipRotator) transferLeadership() error {
 	return nil
 }
 
+// futureError waits for a Raft future until ctx is done. The future keeps
+// running after ctx is done and is resolved when Raft shuts down.
+func futureError(ctx context.Context, future raft.Future) error {
+	done := make(chan error, 1)
+	go func() { done <- future.Error() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// transferCandidates asks every other voter for its health. Unreachable
+// nodes are left out.
+func (n *Node) transferCandidates(logger *slog.Logger) []transferCandidate {
+	future := n.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		logger.Warn("Failed to read cluster configuration", "error", err)
+		return nil
+	}
+
+	var candidates []transferCandidate
+	for _, server := range future.Configuration().Servers {
+		if server.ID == raft.ServerID(n.config.NodeID) || server.Suffrage != raft.Voter {
+			continue
+		}
+
+		var health NodeHealth
+		if err := callNode(string(server.Address), "Health", &Empty{}, &health); err != nil {
+			logger.Debug("Skipping unreachable rotation candidate", "node", server.ID, "error", err)
+			continue
+		}
+
+		candidates = append(candidates, transferCandidate{id: server.ID, addr: server.Address, health: health})
+	}
+
+	return candidates
+}
+
+// chooseTransferTarget picks the caught-up node with a healthy CDC stream
+// that has applied the most entries, or nil when there is none
+func chooseTransferTarget(candidates []transferCandidate) *transferCandidate {
+	var eligible []transferCandidate
+	for _, c := range candidates {
+		if c.health.CDCHealthy && c.health.CaughtUp {
+			eligible = append(eligible, c)
+		}
+	}
+	if len(eligible) == 0 {
+		return nil
+	}
+
+	sort.Slice(eligible, func(i, j int) bool {
+		if eligible[i].health.AppliedIndex != eligible[j].health.AppliedIndex {
+			return eligible[i].health.AppliedIndex > eligible[j].health.AppliedIndex
+		}
+		return eligible[i].id < eligible[j].id
+	})
+
+	return &eligible[0]
+}
+
 func (r *LeadershipRotator) Stop() {
 	close(r.stopCh)
 }
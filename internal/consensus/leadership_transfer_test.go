@@ -6,6 +6,8 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"github.com/hashicorp/raft"
 )
 
 func TestLeadershipRotator_Start(t *testing.T) {
@@ -107,3 +109,43 @@ func TestLeadershipRotator_transferLeadership(t *testing.T) {
 		}
 	})
 }
+
+func TestChooseTransferTarget(t *testing.T) {
+	candidate := func(id string, cdcHealthy, caughtUp bool, applied uint64) transferCandidate {
+		return transferCandidate{
+			id:     raft.ServerID(id),
+			addr:   raft.ServerAddress(id + ":7000"),
+			health: NodeHealth{NodeID: id, CDCHealthy: cdcHealthy, CaughtUp: caughtUp, AppliedIndex: applied},
+		}
+	}
+
+	t.Run("prefers healthy CDC stream", func(t *testing.T) {
+		target := chooseTransferTarget([]transferCandidate{
+			candidate("node2", false, true, 200),
+			candidate("node3", true, true, 150),
+		})
+		if target == nil || target.id != "node3" {
+			t.Errorf("expected node3, got %+v", target)
+		}
+	})
+
+	t.Run("prefers most applied entries", func(t *testing.T) {
+		target := chooseTransferTarget([]transferCandidate{
+			candidate("node2", true, true, 100),
+			candidate("node3", true, true, 150),
+		})
+		if target == nil || target.id != "node3" {
+			t.Errorf("expected node3, got %+v", target)
+		}
+	})
+
+	t.Run("skips nodes still catching up", func(t *testing.T) {
+		target := chooseTransferTarget([]transferCandidate{
+			candidate("node2", true, false, 100),
+			candidate("node3", false, true, 150),
+		})
+		if target != nil {
+			t.Errorf("expected no target, got %+v", target)
+		}
+	})
+}
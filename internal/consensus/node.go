@@ -2,17 +2,27 @@ package consensus
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net"
+	"net/rpc"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/raft"
+	"github.com/witnz/witnz/internal/logging"
+	"github.com/witnz/witnz/internal/signing"
 	"github.com/witnz/witnz/internal/storage"
 )
 
+var (
+	logger = logging.Logger("consensus")
+	// raftLogger carries the output of hashicorp/raft itself
+	raftLogger = logging.HCLogger(logging.Logger("raft"))
+)
+
 type NodeConfig struct {
 	NodeID        string
 	BindAddr      string
@@ -22,25 +32,125 @@ type NodeConfig struct {
 	PeerAddrs     map[string]string
 	JoinRetries   int
 	JoinRetryWait time.Duration
+	// Signer signs proposed log entries and checkpoints; KeyRing holds the
+	// pinned public keys used to verify them. Both are optional.
+	Signer  *signing.Signer
+	KeyRing *signing.KeyRing
+	// Approvers checks the signature of replicated allowances. Without it
+	// every allowance entry is rejected.
+	Approvers AllowanceVerifier
+
+	// Raft timing, snapshot and log compaction settings. Zero values keep
+	// the hashicorp/raft defaults, which suit a LAN.
+	HeartbeatTimeout  time.Duration
+	ElectionTimeout   time.Duration
+	SnapshotInterval  time.Duration
+	SnapshotThreshold uint64
+	TrailingLogs      uint64
+	RetainSnapshots   int
+	MaxAppendEntries  int
+	// CatchUpLag is how many entries a follower may fall behind the
+	// leader's commit index before verification and WAL acks are held back
+	// again (default: DefaultCatchUpLag)
+	CatchUpLag uint64
+}
+
+// DefaultRetainSnapshots is the number of Raft snapshots kept on disk
+const DefaultRetainSnapshots = 2
+
+// raftConfig applies the tuning in c to the hashicorp/raft defaults
+func (c *NodeConfig) raftConfig() (*raft.Config, error) {
+	raftConfig := raft.DefaultConfig()
+	raftConfig.LocalID = raft.ServerID(c.NodeID)
+	raftConfig.Logger = raftLogger
+
+	if c.HeartbeatTimeout > 0 {
+		raftConfig.HeartbeatTimeout = c.HeartbeatTimeout
+		// Keep the default lease-to-heartbeat ratio so a slow link does not
+		// make the leader step down before followers would time out
+		raftConfig.LeaderLeaseTimeout = c.HeartbeatTimeout / 2
+	}
+	if c.ElectionTimeout > 0 {
+		raftConfig.ElectionTimeout = c.ElectionTimeout
+	}
+	if c.SnapshotInterval > 0 {
+		raftConfig.SnapshotInterval = c.SnapshotInterval
+	}
+	if c.SnapshotThreshold > 0 {
+		raftConfig.SnapshotThreshold = c.SnapshotThreshold
+	}
+	if c.TrailingLogs > 0 {
+		raftConfig.TrailingLogs = c.TrailingLogs
+	}
+	if c.MaxAppendEntries > 0 {
+		raftConfig.MaxAppendEntries = c.MaxAppendEntries
+	}
+
+	if err := raft.ValidateConfig(raftConfig); err != nil {
+		return nil, fmt.Errorf("invalid raft configuration: %w", err)
+	}
+
+	return raftConfig, nil
+}
+
+func (c *NodeConfig) catchUpLag() uint64 {
+	if c.CatchUpLag > 0 {
+		return c.CatchUpLag
+	}
+	return DefaultCatchUpLag
+}
+
+func (c *NodeConfig) retainSnapshots() int {
+	if c.RetainSnapshots > 0 {
+		return c.RetainSnapshots
+	}
+	return DefaultRetainSnapshots
 }
 
 type Node struct {
-	config  *NodeConfig
-	raft    *raft.Raft
-	fsm     *FSM
-	storage *storage.Storage
+	config      *NodeConfig
+	raft        *raft.Raft
+	fsm         *FSM
+	storage     *storage.Storage
+	transport   *raft.NetworkTransport
+	logStore    *BoltStore
+	stableStore *BoltStore
+	rpcServer   *rpc.Server
+	observer    HashEntryObserver
+	tables      TableSetObserver
+
+	forwardHandler ForwardHandler
+	cdcHealthy     func() bool
+
+	// caughtUp is closed while the node is caught up with the leader and
+	// replaced by an open channel when it falls behind again
+	caughtUpMu    sync.Mutex
+	caughtUp      chan struct{}
+	catchUpTarget atomic.Uint64
+	stopCh        chan struct{}
+	stopOnce      sync.Once
 }
 
 func NewNode(cfg *NodeConfig, store *storage.Storage) (*Node, error) {
 	return &Node{
-		config:  cfg,
-		storage: store,
+		config:   cfg,
+		storage:  store,
+		caughtUp: make(chan struct{}),
+		stopCh:   make(chan struct{}),
 	}, nil
 }
 
+// SetHashEntryObserver registers an observer for hash entries applied by the
+// FSM. It must be called before Start.
+func (n *Node) SetHashEntryObserver(observer HashEntryObserver) {
+	n.observer = observer
+}
+
 func (n *Node) Start(ctx context.Context) error {
-	raftConfig := raft.DefaultConfig()
-	raftConfig.LocalID = raft.ServerID(n.config.NodeID)
+	raftConfig, err := n.config.raftConfig()
+	if err != nil {
+		return err
+	}
 
 	raftDir := filepath.Join(n.config.DataDir, "raft")
 	if err := os.MkdirAll(raftDir, 0755); err != nil {
@@ -51,13 +161,15 @@ func (n *Node) Start(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to create log store: %w", err)
 	}
+	n.logStore = logStore
 
 	stableStore, err := NewBoltStore(filepath.Join(raftDir, "raft-stable.db"))
 	if err != nil {
 		return fmt.Errorf("failed to create stable store: %w", err)
 	}
+	n.stableStore = stableStore
 
-	snapshotStore, err := raft.NewFileSnapshotStore(raftDir, 2, os.Stderr)
+	snapshotStore, err := raft.NewFileSnapshotStoreWithLogger(raftDir, n.config.retainSnapshots(), raftLogger.Named("snapshot"))
 	if err != nil {
 		return fmt.Errorf("failed to create snapshot store: %w", err)
 	}
@@ -67,12 +179,37 @@ func (n *Node) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to resolve address: %w", err)
 	}
 
-	transport, err := raft.NewTCPTransport(n.config.BindAddr, addr, 3, 10*time.Second, os.Stderr)
+	n.rpcServer = rpc.NewServer()
+	if err := n.rpcServer.RegisterName("Cluster", &ClusterService{node: n}); err != nil {
+		return fmt.Errorf("failed to register cluster RPC: %w", err)
+	}
+
+	streamLayer, err := newMuxStreamLayer(n.config.BindAddr, addr, n.serveClusterRPC)
 	if err != nil {
 		return fmt.Errorf("failed to create transport: %w", err)
 	}
 
+	transport := raft.NewNetworkTransportWithConfig(&raft.NetworkTransportConfig{
+		Stream:  streamLayer,
+		MaxPool: 3,
+		Timeout: 10 * time.Second,
+		Logger:  raftLogger.Named("raft-net"),
+	})
+	n.transport = transport
+
 	n.fsm = NewFSM(n.storage)
+	if n.config.KeyRing != nil {
+		n.fsm.SetKeyRing(n.config.KeyRing)
+	}
+	if n.config.Approvers != nil {
+		n.fsm.SetAllowanceVerifier(n.config.Approvers)
+	}
+	if n.observer != nil {
+		n.fsm.SetObserver(n.observer)
+	}
+	if n.tables != nil {
+		n.fsm.SetTableSetObserver(n.tables)
+	}
 
 	ra, err := raft.NewRaft(raftConfig, n.fsm, logStore, stableStore, snapshotStore, transport)
 	if err != nil {
@@ -117,6 +254,8 @@ func (n *Node) Start(ctx context.Context) error {
 		}
 	}
 
+	go n.trackCatchUp()
+
 	return nil
 }
 
@@ -153,31 +292,94 @@ func (n *Node) waitForLeader() error {
 }
 
 func (n *Node) Stop() error {
+	n.stopOnce.Do(func() { close(n.stopCh) })
+
 	if n.raft != nil {
 		future := n.raft.Shutdown()
 		if err := future.Error(); err != nil {
 			return fmt.Errorf("failed to shutdown raft: %w", err)
 		}
 	}
+	if n.transport != nil {
+		if err := n.transport.Close(); err != nil {
+			return fmt.Errorf("failed to close transport: %w", err)
+		}
+	}
+	for _, store := range []*BoltStore{n.logStore, n.stableStore} {
+		if store != nil {
+			if err := store.Close(); err != nil {
+				return fmt.Errorf("failed to close raft store: %w", err)
+			}
+		}
+	}
 	return nil
 }
 
 func (n *Node) ApplyLog(entry *LogEntry) error {
-	if n.raft.State() != raft.Leader {
-		return fmt.Errorf("not the leader")
+	_, err := n.apply(entry)
+	return err
+}
+
+// ApplyHashEntry replicates a hash chain entry and returns the sequence
+// number the FSM assigned to it
+func (n *Node) ApplyHashEntry(entry *LogEntry) (uint64, error) {
+	if entry.Type != LogEntryHashChain {
+		return 0, fmt.Errorf("not a hash chain entry: %s", entry.Type)
+	}
+
+	resp, err := n.apply(entry)
+	if err != nil {
+		return 0, err
 	}
 
-	data, err := json.Marshal(entry)
+	seq, ok := resp.(uint64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected FSM response: %T", resp)
+	}
+
+	return seq, nil
+}
+
+func (n *Node) apply(entry *LogEntry) (interface{}, error) {
+	future, err := n.propose(entry)
 	if err != nil {
-		return fmt.Errorf("failed to marshal log entry: %w", err)
+		return nil, err
 	}
 
-	future := n.raft.Apply(data, 10*time.Second)
+	return applyResponse(future)
+}
+
+// propose submits an entry to Raft without waiting for it to be applied
+func (n *Node) propose(entry *LogEntry) (raft.ApplyFuture, error) {
+	if !n.IsLeader() {
+		return nil, fmt.Errorf("not the leader")
+	}
+
+	data, err := n.encodeLogEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.raft.Apply(data, 10*time.Second), nil
+}
+
+// applyResponse waits for a proposed entry and returns the FSM response,
+// treating an error response as a failure
+func applyResponse(future raft.ApplyFuture) (interface{}, error) {
 	if err := future.Error(); err != nil {
-		return fmt.Errorf("failed to apply log: %w", err)
+		return nil, fmt.Errorf("failed to apply log: %w", err)
 	}
 
-	return nil
+	if err, ok := future.Response().(error); ok {
+		return nil, err
+	}
+
+	return future.Response(), nil
+}
+
+// encodeLogEntry marshals an entry, signing it when the node has a signing key
+func (n *Node) encodeLogEntry(entry *LogEntry) ([]byte, error) {
+	return marshalLogEntry(entry, n.config.Signer)
 }
 
 // ApplyCheckpoint replicates a Merkle checkpoint to all followers via Raft
@@ -187,27 +389,95 @@ func (n *Node) ApplyCheckpoint(checkpoint *storage.MerkleCheckpoint) error {
 		return fmt.Errorf("not the leader, cannot replicate checkpoint")
 	}
 
-	// Prepare checkpoint data for Raft log entry
-	data := map[string]interface{}{
-		"sequence_num":   checkpoint.SequenceNum,
-		"merkle_root":    checkpoint.MerkleRoot,
-		"record_count":   checkpoint.RecordCount,
-		"hash_algorithm": checkpoint.HashAlgorithm,
+	entry := &LogEntry{
+		Type:       LogEntryCheckpoint,
+		TableName:  checkpoint.TableName,
+		Checkpoint: checkpoint,
+		Timestamp:  checkpoint.Timestamp,
+	}
+
+	return n.ApplyLog(entry)
+}
+
+// SubmitCheckpointSignature attests a checkpoint this node verified itself.
+// Followers forward the signature to the leader over cluster RPC.
+func (n *Node) SubmitCheckpointSignature(sig *storage.CheckpointSignature) error {
+	if n.IsLeader() {
+		return n.replicateCheckpointSignature(sig)
+	}
+
+	return n.callLeader("SubmitCheckpointSignature", sig, &Empty{})
+}
+
+func (n *Node) replicateCheckpointSignature(sig *storage.CheckpointSignature) error {
+	if !n.IsLeader() {
+		return fmt.Errorf("not the leader, cannot replicate checkpoint signature")
 	}
 
-	// Include leaf_map and internal_nodes if present (for optimization)
-	if len(checkpoint.LeafMap) > 0 {
-		data["leaf_map"] = checkpoint.LeafMap
+	if n.config.KeyRing != nil {
+		payload := storage.CheckpointSigningPayload(sig.TableName, sig.SequenceNum, sig.MerkleRoot)
+		if err := n.config.KeyRing.Verify(sig.NodeID, payload, sig.Signature); err != nil {
+			return err
+		}
 	}
-	if len(checkpoint.InternalNodes) > 0 {
-		data["internal_nodes"] = checkpoint.InternalNodes
+
+	entry := &LogEntry{
+		Type:                LogEntryCheckpointSignature,
+		TableName:           sig.TableName,
+		CheckpointSignature: sig,
+		Timestamp:           time.Now(),
 	}
 
+	return n.ApplyLog(entry)
+}
+
+// ApplyFinding replicates a tamper finding to all nodes via Raft
+func (n *Node) ApplyFinding(finding *storage.Finding) error {
 	entry := &LogEntry{
-		Type:      LogEntryCheckpoint,
-		TableName: checkpoint.TableName,
-		Data:      data,
-		Timestamp: checkpoint.Timestamp,
+		Type:      LogEntryFinding,
+		TableName: finding.TableName,
+		Finding:   finding,
+		Timestamp: finding.DetectedAt,
+	}
+
+	return n.ApplyLog(entry)
+}
+
+// ApplyFindingStatus replicates an acknowledge or resolve action on a finding
+func (n *Node) ApplyFindingStatus(id string, status storage.FindingStatus, actor, note string) error {
+	entry := &LogEntry{
+		Type: LogEntryFindingStatus,
+		FindingStatus: &FindingStatusPayload{
+			ID:     id,
+			Status: status,
+			Actor:  actor,
+			Note:   note,
+		},
+		Timestamp: time.Now(),
+	}
+
+	return n.ApplyLog(entry)
+}
+
+// ApplyAllowance replicates a verified allowance for an UPDATE or DELETE
+func (n *Node) ApplyAllowance(allowance *storage.Allowance) error {
+	entry := &LogEntry{
+		Type:      LogEntryAllowance,
+		TableName: allowance.TableName,
+		Allowance: allowance,
+		Timestamp: allowance.SubmittedAt,
+	}
+
+	return n.ApplyLog(entry)
+}
+
+// ApplyDatabaseIdentity replicates the identity a source database reported
+// on connect
+func (n *Node) ApplyDatabaseIdentity(identity *storage.DatabaseIdentity) error {
+	entry := &LogEntry{
+		Type:             LogEntryDatabaseIdentity,
+		DatabaseIdentity: identity,
+		Timestamp:        identity.ObservedAt,
 	}
 
 	return n.ApplyLog(entry)
@@ -266,37 +536,3 @@ func (n *Node) TransferLeadership() error {
 
 	return nil
 }
-
-// SyncHashChainFromLeader synchronizes hash chain from the leader if this node is a follower
-// This ensures that a restarted follower receives hash entries that were created while it was offline
-// Raft automatically replicates logs and snapshots to followers, so we just need to wait for sync
-func (n *Node) SyncHashChainFromLeader(ctx context.Context) error {
-	// Wait for cluster to stabilize and leader to be elected
-	fmt.Println("Waiting for Raft cluster to stabilize...")
-	time.Sleep(3 * time.Second)
-
-	// If we are the leader, no sync needed
-	if n.IsLeader() {
-		fmt.Println("This node is the leader, no hash chain sync needed")
-		return nil
-	}
-
-	leaderAddr := n.Leader()
-	if leaderAddr == "" {
-		return fmt.Errorf("no leader elected yet, cannot sync hash chain")
-	}
-
-	fmt.Printf("This node is a follower, leader is: %s\n", leaderAddr)
-	fmt.Println("Hash chain will be automatically synchronized via Raft log/snapshot replication...")
-
-	// Raft automatically synchronizes logs and snapshots to followers
-	// When a follower rejoins, the leader sends missing log entries or snapshots
-	// The FSM.Apply() and FSM.Restore() methods handle hash chain reconstruction
-	// We just need to wait a bit for the synchronization to complete
-	fmt.Println("Waiting for Raft automatic synchronization (5 seconds)...")
-	time.Sleep(5 * time.Second)
-
-	fmt.Println("✓ Hash chain synchronization complete (Raft handles this automatically)")
-
-	return nil
-}
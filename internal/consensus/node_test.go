@@ -3,6 +3,7 @@ package consensus
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/witnz/witnz/internal/storage"
 )
@@ -203,3 +204,54 @@ func TestNodeStopBeforeStart(t *testing.T) {
 		t.Errorf("Stop should not fail: %v", err)
 	}
 }
+
+func TestNodeConfigRaftConfig(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		cfg := &NodeConfig{NodeID: "node1"}
+
+		raftConfig, err := cfg.raftConfig()
+		if err != nil {
+			t.Fatalf("raftConfig failed: %v", err)
+		}
+		if raftConfig.HeartbeatTimeout != time.Second || raftConfig.MaxAppendEntries != 64 {
+			t.Errorf("Expected hashicorp/raft defaults, got heartbeat %v, max append %d", raftConfig.HeartbeatTimeout, raftConfig.MaxAppendEntries)
+		}
+		if cfg.retainSnapshots() != DefaultRetainSnapshots {
+			t.Errorf("Expected %d retained snapshots, got %d", DefaultRetainSnapshots, cfg.retainSnapshots())
+		}
+	})
+
+	t.Run("CrossRegion", func(t *testing.T) {
+		cfg := &NodeConfig{
+			NodeID:            "node1",
+			HeartbeatTimeout:  3 * time.Second,
+			ElectionTimeout:   5 * time.Second,
+			SnapshotInterval:  5 * time.Minute,
+			SnapshotThreshold: 50000,
+			TrailingLogs:      20000,
+			RetainSnapshots:   5,
+			MaxAppendEntries:  256,
+		}
+
+		raftConfig, err := cfg.raftConfig()
+		if err != nil {
+			t.Fatalf("raftConfig failed: %v", err)
+		}
+		if raftConfig.ElectionTimeout != 5*time.Second || raftConfig.LeaderLeaseTimeout != 1500*time.Millisecond {
+			t.Errorf("Expected election 5s and lease 1.5s, got %v and %v", raftConfig.ElectionTimeout, raftConfig.LeaderLeaseTimeout)
+		}
+		if raftConfig.SnapshotThreshold != 50000 || raftConfig.TrailingLogs != 20000 || raftConfig.MaxAppendEntries != 256 {
+			t.Errorf("Unexpected compaction settings: %+v", raftConfig)
+		}
+		if cfg.retainSnapshots() != 5 {
+			t.Errorf("Expected 5 retained snapshots, got %d", cfg.retainSnapshots())
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		cfg := &NodeConfig{NodeID: "node1", HeartbeatTimeout: 3 * time.Second}
+		if _, err := cfg.raftConfig(); err == nil {
+			t.Error("Expected heartbeat above the election timeout to be rejected")
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package consensus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/witnz/witnz/internal/storage"
+)
+
+// PolicyReply carries the committed cluster policy, nil if none was
+// committed yet
+type PolicyReply struct {
+	Policy *storage.ClusterPolicy `json:"policy,omitempty"`
+}
+
+// PolicyMismatchError reports how a node's config differs from the cluster
+// policy committed to the Raft log
+type PolicyMismatchError struct {
+	ClusterDigest string
+	LocalDigest   string
+	Diff          []string
+}
+
+func (e *PolicyMismatchError) Error() string {
+	return fmt.Sprintf("local config does not match the cluster policy (cluster digest %.12s, local digest %.12s):\n  %s",
+		e.ClusterDigest, e.LocalDigest, strings.Join(e.Diff, "\n  "))
+}
+
+// CheckClusterPolicy compares local with the policy committed to the Raft
+// log and returns a PolicyMismatchError if they differ. When no policy was
+// committed yet, the bootstrap node commits local; other nodes start
+// unchecked. It waits for a leader so the comparison uses the cluster's
+// current policy rather than a stale local copy.
+func (n *Node) CheckClusterPolicy(ctx context.Context, local *storage.ClusterPolicy) error {
+	if err := n.waitForLeaderElected(ctx); err != nil {
+		return err
+	}
+
+	policy, err := n.clusterPolicy()
+	if err != nil {
+		return fmt.Errorf("failed to read cluster policy: %w", err)
+	}
+
+	if policy == nil {
+		if !n.config.Bootstrap {
+			logger.Warn("No cluster policy committed yet, config consistency is not checked")
+			return nil
+		}
+		if err := n.commitClusterPolicy(local); err != nil {
+			return fmt.Errorf("failed to commit cluster policy: %w", err)
+		}
+		logger.Info("Committed cluster policy", "digest", local.Digest())
+		return nil
+	}
+
+	if diff := policy.Diff(local); len(diff) > 0 {
+		return &PolicyMismatchError{
+			ClusterDigest: policy.Digest(),
+			LocalDigest:   local.Digest(),
+			Diff:          diff,
+		}
+	}
+
+	logger.Info("Config matches cluster policy", "digest", policy.Digest())
+	return nil
+}
+
+// waitForLeaderElected blocks until any node is leader
+func (n *Node) waitForLeaderElected(ctx context.Context) error {
+	ticker := time.NewTicker(catchUpPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if n.raft == nil {
+			return fmt.Errorf("raft not initialized")
+		}
+		if addr, _ := n.raft.LeaderWithID(); addr != "" {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("no leader elected: %w", ctx.Err())
+		case <-n.stopCh:
+			return fmt.Errorf("node stopped")
+		case <-ticker.C:
+		}
+	}
+}
+
+// clusterPolicy returns the leader's committed policy. The local copy is used
+// when the leader cannot be reached.
+func (n *Node) clusterPolicy() (*storage.ClusterPolicy, error) {
+	if n.IsLeader() {
+		return n.storage.GetClusterPolicy()
+	}
+
+	var reply PolicyReply
+	if err := n.callLeader("Policy", &Empty{}, &reply); err != nil {
+		logger.Warn("Failed to fetch cluster policy from leader, using local copy", "error", err)
+		return n.storage.GetClusterPolicy()
+	}
+	return reply.Policy, nil
+}
+
+func (n *Node) leaderPolicy() (*storage.ClusterPolicy, error) {
+	if !n.IsLeader() {
+		return nil, fmt.Errorf("not the leader")
+	}
+	return n.storage.GetClusterPolicy()
+}
+
+// commitClusterPolicy replicates the policy, forwarding it to the leader when
+// this node does not lead. The FSM keeps the first policy committed.
+func (n *Node) commitClusterPolicy(policy *storage.ClusterPolicy) error {
+	if n.IsLeader() {
+		return n.replicateClusterPolicy(policy)
+	}
+
+	args, err := n.newProposal(policy)
+	if err != nil {
+		return err
+	}
+
+	return n.callLeader("CommitPolicy", args, &Empty{})
+}
+
+func (n *Node) receiveClusterPolicy(args *SignedProposal) error {
+	var policy storage.ClusterPolicy
+	if err := n.openProposal(args, &policy); err != nil {
+		return err
+	}
+
+	return n.replicateClusterPolicy(&policy)
+}
+
+func (n *Node) replicateClusterPolicy(policy *storage.ClusterPolicy) error {
+	entry := &LogEntry{
+		Type:          LogEntryClusterPolicy,
+		ClusterPolicy: policy,
+		Timestamp:     time.Now(),
+	}
+
+	return n.ApplyLog(entry)
+}
@@ -0,0 +1,112 @@
+package consensus
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"time"
+
+	"github.com/witnz/witnz/internal/storage"
+)
+
+const clusterRPCTimeout = 15 * time.Second
+
+// Empty is the reply type of cluster RPCs that return nothing
+type Empty struct{}
+
+// ClusterService handles RPCs sent by other cluster nodes over the Raft port
+type ClusterService struct {
+	node *Node
+}
+
+// SubmitCheckpointSignature accepts a follower's checkpoint signature and
+// replicates it. Only the leader accepts submissions.
+func (s *ClusterService) SubmitCheckpointSignature(args *storage.CheckpointSignature, reply *Empty) error {
+	return s.node.replicateCheckpointSignature(args)
+}
+
+// ForwardHashEntries accepts hash entries a follower observed on its own CDC
+// stream. Only the leader accepts them; the reply is sent once they are applied.
+func (s *ClusterService) ForwardHashEntries(args *ForwardedEntries, reply *ForwardReply) error {
+	proposed, err := s.node.receiveForwarded(args)
+	if err != nil {
+		return err
+	}
+
+	reply.Proposed = proposed
+	return nil
+}
+
+// ProposeTableSet accepts a table set change made on a follower. Only the
+// leader accepts it; the reply is sent once the entry is applied.
+func (s *ClusterService) ProposeTableSet(args *SignedProposal, reply *Empty) error {
+	return s.node.receiveTableSet(args)
+}
+
+// Policy returns the cluster policy committed to the Raft log
+func (s *ClusterService) Policy(args *Empty, reply *PolicyReply) error {
+	policy, err := s.node.leaderPolicy()
+	if err != nil {
+		return err
+	}
+
+	reply.Policy = policy
+	return nil
+}
+
+// CommitPolicy accepts the cluster policy from a bootstrap node that does
+// not lead. Only the leader accepts it.
+func (s *ClusterService) CommitPolicy(args *SignedProposal, reply *Empty) error {
+	return s.node.receiveClusterPolicy(args)
+}
+
+// CommitIndex returns the leader's commit index so a restarted node knows how
+// far it has to catch up
+func (s *ClusterService) CommitIndex(args *Empty, reply *CommitIndexReply) error {
+	index, err := s.node.commitIndex()
+	if err != nil {
+		return err
+	}
+
+	reply.Index = index
+	return nil
+}
+
+// Health reports this node's CDC stream health and replication progress
+func (s *ClusterService) Health(args *Empty, reply *NodeHealth) error {
+	*reply = s.node.Health()
+	return nil
+}
+
+func (n *Node) serveClusterRPC(conn net.Conn) {
+	n.rpcServer.ServeCodec(jsonrpc.NewServerCodec(conn))
+}
+
+// callLeader invokes a cluster RPC on the current leader
+func (n *Node) callLeader(method string, args, reply interface{}) error {
+	if n.raft == nil {
+		return fmt.Errorf("raft not initialized")
+	}
+
+	leaderAddr, _ := n.raft.LeaderWithID()
+	if leaderAddr == "" {
+		return fmt.Errorf("no leader elected")
+	}
+
+	return callNode(string(leaderAddr), method, args, reply)
+}
+
+// callNode invokes a cluster RPC on the node listening on addr
+func callNode(addr, method string, args, reply interface{}) error {
+	conn, err := dialClusterRPC(addr, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(clusterRPCTimeout))
+
+	client := rpc.NewClientWithCodec(jsonrpc.NewClientCodec(conn))
+	defer client.Close()
+
+	return client.Call("Cluster."+method, args, reply)
+}
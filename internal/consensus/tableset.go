@@ -0,0 +1,101 @@
+package consensus
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/witnz/witnz/internal/storage"
+)
+
+// SignedProposal carries a change a follower asks the leader to replicate.
+// Payload is the JSON encoding of the change, signed by the proposing node
+// when signing is enabled.
+type SignedProposal struct {
+	NodeID    string `json:"node_id"`
+	Payload   []byte `json:"payload"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// newProposal encodes v for the leader, signing it when the node has a key
+func (n *Node) newProposal(v interface{}) (*SignedProposal, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal proposal: %w", err)
+	}
+
+	proposal := &SignedProposal{
+		NodeID:  n.config.NodeID,
+		Payload: payload,
+	}
+	if n.config.Signer != nil {
+		proposal.Signature = n.config.Signer.Sign(payload)
+	}
+	return proposal, nil
+}
+
+// openProposal checks a follower's proposal and decodes it into v. Only the
+// leader accepts proposals.
+func (n *Node) openProposal(args *SignedProposal, v interface{}) error {
+	if !n.IsLeader() {
+		return fmt.Errorf("not the leader")
+	}
+
+	if n.config.KeyRing != nil {
+		if err := n.config.KeyRing.Verify(args.NodeID, args.Payload, args.Signature); err != nil {
+			return fmt.Errorf("rejected proposal: %w", err)
+		}
+	}
+
+	if err := json.Unmarshal(args.Payload, v); err != nil {
+		return fmt.Errorf("failed to unmarshal proposal: %w", err)
+	}
+	return nil
+}
+
+// SetTableSetObserver registers an observer for changes to the replicated
+// table set. It must be called before Start.
+func (n *Node) SetTableSetObserver(observer TableSetObserver) {
+	n.tables = observer
+}
+
+// ProposeTableSet replicates a new set of protected tables and the patterns
+// that produced it. It returns once the entry is applied, so every node
+// switches at the same log index. Followers forward the proposal to the
+// leader.
+func (n *Node) ProposeTableSet(tables []storage.ProtectedTable, patterns []string) error {
+	set := &TableSetPayload{Tables: tables, Patterns: patterns}
+	if n.IsLeader() {
+		return n.replicateTableSet(set)
+	}
+
+	args, err := n.newProposal(set)
+	if err != nil {
+		return err
+	}
+
+	return n.callLeader("ProposeTableSet", args, &Empty{})
+}
+
+func (n *Node) receiveTableSet(args *SignedProposal) error {
+	var set TableSetPayload
+	if err := n.openProposal(args, &set); err != nil {
+		return err
+	}
+
+	return n.replicateTableSet(&set)
+}
+
+func (n *Node) replicateTableSet(set *TableSetPayload) error {
+	if set.Tables == nil {
+		set.Tables = []storage.ProtectedTable{}
+	}
+
+	entry := &LogEntry{
+		Type:      LogEntryTableSet,
+		TableSet:  set,
+		Timestamp: time.Now(),
+	}
+
+	return n.ApplyLog(entry)
+}
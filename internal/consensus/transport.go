@@ -0,0 +1,145 @@
+package consensus
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/raft"
+)
+
+// clusterRPCMarker is the first byte of cluster RPC connections. Raft RPC
+// types occupy the low values, so connections starting with this byte are
+// routed to the cluster RPC server instead of the Raft transport.
+const clusterRPCMarker byte = 0xC7
+
+var errStreamLayerClosed = errors.New("stream layer closed")
+
+// muxStreamLayer shares the Raft bind address between Raft and the cluster
+// RPC server so nodes need no additional port
+type muxStreamLayer struct {
+	listener   net.Listener
+	advertise  net.Addr
+	raftConns  chan net.Conn
+	rpcHandler func(net.Conn)
+	closed     chan struct{}
+	closeOnce  sync.Once
+}
+
+func newMuxStreamLayer(bindAddr string, advertise *net.TCPAddr, rpcHandler func(net.Conn)) (*muxStreamLayer, error) {
+	if advertise.IP == nil || advertise.IP.IsUnspecified() {
+		return nil, fmt.Errorf("local bind address is not advertisable: %s", bindAddr)
+	}
+
+	listener, err := net.Listen("tcp", bindAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", bindAddr, err)
+	}
+
+	l := &muxStreamLayer{
+		listener:   listener,
+		advertise:  advertise,
+		raftConns:  make(chan net.Conn),
+		rpcHandler: rpcHandler,
+		closed:     make(chan struct{}),
+	}
+
+	go l.acceptLoop()
+
+	return l, nil
+}
+
+func (l *muxStreamLayer) acceptLoop() {
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			select {
+			case <-l.closed:
+				return
+			default:
+			}
+			time.Sleep(50 * time.Millisecond)
+			continue
+		}
+
+		go l.route(conn)
+	}
+}
+
+func (l *muxStreamLayer) route(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+
+	first, err := reader.Peek(1)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	buffered := &bufferedConn{Conn: conn, reader: reader}
+
+	if first[0] == clusterRPCMarker {
+		_, _ = reader.Discard(1)
+		l.rpcHandler(buffered)
+		return
+	}
+
+	select {
+	case l.raftConns <- buffered:
+	case <-l.closed:
+		conn.Close()
+	}
+}
+
+func (l *muxStreamLayer) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.raftConns:
+		return conn, nil
+	case <-l.closed:
+		return nil, errStreamLayerClosed
+	}
+}
+
+func (l *muxStreamLayer) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		err = l.listener.Close()
+	})
+	return err
+}
+
+func (l *muxStreamLayer) Addr() net.Addr {
+	return l.advertise
+}
+
+func (l *muxStreamLayer) Dial(address raft.ServerAddress, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("tcp", string(address), timeout)
+}
+
+// dialClusterRPC opens a connection to the cluster RPC server of a peer
+func dialClusterRPC(address string, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write([]byte{clusterRPCMarker}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// bufferedConn replays bytes consumed while routing a connection
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
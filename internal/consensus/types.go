@@ -2,20 +2,95 @@ package consensus
 
 import (
 	"time"
+
+	"github.com/witnz/witnz/internal/storage"
 )
 
 type LogEntryType string
 
 const (
-	LogEntryHashChain  LogEntryType = "hash_chain"
-	LogEntryCheckpoint LogEntryType = "checkpoint"
+	LogEntryHashChain           LogEntryType = "hash_chain"
+	LogEntryCheckpoint          LogEntryType = "checkpoint"
+	LogEntryFinding             LogEntryType = "finding"
+	LogEntryFindingStatus       LogEntryType = "finding_status"
+	LogEntryAllowance           LogEntryType = "allowance"
+	LogEntryCheckpointSignature LogEntryType = "checkpoint_signature"
+	LogEntryHashChainBatch      LogEntryType = "hash_chain_batch"
+	LogEntryTableSet            LogEntryType = "table_set"
+	LogEntryClusterPolicy       LogEntryType = "cluster_policy"
+	LogEntryDatabaseIdentity    LogEntryType = "database_identity"
 )
 
+// LogEntryVersion is the payload version written by this node
+const LogEntryVersion = 1
+
+// LogEntry is a replicated state change. Exactly one payload field is set,
+// matching Type.
 type LogEntry struct {
-	Type      LogEntryType           `json:"type"`
-	TableName string                 `json:"table_name"`
-	Data      map[string]interface{} `json:"data"`
-	Timestamp time.Time              `json:"timestamp"`
+	Version   int          `json:"version"`
+	Type      LogEntryType `json:"type"`
+	TableName string       `json:"table_name"`
+	Timestamp time.Time    `json:"timestamp"`
+
+	HashChain           *HashChainPayload            `json:"hash_chain,omitempty"`
+	HashChainBatch      *HashChainBatchPayload       `json:"hash_chain_batch,omitempty"`
+	Checkpoint          *storage.MerkleCheckpoint    `json:"checkpoint,omitempty"`
+	CheckpointSignature *storage.CheckpointSignature `json:"checkpoint_signature,omitempty"`
+	Finding             *storage.Finding             `json:"finding,omitempty"`
+	FindingStatus       *FindingStatusPayload        `json:"finding_status,omitempty"`
+	Allowance           *storage.Allowance           `json:"allowance,omitempty"`
+	TableSet            *TableSetPayload             `json:"table_set,omitempty"`
+	ClusterPolicy       *storage.ClusterPolicy       `json:"cluster_policy,omitempty"`
+	DatabaseIdentity    *storage.DatabaseIdentity    `json:"database_identity,omitempty"`
+}
+
+// HashChainPayload records one row change. The FSM assigns the sequence
+// number; SequenceNum is only set by entries proposed by older leaders.
+type HashChainPayload struct {
+	SequenceNum   uint64 `json:"sequence_num,omitempty"`
+	DataHash      string `json:"data_hash"`
+	OperationType string `json:"operation_type"`
+	RecordID      string `json:"record_id"`
+	LSN           uint64 `json:"lsn,omitempty"`
+	AllowanceID   string `json:"allowance_id,omitempty"`
+	RowImage      []byte `json:"row_image,omitempty"`
+	RowImageID    string `json:"row_image_id,omitempty"`
+}
+
+// HashChainBatchPayload carries hash chain entries that the FSM applies in a
+// single storage transaction. Each entry is a complete hash_chain LogEntry.
+type HashChainBatchPayload struct {
+	Entries []*LogEntry `json:"entries"`
+}
+
+// HashEntryResult is the FSM outcome for one entry of a batch. Err is set
+// when the entry was rejected; the rest of the batch is unaffected.
+type HashEntryResult struct {
+	SequenceNum uint64
+	Err         error
+}
+
+type FindingStatusPayload struct {
+	ID     string                `json:"id"`
+	Status storage.FindingStatus `json:"status"`
+	Actor  string                `json:"actor"`
+	Note   string                `json:"note,omitempty"`
+}
+
+// TableSetPayload replaces the set of protected tables. Every node switches
+// to the new set when it applies the entry. Patterns lists the configured
+// table patterns, including those that currently match no table.
+type TableSetPayload struct {
+	Tables   []storage.ProtectedTable `json:"tables"`
+	Patterns []string                 `json:"patterns,omitempty"`
+}
+
+// SignedLogEntry wraps an encoded LogEntry with the proposing node's
+// signature. Signer and Signature are empty when signing is disabled.
+type SignedLogEntry struct {
+	Payload   []byte `json:"payload"`
+	Signer    string `json:"signer,omitempty"`
+	Signature string `json:"signature,omitempty"`
 }
 
 type SnapshotMeta struct {
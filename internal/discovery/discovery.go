@@ -0,0 +1,153 @@
+// Package discovery resolves protected table patterns against the tables
+// that exist in PostgreSQL.
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/witnz/witnz/internal/storage"
+)
+
+// Pattern is a glob over table names, optionally limited to one schema.
+// "*_log" matches tables in any schema on the search path; "audit.*"
+// matches every table in the audit schema.
+type Pattern struct {
+	Schema string
+	Table  string
+}
+
+// ParsePattern splits a pattern into its schema and table globs
+func ParsePattern(s string) (Pattern, error) {
+	var p Pattern
+
+	switch parts := strings.Split(s, "."); len(parts) {
+	case 1:
+		p.Table = parts[0]
+	case 2:
+		p.Schema, p.Table = parts[0], parts[1]
+		if p.Schema == "" {
+			return p, fmt.Errorf("invalid table pattern %q: empty schema", s)
+		}
+	default:
+		return p, fmt.Errorf("invalid table pattern %q: expected [schema.]table", s)
+	}
+
+	if p.Table == "" {
+		return p, fmt.Errorf("invalid table pattern %q: empty table", s)
+	}
+	for _, glob := range []string{p.Schema, p.Table} {
+		if _, err := path.Match(glob, ""); err != nil {
+			return p, fmt.Errorf("invalid table pattern %q: %w", s, err)
+		}
+	}
+
+	return p, nil
+}
+
+// Match reports whether the pattern covers a table. Patterns without a
+// schema only cover tables on the search path.
+func (p Pattern) Match(table Table) bool {
+	if p.Schema == "" && !table.Visible {
+		return false
+	}
+	if p.Schema != "" {
+		if ok, _ := path.Match(p.Schema, table.Schema); !ok {
+			return false
+		}
+	}
+	ok, _ := path.Match(p.Table, table.Name)
+	return ok
+}
+
+// Table is an ordinary or partitioned table found in the catalog.
+// Partitions are not listed since they are protected through their parent.
+// Visible is set when its unqualified name resolves to it on the search
+// path.
+type Table struct {
+	Schema  string
+	Name    string
+	Visible bool
+}
+
+func (t Table) String() string {
+	return t.Schema + storage.SchemaSeparator + t.Name
+}
+
+// Reference returns the name witnz refers to the table by: its plain name
+// when that resolves to it on the search path, schema.table otherwise
+func (t Table) Reference() string {
+	if t.Visible {
+		return t.Name
+	}
+	return t.String()
+}
+
+// Identifier returns the quotable identifier of a protected table name in
+// its source's database, which is either a plain name resolved through the
+// search path or schema.table
+func Identifier(table string) pgx.Identifier {
+	schema, name := storage.SplitSchema(table)
+	if schema == "" {
+		return pgx.Identifier{name}
+	}
+	return pgx.Identifier{schema, name}
+}
+
+// ListTables returns the ordinary and partitioned tables outside the system
+// schemas, sorted by schema and name
+func ListTables(ctx context.Context, connString string) ([]Table, error) {
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, `SELECT n.nspname, c.relname, pg_table_is_visible(c.oid)
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p') AND NOT c.relispartition
+		AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+		AND n.nspname NOT LIKE 'pg_toast%'
+		AND n.nspname NOT LIKE 'pg_temp%'`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []Table
+	for rows.Next() {
+		var table Table
+		if err := rows.Scan(&table.Schema, &table.Name, &table.Visible); err != nil {
+			return nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].Schema != tables[j].Schema {
+			return tables[i].Schema < tables[j].Schema
+		}
+		return tables[i].Name < tables[j].Name
+	})
+
+	return tables, nil
+}
+
+// Resolve returns the tables matched by pattern
+func Resolve(pattern Pattern, tables []Table) []Table {
+	var matched []Table
+	for _, table := range tables {
+		if pattern.Match(table) {
+			matched = append(matched, table)
+		}
+	}
+	return matched
+}
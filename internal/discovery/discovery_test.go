@@ -0,0 +1,73 @@
+package discovery
+
+import "testing"
+
+func TestParsePattern(t *testing.T) {
+	valid := map[string]Pattern{
+		"*_log":         {Table: "*_log"},
+		"audit.*":       {Schema: "audit", Table: "*"},
+		"audit_log_20*": {Table: "audit_log_20*"},
+	}
+	for s, want := range valid {
+		got, err := ParsePattern(s)
+		if err != nil || got != want {
+			t.Errorf("Expected %q to parse as %+v, got %+v (err=%v)", s, want, got, err)
+		}
+	}
+
+	for _, s := range []string{"", "audit.", ".log", "a.b.c", "audit_[log"} {
+		if _, err := ParsePattern(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tables := []Table{
+		{Schema: "audit", Name: "events", Visible: false},
+		{Schema: "public", Name: "access_log", Visible: true},
+		{Schema: "public", Name: "audit_log", Visible: true},
+		{Schema: "public", Name: "users", Visible: true},
+		{Schema: "reporting", Name: "audit_log", Visible: false},
+	}
+
+	t.Run("UnqualifiedPattern", func(t *testing.T) {
+		pattern, _ := ParsePattern("*_log")
+		matched := Resolve(pattern, tables)
+		if len(matched) != 2 || matched[0].Reference() != "access_log" || matched[1].Reference() != "audit_log" {
+			t.Errorf("Expected access_log and audit_log, got %v", matched)
+		}
+	})
+
+	t.Run("SchemaPattern", func(t *testing.T) {
+		pattern, _ := ParsePattern("audit.*")
+		matched := Resolve(pattern, tables)
+		if len(matched) != 1 || matched[0].Reference() != "audit.events" {
+			t.Errorf("Expected audit.events outside the search path, got %v", matched)
+		}
+
+		pattern, _ = ParsePattern("*.audit_log")
+		matched = Resolve(pattern, tables)
+		if len(matched) != 2 || matched[0].Reference() != "audit_log" || matched[1].Reference() != "reporting.audit_log" {
+			t.Errorf("Expected audit_log and reporting.audit_log, got %v", matched)
+		}
+
+		pattern, _ = ParsePattern("public.*")
+		if matched := Resolve(pattern, tables); len(matched) != 3 {
+			t.Errorf("Expected 3 tables in public, got %v", matched)
+		}
+	})
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"audit_log":    `"audit_log"`,
+		"audit.events": `"audit"."events"`,
+		"Audit.Events": `"Audit"."Events"`,
+	}
+	for table, want := range tests {
+		if got := Identifier(table).Sanitize(); got != want {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package forensics
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Cipher encrypts row images with AES-256-GCM before they are stored
+// alongside the hash chain.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a Cipher from a hex-encoded 32-byte key.
+func NewCipher(hexKey string) (*Cipher, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key encoding: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid encryption key length: expected 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create block cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts a row image. The table and record ID are bound as
+// additional data so an image cannot be moved to another record.
+func (c *Cipher) Seal(tableName, recordID string, row map[string]string) ([]byte, error) {
+	plaintext, err := json.Marshal(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal row image: %w", err)
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return c.aead.Seal(nonce, nonce, plaintext, additionalData(tableName, recordID)), nil
+}
+
+// Open decrypts a row image sealed for the given table and record ID.
+func (c *Cipher) Open(tableName, recordID string, sealed []byte) (map[string]string, error) {
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, fmt.Errorf("sealed row image too short: %d bytes", len(sealed))
+	}
+
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], additionalData(tableName, recordID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt row image: %w", err)
+	}
+
+	var row map[string]string
+	if err := json.Unmarshal(plaintext, &row); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal row image: %w", err)
+	}
+
+	return row, nil
+}
+
+func additionalData(tableName, recordID string) []byte {
+	return []byte(tableName + ":" + recordID)
+}
@@ -0,0 +1,67 @@
+package forensics
+
+import (
+	"strings"
+	"testing"
+)
+
+const testKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func TestCipherSealOpen(t *testing.T) {
+	c, err := NewCipher(testKey)
+	if err != nil {
+		t.Fatalf("NewCipher failed: %v", err)
+	}
+
+	row := map[string]string{"id": "1", "action": "login"}
+
+	sealed, err := c.Seal("audit_log", "1", row)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	if strings.Contains(string(sealed), "login") {
+		t.Error("sealed row image should not contain plaintext values")
+	}
+
+	opened, err := c.Open("audit_log", "1", sealed)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if opened["action"] != "login" {
+		t.Errorf("expected action=login, got %s", opened["action"])
+	}
+}
+
+func TestCipherOpenWrongRecord(t *testing.T) {
+	c, _ := NewCipher(testKey)
+
+	sealed, err := c.Seal("audit_log", "1", map[string]string{"id": "1"})
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	if _, err := c.Open("audit_log", "2", sealed); err == nil {
+		t.Error("Open should fail when the row image is bound to another record")
+	}
+}
+
+func TestNewCipherInvalidKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"not_hex", "zz"},
+		{"too_short", "0001020304"},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCipher(tt.key); err == nil {
+				t.Errorf("NewCipher(%q) should fail", tt.key)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package forensics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/witnz/witnz/internal/hash"
+)
+
+const (
+	StatusIntact   = "intact"
+	StatusModified = "modified"
+	StatusDeleted  = "deleted"
+)
+
+const (
+	ChangeModified = "modified"
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+)
+
+// ColumnDiff describes a single column that differs between the original
+// row image and the current row.
+type ColumnDiff struct {
+	Column   string `json:"column"`
+	Change   string `json:"change"`
+	Original string `json:"original,omitempty"`
+	Current  string `json:"current,omitempty"`
+}
+
+// Report is the forensic view of a single protected record.
+type Report struct {
+	TableName    string            `json:"table_name"`
+	RecordID     string            `json:"record_id"`
+	SequenceNum  uint64            `json:"sequence_num"`
+	RecordedAt   time.Time         `json:"recorded_at"`
+	ExpectedHash string            `json:"expected_hash"`
+	ActualHash   string            `json:"actual_hash,omitempty"`
+	Status       string            `json:"status"`
+	Original     map[string]string `json:"original,omitempty"`
+	Current      map[string]string `json:"current,omitempty"`
+	Changes      []ColumnDiff      `json:"changes"`
+	GeneratedAt  time.Time         `json:"generated_at"`
+}
+
+// NewReport builds a report from the stored row image and the current row.
+// A nil current row means the record no longer exists in the database.
+func NewReport(tableName, recordID string, entry *RecordedEntry, original, current map[string]string) *Report {
+	report := &Report{
+		TableName:    tableName,
+		RecordID:     recordID,
+		SequenceNum:  entry.SequenceNum,
+		RecordedAt:   entry.Timestamp,
+		ExpectedHash: entry.DataHash,
+		Original:     original,
+		Current:      current,
+		Changes:      []ColumnDiff{},
+		GeneratedAt:  time.Now(),
+	}
+
+	if current == nil {
+		report.Status = StatusDeleted
+		return report
+	}
+
+	report.ActualHash = entry.CurrentHash
+	if original != nil {
+		report.Changes = Diff(original, current)
+	}
+
+	if report.ActualHash != report.ExpectedHash || len(report.Changes) > 0 {
+		report.Status = StatusModified
+	} else {
+		report.Status = StatusIntact
+	}
+
+	return report
+}
+
+// RecordedEntry carries the hash chain information needed for a report.
+type RecordedEntry struct {
+	SequenceNum uint64
+	DataHash    string
+	CurrentHash string
+	Timestamp   time.Time
+}
+
+// Diff returns the column-level differences between two row images.
+// Columns excluded from hashing are ignored since their representation
+// differs between CDC events and database queries.
+func Diff(original, current map[string]string) []ColumnDiff {
+	columns := make(map[string]bool)
+	for col := range original {
+		columns[col] = true
+	}
+	for col := range current {
+		columns[col] = true
+	}
+
+	names := make([]string, 0, len(columns))
+	for col := range columns {
+		if !hash.IsExcludedColumn(col) {
+			names = append(names, col)
+		}
+	}
+	sort.Strings(names)
+
+	diffs := []ColumnDiff{}
+	for _, col := range names {
+		origVal, inOriginal := original[col]
+		curVal, inCurrent := current[col]
+
+		switch {
+		case inOriginal && !inCurrent:
+			diffs = append(diffs, ColumnDiff{Column: col, Change: ChangeRemoved, Original: origVal})
+		case !inOriginal && inCurrent:
+			diffs = append(diffs, ColumnDiff{Column: col, Change: ChangeAdded, Current: curVal})
+		case origVal != curVal:
+			diffs = append(diffs, ColumnDiff{Column: col, Change: ChangeModified, Original: origVal, Current: curVal})
+		}
+	}
+
+	return diffs
+}
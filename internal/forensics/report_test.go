@@ -0,0 +1,78 @@
+package forensics
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	original := map[string]string{
+		"id":         "1",
+		"action":     "login",
+		"actor":      "alice",
+		"created_at": "2024-01-01 00:00:00",
+	}
+	current := map[string]string{
+		"id":         "1",
+		"action":     "logout",
+		"note":       "edited",
+		"created_at": "2024-01-01 00:00:00 +0000 UTC",
+	}
+
+	diffs := Diff(original, current)
+
+	if len(diffs) != 3 {
+		t.Fatalf("expected 3 diffs, got %d: %+v", len(diffs), diffs)
+	}
+
+	expected := []ColumnDiff{
+		{Column: "action", Change: ChangeModified, Original: "login", Current: "logout"},
+		{Column: "actor", Change: ChangeRemoved, Original: "alice"},
+		{Column: "note", Change: ChangeAdded, Current: "edited"},
+	}
+	for i, want := range expected {
+		if diffs[i] != want {
+			t.Errorf("diff %d: expected %+v, got %+v", i, want, diffs[i])
+		}
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	entry := &RecordedEntry{SequenceNum: 3, DataHash: "abc", CurrentHash: "abc"}
+	row := map[string]string{"id": "1", "action": "login"}
+
+	t.Run("intact", func(t *testing.T) {
+		report := NewReport("audit_log", "1", entry, row, row)
+		if report.Status != StatusIntact {
+			t.Errorf("expected status %s, got %s", StatusIntact, report.Status)
+		}
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		modified := &RecordedEntry{SequenceNum: 3, DataHash: "abc", CurrentHash: "def"}
+		current := map[string]string{"id": "1", "action": "logout"}
+
+		report := NewReport("audit_log", "1", modified, row, current)
+		if report.Status != StatusModified {
+			t.Errorf("expected status %s, got %s", StatusModified, report.Status)
+		}
+		if len(report.Changes) != 1 {
+			t.Errorf("expected 1 change, got %d", len(report.Changes))
+		}
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		report := NewReport("audit_log", "1", entry, row, nil)
+		if report.Status != StatusDeleted {
+			t.Errorf("expected status %s, got %s", StatusDeleted, report.Status)
+		}
+
+		data, err := json.Marshal(report)
+		if err != nil {
+			t.Fatalf("failed to marshal report: %v", err)
+		}
+		if len(data) == 0 {
+			t.Error("expected JSON output")
+		}
+	})
+}
@@ -479,8 +479,7 @@ func CalculateDataHash(data map[string]interface{}) string {
 func NormalizeForHash(data map[string]interface{}) map[string]string {
 	result := make(map[string]string)
 	for k, v := range data {
-		// Skip timestamp fields that may have format differences
-		if k == "created_at" || k == "updated_at" {
+		if IsExcludedColumn(k) {
 			continue
 		}
 		result[k] = normalizeValue(v)
@@ -488,6 +487,40 @@ func NormalizeForHash(data map[string]interface{}) map[string]string {
 	return result
 }
 
+// NormalizeRow converts every column to its normalized string representation,
+// including the columns excluded from hashing.
+func NormalizeRow(data map[string]interface{}) map[string]string {
+	result := make(map[string]string, len(data))
+	for k, v := range data {
+		result[k] = normalizeValue(v)
+	}
+	return result
+}
+
+// EncodingVersion identifies how rows are normalized and encoded before they
+// are hashed. Nodes using different versions compute different hashes for the
+// same row.
+const EncodingVersion = 1
+
+// excludedColumns are skipped during hash calculation. Timestamp fields are
+// skipped because they may have format differences.
+var excludedColumns = []string{"created_at", "updated_at"}
+
+// IsExcludedColumn reports whether a column is skipped during hash calculation.
+func IsExcludedColumn(name string) bool {
+	for _, col := range excludedColumns {
+		if name == col {
+			return true
+		}
+	}
+	return false
+}
+
+// ExcludedColumns returns the columns skipped during hash calculation
+func ExcludedColumns() []string {
+	return append([]string(nil), excludedColumns...)
+}
+
 // normalizeValue converts a value to a consistent string representation
 // regardless of whether it came from CDC (text) or PostgreSQL query (native types).
 func normalizeValue(v interface{}) string {
@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// levelTrace is the slog level of hclog trace records
+const levelTrace = slog.LevelDebug - 4
+
+// hclogAdapter lets hashicorp/raft write through a slog logger. Raft's
+// levels map onto slog's, with trace below debug.
+type hclogAdapter struct {
+	logger *slog.Logger
+	name   string
+	args   []interface{}
+}
+
+// HCLogger returns an hclog.Logger that writes to logger
+func HCLogger(logger *slog.Logger) hclog.Logger {
+	return &hclogAdapter{logger: logger}
+}
+
+func slogLevel(level hclog.Level) slog.Level {
+	switch level {
+	case hclog.Trace:
+		return levelTrace
+	case hclog.Debug:
+		return slog.LevelDebug
+	case hclog.Warn:
+		return slog.LevelWarn
+	case hclog.Error:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+func (a *hclogAdapter) Log(level hclog.Level, msg string, args ...interface{}) {
+	if level == hclog.Off {
+		return
+	}
+	a.logger.Log(context.Background(), slogLevel(level), msg, args...)
+}
+
+func (a *hclogAdapter) Trace(msg string, args ...interface{}) { a.Log(hclog.Trace, msg, args...) }
+func (a *hclogAdapter) Debug(msg string, args ...interface{}) { a.Log(hclog.Debug, msg, args...) }
+func (a *hclogAdapter) Info(msg string, args ...interface{})  { a.Log(hclog.Info, msg, args...) }
+func (a *hclogAdapter) Warn(msg string, args ...interface{})  { a.Log(hclog.Warn, msg, args...) }
+func (a *hclogAdapter) Error(msg string, args ...interface{}) { a.Log(hclog.Error, msg, args...) }
+
+func (a *hclogAdapter) enabled(level hclog.Level) bool {
+	return a.logger.Enabled(context.Background(), slogLevel(level))
+}
+
+func (a *hclogAdapter) IsTrace() bool { return a.enabled(hclog.Trace) }
+func (a *hclogAdapter) IsDebug() bool { return a.enabled(hclog.Debug) }
+func (a *hclogAdapter) IsInfo() bool  { return a.enabled(hclog.Info) }
+func (a *hclogAdapter) IsWarn() bool  { return a.enabled(hclog.Warn) }
+func (a *hclogAdapter) IsError() bool { return a.enabled(hclog.Error) }
+
+func (a *hclogAdapter) ImpliedArgs() []interface{} {
+	return a.args
+}
+
+func (a *hclogAdapter) With(args ...interface{}) hclog.Logger {
+	implied := append(append([]interface{}(nil), a.args...), args...)
+	return &hclogAdapter{logger: a.logger.With(args...), name: a.name, args: implied}
+}
+
+func (a *hclogAdapter) Name() string {
+	return a.name
+}
+
+func (a *hclogAdapter) Named(name string) hclog.Logger {
+	if a.name != "" {
+		name = a.name + "." + name
+	}
+	return a.ResetNamed(name)
+}
+
+func (a *hclogAdapter) ResetNamed(name string) hclog.Logger {
+	return &hclogAdapter{logger: a.logger.With("component", name), name: name, args: a.args}
+}
+
+// SetLevel is ignored, levels come from the logging config
+func (a *hclogAdapter) SetLevel(hclog.Level) {}
+
+func (a *hclogAdapter) GetLevel() hclog.Level {
+	for _, level := range []hclog.Level{hclog.Trace, hclog.Debug, hclog.Info, hclog.Warn, hclog.Error} {
+		if a.enabled(level) {
+			return level
+		}
+	}
+	return hclog.Off
+}
+
+func (a *hclogAdapter) StandardLogger(opts *hclog.StandardLoggerOptions) *log.Logger {
+	level := slog.LevelInfo
+	if opts != nil && opts.ForceLevel != hclog.NoLevel {
+		level = slogLevel(opts.ForceLevel)
+	}
+	return slog.NewLogLogger(a.logger.Handler(), level)
+}
+
+func (a *hclogAdapter) StandardWriter(opts *hclog.StandardLoggerOptions) io.Writer {
+	return a.StandardLogger(opts).Writer()
+}
@@ -0,0 +1,199 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Subsystems are the names that accept their own level in the logging config
+var Subsystems = []string{"admin", "alert", "cdc", "consensus", "raft", "verify", "witnz"}
+
+// Options configures the process-wide logger
+type Options struct {
+	// Format is "text" (default) or "json"
+	Format string
+	// Level is the default level of every subsystem (default: info)
+	Level string
+	// Subsystems overrides the level of individual subsystems
+	Subsystems map[string]string
+	// Output defaults to os.Stderr
+	Output io.Writer
+	// Attrs are added to every record, e.g. node_id
+	Attrs []slog.Attr
+}
+
+// root holds the output handler shared by all subsystem loggers. Loggers are
+// created before the config is loaded, so they look the handler up on every
+// record and pick up a new one after Configure.
+var root = struct {
+	mu         sync.Mutex
+	base       atomic.Pointer[slog.Handler]
+	generation atomic.Uint64
+	fallback   slog.Level
+	overrides  map[string]slog.Level
+	levels     map[string]*slog.LevelVar
+}{
+	levels: make(map[string]*slog.LevelVar),
+}
+
+func init() {
+	base := newBase("text", os.Stderr, nil)
+	root.base.Store(&base)
+}
+
+// ParseLevel parses debug, info, warn or error. An empty level is info.
+func ParseLevel(level string) (slog.Level, error) {
+	if level == "" {
+		return slog.LevelInfo, nil
+	}
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("invalid log level: %s", level)
+	}
+	return l, nil
+}
+
+// ValidFormat reports whether format is a supported output format
+func ValidFormat(format string) bool {
+	return format == "" || format == "text" || format == "json"
+}
+
+// Configure replaces the output format and levels of every logger, including
+// loggers created earlier and slog.Default
+func Configure(opts Options) error {
+	if !ValidFormat(opts.Format) {
+		return fmt.Errorf("invalid log format: %s (valid options: text, json)", opts.Format)
+	}
+	fallback, err := ParseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	overrides := make(map[string]slog.Level, len(opts.Subsystems))
+	for subsystem, level := range opts.Subsystems {
+		l, err := ParseLevel(level)
+		if err != nil {
+			return fmt.Errorf("subsystem %s: %w", subsystem, err)
+		}
+		overrides[subsystem] = l
+	}
+
+	output := opts.Output
+	if output == nil {
+		output = os.Stderr
+	}
+	base := newBase(opts.Format, output, opts.Attrs)
+
+	root.mu.Lock()
+	root.fallback = fallback
+	root.overrides = overrides
+	for subsystem, level := range root.levels {
+		level.Set(levelFor(subsystem))
+	}
+	root.base.Store(&base)
+	root.generation.Add(1)
+	root.mu.Unlock()
+
+	slog.SetDefault(Logger("witnz"))
+	return nil
+}
+
+// Logger returns the logger of a subsystem. Every record it writes carries a
+// subsystem attribute.
+func Logger(subsystem string) *slog.Logger {
+	root.mu.Lock()
+	level, ok := root.levels[subsystem]
+	if !ok {
+		level = new(slog.LevelVar)
+		level.Set(levelFor(subsystem))
+		root.levels[subsystem] = level
+	}
+	root.mu.Unlock()
+
+	return slog.New(&handler{subsystem: subsystem, level: level})
+}
+
+// levelFor returns the configured level of a subsystem. root.mu must be held.
+func levelFor(subsystem string) slog.Level {
+	if level, ok := root.overrides[subsystem]; ok {
+		return level
+	}
+	return root.fallback
+}
+
+// newBase builds the output handler. Levels are filtered per subsystem before
+// records reach it, so it accepts everything.
+func newBase(format string, output io.Writer, attrs []slog.Attr) slog.Handler {
+	opts := &slog.HandlerOptions{Level: slog.Level(math.MinInt)}
+	var h slog.Handler
+	if format == "json" {
+		h = slog.NewJSONHandler(output, opts)
+	} else {
+		h = slog.NewTextHandler(output, opts)
+	}
+	if len(attrs) > 0 {
+		sorted := append([]slog.Attr(nil), attrs...)
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+		h = h.WithAttrs(sorted)
+	}
+	return h
+}
+
+// handler filters records by its subsystem's level and writes them through
+// the current base handler
+type handler struct {
+	subsystem string
+	level     *slog.LevelVar
+	// ops are the WithAttrs and WithGroup calls to replay on a new base
+	ops   []func(slog.Handler) slog.Handler
+	cache atomic.Pointer[resolved]
+}
+
+type resolved struct {
+	generation uint64
+	handler    slog.Handler
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	return h.resolve().Handle(ctx, record)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(base slog.Handler) slog.Handler { return base.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.with(func(base slog.Handler) slog.Handler { return base.WithGroup(name) })
+}
+
+func (h *handler) with(op func(slog.Handler) slog.Handler) *handler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &handler{subsystem: h.subsystem, level: h.level, ops: append(ops, op)}
+}
+
+// resolve returns the base handler with the subsystem attribute and the
+// replayed ops applied, rebuilding it after Configure
+func (h *handler) resolve() slog.Handler {
+	generation := root.generation.Load()
+	if cached := h.cache.Load(); cached != nil && cached.generation == generation {
+		return cached.handler
+	}
+
+	resolvedHandler := (*root.base.Load()).WithAttrs([]slog.Attr{slog.String("subsystem", h.subsystem)})
+	for _, op := range h.ops {
+		resolvedHandler = op(resolvedHandler)
+	}
+	h.cache.Store(&resolved{generation: generation, handler: resolvedHandler})
+	return resolvedHandler
+}
@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+func TestConfigure(t *testing.T) {
+	t.Run("SubsystemLevels", func(t *testing.T) {
+		var buf bytes.Buffer
+		cdc := Logger("cdc")
+		if err := Configure(Options{Level: "warn", Subsystems: map[string]string{"cdc": "debug"}, Output: &buf}); err != nil {
+			t.Fatalf("Failed to configure: %v", err)
+		}
+		verify := Logger("verify")
+
+		cdc.Debug("cdc debug")
+		verify.Info("verify info")
+		verify.Warn("verify warn")
+
+		out := buf.String()
+		if !strings.Contains(out, "cdc debug") {
+			t.Errorf("Expected cdc debug record, got %q", out)
+		}
+		if strings.Contains(out, "verify info") {
+			t.Errorf("Expected verify info to be filtered, got %q", out)
+		}
+		if !strings.Contains(out, "verify warn") || !strings.Contains(out, "subsystem=verify") {
+			t.Errorf("Expected verify warn with subsystem attribute, got %q", out)
+		}
+	})
+
+	t.Run("JSONWithAttrs", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := Logger("verify").With("table", "audit_log")
+		if err := Configure(Options{Format: "json", Output: &buf, Attrs: []slog.Attr{slog.String("node_id", "node1")}}); err != nil {
+			t.Fatalf("Failed to configure: %v", err)
+		}
+
+		logger.Error("Tampering detected", "seq", 42)
+
+		var record map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Fatalf("Expected one JSON record, got %q: %v", buf.String(), err)
+		}
+		for key, want := range map[string]interface{}{
+			"msg": "Tampering detected", "level": "ERROR", "node_id": "node1",
+			"subsystem": "verify", "table": "audit_log", "seq": float64(42),
+		} {
+			if record[key] != want {
+				t.Errorf("Expected %s=%v, got %v", key, want, record[key])
+			}
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if err := Configure(Options{Format: "xml"}); err == nil {
+			t.Error("Expected unknown format to be rejected")
+		}
+		if err := Configure(Options{Level: "loud"}); err == nil {
+			t.Error("Expected unknown level to be rejected")
+		}
+		if err := Configure(Options{Subsystems: map[string]string{"raft": "loud"}}); err == nil {
+			t.Error("Expected unknown subsystem level to be rejected")
+		}
+	})
+}
+
+func TestHCLogger(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Configure(Options{Level: "info", Subsystems: map[string]string{"raft": "warn"}, Output: &buf}); err != nil {
+		t.Fatalf("Failed to configure: %v", err)
+	}
+	logger := HCLogger(Logger("raft")).Named("raft-net")
+
+	if logger.IsInfo() || !logger.IsWarn() {
+		t.Errorf("Expected raft logger at warn level, got %v", logger.GetLevel())
+	}
+	if logger.GetLevel() != hclog.Warn {
+		t.Errorf("Expected level warn, got %v", logger.GetLevel())
+	}
+
+	logger.Info("heartbeat")
+	logger.With("term", 3).Warn("failed to contact", "server-id", "node2")
+
+	out := buf.String()
+	if strings.Contains(out, "heartbeat") {
+		t.Errorf("Expected info record to be filtered, got %q", out)
+	}
+	for _, want := range []string{"failed to contact", "component=raft-net", "term=3", "server-id=node2", "subsystem=raft"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output, got %q", want, out)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package mysql
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+const (
+	nativePassword      = "mysql_native_password"
+	cachingSHA2Password = "caching_sha2_password"
+)
+
+// caching_sha2_password status bytes sent after the scramble
+const (
+	fastAuthSuccess     = 3
+	performFullAuth     = 4
+	requestPublicKey    = 2
+	authMoreData        = 0x01
+	authSwitchRequest   = 0xfe
+	scrambleLength      = 20
+	publicKeyPEMMinimum = 64
+)
+
+// authResponse answers the scramble of an authentication plugin
+func authResponse(plugin, password string, scramble []byte) ([]byte, error) {
+	if len(scramble) > scrambleLength {
+		scramble = scramble[:scrambleLength]
+	}
+	switch plugin {
+	case nativePassword:
+		return scrambleNative(scramble, password), nil
+	case cachingSHA2Password:
+		return scrambleSHA256(scramble, password), nil
+	default:
+		return nil, fmt.Errorf("unsupported authentication plugin %s", plugin)
+	}
+}
+
+// authenticate follows the server through plugin switches and the full
+// caching_sha2_password exchange until it accepts or rejects the login
+func (c *Conn) authenticate(plugin, password string, scramble []byte) error {
+	for {
+		data, err := c.readPacket()
+		if err != nil {
+			return err
+		}
+
+		switch data[0] {
+		case 0x00:
+			return nil
+		case 0xff:
+			return parseError(data)
+		case authSwitchRequest:
+			r := newReader(data)
+			r.skip(1)
+			plugin = r.nulString()
+			scramble = r.rest()
+			if len(scramble) > 0 && scramble[len(scramble)-1] == 0 {
+				scramble = scramble[:len(scramble)-1]
+			}
+			response, err := authResponse(plugin, password, scramble)
+			if err != nil {
+				return err
+			}
+			if err := c.writePacket(response); err != nil {
+				return err
+			}
+		case authMoreData:
+			if plugin != cachingSHA2Password || len(data) < 2 {
+				return fmt.Errorf("unexpected authentication data for %s", plugin)
+			}
+			switch data[1] {
+			case fastAuthSuccess:
+				// The OK packet follows
+			case performFullAuth:
+				if err := c.fullAuth(password, scramble); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected caching_sha2_password status %d", data[1])
+			}
+		default:
+			return fmt.Errorf("unexpected authentication packet 0x%02x", data[0])
+		}
+	}
+}
+
+// fullAuth sends the password encrypted with the server's RSA key, which
+// caching_sha2_password asks for when the password is not cached yet
+func (c *Conn) fullAuth(password string, scramble []byte) error {
+	if err := c.writePacket([]byte{requestPublicKey}); err != nil {
+		return err
+	}
+	data, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	if data[0] == 0xff {
+		return parseError(data)
+	}
+	if data[0] != authMoreData || len(data) < publicKeyPEMMinimum {
+		return fmt.Errorf("server did not send its public key")
+	}
+
+	encrypted, err := encryptPassword(data[1:], password, scramble)
+	if err != nil {
+		return err
+	}
+	return c.writePacket(encrypted)
+}
+
+func encryptPassword(keyPEM []byte, password string, scramble []byte) ([]byte, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode server public key")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse server public key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("server public key is not an RSA key")
+	}
+
+	if len(scramble) > scrambleLength {
+		scramble = scramble[:scrambleLength]
+	}
+	plain := append([]byte(password), 0)
+	for i := range plain {
+		plain[i] ^= scramble[i%len(scramble)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, key, plain, nil)
+}
+
+// scrambleNative is SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password)))
+func scrambleNative(scramble []byte, password string) []byte {
+	if password == "" {
+		return nil
+	}
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+
+	h := sha1.New()
+	h.Write(scramble)
+	h.Write(stage2[:])
+	result := h.Sum(nil)
+	for i := range result {
+		result[i] ^= stage1[i]
+	}
+	return result
+}
+
+// scrambleSHA256 is SHA256(password) XOR SHA256(SHA256(SHA256(password)) + scramble)
+func scrambleSHA256(scramble []byte, password string) []byte {
+	if password == "" {
+		return nil
+	}
+	stage1 := sha256.Sum256([]byte(password))
+	stage2 := sha256.Sum256(stage1[:])
+
+	h := sha256.New()
+	h.Write(stage2[:])
+	h.Write(scramble)
+	result := h.Sum(nil)
+	for i := range result {
+		result[i] ^= stage1[i]
+	}
+	return result
+}
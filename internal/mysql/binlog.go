@@ -0,0 +1,326 @@
+package mysql
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventType is the type code of a binary log event
+type EventType uint8
+
+const (
+	QueryEvent              EventType = 2
+	RotateEvent             EventType = 4
+	FormatDescriptionEvent  EventType = 15
+	XIDEvent                EventType = 16
+	TableMapEvent           EventType = 19
+	WriteRowsEventV1        EventType = 23
+	UpdateRowsEventV1       EventType = 24
+	DeleteRowsEventV1       EventType = 25
+	HeartbeatEvent          EventType = 27
+	WriteRowsEventV2        EventType = 30
+	UpdateRowsEventV2       EventType = 31
+	DeleteRowsEventV2       EventType = 32
+	GTIDEvent               EventType = 33
+	AnonymousGTIDEvent      EventType = 34
+	PartialUpdateRowsEvent  EventType = 39
+	TransactionPayloadEvent EventType = 40
+	HeartbeatEventV2        EventType = 41
+	GTIDTaggedEvent         EventType = 42
+)
+
+// eventHeaderSize is the size of a v4 event header
+const eventHeaderSize = 19
+
+// ErrBinlogEnd is returned by ReadEvent when the server ends the dump
+var ErrBinlogEnd = io.EOF
+
+// Position packs a binary log coordinate into one number that grows with
+// the log: the sequence number of the file in the upper 32 bits and the
+// offset within it in the lower ones
+func Position(file uint32, offset uint32) uint64 {
+	return uint64(file)<<32 | uint64(offset)
+}
+
+// SplitPosition is the inverse of Position
+func SplitPosition(position uint64) (file uint32, offset uint32) {
+	return uint32(position >> 32), uint32(position)
+}
+
+// FileSequence returns the sequence number in a binary log file name such
+// as binlog.000042
+func FileSequence(name string) (uint32, error) {
+	dot := strings.LastIndexByte(name, '.')
+	seq, err := strconv.ParseUint(name[dot+1:], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid binary log file name %q", name)
+	}
+	return uint32(seq), nil
+}
+
+// StartBinlogDump registers as a replica with serverID and asks the server
+// to send every transaction not in executed. Events are then read with
+// ReadEvent; the connection cannot run queries any more.
+func (c *Conn) StartBinlogDump(ctx context.Context, serverID uint32, executed GTIDSet, heartbeat time.Duration) error {
+	// Servers before 8.0.26 only know the master variants
+	err := c.Exec(ctx, "SET @source_binlog_checksum = @@global.binlog_checksum, "+
+		"@master_binlog_checksum = @@global.binlog_checksum")
+	if err != nil {
+		return fmt.Errorf("failed to announce checksum support: %w", err)
+	}
+	err = c.Exec(ctx, fmt.Sprintf("SET @source_heartbeat_period = %d, @master_heartbeat_period = %d",
+		heartbeat.Nanoseconds(), heartbeat.Nanoseconds()))
+	if err != nil {
+		return fmt.Errorf("failed to set heartbeat period: %w", err)
+	}
+
+	defer c.watch(ctx)()
+
+	register := []byte{comRegisterSlave}
+	register = binary.LittleEndian.AppendUint32(register, serverID)
+	register = append(register, 0, 0, 0) // host, user and password
+	register = binary.LittleEndian.AppendUint16(register, 0)
+	register = binary.LittleEndian.AppendUint32(register, 0) // replication rank
+	register = binary.LittleEndian.AppendUint32(register, 0) // source id
+	if err := c.writeCommand(register); err != nil {
+		return c.contextError(ctx, err)
+	}
+	if err := c.readResult(); err != nil {
+		return fmt.Errorf("failed to register as replica: %w", c.contextError(ctx, err))
+	}
+
+	gtids := executed.encode()
+	dump := []byte{comBinlogDumpGTID}
+	dump = binary.LittleEndian.AppendUint16(dump, 0)
+	dump = binary.LittleEndian.AppendUint32(dump, serverID)
+	dump = binary.LittleEndian.AppendUint32(dump, 0) // no file name
+	dump = binary.LittleEndian.AppendUint64(dump, 4)
+	dump = binary.LittleEndian.AppendUint32(dump, uint32(len(gtids)))
+	dump = append(dump, gtids...)
+	if err := c.writeCommand(dump); err != nil {
+		return c.contextError(ctx, err)
+	}
+	return nil
+}
+
+// ReadEvent waits for the next event of a binlog dump and returns it
+// undecoded
+func (c *Conn) ReadEvent(ctx context.Context) ([]byte, error) {
+	defer c.watch(ctx)()
+
+	data, err := c.readPacket()
+	if err != nil {
+		return nil, c.contextError(ctx, err)
+	}
+	switch data[0] {
+	case 0x00:
+		return data[1:], nil
+	case 0xff:
+		return nil, parseError(data)
+	case 0xfe:
+		return nil, ErrBinlogEnd
+	default:
+		return nil, fmt.Errorf("unexpected packet 0x%02x in binlog dump", data[0])
+	}
+}
+
+// EventHeader is the common header of every event
+type EventHeader struct {
+	Timestamp time.Time
+	Type      EventType
+	ServerID  uint32
+	EventSize uint32
+	// LogPos is the offset just past the event, 0 for events the server
+	// made up, such as the rotate event that starts a dump
+	LogPos uint32
+	Flags  uint16
+}
+
+// Start returns the offset of the event in its file
+func (h *EventHeader) Start() uint32 {
+	if h.LogPos == 0 {
+		return 0
+	}
+	return h.LogPos - h.EventSize
+}
+
+// Event is one decoded event. Body is one of the *...Body types, or nil
+// for events witnz does not read.
+type Event struct {
+	Header EventHeader
+	Body   interface{}
+}
+
+// RotateBody names the file the following events come from
+type RotateBody struct {
+	File     string
+	Position uint64
+}
+
+// GTIDBody starts a transaction
+type GTIDBody struct {
+	UUID        string
+	Transaction uint64
+}
+
+// QueryBody is a statement logged as text: BEGIN, COMMIT of non
+// transactional tables, and DDL
+type QueryBody struct {
+	Schema string
+	Query  string
+}
+
+// XIDBody commits a transaction
+type XIDBody struct {
+	XID uint64
+}
+
+// RowsBody holds the rows changed by one event
+type RowsBody struct {
+	Table *TableMap
+	// Operation is WriteRowsEventV2, UpdateRowsEventV2 or
+	// DeleteRowsEventV2, also for version 1 events
+	Operation EventType
+	Rows      []Row
+}
+
+// Row is one changed row. Before is nil for inserts, After for deletes.
+// Offset is where the row starts within the event.
+type Row struct {
+	Offset uint32
+	Before map[string]interface{}
+	After  map[string]interface{}
+}
+
+// BinlogParser decodes the events of one binlog dump. It keeps the table
+// maps and the checksum setting between events.
+type BinlogParser struct {
+	checksum bool
+	tables   map[uint64]*TableMap
+}
+
+// NewBinlogParser creates a parser for a server whose binlog_checksum is
+// checksum
+func NewBinlogParser(checksum string) *BinlogParser {
+	return &BinlogParser{
+		checksum: strings.EqualFold(checksum, "CRC32"),
+		tables:   make(map[uint64]*TableMap),
+	}
+}
+
+// Parse decodes one event as returned by ReadEvent
+func (p *BinlogParser) Parse(data []byte) (*Event, error) {
+	if len(data) < eventHeaderSize {
+		return nil, errShortPacket
+	}
+	r := newReader(data)
+	event := &Event{}
+	event.Header.Timestamp = time.Unix(int64(r.uint32()), 0).UTC()
+	event.Header.Type = EventType(r.uint8())
+	event.Header.ServerID = r.uint32()
+	event.Header.EventSize = r.uint32()
+	event.Header.LogPos = r.uint32()
+	event.Header.Flags = r.uint16()
+
+	if event.Header.Type == FormatDescriptionEvent {
+		// The event states the checksum setting of the events after it,
+		// and of itself, in the byte before its checksum
+		if len(data) < eventHeaderSize+57+5 {
+			return nil, errShortPacket
+		}
+		p.checksum = data[len(data)-5] == 1
+	}
+	if p.checksum {
+		if len(data) < eventHeaderSize+4 {
+			return nil, errShortPacket
+		}
+		sum := binary.LittleEndian.Uint32(data[len(data)-4:])
+		if crc32.ChecksumIEEE(data[:len(data)-4]) != sum {
+			return nil, fmt.Errorf("checksum mismatch in %s event at %d", eventName(event.Header.Type), event.Header.LogPos)
+		}
+		r.data = data[:len(data)-4]
+	}
+
+	var err error
+	switch event.Header.Type {
+	case RotateEvent:
+		event.Body = &RotateBody{Position: r.uint64(), File: string(r.rest())}
+		err = r.err
+	case GTIDEvent:
+		r.skip(1)
+		event.Body = &GTIDBody{UUID: formatUUID(r.bytes(16)), Transaction: r.uint64()}
+		err = r.err
+	case AnonymousGTIDEvent:
+		err = errors.New("transaction without a GTID; gtid_mode must be ON")
+	case GTIDTaggedEvent:
+		err = errors.New("tagged GTIDs are not supported")
+	case QueryEvent:
+		event.Body, err = parseQuery(r)
+	case XIDEvent:
+		event.Body = &XIDBody{XID: r.uint64()}
+		err = r.err
+	case TableMapEvent:
+		var table *TableMap
+		if table, err = parseTableMap(r); err == nil {
+			p.tables[table.ID] = table
+		}
+	case WriteRowsEventV1, UpdateRowsEventV1, DeleteRowsEventV1, WriteRowsEventV2, UpdateRowsEventV2, DeleteRowsEventV2:
+		event.Body, err = p.parseRows(event.Header.Type, r)
+	case PartialUpdateRowsEvent:
+		err = errors.New("partial JSON updates are not supported; binlog_row_value_options must be empty")
+	case TransactionPayloadEvent:
+		err = errors.New("compressed transactions are not supported; binlog_transaction_compression must be OFF")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s event at %d: %w", eventName(event.Header.Type), event.Header.LogPos, err)
+	}
+	return event, nil
+}
+
+func parseQuery(r *reader) (*QueryBody, error) {
+	r.skip(8) // thread id and execution time
+	schemaLength := int(r.uint8())
+	r.skip(2) // error code
+	statusLength := int(r.uint16())
+	r.skip(statusLength)
+	body := &QueryBody{Schema: string(r.bytes(schemaLength))}
+	r.skip(1)
+	body.Query = string(r.rest())
+	return body, r.err
+}
+
+func eventName(t EventType) string {
+	switch t {
+	case QueryEvent:
+		return "QUERY"
+	case RotateEvent:
+		return "ROTATE"
+	case FormatDescriptionEvent:
+		return "FORMAT_DESCRIPTION"
+	case XIDEvent:
+		return "XID"
+	case TableMapEvent:
+		return "TABLE_MAP"
+	case WriteRowsEventV1, WriteRowsEventV2:
+		return "WRITE_ROWS"
+	case UpdateRowsEventV1, UpdateRowsEventV2:
+		return "UPDATE_ROWS"
+	case DeleteRowsEventV1, DeleteRowsEventV2:
+		return "DELETE_ROWS"
+	case GTIDEvent, AnonymousGTIDEvent, GTIDTaggedEvent:
+		return "GTID"
+	case PartialUpdateRowsEvent:
+		return "PARTIAL_UPDATE_ROWS"
+	case TransactionPayloadEvent:
+		return "TRANSACTION_PAYLOAD"
+	default:
+		return fmt.Sprintf("type %d", t)
+	}
+}
@@ -0,0 +1,425 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testEvent encodes an event with a CRC32 checksum
+func testEvent(eventType EventType, logPos uint32, body []byte) []byte {
+	size := uint32(eventHeaderSize + len(body) + 4)
+	data := binary.LittleEndian.AppendUint32(nil, 1700000000)
+	data = append(data, byte(eventType))
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = binary.LittleEndian.AppendUint32(data, size)
+	data = binary.LittleEndian.AppendUint32(data, logPos)
+	data = binary.LittleEndian.AppendUint16(data, 0)
+	data = append(data, body...)
+	return binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+}
+
+func lenencString(s string) []byte {
+	return append(appendLenenc(nil, uint64(len(s))), s...)
+}
+
+func metadataField(kind byte, value []byte) []byte {
+	return append(appendLenenc([]byte{kind}, uint64(len(value))), value...)
+}
+
+// testTableMap describes shop.orders:
+//
+//	id INT, name VARCHAR(20), amount DECIMAL(14,4), placed DATETIME(3),
+//	digest BINARY(4), state ENUM('new','paid'), doc JSON, note VARCHAR(20),
+//	tiny TINYINT UNSIGNED
+func testTableMap() []byte {
+	body := []byte{42, 0, 0, 0, 0, 0, 1, 0}
+	body = append(append(append(body, 4), "shop"...), 0)
+	body = append(append(append(body, 6), "orders"...), 0)
+	body = append(body, 9)
+	body = append(body, TypeLong, TypeVarchar, TypeNewDecimal, TypeDateTime2, TypeString, TypeString, TypeJSON, TypeVarchar, TypeTiny)
+
+	meta := []byte{80, 0, 14, 4, 3, TypeString, 4, TypeEnum, 1, 4, 80, 0}
+	body = append(appendLenenc(body, uint64(len(meta))), meta...)
+	body = append(body, 0xff, 0x01)
+
+	body = append(body, metadataField(metaSignedness, []byte{0x20})...)
+	body = append(body, metadataField(metaDefaultCharset, []byte{0xfc, 0xff, 0x00, 1, binaryCollation})...)
+	var names []byte
+	for _, name := range []string{"id", "name", "amount", "placed", "digest", "state", "doc", "note", "tiny"} {
+		names = append(names, lenencString(name)...)
+	}
+	body = append(body, metadataField(metaColumnName, names)...)
+	enum := append(append([]byte{2}, lenencString("new")...), lenencString("paid")...)
+	body = append(body, metadataField(metaEnumValues, enum)...)
+	body = append(body, metadataField(metaSimplePrimaryKey, []byte{0})...)
+	return body
+}
+
+// testJSON is {"a":7,"b":"x"} in binary JSON
+var testJSON = []byte{
+	jsonSmallObject, 2, 0, 22, 0,
+	18, 0, 1, 0, 19, 0, 1, 0,
+	jsonInt16, 7, 0, jsonString, 20, 0,
+	'a', 'b', 1, 'x',
+}
+
+func testRow(id int32, name string) []byte {
+	row := []byte{0x80, 0x00} // note is NULL
+	row = binary.LittleEndian.AppendUint32(row, uint32(id))
+	row = append(append(row, byte(len(name))), name...)
+	row = append(row, 0x81, 0x0d, 0xfb, 0x38, 0xd2, 0x04, 0xd2)
+
+	ym := int64(2024*13 + 3)
+	packed := (ym<<5|5)<<17 | 10<<12 | 20<<6 | 30
+	v := uint64(packed + 0x8000000000)
+	row = append(row, byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	row = append(row, 0x04, 0xce) // 1230 ten-thousandths
+
+	row = append(row, 2, 0x01, 0x02)
+	row = append(row, 2)
+	row = binary.LittleEndian.AppendUint32(row, uint32(len(testJSON)))
+	row = append(row, testJSON...)
+	return append(row, 200)
+}
+
+func testRows(eventType EventType, rows ...[]byte) []byte {
+	body := []byte{42, 0, 0, 0, 0, 0, 1, 0, 2, 0, 9, 0xff, 0x01}
+	if eventType == UpdateRowsEventV2 {
+		body = append(body, 0xff, 0x01)
+	}
+	for _, row := range rows {
+		body = append(body, row...)
+	}
+	return body
+}
+
+func TestBinlogParserRows(t *testing.T) {
+	p := NewBinlogParser("CRC32")
+	if _, err := p.Parse(testEvent(TableMapEvent, 1000, testTableMap())); err != nil {
+		t.Fatalf("Parse table map failed: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"id":     int64(5),
+		"name":   "Alice",
+		"amount": "1234567890.1234",
+		"placed": "2024-03-05 10:20:30.123",
+		"digest": []byte{1, 2, 0, 0},
+		"state":  "paid",
+		"doc":    `{"a":7,"b":"x"}`,
+		"note":   nil,
+		"tiny":   uint64(200),
+	}
+
+	t.Run("Write", func(t *testing.T) {
+		event, err := p.Parse(testEvent(WriteRowsEventV2, 2000, testRows(WriteRowsEventV2, testRow(5, "Alice"), testRow(6, "Bob"))))
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		body, ok := event.Body.(*RowsBody)
+		if !ok {
+			t.Fatalf("Expected rows body, got %T", event.Body)
+		}
+
+		if body.Operation != WriteRowsEventV2 || body.Table.Table != "orders" {
+			t.Errorf("Expected write to orders, got %d to %s", body.Operation, body.Table.Table)
+		}
+		if len(body.Rows) != 2 {
+			t.Fatalf("Expected 2 rows, got %d", len(body.Rows))
+		}
+		if !reflect.DeepEqual(body.Rows[0].After, want) {
+			t.Errorf("Expected %v, got %v", want, body.Rows[0].After)
+		}
+		if body.Rows[1].After["name"] != "Bob" {
+			t.Errorf("Expected Bob, got %v", body.Rows[1].After["name"])
+		}
+		if body.Rows[0].Offset != 32 || body.Rows[1].Offset != 32+uint32(len(testRow(5, "Alice"))) {
+			t.Errorf("Expected offsets 32 and %d, got %d and %d", 32+len(testRow(5, "Alice")), body.Rows[0].Offset, body.Rows[1].Offset)
+		}
+		if !reflect.DeepEqual(body.Table.PrimaryKey, []int{0}) {
+			t.Errorf("Expected primary key [0], got %v", body.Table.PrimaryKey)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		event, err := p.Parse(testEvent(UpdateRowsEventV2, 3000, testRows(UpdateRowsEventV2, testRow(5, "Alice"), testRow(5, "Alicia"))))
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		body := event.Body.(*RowsBody)
+
+		if len(body.Rows) != 1 {
+			t.Fatalf("Expected 1 row, got %d", len(body.Rows))
+		}
+		if body.Rows[0].Before["name"] != "Alice" || body.Rows[0].After["name"] != "Alicia" {
+			t.Errorf("Expected Alice to Alicia, got %v to %v", body.Rows[0].Before["name"], body.Rows[0].After["name"])
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		event, err := p.Parse(testEvent(DeleteRowsEventV2, 4000, testRows(DeleteRowsEventV2, testRow(5, "Alice"))))
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		body := event.Body.(*RowsBody)
+
+		if body.Operation != DeleteRowsEventV2 || body.Rows[0].After != nil || body.Rows[0].Before["id"] != int64(5) {
+			t.Errorf("Expected delete of id 5, got %+v", body.Rows[0])
+		}
+	})
+
+	t.Run("PartialImage", func(t *testing.T) {
+		data := testRows(DeleteRowsEventV2, testRow(5, "Alice"))
+		data[11] = 0x01
+		_, err := p.Parse(testEvent(DeleteRowsEventV2, 4000, data))
+		if err == nil || !strings.Contains(err.Error(), "binlog_row_image") {
+			t.Errorf("Expected binlog_row_image error, got %v", err)
+		}
+	})
+
+	t.Run("UnknownTable", func(t *testing.T) {
+		data := testRows(WriteRowsEventV2, testRow(5, "Alice"))
+		data[0] = 43
+		if _, err := p.Parse(testEvent(WriteRowsEventV2, 2000, data)); err == nil {
+			t.Error("Expected error for unknown table id")
+		}
+	})
+}
+
+func TestBinlogParserEvents(t *testing.T) {
+	t.Run("Transaction", func(t *testing.T) {
+		p := NewBinlogParser("CRC32")
+		raw, _ := uuidBytes(testUUID)
+
+		rotate, err := p.Parse(testEvent(RotateEvent, 0, append(binary.LittleEndian.AppendUint64(nil, 4), "binlog.000003"...)))
+		if err != nil {
+			t.Fatalf("Parse rotate failed: %v", err)
+		}
+		if body := rotate.Body.(*RotateBody); body.File != "binlog.000003" || body.Position != 4 {
+			t.Errorf("Expected binlog.000003 at 4, got %s at %d", body.File, body.Position)
+		}
+
+		gtid, err := p.Parse(testEvent(GTIDEvent, 500, append(append([]byte{1}, raw...), binary.LittleEndian.AppendUint64(nil, 9)...)))
+		if err != nil {
+			t.Fatalf("Parse GTID failed: %v", err)
+		}
+		if body := gtid.Body.(*GTIDBody); body.UUID != testUUID || body.Transaction != 9 {
+			t.Errorf("Expected %s:9, got %s:%d", testUUID, body.UUID, body.Transaction)
+		}
+
+		query := binary.LittleEndian.AppendUint64(nil, 0)
+		query = append(query, 4, 0, 0, 2, 0, 0xaa, 0xbb)
+		query = append(append(query, "shop"...), 0)
+		query = append(query, "TRUNCATE TABLE orders"...)
+		event, err := p.Parse(testEvent(QueryEvent, 600, query))
+		if err != nil {
+			t.Fatalf("Parse query failed: %v", err)
+		}
+		if body := event.Body.(*QueryBody); body.Schema != "shop" || body.Query != "TRUNCATE TABLE orders" {
+			t.Errorf("Expected TRUNCATE in shop, got %q in %s", body.Query, body.Schema)
+		}
+		if event.Header.Start() != 600-event.Header.EventSize {
+			t.Errorf("Expected start %d, got %d", 600-event.Header.EventSize, event.Header.Start())
+		}
+
+		xid, err := p.Parse(testEvent(XIDEvent, 700, binary.LittleEndian.AppendUint64(nil, 77)))
+		if err != nil {
+			t.Fatalf("Parse XID failed: %v", err)
+		}
+		if body := xid.Body.(*XIDBody); body.XID != 77 {
+			t.Errorf("Expected XID 77, got %d", body.XID)
+		}
+	})
+
+	t.Run("Checksum", func(t *testing.T) {
+		p := NewBinlogParser("CRC32")
+		data := testEvent(XIDEvent, 700, binary.LittleEndian.AppendUint64(nil, 77))
+		data[eventHeaderSize] ^= 0xff
+
+		_, err := p.Parse(data)
+		if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Errorf("Expected checksum mismatch, got %v", err)
+		}
+	})
+
+	t.Run("FormatDescriptionDisablesChecksum", func(t *testing.T) {
+		p := NewBinlogParser("CRC32")
+		body := binary.LittleEndian.AppendUint16(nil, 4)
+		body = append(body, make([]byte, 50+4)...)
+		body = append(body, eventHeaderSize)
+		body = append(body, make([]byte, 41)...)
+		body = append(body, 0) // checksum off
+
+		if _, err := p.Parse(testEvent(FormatDescriptionEvent, 126, body)); err != nil {
+			t.Fatalf("Parse format description failed: %v", err)
+		}
+
+		xid := testEvent(XIDEvent, 700, binary.LittleEndian.AppendUint64(nil, 77))
+		xid = xid[:len(xid)-4]
+		if _, err := p.Parse(xid); err != nil {
+			t.Errorf("Expected event without checksum to parse, got %v", err)
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		for _, eventType := range []EventType{AnonymousGTIDEvent, TransactionPayloadEvent, PartialUpdateRowsEvent, GTIDTaggedEvent} {
+			p := NewBinlogParser("NONE")
+			data := testEvent(eventType, 800, make([]byte, 40))
+			if _, err := p.Parse(data[:len(data)-4]); err == nil {
+				t.Errorf("Expected error for event type %d", eventType)
+			}
+		}
+	})
+}
+
+func TestDecodeValues(t *testing.T) {
+	tests := []struct {
+		name string
+		col  ColumnInfo
+		data []byte
+		want interface{}
+	}{
+		{"SignedTiny", ColumnInfo{Type: TypeTiny}, []byte{0xff}, int64(-1)},
+		{"Int24", ColumnInfo{Type: TypeInt24}, []byte{0xfe, 0xff, 0xff}, int64(-2)},
+		{"UnsignedBigint", ColumnInfo{Type: TypeLongLong, Unsigned: true}, bytes.Repeat([]byte{0xff}, 8), uint64(1<<64 - 1)},
+		{"Float", ColumnInfo{Type: TypeFloat}, []byte{0x00, 0x00, 0xc0, 0x3f}, float32(1.5)},
+		{"NegativeDecimal", ColumnInfo{Type: TypeNewDecimal, Meta: 5<<8 | 2}, []byte{0x7f, 0xfe, 0xff}, "-1.00"},
+		{"SmallDecimal", ColumnInfo{Type: TypeNewDecimal, Meta: 4<<8 | 4}, []byte{0x80, 0x00}, "0.0000"},
+		{"Date", ColumnInfo{Type: TypeDate}, []byte{0x65, 0xd0, 0x0f}, "2024-03-05"},
+		{"Year", ColumnInfo{Type: TypeYear}, []byte{124}, "2024"},
+		{"ZeroYear", ColumnInfo{Type: TypeYear}, []byte{0}, "0000"},
+		{"Timestamp2", ColumnInfo{Type: TypeTimestamp2}, []byte{0x65, 0x53, 0xf1, 0x00}, "2023-11-14 22:13:20"},
+		{"ZeroTimestamp2", ColumnInfo{Type: TypeTimestamp2, Meta: 2}, []byte{0, 0, 0, 0, 0}, "0000-00-00 00:00:00.00"},
+		{"NegativeTime2", ColumnInfo{Type: TypeTime2}, []byte{0x7f, 0xef, 0x7d}, "-01:02:03"},
+		{"Time2Micros", ColumnInfo{Type: TypeTime2, Meta: 6}, []byte{0x80, 0xc8, 0xb8, 0x0c, 0x0a, 0x14}, "12:34:56.789012"},
+		{"Bit", ColumnInfo{Type: TypeBit, Meta: 1<<8 | 2}, []byte{0x01, 0x81}, []byte{0x01, 0x81}},
+		{"Set", ColumnInfo{Type: TypeSet, Meta: 1, Values: []string{"a", "b", "c"}}, []byte{0x05}, "a,c"},
+		{"Text", ColumnInfo{Type: TypeBlob, Meta: 2, Collation: 255}, []byte{2, 0, 'h', 'i'}, "hi"},
+		{"Blob", ColumnInfo{Type: TypeBlob, Meta: 2, Collation: binaryCollation}, []byte{2, 0, 'h', 'i'}, []byte("hi")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newReader(tt.data)
+			got, err := tt.col.decode(r)
+			if err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %#v, got %#v", tt.want, got)
+			}
+			if r.remaining() != 0 {
+				t.Errorf("Expected all %d bytes to be read, %d left", len(tt.data), r.remaining())
+			}
+		})
+	}
+}
+
+func TestColumnValue(t *testing.T) {
+	tests := []struct {
+		name string
+		col  Column
+		text string
+		want interface{}
+	}{
+		{"Int", Column{Type: TypeLong}, "-5", int64(-5)},
+		{"ZerofillUnsigned", Column{Type: TypeLong, Flags: unsignedFlag}, "0000000200", uint64(200)},
+		{"Float", Column{Type: TypeFloat}, "1.5", float32(1.5)},
+		{"Decimal", Column{Type: TypeNewDecimal, Charset: binaryCollation}, "1234567890.1234", "1234567890.1234"},
+		{"Binary", Column{Type: TypeString, Charset: binaryCollation}, "\x01\x02\x00\x00", []byte{1, 2, 0, 0}},
+		{"Enum", Column{Type: TypeString, Charset: binaryCollation, Flags: enumFlag}, "paid", "paid"},
+		{"Text", Column{Type: TypeVarString, Charset: 255}, "Alice", "Alice"},
+		{"JSON", Column{Type: TypeJSON}, `{"b": "x", "a": 7.0}`, `{"a":7,"b":"x"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.col.Value([]byte(tt.text))
+			if err != nil {
+				t.Fatalf("Value failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("Null", func(t *testing.T) {
+		got, err := (&Column{Type: TypeLong}).Value(nil)
+		if err != nil || got != nil {
+			t.Errorf("Expected nil, got %v (%v)", got, err)
+		}
+	})
+}
+
+func TestDecodeJSON(t *testing.T) {
+	t.Run("Object", func(t *testing.T) {
+		got, err := decodeJSON(testJSON)
+		if err != nil {
+			t.Fatalf("decodeJSON failed: %v", err)
+		}
+		if got != `{"a":7,"b":"x"}` {
+			t.Errorf(`Expected {"a":7,"b":"x"}, got %s`, got)
+		}
+	})
+
+	t.Run("ArrayWithOpaque", func(t *testing.T) {
+		// [1.50 as DECIMAL(3,2), 2.5 as double, null]
+		decimal := []byte{jsonOpaque, TypeNewDecimal, 4, 3, 2, 0x81, 0x32}
+		double := binary.LittleEndian.AppendUint64(nil, 0x4004000000000000)
+		data := []byte{jsonSmallArray, 3, 0, 0, 0}
+		data = append(data, decimal[0], 0, 0, jsonDouble, 0, 0, jsonLiteral, 0x00, 0)
+		decimalOffset := len(data) - 1
+		data = append(data, decimal[1:]...)
+		doubleOffset := len(data) - 1
+		data = append(data, double...)
+		data[5+1] = byte(decimalOffset)
+		data[5+4] = byte(doubleOffset)
+		data[3] = byte(len(data) - 1)
+
+		got, err := decodeJSON(data)
+		if err != nil {
+			t.Fatalf("decodeJSON failed: %v", err)
+		}
+		if got != `[1.5,2.5,null]` {
+			t.Errorf("Expected [1.5,2.5,null], got %s", got)
+		}
+
+		text, err := CanonicalJSON([]byte("[1.50, 2.5, null]"))
+		if err != nil {
+			t.Fatalf("CanonicalJSON failed: %v", err)
+		}
+		if text != got {
+			t.Errorf("Expected text and binary JSON to match, got %s and %s", text, got)
+		}
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		if _, err := decodeJSON([]byte{jsonSmallObject, 5, 0}); err == nil {
+			t.Error("Expected error for truncated object")
+		}
+	})
+}
+
+func TestFileSequence(t *testing.T) {
+	seq, err := FileSequence("mysql-bin.000042")
+	if err != nil {
+		t.Fatalf("FileSequence failed: %v", err)
+	}
+	if seq != 42 {
+		t.Errorf("Expected 42, got %d", seq)
+	}
+
+	if _, err := FileSequence("binlog"); err == nil {
+		t.Error("Expected error for a name without sequence")
+	}
+
+	file, offset := SplitPosition(Position(42, 1000))
+	if file != 42 || offset != 1000 {
+		t.Errorf("Expected 42/1000, got %d/%d", file, offset)
+	}
+}
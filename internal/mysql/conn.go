@@ -0,0 +1,298 @@
+// Package mysql is a minimal MySQL client: it runs text queries and reads
+// the binary log as a replica, which is all witnz needs to protect MySQL
+// tables.
+package mysql
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Capability flags negotiated in the handshake
+const (
+	clientLongPassword               uint32 = 0x00000001
+	clientLongFlag                   uint32 = 0x00000004
+	clientConnectWithDB              uint32 = 0x00000008
+	clientProtocol41                 uint32 = 0x00000200
+	clientTransactions               uint32 = 0x00002000
+	clientSecureConnection           uint32 = 0x00008000
+	clientMultiResults               uint32 = 0x00020000
+	clientPluginAuth                 uint32 = 0x00080000
+	clientPluginAuthLenencClientData uint32 = 0x00200000
+)
+
+const (
+	comQuit           = 0x01
+	comQuery          = 0x03
+	comRegisterSlave  = 0x15
+	comBinlogDumpGTID = 0x1e
+)
+
+// utf8mb4GeneralCI is the connection collation, known to every server
+// version that supports GTIDs
+const utf8mb4GeneralCI = 45
+
+// Error codes the callers act on
+const (
+	ErrParse       = 1064
+	ErrSourceFatal = 1236
+)
+
+// Config is where and as whom to connect
+type Config struct {
+	Host     string
+	Port     int
+	Database string
+	User     string
+	Password string
+}
+
+// DefaultPort is used when a config has no port
+const DefaultPort = 3306
+
+// ParseConnString reads a connection string of space-separated key=value
+// pairs, as witnz builds them for every database
+func ParseConnString(connStr string) (*Config, error) {
+	config := &Config{Port: DefaultPort}
+	for _, field := range strings.Fields(connStr) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid connection string field: %s", field)
+		}
+		switch key {
+		case "host":
+			config.Host = value
+		case "port":
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid port: %s", value)
+			}
+			if port != 0 {
+				config.Port = port
+			}
+		case "dbname":
+			config.Database = value
+		case "user":
+			config.User = value
+		case "password":
+			config.Password = value
+		}
+	}
+	return config, nil
+}
+
+// Error is an error reported by the server
+type Error struct {
+	Code    uint16
+	State   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("Error %d (%s): %s", e.Code, e.State, e.Message)
+}
+
+func parseError(data []byte) error {
+	r := newReader(data)
+	r.skip(1)
+	e := &Error{Code: r.uint16()}
+	if r.remaining() > 0 && r.data[r.pos] == '#' {
+		r.skip(1)
+		e.State = string(r.bytes(5))
+	}
+	e.Message = string(r.rest())
+	if r.err != nil {
+		return r.err
+	}
+	return e
+}
+
+// IsError reports whether err is a server error with the given code
+func IsError(err error, code uint16) bool {
+	var mysqlErr *Error
+	return errors.As(err, &mysqlErr) && mysqlErr.Code == code
+}
+
+// Conn is one connection to a MySQL server. It is not safe for concurrent
+// use.
+type Conn struct {
+	netConn       net.Conn
+	r             *bufio.Reader
+	seq           uint8
+	serverVersion string
+	// broken is set once a read or write failed part way; the connection
+	// cannot be used after that
+	broken error
+}
+
+// Connect opens a connection and authenticates with
+// mysql_native_password or caching_sha2_password
+func Connect(ctx context.Context, config *Config) (*Conn, error) {
+	port := config.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+
+	var dialer net.Dialer
+	netConn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(config.Host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	c := &Conn{netConn: netConn, r: bufio.NewReader(netConn)}
+	stop := c.watch(ctx)
+	err = c.handshake(config)
+	stop()
+	if err != nil {
+		netConn.Close()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to connect: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	return c, nil
+}
+
+// ServerVersion returns the version the server announced
+func (c *Conn) ServerVersion() string {
+	return c.serverVersion
+}
+
+// watch aborts blocking reads and writes once ctx is done
+func (c *Conn) watch(ctx context.Context) func() {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.netConn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		c.netConn.SetDeadline(time.Now())
+	})
+	return func() {
+		stop()
+		c.netConn.SetDeadline(time.Time{})
+	}
+}
+
+// fail marks the connection broken after an I/O error
+func (c *Conn) fail(err error) error {
+	if c.broken == nil {
+		c.broken = fmt.Errorf("connection broken: %w", err)
+	}
+	return err
+}
+
+func (c *Conn) handshake(config *Config) error {
+	data, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	if data[0] == 0xff {
+		return parseError(data)
+	}
+	if data[0] != 10 {
+		return fmt.Errorf("unsupported protocol version %d", data[0])
+	}
+
+	r := newReader(data)
+	r.skip(1)
+	c.serverVersion = r.nulString()
+	r.skip(4)
+	scramble := append([]byte(nil), r.bytes(8)...)
+	r.skip(1)
+	capabilities := uint32(r.uint16())
+
+	plugin := nativePassword
+	if r.remaining() > 0 {
+		r.skip(3)
+		capabilities |= uint32(r.uint16()) << 16
+		authLength := int(r.uint8())
+		r.skip(10)
+		if capabilities&clientSecureConnection != 0 {
+			part := r.bytes(max(13, authLength-8))
+			if len(part) > 0 && part[len(part)-1] == 0 {
+				part = part[:len(part)-1]
+			}
+			scramble = append(scramble, part...)
+		}
+		if capabilities&clientPluginAuth != 0 {
+			plugin = r.nulString()
+		}
+	}
+	if r.err != nil {
+		return fmt.Errorf("failed to read handshake: %w", r.err)
+	}
+	if capabilities&clientProtocol41 == 0 || capabilities&clientSecureConnection == 0 {
+		return fmt.Errorf("server %s is too old", c.serverVersion)
+	}
+
+	flags := clientLongPassword | clientLongFlag | clientProtocol41 | clientTransactions |
+		clientSecureConnection | clientMultiResults | clientPluginAuth | clientPluginAuthLenencClientData
+	if config.Database != "" {
+		flags |= clientConnectWithDB
+	}
+	flags &= capabilities
+
+	// Other plugins are negotiated through an authentication switch
+	if plugin != cachingSHA2Password {
+		plugin = nativePassword
+	}
+	authData, err := authResponse(plugin, config.Password, scramble)
+	if err != nil {
+		return err
+	}
+
+	response := make([]byte, 0, 128)
+	response = append(response, byte(flags), byte(flags>>8), byte(flags>>16), byte(flags>>24))
+	response = append(response, 0, 0, 0, 1)
+	response = append(response, utf8mb4GeneralCI)
+	response = append(response, make([]byte, 23)...)
+	response = append(response, config.User...)
+	response = append(response, 0)
+	if flags&clientPluginAuthLenencClientData != 0 {
+		response = appendLenenc(response, uint64(len(authData)))
+	} else {
+		response = append(response, byte(len(authData)))
+	}
+	response = append(response, authData...)
+	if flags&clientConnectWithDB != 0 {
+		response = append(append(response, config.Database...), 0)
+	}
+	if flags&clientPluginAuth != 0 {
+		response = append(append(response, plugin...), 0)
+	}
+	if err := c.writePacket(response); err != nil {
+		return err
+	}
+
+	return c.authenticate(plugin, config.Password, scramble)
+}
+
+// readResult reads an OK or ERR packet
+func (c *Conn) readResult() error {
+	data, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	switch data[0] {
+	case 0x00:
+		return nil
+	case 0xff:
+		return parseError(data)
+	default:
+		return fmt.Errorf("unexpected packet 0x%02x", data[0])
+	}
+}
+
+// Close ends the session and closes the connection
+func (c *Conn) Close() error {
+	if c.broken == nil {
+		c.netConn.SetDeadline(time.Now().Add(time.Second))
+		_ = c.writeCommand([]byte{comQuit})
+	}
+	return c.netConn.Close()
+}
@@ -0,0 +1,406 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeConn is the server side of a test connection
+type fakeConn struct {
+	t    *testing.T
+	conn net.Conn
+	seq  uint8
+}
+
+func (fc *fakeConn) read() []byte {
+	var header [4]byte
+	if _, err := io.ReadFull(fc.conn, header[:]); err != nil {
+		fc.t.Errorf("server read failed: %v", err)
+		return nil
+	}
+	payload := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	if _, err := io.ReadFull(fc.conn, payload); err != nil {
+		fc.t.Errorf("server read failed: %v", err)
+		return nil
+	}
+	fc.seq = header[3] + 1
+	return payload
+}
+
+func (fc *fakeConn) write(payload []byte) {
+	packet := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), fc.seq}
+	fc.seq++
+	if _, err := fc.conn.Write(append(packet, payload...)); err != nil {
+		fc.t.Errorf("server write failed: %v", err)
+	}
+}
+
+func (fc *fakeConn) ok() {
+	fc.write([]byte{0x00, 0, 0, 2, 0, 0, 0})
+}
+
+func (fc *fakeConn) fail(code uint16, message string) {
+	payload := binary.LittleEndian.AppendUint16([]byte{0xff}, code)
+	fc.write(append(append(payload, "#42000"...), message...))
+}
+
+func (fc *fakeConn) eof() {
+	fc.write([]byte{0xfe, 0, 0, 2, 0})
+}
+
+// result sends a text result set whose values are all strings, nil for
+// NULL
+func (fc *fakeConn) result(columns []string, rows ...[]interface{}) {
+	fc.write(appendLenenc(nil, uint64(len(columns))))
+	for _, name := range columns {
+		def := lenencString("def")
+		for _, part := range []string{"", "", "", name, name} {
+			def = append(def, lenencString(part)...)
+		}
+		def = append(def, 0x0c, 45, 0, 0, 1, 0, 0, TypeVarString, 0, 0, 0, 0, 0)
+		fc.write(def)
+	}
+	fc.eof()
+	for _, row := range rows {
+		var data []byte
+		for _, value := range row {
+			if value == nil {
+				data = append(data, 0xfb)
+			} else {
+				data = append(data, lenencString(value.(string))...)
+			}
+		}
+		fc.write(data)
+	}
+	fc.eof()
+}
+
+// query reads a COM_QUERY and returns its text
+func (fc *fakeConn) query() string {
+	data := fc.read()
+	if len(data) == 0 || data[0] != comQuery {
+		fc.t.Errorf("Expected COM_QUERY, got %x", data)
+		return ""
+	}
+	return string(data[1:])
+}
+
+var testScramble = []byte("abcdefghijklmnopqrst")
+
+// startServer accepts one connection, runs the handshake with plugin and
+// hands the connection to serve
+func startServer(t *testing.T, plugin string, auth func(fc *fakeConn, response []byte), serve func(fc *fakeConn)) *Config {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	done := make(chan struct{})
+	t.Cleanup(func() { <-done })
+	go func() {
+		defer close(done)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fc := &fakeConn{t: t, conn: conn}
+
+		capabilities := clientLongPassword | clientLongFlag | clientConnectWithDB | clientProtocol41 |
+			clientTransactions | clientSecureConnection | clientMultiResults | clientPluginAuth | clientPluginAuthLenencClientData
+		greeting := append([]byte{10}, "8.0.36\x00"...)
+		greeting = binary.LittleEndian.AppendUint32(greeting, 7)
+		greeting = append(append(greeting, testScramble[:8]...), 0)
+		greeting = binary.LittleEndian.AppendUint16(greeting, uint16(capabilities))
+		greeting = append(greeting, 45, 2, 0)
+		greeting = binary.LittleEndian.AppendUint16(greeting, uint16(capabilities>>16))
+		greeting = append(greeting, 21)
+		greeting = append(greeting, make([]byte, 10)...)
+		greeting = append(append(greeting, testScramble[8:]...), 0)
+		greeting = append(append(greeting, plugin...), 0)
+		fc.write(greeting)
+
+		r := newReader(fc.read())
+		r.skip(4 + 4 + 1 + 23)
+		if user := r.nulString(); user != "witnz" {
+			t.Errorf("Expected user witnz, got %s", user)
+		}
+		response := r.lenencBytes()
+		if database := r.nulString(); database != "shop" {
+			t.Errorf("Expected database shop, got %s", database)
+		}
+		auth(fc, response)
+		if serve != nil {
+			serve(fc)
+		}
+	}()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	config, err := ParseConnString("host=127.0.0.1 port=" + strconv.Itoa(port) + " dbname=shop user=witnz password=secret sslmode=disable")
+	if err != nil {
+		t.Fatalf("ParseConnString failed: %v", err)
+	}
+	return config
+}
+
+func nativeAuth(fc *fakeConn, response []byte) {
+	if !bytes.Equal(response, scrambleNative(testScramble, "secret")) {
+		fc.fail(1045, "Access denied")
+		return
+	}
+	fc.ok()
+}
+
+func TestConnect(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("NativePassword", func(t *testing.T) {
+		config := startServer(t, nativePassword, nativeAuth, nil)
+		conn, err := Connect(ctx, config)
+		if err != nil {
+			t.Fatalf("Connect failed: %v", err)
+		}
+		defer conn.Close()
+
+		if conn.ServerVersion() != "8.0.36" {
+			t.Errorf("Expected server version 8.0.36, got %s", conn.ServerVersion())
+		}
+	})
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		config := startServer(t, nativePassword, nativeAuth, nil)
+		config.Password = "wrong"
+
+		_, err := Connect(ctx, config)
+		if !IsError(err, 1045) {
+			t.Errorf("Expected access denied, got %v", err)
+		}
+	})
+
+	t.Run("AuthSwitch", func(t *testing.T) {
+		config := startServer(t, "authentication_ldap_sasl_client", func(fc *fakeConn, response []byte) {
+			other := []byte("ABCDEFGHIJKLMNOPQRST")
+			fc.write(append(append(append([]byte{authSwitchRequest}, nativePassword...), 0), append(other, 0)...))
+			if !bytes.Equal(fc.read(), scrambleNative(other, "secret")) {
+				fc.fail(1045, "Access denied")
+				return
+			}
+			fc.ok()
+		}, nil)
+
+		conn, err := Connect(ctx, config)
+		if err != nil {
+			t.Fatalf("Connect failed: %v", err)
+		}
+		conn.Close()
+	})
+
+	t.Run("CachingSHA2FullAuth", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("GenerateKey failed: %v", err)
+		}
+		public, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})
+
+		config := startServer(t, cachingSHA2Password, func(fc *fakeConn, response []byte) {
+			if !bytes.Equal(response, scrambleSHA256(testScramble, "secret")) {
+				t.Errorf("Expected SHA256 scramble")
+			}
+			fc.write([]byte{authMoreData, performFullAuth})
+			if request := fc.read(); !bytes.Equal(request, []byte{requestPublicKey}) {
+				t.Errorf("Expected public key request, got %x", request)
+			}
+			fc.write(append([]byte{authMoreData}, keyPEM...))
+
+			plain, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, key, fc.read(), nil)
+			if err != nil {
+				t.Errorf("DecryptOAEP failed: %v", err)
+			}
+			for i := range plain {
+				plain[i] ^= testScramble[i%len(testScramble)]
+			}
+			if string(plain) != "secret\x00" {
+				fc.fail(1045, "Access denied")
+				return
+			}
+			fc.ok()
+		}, nil)
+
+		conn, err := Connect(ctx, config)
+		if err != nil {
+			t.Fatalf("Connect failed: %v", err)
+		}
+		conn.Close()
+	})
+}
+
+func TestQuery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	config := startServer(t, nativePassword, nativeAuth, func(fc *fakeConn) {
+		if q := fc.query(); !strings.HasPrefix(q, "SHOW GLOBAL VARIABLES WHERE Variable_name IN (X'6c6f675f62696e'") {
+			t.Errorf("Unexpected query %s", q)
+		}
+		fc.result([]string{"Variable_name", "Value"},
+			[]interface{}{"log_bin", "ON"},
+			[]interface{}{"binlog_format", "ROW"})
+
+		if q := fc.query(); q != "SHOW BINARY LOG STATUS" {
+			t.Errorf("Expected SHOW BINARY LOG STATUS, got %s", q)
+		}
+		fc.fail(ErrParse, "You have an error in your SQL syntax")
+		if q := fc.query(); q != "SHOW MASTER STATUS" {
+			t.Errorf("Expected SHOW MASTER STATUS, got %s", q)
+		}
+		fc.result([]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"},
+			[]interface{}{"binlog.000003", "157", "", "", testUUID + ":1-9"})
+
+		fc.query()
+		fc.result([]string{"id"}, []interface{}{"1"}, []interface{}{nil}, []interface{}{"3"})
+
+		fc.query()
+		fc.fail(1146, "Table 'shop.missing' doesn't exist")
+
+		// The connection still works after an error
+		fc.query()
+		fc.ok()
+	})
+
+	conn, err := Connect(ctx, config)
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer conn.Close()
+
+	variables, err := conn.Variables(ctx, "log_bin", "binlog_format", "gtid_mode")
+	if err != nil {
+		t.Fatalf("Variables failed: %v", err)
+	}
+	if len(variables) != 2 || variables["log_bin"] != "ON" || variables["binlog_format"] != "ROW" {
+		t.Errorf("Expected log_bin and binlog_format, got %v", variables)
+	}
+
+	status, err := conn.BinlogStatus(ctx)
+	if err != nil {
+		t.Fatalf("BinlogStatus failed: %v", err)
+	}
+	if status.File != "binlog.000003" || status.Position != 157 || status.Executed.String() != testUUID+":1-9" {
+		t.Errorf("Expected binlog.000003:157 at %s:1-9, got %+v", testUUID, status)
+	}
+
+	var rows []string
+	err = conn.QueryEach(ctx, "SELECT id FROM orders", func(columns []Column, row [][]byte) error {
+		if row[0] == nil {
+			rows = append(rows, "NULL")
+			return errors.New("stop")
+		}
+		rows = append(rows, string(row[0]))
+		return nil
+	})
+	if err == nil || err.Error() != "stop" {
+		t.Errorf("Expected the callback error, got %v", err)
+	}
+	if strings.Join(rows, ",") != "1,NULL" {
+		t.Errorf("Expected rows 1,NULL, got %v", rows)
+	}
+
+	if _, err := conn.Query(ctx, "SELECT * FROM missing"); !IsError(err, 1146) {
+		t.Errorf("Expected error 1146, got %v", err)
+	}
+	if err := conn.Exec(ctx, "SET time_zone = '+00:00'"); err != nil {
+		t.Errorf("Exec failed: %v", err)
+	}
+}
+
+func TestBinlogDump(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	executed, _ := ParseGTIDSet(testUUID + ":1-9")
+	xid := testEvent(XIDEvent, 700, binary.LittleEndian.AppendUint64(nil, 77))
+
+	config := startServer(t, nativePassword, nativeAuth, func(fc *fakeConn) {
+		for _, prefix := range []string{"SET @source_binlog_checksum", "SET @source_heartbeat_period = 5000000000"} {
+			if q := fc.query(); !strings.HasPrefix(q, prefix) {
+				t.Errorf("Expected %s, got %s", prefix, q)
+			}
+			fc.ok()
+		}
+
+		register := fc.read()
+		if register[0] != comRegisterSlave || binary.LittleEndian.Uint32(register[1:]) != 1234 {
+			t.Errorf("Expected COM_REGISTER_SLAVE for 1234, got %x", register)
+		}
+		fc.ok()
+
+		dump := fc.read()
+		r := newReader(dump)
+		if command := r.uint8(); command != comBinlogDumpGTID {
+			t.Errorf("Expected COM_BINLOG_DUMP_GTID, got %d", command)
+		}
+		r.skip(2)
+		if serverID := r.uint32(); serverID != 1234 {
+			t.Errorf("Expected server id 1234, got %d", serverID)
+		}
+		r.skip(int(r.uint32()) + 8)
+		if gtids := r.bytes(int(r.uint32())); !bytes.Equal(gtids, executed.encode()) {
+			t.Errorf("Expected encoded GTID set, got %x", gtids)
+		}
+
+		fc.write(append([]byte{0x00}, xid...))
+		fc.fail(ErrSourceFatal, "Cannot replicate because the source purged required binary logs")
+	})
+
+	conn, err := Connect(ctx, config)
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.StartBinlogDump(ctx, 1234, executed, 5*time.Second); err != nil {
+		t.Fatalf("StartBinlogDump failed: %v", err)
+	}
+
+	event, err := conn.ReadEvent(ctx)
+	if err != nil {
+		t.Fatalf("ReadEvent failed: %v", err)
+	}
+	if !bytes.Equal(event, xid) {
+		t.Errorf("Expected the XID event, got %x", event)
+	}
+
+	if _, err := conn.ReadEvent(ctx); !IsError(err, ErrSourceFatal) {
+		t.Errorf("Expected error %d, got %v", ErrSourceFatal, err)
+	}
+}
+
+func TestParseConnString(t *testing.T) {
+	config, err := ParseConnString("host=db port=0 dbname=shop user=witnz password=secret sslmode=disable")
+	if err != nil {
+		t.Fatalf("ParseConnString failed: %v", err)
+	}
+	if config.Host != "db" || config.Port != 3306 || config.Database != "shop" || config.User != "witnz" || config.Password != "secret" {
+		t.Errorf("Unexpected config %+v", config)
+	}
+
+	if _, err := ParseConnString("host=db port=abc"); err == nil {
+		t.Error("Expected error for invalid port")
+	}
+}
@@ -0,0 +1,153 @@
+package mysql
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Interval is a half-open range [Start, End) of transaction numbers
+type Interval struct {
+	Start uint64
+	End   uint64
+}
+
+// GTIDSet maps a server UUID to the sorted, disjoint intervals of
+// transactions executed from it
+type GTIDSet map[string][]Interval
+
+// ParseGTIDSet reads a set as MySQL prints it, such as
+// "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7,4f3a...:1"
+func ParseGTIDSet(s string) (GTIDSet, error) {
+	set := GTIDSet{}
+	s = strings.Join(strings.Fields(s), "")
+	if s == "" {
+		return set, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		fields := strings.Split(part, ":")
+		uuid, err := normalizeUUID(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid GTID set %q: %w", s, err)
+		}
+		if len(fields) == 1 {
+			return nil, fmt.Errorf("invalid GTID set %q: no intervals for %s", s, uuid)
+		}
+		for _, field := range fields[1:] {
+			first, last, found := strings.Cut(field, "-")
+			start, err := strconv.ParseUint(first, 10, 63)
+			if err != nil || start == 0 {
+				return nil, fmt.Errorf("invalid GTID set %q: bad interval %s", s, field)
+			}
+			end := start
+			if found {
+				if end, err = strconv.ParseUint(last, 10, 63); err != nil || end < start {
+					return nil, fmt.Errorf("invalid GTID set %q: bad interval %s", s, field)
+				}
+			}
+			set.addInterval(uuid, Interval{Start: start, End: end + 1})
+		}
+	}
+	return set, nil
+}
+
+// normalizeUUID lower-cases a UUID and checks its shape
+func normalizeUUID(s string) (string, error) {
+	s = strings.ToLower(s)
+	if _, err := uuidBytes(s); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+func uuidBytes(uuid string) ([]byte, error) {
+	raw, err := hex.DecodeString(strings.ReplaceAll(uuid, "-", ""))
+	if err != nil || len(raw) != 16 || len(uuid) != 36 {
+		return nil, fmt.Errorf("invalid server UUID %q", uuid)
+	}
+	return raw, nil
+}
+
+func formatUUID(raw []byte) string {
+	h := hex.EncodeToString(raw)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// Add records one transaction
+func (s GTIDSet) Add(uuid string, transaction uint64) {
+	s.addInterval(strings.ToLower(uuid), Interval{Start: transaction, End: transaction + 1})
+}
+
+func (s GTIDSet) addInterval(uuid string, add Interval) {
+	intervals := append(s[uuid], add)
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Start < intervals[j].Start })
+
+	merged := intervals[:1]
+	for _, next := range intervals[1:] {
+		last := &merged[len(merged)-1]
+		if next.Start <= last.End {
+			last.End = max(last.End, next.End)
+			continue
+		}
+		merged = append(merged, next)
+	}
+	s[uuid] = merged
+}
+
+// Clone returns a copy that can be changed independently
+func (s GTIDSet) Clone() GTIDSet {
+	clone := make(GTIDSet, len(s))
+	for uuid, intervals := range s {
+		clone[uuid] = append([]Interval(nil), intervals...)
+	}
+	return clone
+}
+
+// String prints the set the way MySQL does, with UUIDs in order
+func (s GTIDSet) String() string {
+	uuids := make([]string, 0, len(s))
+	for uuid := range s {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	var b strings.Builder
+	for i, uuid := range uuids {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(uuid)
+		for _, interval := range s[uuid] {
+			if interval.End-interval.Start == 1 {
+				fmt.Fprintf(&b, ":%d", interval.Start)
+			} else {
+				fmt.Fprintf(&b, ":%d-%d", interval.Start, interval.End-1)
+			}
+		}
+	}
+	return b.String()
+}
+
+// encode returns the binary form COM_BINLOG_DUMP_GTID expects
+func (s GTIDSet) encode() []byte {
+	uuids := make([]string, 0, len(s))
+	for uuid := range s {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	b := binary.LittleEndian.AppendUint64(nil, uint64(len(uuids)))
+	for _, uuid := range uuids {
+		raw, _ := uuidBytes(uuid)
+		b = append(b, raw...)
+		b = binary.LittleEndian.AppendUint64(b, uint64(len(s[uuid])))
+		for _, interval := range s[uuid] {
+			b = binary.LittleEndian.AppendUint64(b, interval.Start)
+			b = binary.LittleEndian.AppendUint64(b, interval.End)
+		}
+	}
+	return b
+}
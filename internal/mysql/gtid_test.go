@@ -0,0 +1,89 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+const testUUID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+func TestParseGTIDSet(t *testing.T) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		text := "4f3a0000-0000-0000-0000-000000000001:1," + testUUID + ":1-5:7"
+		set, err := ParseGTIDSet(text)
+		if err != nil {
+			t.Fatalf("ParseGTIDSet failed: %v", err)
+		}
+
+		want := testUUID + ":1-5:7,4f3a0000-0000-0000-0000-000000000001:1"
+		if got := set.String(); got != want {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+	})
+
+	t.Run("MultiLine", func(t *testing.T) {
+		set, err := ParseGTIDSet(" " + testUUID + ":1-3,\n4f3a0000-0000-0000-0000-000000000001:2\n")
+		if err != nil {
+			t.Fatalf("ParseGTIDSet failed: %v", err)
+		}
+		if len(set) != 2 {
+			t.Errorf("Expected 2 servers, got %d", len(set))
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		set, err := ParseGTIDSet("")
+		if err != nil {
+			t.Fatalf("ParseGTIDSet failed: %v", err)
+		}
+		if set.String() != "" {
+			t.Errorf("Expected empty set, got %s", set)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, text := range []string{"not-a-uuid:1", testUUID, testUUID + ":0", testUUID + ":5-3", testUUID + ":x"} {
+			if _, err := ParseGTIDSet(text); err == nil {
+				t.Errorf("Expected error for %q", text)
+			}
+		}
+	})
+}
+
+func TestGTIDSetAdd(t *testing.T) {
+	set, err := ParseGTIDSet(testUUID + ":1-5:7")
+	if err != nil {
+		t.Fatalf("ParseGTIDSet failed: %v", err)
+	}
+	clone := set.Clone()
+
+	set.Add(testUUID, 6)
+	set.Add("4F3A0000-0000-0000-0000-000000000001", 1)
+
+	want := testUUID + ":1-7,4f3a0000-0000-0000-0000-000000000001:1"
+	if got := set.String(); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if got := clone.String(); got != testUUID+":1-5:7" {
+		t.Errorf("Expected clone to be unchanged, got %s", got)
+	}
+}
+
+func TestGTIDSetEncode(t *testing.T) {
+	set, err := ParseGTIDSet(testUUID + ":1-5:7")
+	if err != nil {
+		t.Fatalf("ParseGTIDSet failed: %v", err)
+	}
+
+	raw, _ := uuidBytes(testUUID)
+	want := binary.LittleEndian.AppendUint64(nil, 1)
+	want = append(want, raw...)
+	for _, v := range []uint64{2, 1, 6, 7, 8} {
+		want = binary.LittleEndian.AppendUint64(want, v)
+	}
+
+	if got := set.encode(); !bytes.Equal(got, want) {
+		t.Errorf("Expected %x, got %x", want, got)
+	}
+}
@@ -0,0 +1,274 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Value types of MySQL's binary JSON format
+const (
+	jsonSmallObject = 0x00
+	jsonLargeObject = 0x01
+	jsonSmallArray  = 0x02
+	jsonLargeArray  = 0x03
+	jsonLiteral     = 0x04
+	jsonInt16       = 0x05
+	jsonUint16      = 0x06
+	jsonInt32       = 0x07
+	jsonUint32      = 0x08
+	jsonInt64       = 0x09
+	jsonUint64      = 0x0a
+	jsonDouble      = 0x0b
+	jsonString      = 0x0c
+	jsonOpaque      = 0x0f
+)
+
+var errBadJSON = errors.New("malformed binary JSON")
+
+// decodeJSON turns a JSON column of a row event into canonical JSON text
+func decodeJSON(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "null", nil
+	}
+	value, err := decodeJSONValue(data[0], data[1:])
+	if err != nil {
+		return "", err
+	}
+	return marshalJSON(value)
+}
+
+// CanonicalJSON rewrites JSON text the way decodeJSON prints binary JSON:
+// keys sorted, no spaces and numbers in their shortest form
+func CanonicalJSON(text []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(text))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return "", fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	return marshalJSON(value)
+}
+
+func marshalJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(normalizeJSON(value))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return string(data), nil
+}
+
+// normalizeJSON gives every number a single spelling, so 1.0 and 1, or a
+// DECIMAL and a DOUBLE of the same value, print the same
+func normalizeJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeJSON(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeJSON(item)
+		}
+	case json.Number:
+		return normalizeNumber(string(v))
+	}
+	return value
+}
+
+func normalizeNumber(s string) json.Number {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return json.Number(strconv.FormatInt(i, 10))
+	}
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return json.Number(strconv.FormatUint(u, 10))
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) {
+		if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return json.Number(strconv.FormatInt(int64(f), 10))
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return json.Number(s)
+}
+
+func decodeJSONValue(t byte, data []byte) (interface{}, error) {
+	switch t {
+	case jsonSmallObject, jsonLargeObject:
+		return decodeJSONContainer(data, t == jsonLargeObject, true)
+	case jsonSmallArray, jsonLargeArray:
+		return decodeJSONContainer(data, t == jsonLargeArray, false)
+	case jsonLiteral:
+		if len(data) < 1 {
+			return nil, errBadJSON
+		}
+		switch data[0] {
+		case 0x00:
+			return nil, nil
+		case 0x01:
+			return true, nil
+		case 0x02:
+			return false, nil
+		}
+		return nil, errBadJSON
+	case jsonInt16, jsonUint16, jsonInt32, jsonUint32, jsonInt64, jsonUint64, jsonDouble:
+		size := map[byte]int{jsonInt16: 2, jsonUint16: 2, jsonInt32: 4, jsonUint32: 4}[t]
+		if size == 0 {
+			size = 8
+		}
+		if len(data) < size {
+			return nil, errBadJSON
+		}
+		v := newReader(data).uint(size)
+		switch t {
+		case jsonInt16:
+			return json.Number(strconv.FormatInt(int64(int16(v)), 10)), nil
+		case jsonInt32:
+			return json.Number(strconv.FormatInt(int64(int32(v)), 10)), nil
+		case jsonInt64:
+			return json.Number(strconv.FormatInt(int64(v), 10)), nil
+		case jsonDouble:
+			return json.Number(strconv.FormatFloat(math.Float64frombits(v), 'g', -1, 64)), nil
+		default:
+			return json.Number(strconv.FormatUint(v, 10)), nil
+		}
+	case jsonString:
+		n, size, err := jsonLength(data)
+		if err != nil || size+n > len(data) {
+			return nil, errBadJSON
+		}
+		return string(data[size : size+n]), nil
+	case jsonOpaque:
+		if len(data) < 1 {
+			return nil, errBadJSON
+		}
+		n, size, err := jsonLength(data[1:])
+		if err != nil || 1+size+n > len(data) {
+			return nil, errBadJSON
+		}
+		return decodeJSONOpaque(data[0], data[1+size:1+size+n])
+	default:
+		return nil, fmt.Errorf("unknown binary JSON type %d", t)
+	}
+}
+
+// jsonLength reads a length stored in 7 bit groups, low group first
+func jsonLength(data []byte) (n, size int, err error) {
+	for i := 0; i < 5 && i < len(data); i++ {
+		n |= int(data[i]&0x7f) << (7 * i)
+		if data[i]&0x80 == 0 {
+			return n, i + 1, nil
+		}
+	}
+	return 0, 0, errBadJSON
+}
+
+func decodeJSONContainer(data []byte, large, object bool) (interface{}, error) {
+	offsetSize := 2
+	if large {
+		offsetSize = 4
+	}
+	if len(data) < 2*offsetSize {
+		return nil, errBadJSON
+	}
+	r := newReader(data)
+	count := int(r.uint(offsetSize))
+	size := int(r.uint(offsetSize))
+	if size > len(data) {
+		return nil, errBadJSON
+	}
+	data = data[:size]
+
+	keyEntries := 0
+	if object {
+		keyEntries = count * (offsetSize + 2)
+	}
+	valueEntry := 1 + offsetSize
+	if 2*offsetSize+keyEntries+count*valueEntry > size {
+		return nil, errBadJSON
+	}
+
+	values := make([]interface{}, count)
+	for i := range values {
+		entry := 2*offsetSize + keyEntries + i*valueEntry
+		t := data[entry]
+		switch {
+		case t == jsonLiteral, t == jsonInt16, t == jsonUint16,
+			large && (t == jsonInt32 || t == jsonUint32):
+			// Small scalars are stored in the entry itself
+			value, err := decodeJSONValue(t, data[entry+1:entry+valueEntry])
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		default:
+			offset := int(newReader(data[entry+1:]).uint(offsetSize))
+			if offset >= size {
+				return nil, errBadJSON
+			}
+			value, err := decodeJSONValue(t, data[offset:])
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+	}
+	if !object {
+		return values, nil
+	}
+
+	result := make(map[string]interface{}, count)
+	for i := range values {
+		entry := 2*offsetSize + i*(offsetSize+2)
+		kr := newReader(data[entry:])
+		offset, length := int(kr.uint(offsetSize)), int(kr.uint16())
+		if offset+length > size {
+			return nil, errBadJSON
+		}
+		result[string(data[offset:offset+length])] = values[i]
+	}
+	return result, nil
+}
+
+// decodeJSONOpaque decodes the MySQL values JSON keeps in their own
+// binary format. DECIMALs become numbers and temporal values strings, as
+// in the JSON text MySQL prints.
+func decodeJSONOpaque(fieldType byte, data []byte) (interface{}, error) {
+	switch fieldType {
+	case TypeNewDecimal:
+		if len(data) < 2 {
+			return nil, errBadJSON
+		}
+		value, err := decodeDecimal(newReader(data[2:]), int(data[0]), int(data[1]))
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(value), nil
+	case TypeDate, TypeDateTime, TypeTimestamp, TypeTime:
+		if len(data) < 8 {
+			return nil, errBadJSON
+		}
+		packed := int64(binary.LittleEndian.Uint64(data))
+		if fieldType == TypeTime {
+			return formatPackedTime(packed, 6), nil
+		}
+		if packed < 0 {
+			packed = -packed
+		}
+		ymdhms, micros := packed>>24, packed%(1<<24)
+		ymd, hms := ymdhms>>17, ymdhms%(1<<17)
+		ym := ymd >> 5
+		date := fmt.Sprintf("%04d-%02d-%02d", ym/13, ym%13, ymd%(1<<5))
+		if fieldType == TypeDate {
+			return date, nil
+		}
+		return fmt.Sprintf("%s %02d:%02d:%02d%s", date, hms>>12, (hms>>6)%(1<<6), hms%(1<<6), formatFraction(micros, 6)), nil
+	default:
+		return fmt.Sprintf("base64:type%d:%s", fieldType, base64.StdEncoding.EncodeToString(data)), nil
+	}
+}
@@ -0,0 +1,216 @@
+package mysql
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// maxPacketSize is the largest payload of a single packet. Longer payloads
+// are split and end with a shorter packet.
+const maxPacketSize = 1<<24 - 1
+
+var errShortPacket = errors.New("malformed packet: unexpected end of data")
+
+// reader decodes the fields of a packet or binlog event. The first read
+// past the end sets err, and every later read returns zero values.
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func newReader(data []byte) *reader {
+	return &reader{data: data}
+}
+
+func (r *reader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > r.remaining() {
+		r.err = errShortPacket
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *reader) rest() []byte {
+	return r.bytes(r.remaining())
+}
+
+func (r *reader) uint8() uint8 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+// uint reads an n byte little-endian integer
+func (r *reader) uint(n int) uint64 {
+	b := r.bytes(n)
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v
+}
+
+// uintBE reads an n byte big-endian integer
+func (r *reader) uintBE(n int) uint64 {
+	var v uint64
+	for _, b := range r.bytes(n) {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func (r *reader) uint16() uint16 {
+	return uint16(r.uint(2))
+}
+
+func (r *reader) uint32() uint32 {
+	return uint32(r.uint(4))
+}
+
+func (r *reader) uint64() uint64 {
+	return r.uint(8)
+}
+
+// lenenc reads a length-encoded integer. null is set for the NULL marker
+// of text rows.
+func (r *reader) lenenc() (v uint64, null bool) {
+	switch first := r.uint8(); first {
+	case 0xfb:
+		return 0, true
+	case 0xfc:
+		return r.uint(2), false
+	case 0xfd:
+		return r.uint(3), false
+	case 0xfe:
+		return r.uint(8), false
+	default:
+		return uint64(first), false
+	}
+}
+
+func (r *reader) lenencInt() uint64 {
+	v, _ := r.lenenc()
+	return v
+}
+
+func (r *reader) lenencBytes() []byte {
+	n, null := r.lenenc()
+	if null || r.err != nil {
+		return nil
+	}
+	if n > uint64(r.remaining()) {
+		r.err = errShortPacket
+		return nil
+	}
+	return r.bytes(int(n))
+}
+
+func (r *reader) lenencString() string {
+	return string(r.lenencBytes())
+}
+
+// nulString reads a string terminated by a zero byte, or by the end of the
+// data
+func (r *reader) nulString() string {
+	if r.err != nil {
+		return ""
+	}
+	for i := r.pos; i < len(r.data); i++ {
+		if r.data[i] == 0 {
+			s := string(r.data[r.pos:i])
+			r.pos = i + 1
+			return s
+		}
+	}
+	return string(r.rest())
+}
+
+func appendLenenc(b []byte, v uint64) []byte {
+	switch {
+	case v < 0xfb:
+		return append(b, byte(v))
+	case v < 1<<16:
+		return binary.LittleEndian.AppendUint16(append(b, 0xfc), uint16(v))
+	case v < 1<<24:
+		return append(b, 0xfd, byte(v), byte(v>>8), byte(v>>16))
+	default:
+		return binary.LittleEndian.AppendUint64(append(b, 0xfe), v)
+	}
+}
+
+// readPacket reads one payload, joining the packets of a split payload
+func (c *Conn) readPacket() ([]byte, error) {
+	if c.broken != nil {
+		return nil, c.broken
+	}
+	var payload []byte
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(c.r, header[:]); err != nil {
+			return nil, c.fail(err)
+		}
+		length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+		c.seq = header[3] + 1
+
+		chunk := make([]byte, length)
+		if _, err := io.ReadFull(c.r, chunk); err != nil {
+			return nil, c.fail(err)
+		}
+		if payload == nil && length < maxPacketSize {
+			if length == 0 {
+				return nil, c.fail(errShortPacket)
+			}
+			return chunk, nil
+		}
+		payload = append(payload, chunk...)
+		if length < maxPacketSize {
+			return payload, nil
+		}
+	}
+}
+
+// writePacket writes one payload, split into packets of at most
+// maxPacketSize bytes
+func (c *Conn) writePacket(payload []byte) error {
+	for {
+		n := min(len(payload), maxPacketSize)
+		packet := make([]byte, 4, 4+n)
+		packet[0], packet[1], packet[2], packet[3] = byte(n), byte(n>>8), byte(n>>16), c.seq
+		packet = append(packet, payload[:n]...)
+		c.seq++
+
+		if _, err := c.netConn.Write(packet); err != nil {
+			return c.fail(err)
+		}
+		payload = payload[n:]
+		if n < maxPacketSize {
+			return nil
+		}
+	}
+}
+
+// writeCommand starts a new command exchange
+func (c *Conn) writeCommand(payload []byte) error {
+	if c.broken != nil {
+		return c.broken
+	}
+	c.seq = 0
+	return c.writePacket(payload)
+}
@@ -0,0 +1,211 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// Column describes one column of a query result
+type Column struct {
+	Name     string
+	Type     byte
+	Charset  uint16
+	Flags    uint16
+	Decimals uint8
+}
+
+// Result is a query result read into memory
+type Result struct {
+	Columns []Column
+	// Rows hold the text value of every column, nil for NULL
+	Rows [][][]byte
+}
+
+// Value returns the text of a column of a row, and false for NULL or an
+// unknown column
+func (res *Result) Value(row int, column string) (string, bool) {
+	for i, col := range res.Columns {
+		if col.Name == column && row < len(res.Rows) && res.Rows[row][i] != nil {
+			return string(res.Rows[row][i]), true
+		}
+	}
+	return "", false
+}
+
+// Exec runs a statement that returns no rows
+func (c *Conn) Exec(ctx context.Context, query string) error {
+	return c.QueryEach(ctx, query, func([]Column, [][]byte) error { return nil })
+}
+
+// Query runs a statement and reads all of its rows
+func (c *Conn) Query(ctx context.Context, query string) (*Result, error) {
+	res := &Result{}
+	err := c.QueryEach(ctx, query, func(columns []Column, row [][]byte) error {
+		res.Columns = columns
+		res.Rows = append(res.Rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// QueryEach runs a statement and calls fn for every row as it arrives. If
+// fn fails, the remaining rows are read and dropped and its error is
+// returned.
+func (c *Conn) QueryEach(ctx context.Context, query string, fn func(columns []Column, row [][]byte) error) error {
+	defer c.watch(ctx)()
+
+	if err := c.writeCommand(append([]byte{comQuery}, query...)); err != nil {
+		return c.contextError(ctx, err)
+	}
+
+	data, err := c.readPacket()
+	if err != nil {
+		return c.contextError(ctx, err)
+	}
+	switch data[0] {
+	case 0x00:
+		return nil
+	case 0xff:
+		return parseError(data)
+	}
+
+	r := newReader(data)
+	count := int(r.lenencInt())
+	if r.err != nil {
+		return r.err
+	}
+
+	columns := make([]Column, count)
+	for i := range columns {
+		data, err := c.readPacket()
+		if err != nil {
+			return c.contextError(ctx, err)
+		}
+		if columns[i], err = parseColumn(data); err != nil {
+			return err
+		}
+	}
+	if _, err := c.readPacket(); err != nil {
+		return c.contextError(ctx, err)
+	}
+
+	var fnErr error
+	for {
+		data, err := c.readPacket()
+		if err != nil {
+			return c.contextError(ctx, err)
+		}
+		if data[0] == 0xfe && len(data) < 9 {
+			return fnErr
+		}
+		if data[0] == 0xff {
+			return parseError(data)
+		}
+		if fnErr != nil {
+			continue
+		}
+
+		r := newReader(data)
+		row := make([][]byte, count)
+		for i := range row {
+			row[i] = r.lenencBytes()
+		}
+		if r.err != nil {
+			fnErr = fmt.Errorf("failed to read row: %w", r.err)
+			continue
+		}
+		fnErr = fn(columns, row)
+	}
+}
+
+func (c *Conn) contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func parseColumn(data []byte) (Column, error) {
+	r := newReader(data)
+	r.lenencBytes() // catalog
+	r.lenencBytes() // schema
+	r.lenencBytes() // table
+	r.lenencBytes() // original table
+	col := Column{Name: r.lenencString()}
+	r.lenencBytes() // original name
+	r.lenencInt()
+	col.Charset = r.uint16()
+	r.skip(4) // column length
+	col.Type = r.uint8()
+	col.Flags = r.uint16()
+	col.Decimals = r.uint8()
+	if r.err != nil {
+		return Column{}, fmt.Errorf("failed to read column definition: %w", r.err)
+	}
+	return col, nil
+}
+
+// Variables returns the global value of server variables. Variables the
+// server does not know are left out.
+func (c *Conn) Variables(ctx context.Context, names ...string) (map[string]string, error) {
+	query := "SHOW GLOBAL VARIABLES WHERE Variable_name IN ("
+	for i, name := range names {
+		if i > 0 {
+			query += ", "
+		}
+		query += Literal(name)
+	}
+	res, err := c.Query(ctx, query+")")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server variables: %w", err)
+	}
+
+	variables := make(map[string]string, len(res.Rows))
+	for _, row := range res.Rows {
+		if len(row) == 2 {
+			variables[string(row[0])] = string(row[1])
+		}
+	}
+	return variables, nil
+}
+
+// BinlogStatus is the binary log position of the server and the GTIDs it
+// executed up to there
+type BinlogStatus struct {
+	File     string
+	Position uint32
+	Executed GTIDSet
+}
+
+// BinlogStatus reads the current binary log position
+func (c *Conn) BinlogStatus(ctx context.Context) (*BinlogStatus, error) {
+	res, err := c.Query(ctx, "SHOW BINARY LOG STATUS")
+	if IsError(err, ErrParse) {
+		// Servers before 8.2 only know the old name
+		res, err = c.Query(ctx, "SHOW MASTER STATUS")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read binary log status: %w", err)
+	}
+
+	file, ok := res.Value(0, "File")
+	if !ok {
+		return nil, fmt.Errorf("binary logging is disabled")
+	}
+	position, _ := res.Value(0, "Position")
+	offset, err := strconv.ParseUint(position, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid binary log position %q", position)
+	}
+	executedText, _ := res.Value(0, "Executed_Gtid_Set")
+	executed, err := ParseGTIDSet(executedText)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BinlogStatus{File: file, Position: uint32(offset), Executed: executed}, nil
+}
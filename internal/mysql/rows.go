@@ -0,0 +1,586 @@
+package mysql
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+	"time"
+)
+
+// Column types as they appear in table maps and result sets
+const (
+	TypeDecimal    = 0
+	TypeTiny       = 1
+	TypeShort      = 2
+	TypeLong       = 3
+	TypeFloat      = 4
+	TypeDouble     = 5
+	TypeNull       = 6
+	TypeTimestamp  = 7
+	TypeLongLong   = 8
+	TypeInt24      = 9
+	TypeDate       = 10
+	TypeTime       = 11
+	TypeDateTime   = 12
+	TypeYear       = 13
+	TypeVarchar    = 15
+	TypeBit        = 16
+	TypeTimestamp2 = 17
+	TypeDateTime2  = 18
+	TypeTime2      = 19
+	TypeJSON       = 245
+	TypeNewDecimal = 246
+	TypeEnum       = 247
+	TypeSet        = 248
+	TypeTinyBlob   = 249
+	TypeMediumBlob = 250
+	TypeLongBlob   = 251
+	TypeBlob       = 252
+	TypeVarString  = 253
+	TypeString     = 254
+	TypeGeometry   = 255
+)
+
+// binaryCollation marks binary strings, which are returned as []byte
+const binaryCollation = 63
+
+// Optional table map metadata, logged with binlog_row_metadata=FULL
+const (
+	metaSignedness       = 1
+	metaDefaultCharset   = 2
+	metaColumnCharset    = 3
+	metaColumnName       = 4
+	metaSetValues        = 5
+	metaEnumValues       = 6
+	metaSimplePrimaryKey = 8
+	metaPrefixPrimaryKey = 9
+)
+
+// TableMap describes the table of the rows events that follow it
+type TableMap struct {
+	ID         uint64
+	Schema     string
+	Table      string
+	Columns    []ColumnInfo
+	PrimaryKey []int
+}
+
+// ColumnInfo is what the binary log tells about a column
+type ColumnInfo struct {
+	Name string
+	// Type is the real type; ENUM and SET columns are logged as strings
+	// but carry their real type in the metadata
+	Type      byte
+	Meta      uint16
+	Unsigned  bool
+	Collation uint64
+	Values    []string // of ENUM and SET columns
+}
+
+func parseTableMap(r *reader) (*TableMap, error) {
+	table := &TableMap{ID: r.uint(6)}
+	r.skip(2)
+	table.Schema = string(r.bytes(int(r.uint8())))
+	r.skip(1)
+	table.Table = string(r.bytes(int(r.uint8())))
+	r.skip(1)
+
+	count := int(r.lenencInt())
+	if r.err != nil || count > r.remaining() {
+		return nil, errShortPacket
+	}
+	table.Columns = make([]ColumnInfo, count)
+	for i, t := range r.bytes(count) {
+		table.Columns[i].Type = t
+	}
+
+	meta := newReader(r.lenencBytes())
+	for i := range table.Columns {
+		col := &table.Columns[i]
+		switch col.Type {
+		case TypeString:
+			// Real type and length, the length's upper bits folded into
+			// the type byte
+			b0, b1 := meta.uint8(), meta.uint8()
+			if b0&0x30 != 0x30 {
+				col.Meta = uint16(b1) | uint16((b0&0x30)^0x30)<<4
+				col.Type = b0 | 0x30
+			} else {
+				col.Meta = uint16(b1)
+				col.Type = b0
+			}
+		case TypeVarchar, TypeVarString, TypeBit:
+			col.Meta = meta.uint16()
+		case TypeNewDecimal:
+			col.Meta = uint16(meta.uint8())<<8 | uint16(meta.uint8())
+		case TypeBlob, TypeJSON, TypeGeometry, TypeFloat, TypeDouble,
+			TypeTimestamp2, TypeDateTime2, TypeTime2:
+			col.Meta = uint16(meta.uint8())
+		}
+	}
+	if meta.err != nil {
+		return nil, fmt.Errorf("failed to read column metadata: %w", meta.err)
+	}
+
+	r.skip((count + 7) / 8) // nullable columns
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	for r.remaining() > 0 {
+		kind := r.uint8()
+		value := newReader(r.lenencBytes())
+		if r.err != nil {
+			return nil, r.err
+		}
+		table.readOptionalMetadata(kind, value)
+		if value.err != nil {
+			return nil, fmt.Errorf("failed to read optional metadata %d: %w", kind, value.err)
+		}
+	}
+
+	for i, col := range table.Columns {
+		if col.Name == "" {
+			return nil, fmt.Errorf("no column names for %s.%s; binlog_row_metadata must be FULL", table.Schema, table.Table)
+		}
+		if (col.Type == TypeEnum || col.Type == TypeSet) && col.Values == nil {
+			return nil, fmt.Errorf("no values for column %s of %s.%s", table.Columns[i].Name, table.Schema, table.Table)
+		}
+	}
+	return table, nil
+}
+
+func (t *TableMap) readOptionalMetadata(kind uint8, r *reader) {
+	switch kind {
+	case metaSignedness:
+		bitmap := r.rest()
+		n := 0
+		for i := range t.Columns {
+			if !isNumeric(t.Columns[i].Type) {
+				continue
+			}
+			if n/8 < len(bitmap) {
+				t.Columns[i].Unsigned = bitmap[n/8]&(0x80>>(n%8)) != 0
+			}
+			n++
+		}
+	case metaDefaultCharset:
+		def := r.lenencInt()
+		for i := range t.Columns {
+			if isCharacter(t.Columns[i].Type) {
+				t.Columns[i].Collation = def
+			}
+		}
+		overrides := map[uint64]uint64{}
+		for r.remaining() > 0 && r.err == nil {
+			index := r.lenencInt()
+			overrides[index] = r.lenencInt()
+		}
+		t.eachCharacterColumn(func(n uint64, col *ColumnInfo) {
+			if collation, ok := overrides[n]; ok {
+				col.Collation = collation
+			}
+		})
+	case metaColumnCharset:
+		t.eachCharacterColumn(func(n uint64, col *ColumnInfo) {
+			col.Collation = r.lenencInt()
+		})
+	case metaColumnName:
+		for i := range t.Columns {
+			t.Columns[i].Name = r.lenencString()
+		}
+	case metaSetValues, metaEnumValues:
+		want := byte(TypeSet)
+		if kind == metaEnumValues {
+			want = TypeEnum
+		}
+		for i := range t.Columns {
+			if t.Columns[i].Type != want {
+				continue
+			}
+			values := make([]string, r.lenencInt())
+			for j := range values {
+				values[j] = r.lenencString()
+			}
+			t.Columns[i].Values = values
+		}
+	case metaSimplePrimaryKey:
+		for r.remaining() > 0 && r.err == nil {
+			t.PrimaryKey = append(t.PrimaryKey, int(r.lenencInt()))
+		}
+	case metaPrefixPrimaryKey:
+		for r.remaining() > 0 && r.err == nil {
+			t.PrimaryKey = append(t.PrimaryKey, int(r.lenencInt()))
+			r.lenencInt() // prefix length
+		}
+	}
+}
+
+func (t *TableMap) eachCharacterColumn(fn func(n uint64, col *ColumnInfo)) {
+	var n uint64
+	for i := range t.Columns {
+		if isCharacter(t.Columns[i].Type) {
+			fn(n, &t.Columns[i])
+			n++
+		}
+	}
+}
+
+func isNumeric(t byte) bool {
+	switch t {
+	case TypeTiny, TypeShort, TypeInt24, TypeLong, TypeLongLong, TypeNewDecimal, TypeFloat, TypeDouble:
+		return true
+	}
+	return false
+}
+
+func isCharacter(t byte) bool {
+	switch t {
+	case TypeString, TypeVarString, TypeVarchar, TypeBlob:
+		return true
+	}
+	return false
+}
+
+func (p *BinlogParser) parseRows(eventType EventType, r *reader) (*RowsBody, error) {
+	body := &RowsBody{}
+	switch eventType {
+	case WriteRowsEventV1, WriteRowsEventV2:
+		body.Operation = WriteRowsEventV2
+	case UpdateRowsEventV1, UpdateRowsEventV2:
+		body.Operation = UpdateRowsEventV2
+	default:
+		body.Operation = DeleteRowsEventV2
+	}
+
+	id := r.uint(6)
+	r.skip(2)
+	if eventType >= WriteRowsEventV2 {
+		r.skip(int(r.uint16()) - 2) // extra data, including its length
+	}
+	table, ok := p.tables[id]
+	if !ok {
+		return nil, fmt.Errorf("rows for unknown table id %d", id)
+	}
+	body.Table = table
+
+	count := int(r.lenencInt())
+	if count != len(table.Columns) {
+		return nil, fmt.Errorf("rows of %s.%s have %d columns, table map has %d", table.Schema, table.Table, count, len(table.Columns))
+	}
+	before := r.bytes((count + 7) / 8)
+	after := before
+	if body.Operation == UpdateRowsEventV2 {
+		after = r.bytes((count + 7) / 8)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if !allSet(before, count) || !allSet(after, count) {
+		return nil, fmt.Errorf("rows of %s.%s are partial; binlog_row_image must be FULL", table.Schema, table.Table)
+	}
+
+	for r.remaining() > 0 {
+		row := Row{Offset: uint32(r.pos)}
+		image, err := table.readRow(r)
+		if err != nil {
+			return nil, err
+		}
+		switch body.Operation {
+		case WriteRowsEventV2:
+			row.After = image
+		case DeleteRowsEventV2:
+			row.Before = image
+		default:
+			row.Before = image
+			if row.After, err = table.readRow(r); err != nil {
+				return nil, err
+			}
+		}
+		body.Rows = append(body.Rows, row)
+	}
+	return body, nil
+}
+
+func allSet(bitmap []byte, count int) bool {
+	n := 0
+	for _, b := range bitmap {
+		n += bits.OnesCount8(b)
+	}
+	return n == count
+}
+
+func (t *TableMap) readRow(r *reader) (map[string]interface{}, error) {
+	nulls := r.bytes((len(t.Columns) + 7) / 8)
+	values := make(map[string]interface{}, len(t.Columns))
+	for i := range t.Columns {
+		col := &t.Columns[i]
+		if r.err == nil && nulls[i/8]&(1<<(i%8)) != 0 {
+			values[col.Name] = nil
+			continue
+		}
+		value, err := col.decode(r)
+		if r.err != nil {
+			return nil, r.err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode column %s of %s.%s: %w", col.Name, t.Schema, t.Table, err)
+		}
+		values[col.Name] = value
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return values, nil
+}
+
+// decode reads a value in its row event encoding. Values come out the way
+// Column.Value decodes them from the text protocol, so the two can be
+// compared.
+func (col *ColumnInfo) decode(r *reader) (interface{}, error) {
+	switch col.Type {
+	case TypeTiny:
+		return col.integer(r.uint(1), 8), nil
+	case TypeShort:
+		return col.integer(r.uint(2), 16), nil
+	case TypeInt24:
+		return col.integer(r.uint(3), 24), nil
+	case TypeLong:
+		return col.integer(r.uint(4), 32), nil
+	case TypeLongLong:
+		return col.integer(r.uint(8), 64), nil
+	case TypeFloat:
+		return math.Float32frombits(r.uint32()), nil
+	case TypeDouble:
+		return math.Float64frombits(r.uint64()), nil
+	case TypeNewDecimal:
+		precision, scale := int(col.Meta>>8), int(col.Meta&0xff)
+		return decodeDecimal(r, precision, scale)
+	case TypeYear:
+		if year := r.uint8(); year != 0 {
+			return fmt.Sprintf("%04d", 1900+int(year)), nil
+		}
+		return "0000", nil
+	case TypeDate:
+		v := r.uint(3)
+		return fmt.Sprintf("%04d-%02d-%02d", v>>9, (v>>5)&15, v&31), nil
+	case TypeTime:
+		v := int64(r.uint(3))
+		sign := ""
+		if v&0x800000 != 0 {
+			v, sign = (1<<24)-v, "-"
+		}
+		return fmt.Sprintf("%s%02d:%02d:%02d", sign, v/10000, v/100%100, v%100), nil
+	case TypeDateTime:
+		v := r.uint64()
+		d, t := v/1000000, v%1000000
+		return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", d/10000, d/100%100, d%100, t/10000, t/100%100, t%100), nil
+	case TypeTimestamp:
+		return formatTimestamp(int64(r.uint32()), 0, 0), nil
+	case TypeTimestamp2:
+		seconds := int64(r.uintBE(4))
+		return formatTimestamp(seconds, readFraction(r, int(col.Meta)), int(col.Meta)), nil
+	case TypeDateTime2:
+		return decodeDateTime2(r, int(col.Meta)), nil
+	case TypeTime2:
+		return decodeTime2(r, int(col.Meta)), nil
+	case TypeBit:
+		n := int(col.Meta>>8)*8 + int(col.Meta&0xff)
+		return bytes.Clone(r.bytes((n + 7) / 8)), nil
+	case TypeEnum:
+		index := int(r.uint(int(col.Meta)))
+		if index == 0 || index > len(col.Values) {
+			return "", nil
+		}
+		return col.Values[index-1], nil
+	case TypeSet:
+		mask := r.uint(int(col.Meta))
+		var members []string
+		for i, value := range col.Values {
+			if mask&(1<<i) != 0 {
+				members = append(members, value)
+			}
+		}
+		return strings.Join(members, ","), nil
+	case TypeVarchar, TypeVarString:
+		size := 1
+		if col.Meta > 255 {
+			size = 2
+		}
+		return col.text(r.bytes(int(r.uint(size)))), nil
+	case TypeString:
+		size := 1
+		if col.Meta > 255 {
+			size = 2
+		}
+		value := r.bytes(int(r.uint(size)))
+		if col.Collation == binaryCollation && len(value) < int(col.Meta) {
+			// BINARY columns are logged without their zero padding
+			value = append(bytes.Clone(value), make([]byte, int(col.Meta)-len(value))...)
+		}
+		return col.text(value), nil
+	case TypeBlob:
+		return col.text(r.bytes(int(r.uint(int(col.Meta))))), nil
+	case TypeGeometry:
+		return bytes.Clone(r.bytes(int(r.uint(int(col.Meta))))), nil
+	case TypeJSON:
+		data := r.bytes(int(r.uint(int(col.Meta))))
+		if r.err != nil {
+			return nil, nil
+		}
+		return decodeJSON(data)
+	default:
+		return nil, fmt.Errorf("unsupported column type %d", col.Type)
+	}
+}
+
+// integer returns v, an integer of the given width, as an int64 or for
+// unsigned columns as a uint64
+func (col *ColumnInfo) integer(v uint64, width int) interface{} {
+	if col.Unsigned {
+		return v
+	}
+	shift := 64 - width
+	return int64(v<<shift) >> shift
+}
+
+// text returns binary strings as bytes and the others as strings
+func (col *ColumnInfo) text(value []byte) interface{} {
+	if col.Collation == binaryCollation {
+		return bytes.Clone(value)
+	}
+	return string(value)
+}
+
+// readFraction reads the fractional seconds of a temporal2 value and
+// returns them in microseconds
+func readFraction(r *reader, fsp int) int64 {
+	switch fsp {
+	case 1, 2:
+		return int64(r.uintBE(1)) * 10000
+	case 3, 4:
+		return int64(r.uintBE(2)) * 100
+	case 5, 6:
+		return int64(r.uintBE(3))
+	}
+	return 0
+}
+
+// formatFraction prints microseconds with fsp digits, as MySQL does for a
+// column of that precision
+func formatFraction(micros int64, fsp int) string {
+	if fsp <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(".%0*d", fsp, micros/int64(math.Pow10(6-fsp)))
+}
+
+func formatTimestamp(seconds, micros int64, fsp int) string {
+	if seconds == 0 && micros == 0 {
+		return "0000-00-00 00:00:00" + formatFraction(0, fsp)
+	}
+	return time.Unix(seconds, 0).UTC().Format("2006-01-02 15:04:05") + formatFraction(micros, fsp)
+}
+
+func decodeDateTime2(r *reader, fsp int) string {
+	v := int64(r.uintBE(5)) - 0x8000000000
+	micros := readFraction(r, fsp)
+	ymd, hms := v>>17, v%(1<<17)
+	ym := ymd >> 5
+	return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d%s",
+		ym/13, ym%13, ymd%(1<<5), hms>>12, (hms>>6)%(1<<6), hms%(1<<6), formatFraction(micros, fsp))
+}
+
+func decodeTime2(r *reader, fsp int) string {
+	var packed int64
+	switch fsp {
+	case 1, 2, 3, 4:
+		hms := int64(r.uintBE(3)) - 0x800000
+		size := 1
+		scale := int64(10000)
+		if fsp > 2 {
+			size, scale = 2, 100
+		}
+		frac := int64(r.uintBE(size))
+		if hms < 0 && frac != 0 {
+			// The fraction of a negative time counts down from the next
+			// second
+			hms++
+			frac -= 1 << (8 * size)
+		}
+		packed = hms<<24 + frac*scale
+	case 5, 6:
+		packed = int64(r.uintBE(6)) - 0x800000000000
+	default:
+		packed = (int64(r.uintBE(3)) - 0x800000) << 24
+	}
+	return formatPackedTime(packed, fsp)
+}
+
+// formatPackedTime prints a TIME packed as hours, minutes and seconds above
+// 24 bits of microseconds
+func formatPackedTime(packed int64, fsp int) string {
+	sign := ""
+	if packed < 0 {
+		packed, sign = -packed, "-"
+	}
+	hms, micros := packed>>24, packed%(1<<24)
+	return fmt.Sprintf("%s%02d:%02d:%02d%s", sign, (hms>>12)%(1<<10), (hms>>6)%(1<<6), hms%(1<<6), formatFraction(micros, fsp))
+}
+
+// digitsBytes is the size of a decimal group of fewer than nine digits
+var digitsBytes = [10]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// decodeDecimal reads a DECIMAL in MySQL's binary format and prints it as
+// the text protocol does
+func decodeDecimal(r *reader, precision, scale int) (string, error) {
+	intDigits := precision - scale
+	size := intDigits/9*4 + digitsBytes[intDigits%9] + scale/9*4 + digitsBytes[scale%9]
+	data := bytes.Clone(r.bytes(size))
+	if r.err != nil {
+		return "", r.err
+	}
+	if size == 0 {
+		return "", errors.New("empty decimal")
+	}
+
+	negative := data[0]&0x80 == 0
+	data[0] ^= 0x80
+	if negative {
+		for i := range data {
+			data[i] ^= 0xff
+		}
+	}
+
+	d := newReader(data)
+	var b strings.Builder
+	if negative {
+		b.WriteByte('-')
+	}
+	var integer strings.Builder
+	if lead := intDigits % 9; lead > 0 {
+		fmt.Fprintf(&integer, "%d", d.uintBE(digitsBytes[lead]))
+	}
+	for i := 0; i < intDigits/9; i++ {
+		fmt.Fprintf(&integer, "%09d", d.uintBE(4))
+	}
+	digits := strings.TrimLeft(integer.String(), "0")
+	if digits == "" {
+		digits = "0"
+	}
+	b.WriteString(digits)
+
+	if scale > 0 {
+		b.WriteByte('.')
+		for i := 0; i < scale/9; i++ {
+			fmt.Fprintf(&b, "%09d", d.uintBE(4))
+		}
+		if rest := scale % 9; rest > 0 {
+			fmt.Fprintf(&b, "%0*d", rest, d.uintBE(digitsBytes[rest]))
+		}
+	}
+	return b.String(), nil
+}
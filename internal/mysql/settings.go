@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Setting is a server variable the binary log must be configured with for
+// witnz to read full row images
+type Setting struct {
+	Name  string
+	Value string
+	// Optional settings are only checked on servers that have the variable
+	Optional bool
+}
+
+// BinlogSettings are the settings a protected MySQL server needs
+var BinlogSettings = []Setting{
+	{Name: "log_bin", Value: "ON"},
+	{Name: "binlog_format", Value: "ROW"},
+	{Name: "binlog_row_image", Value: "FULL"},
+	{Name: "binlog_row_metadata", Value: "FULL"},
+	{Name: "gtid_mode", Value: "ON"},
+	{Name: "binlog_transaction_compression", Value: "OFF", Optional: true},
+	{Name: "binlog_row_value_options", Value: "", Optional: true},
+}
+
+// SettingNames returns the names of BinlogSettings, to read them with
+// Variables
+func SettingNames() []string {
+	names := make([]string, len(BinlogSettings))
+	for i, setting := range BinlogSettings {
+		names[i] = setting.Name
+	}
+	return names
+}
+
+// Check returns an error if the setting has another value in variables
+func (s Setting) Check(variables map[string]string) error {
+	value, ok := variables[s.Name]
+	if !ok && s.Optional {
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("%s is not set, must be %q", s.Name, s.Value)
+	}
+	if !strings.EqualFold(value, s.Value) {
+		return fmt.Errorf("%s is %q, must be %q", s.Name, value, s.Value)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// Column definition flags
+const (
+	unsignedFlag = 0x0020
+	enumFlag     = 0x0100
+	setFlag      = 0x0800
+)
+
+// Value converts a value of the column from the text protocol to the Go
+// value the binary log decodes it to. The session must run with
+// character_set_results NULL and time_zone '+00:00' for strings and
+// TIMESTAMPs to match.
+func (col *Column) Value(raw []byte) (interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	text := string(raw)
+	switch col.Type {
+	case TypeTiny, TypeShort, TypeInt24, TypeLong, TypeLongLong:
+		if col.Flags&unsignedFlag != 0 {
+			return strconv.ParseUint(text, 10, 64)
+		}
+		return strconv.ParseInt(text, 10, 64)
+	case TypeFloat:
+		v, err := strconv.ParseFloat(text, 32)
+		return float32(v), err
+	case TypeDouble:
+		return strconv.ParseFloat(text, 64)
+	case TypeJSON:
+		return CanonicalJSON(raw)
+	case TypeBit, TypeGeometry:
+		return bytes.Clone(raw), nil
+	case TypeVarchar, TypeVarString, TypeString, TypeTinyBlob, TypeMediumBlob, TypeLongBlob, TypeBlob:
+		if col.Charset == binaryCollation && col.Flags&(enumFlag|setFlag) == 0 {
+			return bytes.Clone(raw), nil
+		}
+		return text, nil
+	default:
+		// DECIMAL, temporal types and YEAR print the same in both
+		return text, nil
+	}
+}
+
+// QuoteIdentifier quotes a table name, schema.table names part by part
+func QuoteIdentifier(name string) string {
+	parts := strings.SplitN(name, ".", 2)
+	for i, part := range parts {
+		parts[i] = "`" + strings.ReplaceAll(part, "`", "``") + "`"
+	}
+	return strings.Join(parts, ".")
+}
+
+// Literal returns s as a hexadecimal literal, which needs no escaping
+func Literal(s string) string {
+	return "X'" + hex.EncodeToString([]byte(s)) + "'"
+}
@@ -0,0 +1,201 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/witnz/witnz/internal/cdc"
+	"github.com/witnz/witnz/internal/mysql"
+)
+
+// mysqlTableInfo is what information_schema reports about a protected table
+type mysqlTableInfo struct {
+	Name          string
+	Exists        bool
+	HasPrimaryKey bool
+}
+
+// binlogRemediation is how to fix each of mysql.BinlogSettings
+var binlogRemediation = map[string]string{
+	"log_bin":                        "Start mysqld without skip-log-bin / disable-log-bin; the binary log is on by default since MySQL 8.0",
+	"binlog_format":                  "SET PERSIST binlog_format = 'ROW';",
+	"binlog_row_image":               "SET PERSIST binlog_row_image = 'FULL';",
+	"binlog_row_metadata":            "SET PERSIST binlog_row_metadata = 'FULL';",
+	"gtid_mode":                      "Set enforce_gtid_consistency = ON and gtid_mode = ON in my.cnf, then restart MySQL",
+	"binlog_transaction_compression": "SET PERSIST binlog_transaction_compression = OFF;",
+	"binlog_row_value_options":       "SET PERSIST binlog_row_value_options = '';",
+}
+
+// minBinlogRetention is the binary log retention below which a stopped
+// node is likely to find its resume position purged
+const minBinlogRetention = 7 * 24 * time.Hour
+
+// CheckMySQL checks the binary log settings, the grants and every protected
+// table against what binlog streaming and verification need
+func CheckMySQL(ctx context.Context, config *cdc.ReplicationConfig, tables []string) []Check {
+	conn, err := mysql.Connect(ctx, &mysql.Config{
+		Host:     config.Host,
+		Port:     config.Port,
+		Database: config.Database,
+		User:     config.User,
+		Password: config.Password,
+	})
+	if err != nil {
+		return []Check{fail("connection",
+			"Check database.host, database.port and the credentials, and that the account may connect from this node",
+			"cannot connect to %s:%d/%s: %v", config.Host, config.Port, config.Database, err)}
+	}
+	defer conn.Close()
+
+	var checks []Check
+	checks = append(checks, pass("connection", "connected to %s:%d/%s as %s", config.Host, config.Port, config.Database, config.User))
+
+	variables, err := conn.Variables(ctx, append(mysql.SettingNames(), "binlog_expire_logs_seconds")...)
+	if err != nil {
+		checks = append(checks, fail("binary log", "", "failed to read server variables: %v", err))
+	} else {
+		checks = append(checks, binlogSettingChecks(variables)...)
+		checks = append(checks, binlogRetentionCheck(variables))
+	}
+
+	res, err := conn.Query(ctx, "SHOW GRANTS FOR CURRENT_USER()")
+	if err != nil {
+		checks = append(checks, fail("replication grants", "", "failed to read grants: %v", err))
+	} else {
+		grants := make([]string, len(res.Rows))
+		for i := range res.Rows {
+			grants[i] = string(res.Rows[i][0])
+		}
+		checks = append(checks, replicationGrantsCheck(config.User, grants))
+	}
+
+	for _, table := range tables {
+		info, err := loadMySQLTableInfo(ctx, conn, config.Database, table)
+		if err != nil {
+			checks = append(checks, fail(table, "", "failed to inspect table: %v", err))
+			continue
+		}
+		checks = append(checks, mysqlTableChecks(config.User, info)...)
+	}
+
+	return checks
+}
+
+// loadMySQLTableInfo reads a table from information_schema, which only
+// lists the columns the user has privileges on. Tables outside the
+// configured database are named database.table.
+func loadMySQLTableInfo(ctx context.Context, conn *mysql.Conn, database, table string) (mysqlTableInfo, error) {
+	info := mysqlTableInfo{Name: table}
+
+	schema, name := database, table
+	if before, after, ok := strings.Cut(table, "."); ok {
+		schema, name = before, after
+	}
+
+	res, err := conn.Query(ctx, fmt.Sprintf(
+		"SELECT COLUMN_KEY FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = %s AND TABLE_NAME = %s",
+		mysql.Literal(schema), mysql.Literal(name)))
+	if err != nil {
+		return info, err
+	}
+
+	info.Exists = len(res.Rows) > 0
+	for i := range res.Rows {
+		if key, _ := res.Value(i, "COLUMN_KEY"); key == "PRI" {
+			info.HasPrimaryKey = true
+		}
+	}
+	return info, nil
+}
+
+func binlogSettingChecks(variables map[string]string) []Check {
+	checks := make([]Check, 0, len(mysql.BinlogSettings))
+	for _, setting := range mysql.BinlogSettings {
+		if err := setting.Check(variables); err != nil {
+			checks = append(checks, fail(setting.Name, binlogRemediation[setting.Name], "%v", err))
+			continue
+		}
+		if _, ok := variables[setting.Name]; !ok {
+			checks = append(checks, pass(setting.Name, "not supported by this server, nothing to check"))
+			continue
+		}
+		checks = append(checks, pass(setting.Name, "%s is %q", setting.Name, variables[setting.Name]))
+	}
+	return checks
+}
+
+// binlogRetentionCheck warns when binary logs are purged soon enough that a
+// node stopped for a while cannot resume and must be initialized again
+func binlogRetentionCheck(variables map[string]string) Check {
+	value, ok := variables["binlog_expire_logs_seconds"]
+	if !ok {
+		return warn("binlog retention", "", "binlog_expire_logs_seconds is not available, check expire_logs_days")
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fail("binlog retention", "", "invalid binlog_expire_logs_seconds: %s", value)
+	}
+	if seconds == 0 {
+		return pass("binlog retention", "binary logs are not purged automatically")
+	}
+
+	retention := time.Duration(seconds) * time.Second
+	if retention < minBinlogRetention {
+		return warn("binlog retention",
+			fmt.Sprintf("SET PERSIST binlog_expire_logs_seconds = %d;", int64(minBinlogRetention/time.Second)),
+			"binary logs are purged after %s; a node stopped for longer must be initialized again", retention)
+	}
+	return pass("binlog retention", "binary logs are kept for %s", retention)
+}
+
+// replicationGrantsCheck looks for the global privileges a binlog dump
+// needs in the output of SHOW GRANTS
+func replicationGrantsCheck(user string, grants []string) Check {
+	var slave, client bool
+	for _, grant := range grants {
+		grant = strings.ToUpper(grant)
+		if !strings.Contains(grant, " ON *.* ") {
+			continue
+		}
+		if strings.Contains(grant, "ALL PRIVILEGES") {
+			slave, client = true, true
+		}
+		slave = slave || strings.Contains(grant, "REPLICATION SLAVE")
+		client = client || strings.Contains(grant, "REPLICATION CLIENT")
+	}
+
+	if !slave || !client {
+		return fail("replication grants",
+			fmt.Sprintf("GRANT REPLICATION SLAVE, REPLICATION CLIENT ON *.* TO %s;", account(user)),
+			"user %s lacks REPLICATION SLAVE or REPLICATION CLIENT", user)
+	}
+	return pass("replication grants", "user %s may read the binary log", user)
+}
+
+// account names a user in GRANT statements; the host part is left to fill in
+func account(user string) string {
+	return fmt.Sprintf("'%s'@'<host>'", strings.ReplaceAll(user, "'", "''"))
+}
+
+func mysqlTableChecks(user string, info mysqlTableInfo) []Check {
+	table := mysql.QuoteIdentifier(info.Name)
+
+	if !info.Exists {
+		return []Check{fail(info.Name,
+			fmt.Sprintf("Create the table or remove it from protected_tables; otherwise GRANT SELECT ON %s TO %s;", table, account(user)),
+			"table does not exist or user %s cannot read it", user)}
+	}
+
+	checks := []Check{pass(info.Name+": SELECT", "user %s can read the table", user)}
+	if info.HasPrimaryKey {
+		checks = append(checks, pass(info.Name+": primary key", "table has a primary key"))
+	} else {
+		checks = append(checks, fail(info.Name+": primary key",
+			fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (<columns>);", table),
+			"table has no primary key, so changes cannot be matched to records"))
+	}
+	return checks
+}
@@ -0,0 +1,338 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/witnz/witnz/internal/cdc"
+	"github.com/witnz/witnz/internal/discovery"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+// tableInfo is what the catalog reports about a protected table
+type tableInfo struct {
+	Name            string
+	Exists          bool
+	CanSelect       bool
+	HasPrimaryKey   bool
+	ReplicaIdentity string
+	// Partitioned tables log changes through their leaf partitions, whose
+	// replica identity is what counts
+	Partitioned bool
+	Partitions  []partitionInfo
+}
+
+// partitionInfo is a leaf partition, named as PostgreSQL prints it
+type partitionInfo struct {
+	Name            string
+	ReplicaIdentity string
+}
+
+// publicationInfo is what the catalog reports about the publication
+type publicationInfo struct {
+	Exists    bool
+	AllTables bool
+	ViaRoot   bool
+	Tables    map[string]bool
+}
+
+// CheckPostgres checks the server settings, the role and every protected
+// table against what logical replication and verification need
+func CheckPostgres(ctx context.Context, config *cdc.ReplicationConfig, tables []string) []Check {
+	connString := fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s",
+		config.Host, config.Port, config.Database, config.User, config.Password)
+
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		return []Check{fail("connection",
+			"Check database.host, database.port and the credentials, and that pg_hba.conf admits this node",
+			"cannot connect to %s:%d/%s: %v", config.Host, config.Port, config.Database, err)}
+	}
+	defer conn.Close(ctx)
+
+	var checks []Check
+	checks = append(checks, pass("connection", "connected to %s:%d/%s as %s", config.Host, config.Port, config.Database, config.User))
+
+	var walLevel string
+	if err := conn.QueryRow(ctx, "SHOW wal_level").Scan(&walLevel); err != nil {
+		checks = append(checks, fail("wal_level", "", "failed to read wal_level: %v", err))
+	} else {
+		checks = append(checks, walLevelCheck(walLevel))
+	}
+
+	var maxSlots, usedSlots int
+	var slotExists bool
+	err = conn.QueryRow(ctx, `SELECT current_setting('max_replication_slots')::int,
+		(SELECT count(*) FROM pg_replication_slots),
+		EXISTS (SELECT 1 FROM pg_replication_slots WHERE slot_name = $1)`, config.SlotName).
+		Scan(&maxSlots, &usedSlots, &slotExists)
+	if err != nil {
+		checks = append(checks, fail("replication slots", "", "failed to read replication slots: %v", err))
+	} else {
+		checks = append(checks, replicationSlotsCheck(config.SlotName, maxSlots, usedSlots, slotExists))
+	}
+
+	var canReplicate bool
+	err = conn.QueryRow(ctx, "SELECT rolreplication OR rolsuper FROM pg_roles WHERE rolname = current_user").Scan(&canReplicate)
+	if err != nil {
+		checks = append(checks, fail("replication role", "", "failed to read role attributes: %v", err))
+	} else {
+		checks = append(checks, replicationRoleCheck(config.User, canReplicate))
+	}
+
+	var partitioned []string
+	for _, table := range tables {
+		info, err := loadTableInfo(ctx, conn, table)
+		if err != nil {
+			checks = append(checks, fail(table, "", "failed to inspect table: %v", err))
+			continue
+		}
+		if info.Partitioned {
+			partitioned = append(partitioned, table)
+		}
+		checks = append(checks, tableChecks(config.User, info)...)
+	}
+
+	pub, err := loadPublicationInfo(ctx, conn, config.PublicationName)
+	if err != nil {
+		checks = append(checks, fail("publication", "", "failed to inspect publication: %v", err))
+	} else {
+		checks = append(checks, publicationChecks(config.PublicationName, pub, tables, partitioned)...)
+	}
+
+	return checks
+}
+
+func loadTableInfo(ctx context.Context, conn *pgx.Conn, table string) (tableInfo, error) {
+	info := tableInfo{Name: table}
+
+	var oid *uint32
+	if err := conn.QueryRow(ctx, "SELECT to_regclass($1)::oid", discovery.Identifier(table).Sanitize()).Scan(&oid); err != nil {
+		return info, err
+	}
+	if oid == nil {
+		return info, nil
+	}
+	info.Exists = true
+
+	err := conn.QueryRow(ctx, `SELECT has_table_privilege(c.oid, 'SELECT'),
+		EXISTS (SELECT 1 FROM pg_index i WHERE i.indrelid = c.oid AND i.indisprimary),
+		c.relreplident::text, c.relkind = 'p'
+		FROM pg_class c WHERE c.oid = $1`, *oid).
+		Scan(&info.CanSelect, &info.HasPrimaryKey, &info.ReplicaIdentity, &info.Partitioned)
+	if err != nil || !info.Partitioned {
+		return info, err
+	}
+
+	rows, err := conn.Query(ctx, `SELECT t.relid::regclass::text, c.relreplident::text
+		FROM pg_partition_tree($1::oid::regclass) t JOIN pg_class c ON c.oid = t.relid
+		WHERE t.isleaf ORDER BY 1`, *oid)
+	if err != nil {
+		return info, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var partition partitionInfo
+		if err := rows.Scan(&partition.Name, &partition.ReplicaIdentity); err != nil {
+			return info, err
+		}
+		info.Partitions = append(info.Partitions, partition)
+	}
+	return info, rows.Err()
+}
+
+func loadPublicationInfo(ctx context.Context, conn *pgx.Conn, name string) (publicationInfo, error) {
+	info := publicationInfo{Tables: make(map[string]bool)}
+
+	err := conn.QueryRow(ctx, "SELECT puballtables, pubviaroot FROM pg_publication WHERE pubname = $1", name).
+		Scan(&info.AllTables, &info.ViaRoot)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return info, nil
+	}
+	if err != nil {
+		return info, err
+	}
+	info.Exists = true
+
+	// Tables are listed as schema.table, and by their plain name when it
+	// resolves to them on the search path
+	rows, err := conn.Query(ctx, `SELECT schemaname, tablename,
+		pg_table_is_visible(format('%I.%I', schemaname, tablename)::regclass)
+		FROM pg_publication_tables WHERE pubname = $1`, name)
+	if err != nil {
+		return info, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schema, table string
+		var visible bool
+		if err := rows.Scan(&schema, &table, &visible); err != nil {
+			return info, err
+		}
+		info.Tables[schema+storage.SchemaSeparator+table] = true
+		if visible {
+			info.Tables[table] = true
+		}
+	}
+	return info, rows.Err()
+}
+
+func walLevelCheck(level string) Check {
+	if level != "logical" {
+		return fail("wal_level",
+			"ALTER SYSTEM SET wal_level = logical; -- then restart PostgreSQL",
+			"wal_level is %s, logical replication needs logical", level)
+	}
+	return pass("wal_level", "wal_level is logical")
+}
+
+func replicationSlotsCheck(slot string, max, used int, exists bool) Check {
+	if exists {
+		return pass("replication slots", "slot %s exists (%d of %d slots in use)", slot, used, max)
+	}
+
+	remediation := fmt.Sprintf("ALTER SYSTEM SET max_replication_slots = %d; -- then restart PostgreSQL", used+2)
+	switch free := max - used; {
+	case free <= 0:
+		return fail("replication slots", remediation,
+			"all %d replication slots are in use, none left for %s", max, slot)
+	case free == 1:
+		return warn("replication slots", remediation,
+			"only one free replication slot, which %s will take", slot)
+	default:
+		return pass("replication slots", "%d of %d replication slots free", free, max)
+	}
+}
+
+func replicationRoleCheck(user string, canReplicate bool) Check {
+	if !canReplicate {
+		return fail("replication role",
+			fmt.Sprintf("ALTER ROLE %s WITH REPLICATION;", pgx.Identifier{user}.Sanitize()),
+			"role %s lacks the REPLICATION attribute", user)
+	}
+	return pass("replication role", "role %s may start logical replication", user)
+}
+
+func tableChecks(user string, info tableInfo) []Check {
+	table := discovery.Identifier(info.Name).Sanitize()
+
+	if !info.Exists {
+		return []Check{fail(info.Name, "Create the table or remove it from protected_tables", "table does not exist")}
+	}
+
+	var checks []Check
+
+	if info.CanSelect {
+		checks = append(checks, pass(info.Name+": SELECT", "role %s can read the table", user))
+	} else {
+		checks = append(checks, fail(info.Name+": SELECT",
+			fmt.Sprintf("GRANT SELECT ON %s TO %s;", table, pgx.Identifier{user}.Sanitize()),
+			"role %s cannot read the table, so Merkle verification fails", user))
+	}
+
+	if info.HasPrimaryKey {
+		checks = append(checks, pass(info.Name+": primary key", "table has a primary key"))
+	} else {
+		checks = append(checks, fail(info.Name+": primary key",
+			fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (<columns>);", table),
+			"table has no primary key, so changes cannot be matched to records"))
+	}
+
+	if info.Partitioned {
+		checks = append(checks, partitionIdentityCheck(info))
+	} else {
+		checks = append(checks, replicaIdentityCheck(info.Name+": replica identity", table, info.ReplicaIdentity, info.HasPrimaryKey))
+	}
+	return checks
+}
+
+// replicaIdentityCheck reports how much of the old row PostgreSQL logs for
+// UPDATE and DELETE. Findings record the hash of the old row, which needs
+// every column. relation is the quoted name used in the remediation.
+func replicaIdentityCheck(name, relation, identity string, hasPrimaryKey bool) Check {
+	remediation := fmt.Sprintf("ALTER TABLE %s REPLICA IDENTITY FULL;", relation)
+
+	switch identity {
+	case "f":
+		return pass(name, "replica identity is FULL")
+	case "n":
+		return fail(name, remediation, "replica identity is NOTHING, so UPDATE and DELETE are not identifiable")
+	case "d":
+		if !hasPrimaryKey {
+			return fail(name, remediation, "replica identity is DEFAULT without a primary key, so UPDATE and DELETE are not identifiable")
+		}
+		return warn(name, remediation, "replica identity is DEFAULT, so findings lack the original row's hash")
+	default:
+		return warn(name, remediation, "replica identity uses an index, so findings lack the original row's hash")
+	}
+}
+
+// partitionIdentityCheck reports the weakest replica identity among the
+// leaf partitions of a partitioned table. Setting it on the parent does not
+// change existing partitions.
+func partitionIdentityCheck(info tableInfo) Check {
+	name := info.Name + ": replica identity"
+	if len(info.Partitions) == 0 {
+		return pass(name, "partitioned table has no partitions yet")
+	}
+
+	worst := pass(name, "replica identity is FULL on all %d partitions", len(info.Partitions))
+	var weak, remediations []string
+	for _, partition := range info.Partitions {
+		check := replicaIdentityCheck(name, partition.Name, partition.ReplicaIdentity, info.HasPrimaryKey)
+		if check.Status == StatusPass {
+			continue
+		}
+		weak = append(weak, partition.Name)
+		remediations = append(remediations, check.Remediation)
+		if worst.Status != StatusFail {
+			worst = check
+		}
+	}
+
+	if len(weak) > 0 {
+		worst.Message = fmt.Sprintf("partitions %s: %s", strings.Join(weak, ", "), worst.Message)
+		worst.Remediation = strings.Join(remediations, " ")
+	}
+	return worst
+}
+
+// publicationChecks checks that the publication sends the changes of every
+// protected table. Partitioned tables need publish_via_partition_root so
+// their changes arrive under the parent's name.
+func publicationChecks(name string, pub publicationInfo, tables, partitioned []string) []Check {
+	if !pub.Exists {
+		return []Check{warn("publication",
+			fmt.Sprintf("CREATE PUBLICATION %s FOR ALL TABLES WITH (publish_via_partition_root = true); -- or run 'witnz init'", pgx.Identifier{name}.Sanitize()),
+			"publication %s does not exist yet", name)}
+	}
+
+	var checks []Check
+	if len(partitioned) > 0 && !pub.ViaRoot {
+		checks = append(checks, fail("publication: partition root",
+			fmt.Sprintf("ALTER PUBLICATION %s SET (publish_via_partition_root = true);", pgx.Identifier{name}.Sanitize()),
+			"%s publishes partition changes under partition names, so %s receive none",
+			name, strings.Join(partitioned, ", ")))
+	}
+
+	if pub.AllTables {
+		return append(checks, pass("publication", "publication %s covers all tables", name))
+	}
+
+	for _, table := range tables {
+		check := table + ": publication"
+		if pub.Tables[table] {
+			checks = append(checks, pass(check, "published by %s", name))
+			continue
+		}
+		checks = append(checks, fail(check,
+			fmt.Sprintf("ALTER PUBLICATION %s ADD TABLE %s;", pgx.Identifier{name}.Sanitize(), discovery.Identifier(table).Sanitize()),
+			"not published by %s, so its changes are never received", name))
+	}
+	return checks
+}
@@ -0,0 +1,77 @@
+// Package preflight checks that the source databases and the Raft peers are
+// ready for a witnz node before it starts.
+package preflight
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Check is the outcome of one readiness check. Remediation is set for
+// checks that did not pass and is usually SQL to run as a superuser.
+type Check struct {
+	Name        string `json:"name"`
+	Status      Status `json:"status"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+func pass(name, format string, args ...interface{}) Check {
+	return Check{Name: name, Status: StatusPass, Message: fmt.Sprintf(format, args...)}
+}
+
+func warn(name, remediation, format string, args ...interface{}) Check {
+	return Check{Name: name, Status: StatusWarn, Message: fmt.Sprintf(format, args...), Remediation: remediation}
+}
+
+func fail(name, remediation, format string, args ...interface{}) Check {
+	return Check{Name: name, Status: StatusFail, Message: fmt.Sprintf(format, args...), Remediation: remediation}
+}
+
+// Failures counts the checks that failed
+func Failures(checks []Check) int {
+	failed := 0
+	for _, check := range checks {
+		if check.Status == StatusFail {
+			failed++
+		}
+	}
+	return failed
+}
+
+// CheckPeers dials the Raft address of every peer
+func CheckPeers(peers map[string]string, timeout time.Duration) []Check {
+	ids := make([]string, 0, len(peers))
+	for id := range peers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	checks := make([]Check, 0, len(ids))
+	for _, id := range ids {
+		addr := peers[id]
+		name := "peer " + id
+
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			checks = append(checks, fail(name,
+				fmt.Sprintf("Start witnz on %s and allow TCP connections to %s from this node", id, addr),
+				"cannot reach %s: %v", addr, err))
+			continue
+		}
+		conn.Close()
+		checks = append(checks, pass(name, "reachable at %s", addr))
+	}
+
+	return checks
+}
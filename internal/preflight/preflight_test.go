@@ -0,0 +1,260 @@
+package preflight
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostgresChecks(t *testing.T) {
+	t.Run("WalLevel", func(t *testing.T) {
+		if check := walLevelCheck("logical"); check.Status != StatusPass {
+			t.Errorf("Expected pass for logical, got %+v", check)
+		}
+		check := walLevelCheck("replica")
+		if check.Status != StatusFail || !strings.Contains(check.Remediation, "wal_level = logical") {
+			t.Errorf("Expected fail with remediation for replica, got %+v", check)
+		}
+	})
+
+	t.Run("ReplicationSlots", func(t *testing.T) {
+		tests := []struct {
+			max, used int
+			exists    bool
+			want      Status
+		}{
+			{10, 10, true, StatusPass},
+			{10, 2, false, StatusPass},
+			{10, 9, false, StatusWarn},
+			{10, 10, false, StatusFail},
+		}
+		for _, tt := range tests {
+			if check := replicationSlotsCheck("witnz_node1", tt.max, tt.used, tt.exists); check.Status != tt.want {
+				t.Errorf("Expected %s for %d/%d slots (exists=%v), got %+v", tt.want, tt.used, tt.max, tt.exists, check)
+			}
+		}
+	})
+
+	t.Run("ReplicationRole", func(t *testing.T) {
+		check := replicationRoleCheck("witnz", false)
+		if check.Status != StatusFail || check.Remediation != `ALTER ROLE "witnz" WITH REPLICATION;` {
+			t.Errorf("Expected fail with ALTER ROLE remediation, got %+v", check)
+		}
+	})
+
+	t.Run("Tables", func(t *testing.T) {
+		missing := tableChecks("witnz", tableInfo{Name: "audit_log"})
+		if len(missing) != 1 || missing[0].Status != StatusFail {
+			t.Errorf("Expected a single failure for a missing table, got %+v", missing)
+		}
+
+		ready := tableChecks("witnz", tableInfo{Name: "audit_log", Exists: true, CanSelect: true, HasPrimaryKey: true, ReplicaIdentity: "f"})
+		if Failures(ready) != 0 || ready[2].Status != StatusPass {
+			t.Errorf("Expected a ready table to pass, got %+v", ready)
+		}
+
+		checks := tableChecks("witnz", tableInfo{Name: "audit_log", Exists: true, ReplicaIdentity: "d"})
+		if Failures(checks) != 3 {
+			t.Errorf("Expected 3 failures, got %+v", checks)
+		}
+		if checks[0].Remediation != `GRANT SELECT ON "audit_log" TO "witnz";` {
+			t.Errorf("Expected GRANT remediation, got %q", checks[0].Remediation)
+		}
+		if checks[2].Remediation != `ALTER TABLE "audit_log" REPLICA IDENTITY FULL;` {
+			t.Errorf("Expected REPLICA IDENTITY remediation, got %q", checks[2].Remediation)
+		}
+	})
+
+	t.Run("ReplicaIdentity", func(t *testing.T) {
+		tests := []struct {
+			identity string
+			want     Status
+		}{
+			{"f", StatusPass},
+			{"d", StatusWarn},
+			{"i", StatusWarn},
+			{"n", StatusFail},
+		}
+		for _, tt := range tests {
+			if check := replicaIdentityCheck("audit_log", `"audit_log"`, tt.identity, true); check.Status != tt.want {
+				t.Errorf("Expected %s for replica identity %s, got %+v", tt.want, tt.identity, check)
+			}
+		}
+	})
+
+	t.Run("PartitionedTable", func(t *testing.T) {
+		info := tableInfo{
+			Name:            "events",
+			Exists:          true,
+			CanSelect:       true,
+			HasPrimaryKey:   true,
+			ReplicaIdentity: "d",
+			Partitioned:     true,
+			Partitions: []partitionInfo{
+				{Name: "events_2025_01", ReplicaIdentity: "f"},
+				{Name: "events_2025_02", ReplicaIdentity: "n"},
+				{Name: "events_2025_03", ReplicaIdentity: "d"},
+			},
+		}
+
+		check := tableChecks("witnz", info)[2]
+		if check.Status != StatusFail || !strings.Contains(check.Message, "events_2025_02, events_2025_03") {
+			t.Errorf("Expected a failure naming both weak partitions, got %+v", check)
+		}
+		if check.Remediation != "ALTER TABLE events_2025_02 REPLICA IDENTITY FULL; ALTER TABLE events_2025_03 REPLICA IDENTITY FULL;" {
+			t.Errorf("Expected a remediation per partition, got %q", check.Remediation)
+		}
+
+		info.Partitions = info.Partitions[:1]
+		if check := tableChecks("witnz", info)[2]; check.Status != StatusPass {
+			t.Errorf("Expected FULL partitions to pass although the parent is DEFAULT, got %+v", check)
+		}
+	})
+
+	t.Run("Publication", func(t *testing.T) {
+		tables := []string{"audit_log", "payments"}
+
+		if checks := publicationChecks("witnz_publication", publicationInfo{}, tables, nil); len(checks) != 1 || checks[0].Status != StatusWarn {
+			t.Errorf("Expected a warning for a missing publication, got %+v", checks)
+		}
+		if checks := publicationChecks("witnz_publication", publicationInfo{Exists: true, AllTables: true}, tables, nil); Failures(checks) != 0 {
+			t.Errorf("Expected an all-tables publication to pass, got %+v", checks)
+		}
+
+		checks := publicationChecks("witnz_publication", publicationInfo{Exists: true, AllTables: true}, tables, []string{"audit_log"})
+		if Failures(checks) != 1 || checks[0].Remediation != `ALTER PUBLICATION "witnz_publication" SET (publish_via_partition_root = true);` {
+			t.Errorf("Expected partitioned tables to require publish_via_partition_root, got %+v", checks)
+		}
+
+		pub := publicationInfo{Exists: true, Tables: map[string]bool{"audit_log": true}}
+		checks = publicationChecks("witnz_publication", pub, tables, nil)
+		if Failures(checks) != 1 || checks[1].Remediation != `ALTER PUBLICATION "witnz_publication" ADD TABLE "payments";` {
+			t.Errorf("Expected payments to fail with ADD TABLE remediation, got %+v", checks)
+		}
+
+		checks = publicationChecks("witnz_publication", pub, []string{"audit.events"}, nil)
+		if Failures(checks) != 1 || checks[0].Remediation != `ALTER PUBLICATION "witnz_publication" ADD TABLE "audit"."events";` {
+			t.Errorf("Expected audit.events to be quoted by schema and table, got %+v", checks)
+		}
+	})
+}
+
+func TestMySQLChecks(t *testing.T) {
+	ready := map[string]string{
+		"log_bin":                        "ON",
+		"binlog_format":                  "ROW",
+		"binlog_row_image":               "FULL",
+		"binlog_row_metadata":            "FULL",
+		"gtid_mode":                      "ON",
+		"binlog_transaction_compression": "OFF",
+		"binlog_row_value_options":       "",
+	}
+
+	t.Run("BinlogSettings", func(t *testing.T) {
+		if checks := binlogSettingChecks(ready); Failures(checks) != 0 {
+			t.Errorf("Expected a ready server to pass, got %+v", checks)
+		}
+
+		// MySQL 5.7 has neither binlog_transaction_compression nor
+		// binlog_row_value_options before 8.0
+		old := map[string]string{"log_bin": "ON", "binlog_format": "row", "binlog_row_image": "full", "binlog_row_metadata": "FULL", "gtid_mode": "ON"}
+		if checks := binlogSettingChecks(old); Failures(checks) != 0 {
+			t.Errorf("Expected missing optional settings to pass, got %+v", checks)
+		}
+
+		statement := map[string]string{"log_bin": "ON", "binlog_format": "MIXED", "binlog_row_image": "MINIMAL", "gtid_mode": "OFF"}
+		checks := binlogSettingChecks(statement)
+		if Failures(checks) != 4 {
+			t.Fatalf("Expected 4 failures, got %+v", checks)
+		}
+		if checks[1].Remediation != "SET PERSIST binlog_format = 'ROW';" {
+			t.Errorf("Expected SET PERSIST remediation, got %q", checks[1].Remediation)
+		}
+		if !strings.Contains(checks[3].Message, "binlog_row_metadata is not set") {
+			t.Errorf("Expected the missing binlog_row_metadata to be reported, got %+v", checks[3])
+		}
+	})
+
+	t.Run("BinlogRetention", func(t *testing.T) {
+		tests := []struct {
+			seconds string
+			want    Status
+		}{
+			{"0", StatusPass},
+			{"2592000", StatusPass},
+			{"3600", StatusWarn},
+			{"soon", StatusFail},
+		}
+		for _, tt := range tests {
+			if check := binlogRetentionCheck(map[string]string{"binlog_expire_logs_seconds": tt.seconds}); check.Status != tt.want {
+				t.Errorf("Expected %s for %s seconds, got %+v", tt.want, tt.seconds, check)
+			}
+		}
+	})
+
+	t.Run("ReplicationGrants", func(t *testing.T) {
+		granted := []string{
+			"GRANT USAGE ON *.* TO `witnz`@`%`",
+			"GRANT REPLICATION SLAVE, REPLICATION CLIENT ON *.* TO `witnz`@`%`",
+			"GRANT SELECT ON `shop`.* TO `witnz`@`%`",
+		}
+		if check := replicationGrantsCheck("witnz", granted); check.Status != StatusPass {
+			t.Errorf("Expected the replication grants to pass, got %+v", check)
+		}
+		if check := replicationGrantsCheck("root", []string{"GRANT ALL PRIVILEGES ON *.* TO `root`@`localhost` WITH GRANT OPTION"}); check.Status != StatusPass {
+			t.Errorf("Expected ALL PRIVILEGES to pass, got %+v", check)
+		}
+
+		check := replicationGrantsCheck("witnz", []string{"GRANT ALL PRIVILEGES ON `shop`.* TO `witnz`@`%`"})
+		if check.Status != StatusFail || check.Remediation != "GRANT REPLICATION SLAVE, REPLICATION CLIENT ON *.* TO 'witnz'@'<host>';" {
+			t.Errorf("Expected fail with GRANT remediation, got %+v", check)
+		}
+	})
+
+	t.Run("Tables", func(t *testing.T) {
+		missing := mysqlTableChecks("witnz", mysqlTableInfo{Name: "audit.events"})
+		if len(missing) != 1 || missing[0].Status != StatusFail || !strings.Contains(missing[0].Remediation, "GRANT SELECT ON `audit`.`events`") {
+			t.Errorf("Expected a single failure for a missing table, got %+v", missing)
+		}
+
+		if checks := mysqlTableChecks("witnz", mysqlTableInfo{Name: "orders", Exists: true, HasPrimaryKey: true}); Failures(checks) != 0 {
+			t.Errorf("Expected a ready table to pass, got %+v", checks)
+		}
+
+		checks := mysqlTableChecks("witnz", mysqlTableInfo{Name: "orders", Exists: true})
+		if Failures(checks) != 1 || checks[1].Remediation != "ALTER TABLE `orders` ADD PRIMARY KEY (<columns>);" {
+			t.Errorf("Expected a primary key failure, got %+v", checks)
+		}
+	})
+}
+
+func TestCheckPeers(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	checks := CheckPeers(map[string]string{
+		"node2": listener.Addr().String(),
+		"node3": closedAddr,
+	}, time.Second)
+
+	if len(checks) != 2 {
+		t.Fatalf("Expected 2 checks, got %d", len(checks))
+	}
+	if checks[0].Name != "peer node2" || checks[0].Status != StatusPass {
+		t.Errorf("Expected node2 reachable, got %+v", checks[0])
+	}
+	if checks[1].Name != "peer node3" || checks[1].Status != StatusFail {
+		t.Errorf("Expected node3 unreachable, got %+v", checks[1])
+	}
+}
@@ -0,0 +1,132 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadOrCreateKey reads a hex-encoded Ed25519 private key from path,
+// generating and saving a new one if the file does not exist
+func LoadOrCreateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid node key in %s: %w", path, err)
+		}
+		if len(key) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("node key in %s must be %d bytes, got %d", path, ed25519.PrivateKeySize, len(key))
+		}
+		return ed25519.PrivateKey(key), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read node key: %w", err)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate node key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write node key: %w", err)
+	}
+
+	return key, nil
+}
+
+// Signer signs data on behalf of a cluster node
+type Signer struct {
+	nodeID string
+	key    ed25519.PrivateKey
+}
+
+func NewSigner(nodeID string, key ed25519.PrivateKey) *Signer {
+	return &Signer{
+		nodeID: nodeID,
+		key:    key,
+	}
+}
+
+func (s *Signer) NodeID() string {
+	return s.nodeID
+}
+
+// PublicKey returns the hex-encoded public key to pin on the other nodes
+func (s *Signer) PublicKey() string {
+	return hex.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// Sign returns a base64-encoded signature of data
+func (s *Signer) Sign(data []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, data))
+}
+
+// KeyRing holds the pinned public keys of every node in the cluster
+type KeyRing struct {
+	keys map[string]ed25519.PublicKey
+}
+
+// NewKeyRing creates a key ring from node IDs mapped to hex-encoded public keys
+func NewKeyRing(publicKeys map[string]string) (*KeyRing, error) {
+	keys := make(map[string]ed25519.PublicKey, len(publicKeys))
+
+	for nodeID, publicKey := range publicKeys {
+		key, err := hex.DecodeString(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key for node %s: %w", nodeID, err)
+		}
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("public key for node %s must be %d bytes, got %d", nodeID, ed25519.PublicKeySize, len(key))
+		}
+		keys[nodeID] = ed25519.PublicKey(key)
+	}
+
+	return &KeyRing{keys: keys}, nil
+}
+
+// Verify checks a base64-encoded signature made by the given node
+func (r *KeyRing) Verify(nodeID string, data []byte, signature string) error {
+	key, ok := r.keys[nodeID]
+	if !ok {
+		return fmt.Errorf("no public key pinned for node %s", nodeID)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding from node %s: %w", nodeID, err)
+	}
+
+	if !ed25519.Verify(key, data, sig) {
+		return fmt.Errorf("invalid signature from node %s", nodeID)
+	}
+
+	return nil
+}
+
+// Quorum returns the number of node signatures required for a majority
+func (r *KeyRing) Quorum() int {
+	return len(r.keys)/2 + 1
+}
+
+// CountValid returns how many of the signatures (node ID -> signature) are
+// valid for data
+func (r *KeyRing) CountValid(data []byte, signatures map[string]string) int {
+	valid := 0
+	for nodeID, signature := range signatures {
+		if r.Verify(nodeID, data, signature) == nil {
+			valid++
+		}
+	}
+	return valid
+}
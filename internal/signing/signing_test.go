@@ -0,0 +1,79 @@
+package signing
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadOrCreateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "node.key")
+
+	created, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateKey failed: %v", err)
+	}
+
+	loaded, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateKey failed on reload: %v", err)
+	}
+
+	if !created.Equal(loaded) {
+		t.Error("Expected the saved key to be loaded again")
+	}
+}
+
+func TestKeyRing(t *testing.T) {
+	signers := make(map[string]*Signer)
+	publicKeys := make(map[string]string)
+	for _, id := range []string{"node1", "node2", "node3"} {
+		key, err := LoadOrCreateKey(filepath.Join(t.TempDir(), "node.key"))
+		if err != nil {
+			t.Fatalf("LoadOrCreateKey failed: %v", err)
+		}
+		signers[id] = NewSigner(id, key)
+		publicKeys[id] = signers[id].PublicKey()
+	}
+
+	ring, err := NewKeyRing(publicKeys)
+	if err != nil {
+		t.Fatalf("NewKeyRing failed: %v", err)
+	}
+
+	data := []byte("checkpoint")
+	signature := signers["node1"].Sign(data)
+
+	t.Run("Verify", func(t *testing.T) {
+		if err := ring.Verify("node1", data, signature); err != nil {
+			t.Errorf("Expected valid signature, got: %v", err)
+		}
+		if err := ring.Verify("node2", data, signature); err == nil {
+			t.Error("Signature attributed to the wrong node should fail")
+		}
+		if err := ring.Verify("node1", []byte("other"), signature); err == nil {
+			t.Error("Signature over different data should fail")
+		}
+		if err := ring.Verify("node4", data, signature); err == nil {
+			t.Error("Unknown node should fail")
+		}
+	})
+
+	t.Run("Quorum", func(t *testing.T) {
+		if ring.Quorum() != 2 {
+			t.Errorf("Expected quorum 2 for 3 nodes, got %d", ring.Quorum())
+		}
+
+		signatures := map[string]string{
+			"node1": signature,
+			"node2": signature,
+		}
+		if valid := ring.CountValid(data, signatures); valid != 1 {
+			t.Errorf("Expected 1 valid signature, got %d", valid)
+		}
+
+		signatures["node2"] = signers["node2"].Sign(data)
+		if valid := ring.CountValid(data, signatures); valid != 2 {
+			t.Errorf("Expected 2 valid signatures, got %d", valid)
+		}
+	})
+}
@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrAllowanceNotFound is returned by GetAllowance for an unknown ID
+var ErrAllowanceNotFound = errors.New("allowance not found")
+
+// Allowance pre-authorizes a single UPDATE or DELETE on a protected record.
+// It is signed by an approver and consumed by the first matching CDC event.
+type Allowance struct {
+	ID           string    `json:"id"`
+	TableName    string    `json:"table_name"`
+	RecordID     string    `json:"record_id"`
+	Operation    string    `json:"operation"`
+	ExpectedHash string    `json:"expected_hash"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Approver     string    `json:"approver"`
+	Reason       string    `json:"reason,omitempty"`
+	Signature    string    `json:"signature"`
+	SubmittedAt  time.Time `json:"submitted_at,omitempty"`
+	ConsumedAt   time.Time `json:"consumed_at,omitempty"`
+	ConsumedLSN  uint64    `json:"consumed_lsn,omitempty"`
+}
+
+func (a *Allowance) Consumed() bool {
+	return !a.ConsumedAt.IsZero()
+}
+
+// Matches reports whether the allowance covers a change to the given record
+// whose current recorded hash is oldHash
+func (a *Allowance) Matches(tableName, recordID, operation, oldHash string, at time.Time) bool {
+	return !a.Consumed() &&
+		a.TableName == tableName &&
+		a.RecordID == recordID &&
+		a.Operation == operation &&
+		a.ExpectedHash == oldHash &&
+		at.Before(a.ExpiresAt)
+}
+
+func (s *Storage) SaveAllowance(allowance *Allowance) error {
+	if allowance.ID == "" {
+		return fmt.Errorf("allowance ID is required")
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(AllowanceBucket)
+
+		if bucket.Get([]byte(allowance.ID)) != nil {
+			return fmt.Errorf("allowance already exists: %s", allowance.ID)
+		}
+
+		data, err := json.Marshal(allowance)
+		if err != nil {
+			return fmt.Errorf("failed to marshal allowance: %w", err)
+		}
+
+		if err := bucket.Put([]byte(allowance.ID), data); err != nil {
+			return err
+		}
+
+		return tx.Bucket(AllowanceRecordBucket).Put(allowanceRecordKey(allowance), []byte(allowance.ID))
+	})
+}
+
+// allowanceRecordKey indexes an allowance under the change it covers.
+// Table names and operations never contain a colon; record IDs may, so
+// lookups compare the stored allowance as well.
+func allowanceRecordKey(a *Allowance) []byte {
+	return []byte(allowanceRecordPrefix(a.TableName, a.RecordID, a.Operation) + a.ID)
+}
+
+func allowanceRecordPrefix(tableName, recordID, operation string) string {
+	return fmt.Sprintf("%s:%s:%s:", tableName, operation, recordID)
+}
+
+// FindAllowances returns the allowances, consumed or not, covering an
+// operation on one record
+func (s *Storage) FindAllowances(tableName, recordID, operation string) ([]*Allowance, error) {
+	var allowances []*Allowance
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(allowanceRecordPrefix(tableName, recordID, operation))
+		stored := tx.Bucket(AllowanceBucket)
+
+		c := tx.Bucket(AllowanceRecordBucket).Cursor()
+		for k, id := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, id = c.Next() {
+			data := stored.Get(id)
+			if data == nil {
+				continue
+			}
+			var allowance Allowance
+			if err := json.Unmarshal(data, &allowance); err != nil {
+				return fmt.Errorf("failed to unmarshal allowance: %w", err)
+			}
+			if allowance.TableName == tableName && allowance.RecordID == recordID && allowance.Operation == operation {
+				allowances = append(allowances, &allowance)
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return allowances, nil
+}
+
+// rebuildAllowanceIndex recreates the record index from the stored
+// allowances
+func rebuildAllowanceIndex(tx *bolt.Tx) error {
+	if err := resetBuckets(tx, [][]byte{AllowanceRecordBucket}); err != nil {
+		return err
+	}
+	index := tx.Bucket(AllowanceRecordBucket)
+
+	return tx.Bucket(AllowanceBucket).ForEach(func(k, v []byte) error {
+		var allowance Allowance
+		if err := json.Unmarshal(v, &allowance); err != nil {
+			return nil
+		}
+		return index.Put(allowanceRecordKey(&allowance), bytes.Clone(k))
+	})
+}
+
+func (s *Storage) GetAllowance(id string) (*Allowance, error) {
+	var allowance Allowance
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(AllowanceBucket)
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("%w: %s", ErrAllowanceNotFound, id)
+		}
+		return json.Unmarshal(data, &allowance)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &allowance, nil
+}
+
+// ListAllowances returns all allowances ordered by expiry
+func (s *Storage) ListAllowances() ([]*Allowance, error) {
+	allowances := make([]*Allowance, 0)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(AllowanceBucket)
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var allowance Allowance
+			if err := json.Unmarshal(v, &allowance); err != nil {
+				return nil
+			}
+			allowances = append(allowances, &allowance)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(allowances, func(i, j int) bool {
+		return allowances[i].ExpiresAt.Before(allowances[j].ExpiresAt)
+	})
+
+	return allowances, nil
+}
+
+func consumeAllowance(tx *bolt.Tx, entry *HashEntry) error {
+	allowances := tx.Bucket(AllowanceBucket)
+
+	data := allowances.Get([]byte(entry.AllowanceID))
+	if data == nil {
+		return fmt.Errorf("allowance not found: %s", entry.AllowanceID)
+	}
+
+	var allowance Allowance
+	if err := json.Unmarshal(data, &allowance); err != nil {
+		return fmt.Errorf("failed to unmarshal allowance: %w", err)
+	}
+
+	if allowance.Consumed() {
+		return fmt.Errorf("allowance %s already consumed", allowance.ID)
+	}
+
+	allowance.ConsumedAt = entry.Timestamp
+	allowance.ConsumedLSN = entry.LSN
+
+	updated, err := json.Marshal(&allowance)
+	if err != nil {
+		return fmt.Errorf("failed to marshal allowance: %w", err)
+	}
+
+	return allowances.Put([]byte(allowance.ID), updated)
+}
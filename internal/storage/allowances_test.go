@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestFindAllowances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "witnz.db")
+	storage, err := New(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	expires := time.Now().Add(time.Hour)
+	for _, a := range []*Allowance{
+		{ID: "al-1", TableName: "users", RecordID: "1", Operation: "DELETE", ExpiresAt: expires},
+		{ID: "al-2", TableName: "users", RecordID: "1", Operation: "UPDATE", ExpiresAt: expires},
+		{ID: "al-3", TableName: "users", RecordID: "1:2", Operation: "DELETE", ExpiresAt: expires},
+		{ID: "al-4", TableName: "users_archive", RecordID: "1", Operation: "DELETE", ExpiresAt: expires},
+	} {
+		if err := storage.SaveAllowance(a); err != nil {
+			t.Fatalf("SaveAllowance failed: %v", err)
+		}
+	}
+
+	check := func(t *testing.T, storage *Storage) {
+		found, err := storage.FindAllowances("users", "1", "DELETE")
+		if err != nil {
+			t.Fatalf("FindAllowances failed: %v", err)
+		}
+		if len(found) != 1 || found[0].ID != "al-1" {
+			t.Errorf("Expected only al-1, got %+v", found)
+		}
+
+		if found, _ := storage.FindAllowances("users", "2", "DELETE"); len(found) != 0 {
+			t.Errorf("Expected no allowances for record 2, got %+v", found)
+		}
+	}
+
+	t.Run("Lookup", func(t *testing.T) {
+		check(t, storage)
+	})
+
+	t.Run("ConsumedAllowanceStaysIndexed", func(t *testing.T) {
+		if _, err := storage.AppendHashEntry(&HashEntry{TableName: "users", OperationType: "DELETE", RecordID: "1", AllowanceID: "al-1", LSN: 100, Timestamp: time.Now()}); err != nil {
+			t.Fatalf("AppendHashEntry failed: %v", err)
+		}
+		found, _ := storage.FindAllowances("users", "1", "DELETE")
+		if len(found) != 1 || !found[0].Consumed() || found[0].ConsumedLSN != 100 {
+			t.Errorf("Expected al-1 consumed at LSN 100, got %+v", found)
+		}
+	})
+
+	t.Run("SnapshotRestore", func(t *testing.T) {
+		snapshot, err := storage.Snapshot()
+		if err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := snapshot.Write(&buf); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		snapshot.Close()
+
+		restored, err := New(filepath.Join(t.TempDir(), "restored.db"))
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		defer restored.Close()
+		if err := restored.RestoreSnapshot(&buf); err != nil {
+			t.Fatalf("RestoreSnapshot failed: %v", err)
+		}
+		check(t, restored)
+	})
+
+	t.Run("MigrationBuildsIndex", func(t *testing.T) {
+		storage.Close()
+
+		// Databases from schema version 2 have allowances but no index
+		db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		err = db.Update(func(tx *bolt.Tx) error {
+			if err := tx.DeleteBucket(AllowanceRecordBucket); err != nil {
+				return err
+			}
+			return tx.Bucket(MetadataBucket).Put([]byte(schemaVersionKey), []byte("2"))
+		})
+		db.Close()
+		if err != nil {
+			t.Fatalf("Failed to write version 2 database: %v", err)
+		}
+
+		storage, err = New(path)
+		if err != nil {
+			t.Fatalf("Failed to open version 2 database: %v", err)
+		}
+		defer storage.Close()
+		check(t, storage)
+	})
+}
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+type FindingKind string
+
+const (
+	FindingPhantom          FindingKind = "phantom"
+	FindingDeleted          FindingKind = "deleted"
+	FindingModified         FindingKind = "modified"
+	FindingUpdate           FindingKind = "update"
+	FindingDelete           FindingKind = "delete"
+	FindingTruncate         FindingKind = "truncate"
+	FindingLeaderDivergence FindingKind = "leader_divergence"
+	// FindingDatabaseRollback is a source database that was replaced,
+	// restored or rolled back behind positions witnz already recorded
+	FindingDatabaseRollback FindingKind = "database_rollback"
+	// FindingDetectorTamper is a change to the publication, the replication
+	// slot or a protected table that lets changes go unnoticed
+	FindingDetectorTamper FindingKind = "detector_tamper"
+)
+
+type DetectionSource string
+
+const (
+	DetectedByCDC      DetectionSource = "cdc"
+	DetectedByMerkle   DetectionSource = "merkle"
+	DetectedByWitness  DetectionSource = "witness"
+	DetectedByWatchdog DetectionSource = "watchdog"
+)
+
+type FindingStatus string
+
+const (
+	FindingOpen         FindingStatus = "open"
+	FindingAcknowledged FindingStatus = "acknowledged"
+	FindingResolved     FindingStatus = "resolved"
+)
+
+// Finding is a single tampering result recorded by a detector
+type Finding struct {
+	ID           string          `json:"id"`
+	TableName    string          `json:"table_name"`
+	RecordID     string          `json:"record_id,omitempty"`
+	Kind         FindingKind     `json:"kind"`
+	DetectedBy   DetectionSource `json:"detected_by"`
+	NodeID       string          `json:"node_id"`
+	LSN          uint64          `json:"lsn,omitempty"`
+	DetectedAt   time.Time       `json:"detected_at"`
+	ExpectedHash string          `json:"expected_hash,omitempty"`
+	ActualHash   string          `json:"actual_hash,omitempty"`
+	Details      string          `json:"details,omitempty"`
+	Status       FindingStatus   `json:"status"`
+	StatusBy     string          `json:"status_by,omitempty"`
+	StatusNote   string          `json:"status_note,omitempty"`
+	UpdatedAt    time.Time       `json:"updated_at,omitempty"`
+	// Local marks a finding kept on this node only and never replicated
+	Local bool `json:"local,omitempty"`
+}
+
+// IsLocal reports whether the finding belongs to this node only. Witness
+// findings recorded before the Local flag existed are local as well.
+func (f *Finding) IsLocal() bool {
+	return f.Local || f.DetectedBy == DetectedByWitness
+}
+
+// NewFindingID returns a time-ordered identifier for a new finding
+func NewFindingID(detectedAt time.Time) string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%s", detectedAt.UTC().Format("20060102150405"), hex.EncodeToString(suffix))
+}
+
+// CanTransition reports whether a finding may move from one workflow state to another
+func CanTransition(from, to FindingStatus) bool {
+	switch from {
+	case FindingOpen:
+		return to == FindingAcknowledged || to == FindingResolved
+	case FindingAcknowledged:
+		return to == FindingResolved
+	default:
+		return false
+	}
+}
+
+func (s *Storage) SaveFinding(finding *Finding) error {
+	if finding.ID == "" {
+		return fmt.Errorf("finding ID is required")
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(FindingBucket)
+
+		data, err := json.Marshal(finding)
+		if err != nil {
+			return fmt.Errorf("failed to marshal finding: %w", err)
+		}
+
+		return bucket.Put([]byte(finding.ID), data)
+	})
+}
+
+func (s *Storage) GetFinding(id string) (*Finding, error) {
+	var finding Finding
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(FindingBucket)
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("finding not found: %s", id)
+		}
+		return json.Unmarshal(data, &finding)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &finding, nil
+}
+
+// ListFindings returns findings ordered by detection time.
+// An empty status returns findings in every state.
+func (s *Storage) ListFindings(status FindingStatus) ([]*Finding, error) {
+	findings := make([]*Finding, 0)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(FindingBucket)
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var finding Finding
+			if err := json.Unmarshal(v, &finding); err != nil {
+				return nil
+			}
+			if status == "" || finding.Status == status {
+				findings = append(findings, &finding)
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].DetectedAt.Before(findings[j].DetectedAt)
+	})
+
+	return findings, nil
+}
+
+// UpdateFindingStatus moves a finding through the acknowledge/resolve workflow
+func (s *Storage) UpdateFindingStatus(id string, status FindingStatus, actor, note string, at time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(FindingBucket)
+
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("finding not found: %s", id)
+		}
+
+		var finding Finding
+		if err := json.Unmarshal(data, &finding); err != nil {
+			return fmt.Errorf("failed to unmarshal finding: %w", err)
+		}
+
+		if !CanTransition(finding.Status, status) {
+			return fmt.Errorf("cannot change finding %s from %s to %s", id, finding.Status, status)
+		}
+
+		finding.Status = status
+		finding.StatusBy = actor
+		finding.StatusNote = note
+		finding.UpdatedAt = at
+
+		updated, err := json.Marshal(&finding)
+		if err != nil {
+			return fmt.Errorf("failed to marshal finding: %w", err)
+		}
+
+		return bucket.Put([]byte(id), updated)
+	})
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFindings(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	storage, err := New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	now := time.Now()
+	first := &Finding{
+		ID:         NewFindingID(now),
+		TableName:  "audit_log",
+		RecordID:   "1",
+		Kind:       FindingUpdate,
+		DetectedBy: DetectedByCDC,
+		DetectedAt: now,
+		Status:     FindingOpen,
+	}
+	second := &Finding{
+		ID:         NewFindingID(now.Add(time.Second)),
+		TableName:  "audit_log",
+		RecordID:   "2",
+		Kind:       FindingPhantom,
+		DetectedBy: DetectedByMerkle,
+		DetectedAt: now.Add(time.Second),
+		Status:     FindingOpen,
+	}
+
+	t.Run("SaveAndList", func(t *testing.T) {
+		for _, f := range []*Finding{second, first} {
+			if err := storage.SaveFinding(f); err != nil {
+				t.Fatalf("SaveFinding failed: %v", err)
+			}
+		}
+
+		findings, err := storage.ListFindings("")
+		if err != nil {
+			t.Fatalf("ListFindings failed: %v", err)
+		}
+
+		if len(findings) != 2 {
+			t.Fatalf("Expected 2 findings, got %d", len(findings))
+		}
+		if findings[0].ID != first.ID {
+			t.Errorf("Expected findings ordered by detection time, got %s first", findings[0].ID)
+		}
+	})
+
+	t.Run("Workflow", func(t *testing.T) {
+		if err := storage.UpdateFindingStatus(first.ID, FindingAcknowledged, "alice", "looking", now); err != nil {
+			t.Fatalf("UpdateFindingStatus failed: %v", err)
+		}
+
+		if err := storage.UpdateFindingStatus(first.ID, FindingResolved, "alice", "restored from backup", now); err != nil {
+			t.Fatalf("UpdateFindingStatus failed: %v", err)
+		}
+
+		if err := storage.UpdateFindingStatus(first.ID, FindingAcknowledged, "bob", "", now); err == nil {
+			t.Error("Resolved finding should not move back to acknowledged")
+		}
+
+		resolved, err := storage.GetFinding(first.ID)
+		if err != nil {
+			t.Fatalf("GetFinding failed: %v", err)
+		}
+		if resolved.Status != FindingResolved || resolved.StatusBy != "alice" {
+			t.Errorf("Unexpected finding state: status=%s by=%s", resolved.Status, resolved.StatusBy)
+		}
+
+		open, err := storage.ListFindings(FindingOpen)
+		if err != nil {
+			t.Fatalf("ListFindings failed: %v", err)
+		}
+		if len(open) != 1 || open[0].ID != second.ID {
+			t.Errorf("Expected only the second finding to be open, got %d", len(open))
+		}
+	})
+
+	t.Run("UnknownFinding", func(t *testing.T) {
+		if _, err := storage.GetFinding("missing"); err == nil {
+			t.Error("GetFinding should fail for unknown ID")
+		}
+		if err := storage.UpdateFindingStatus("missing", FindingResolved, "alice", "", now); err == nil {
+			t.Error("UpdateFindingStatus should fail for unknown ID")
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// databaseIdentityPrefix prefixes the metadata key of each source's identity
+const databaseIdentityPrefix = "database_identity:"
+
+// DatabaseIdentity is what a source database reported about itself through
+// IDENTIFY_SYSTEM when CDC last connected. A restore from backup or
+// pg_resetwal changes the system identifier, the timeline or moves the WAL
+// position backwards.
+type DatabaseIdentity struct {
+	// Source is the name of the source, empty for the default source
+	Source   string `json:"source"`
+	SystemID string `json:"system_id"`
+	Timeline int32  `json:"timeline"`
+	// XLogPos is the server's WAL flush position when it was identified
+	XLogPos uint64 `json:"xlog_pos"`
+	// AcknowledgedLSN is the highest position witnz had acknowledged
+	AcknowledgedLSN uint64    `json:"acknowledged_lsn,omitempty"`
+	NodeID          string    `json:"node_id"`
+	ObservedAt      time.Time `json:"observed_at"`
+}
+
+// Changes describes how current differs from the recorded identity in ways
+// a running database cannot explain, one line per change. acknowledged is
+// the highest position acknowledged since, which the server must not be
+// behind.
+func (id *DatabaseIdentity) Changes(current *DatabaseIdentity, acknowledged uint64) []string {
+	var changes []string
+
+	if id.SystemID != current.SystemID {
+		changes = append(changes, fmt.Sprintf("system identifier changed from %s to %s, the database was replaced",
+			id.SystemID, current.SystemID))
+	}
+	if id.Timeline != current.Timeline {
+		changes = append(changes, fmt.Sprintf("timeline switched from %d to %d, the database was restored or promoted",
+			id.Timeline, current.Timeline))
+	}
+
+	floor := max(id.XLogPos, id.AcknowledgedLSN, acknowledged)
+	if current.XLogPos < floor {
+		changes = append(changes, fmt.Sprintf("WAL position %s is behind %s, the database was rolled back",
+			FormatLSN(current.XLogPos), FormatLSN(floor)))
+	}
+
+	return changes
+}
+
+// FormatLSN prints a WAL position the way PostgreSQL does
+func FormatLSN(lsn uint64) string {
+	return fmt.Sprintf("%X/%X", uint32(lsn>>32), uint32(lsn))
+}
+
+// SaveDatabaseIdentity stores the identity of a source database
+func (s *Storage) SaveDatabaseIdentity(identity *DatabaseIdentity) error {
+	data, err := json.Marshal(identity)
+	if err != nil {
+		return fmt.Errorf("failed to marshal database identity: %w", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(MetadataBucket).Put([]byte(databaseIdentityPrefix+identity.Source), data)
+	})
+}
+
+// GetDatabaseIdentity returns the recorded identity of a source database, or
+// nil if none was recorded yet
+func (s *Storage) GetDatabaseIdentity(source string) (*DatabaseIdentity, error) {
+	var identity *DatabaseIdentity
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(MetadataBucket).Get([]byte(databaseIdentityPrefix + source))
+		if data == nil {
+			return nil
+		}
+		identity = &DatabaseIdentity{}
+		if err := json.Unmarshal(data, identity); err != nil {
+			return fmt.Errorf("failed to unmarshal database identity: %w", err)
+		}
+		return nil
+	})
+
+	return identity, err
+}
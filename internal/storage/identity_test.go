@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDatabaseIdentity(t *testing.T) {
+	recorded := &DatabaseIdentity{SystemID: "7300000000000000001", Timeline: 1, XLogPos: 0x1000, AcknowledgedLSN: 0x1200}
+
+	t.Run("Unchanged", func(t *testing.T) {
+		current := &DatabaseIdentity{SystemID: "7300000000000000001", Timeline: 1, XLogPos: 0x2000}
+		if changes := recorded.Changes(current, 0x1800); len(changes) != 0 {
+			t.Errorf("Expected no changes, got %v", changes)
+		}
+	})
+
+	t.Run("Replaced", func(t *testing.T) {
+		current := &DatabaseIdentity{SystemID: "7300000000000000002", Timeline: 2, XLogPos: 0x2000}
+		changes := recorded.Changes(current, 0)
+		if len(changes) != 2 {
+			t.Fatalf("Expected 2 changes, got %v", changes)
+		}
+		if !strings.Contains(changes[0], "system identifier changed") {
+			t.Errorf("Expected system identifier change, got %s", changes[0])
+		}
+		if !strings.Contains(changes[1], "timeline switched from 1 to 2") {
+			t.Errorf("Expected timeline switch, got %s", changes[1])
+		}
+	})
+
+	t.Run("RolledBack", func(t *testing.T) {
+		current := &DatabaseIdentity{SystemID: "7300000000000000001", Timeline: 1, XLogPos: 0x1100}
+		changes := recorded.Changes(current, 0)
+		if len(changes) != 1 || !strings.Contains(changes[0], "0/1100 is behind 0/1200") {
+			t.Errorf("Expected rollback behind the recorded acknowledged LSN, got %v", changes)
+		}
+
+		current.XLogPos = 0x2000
+		changes = recorded.Changes(current, 0x3000)
+		if len(changes) != 1 || !strings.Contains(changes[0], "behind 0/3000") {
+			t.Errorf("Expected rollback behind the acknowledged LSN, got %v", changes)
+		}
+	})
+
+	t.Run("SaveAndGet", func(t *testing.T) {
+		store, err := New(filepath.Join(t.TempDir(), "witnz.db"))
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		defer store.Close()
+
+		if identity, err := store.GetDatabaseIdentity("billing"); err != nil || identity != nil {
+			t.Fatalf("Expected no identity, got %+v (err=%v)", identity, err)
+		}
+
+		billing := *recorded
+		billing.Source = "billing"
+		if err := store.SaveDatabaseIdentity(&billing); err != nil {
+			t.Fatalf("SaveDatabaseIdentity failed: %v", err)
+		}
+
+		identity, err := store.GetDatabaseIdentity("billing")
+		if err != nil {
+			t.Fatalf("GetDatabaseIdentity failed: %v", err)
+		}
+		if identity == nil || identity.SystemID != billing.SystemID || identity.XLogPos != billing.XLogPos {
+			t.Errorf("Expected stored identity %+v, got %+v", billing, identity)
+		}
+		if identity, _ := store.GetDatabaseIdentity(""); identity != nil {
+			t.Errorf("Expected no identity for the default source, got %+v", identity)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// SchemaVersion is the current on-disk layout. Version 1 stored hash entries
+// and checkpoints under flat "table:seq" keys; version 2 keeps one nested
+// bucket per table keyed by big-endian sequence numbers; version 3 indexes
+// allowances by the record they cover.
+const SchemaVersion = 3
+
+const schemaVersionKey = "schema_version"
+
+// seqKey encodes a sequence number so that keys sort numerically
+func seqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// tableBucket returns the nested bucket of a table, or nil if the table has
+// no entries yet
+func tableBucket(tx *bolt.Tx, parent []byte, tableName string) *bolt.Bucket {
+	return tx.Bucket(parent).Bucket([]byte(tableName))
+}
+
+func createTableBucket(tx *bolt.Tx, parent []byte, tableName string) (*bolt.Bucket, error) {
+	bucket, err := tx.Bucket(parent).CreateBucketIfNotExists([]byte(tableName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bucket for table %s: %w", tableName, err)
+	}
+	return bucket, nil
+}
+
+// migrate upgrades a database written by an older version in place
+func migrate(tx *bolt.Tx) error {
+	metadata := tx.Bucket(MetadataBucket)
+
+	version := 1
+	if raw := metadata.Get([]byte(schemaVersionKey)); raw != nil {
+		v, err := strconv.Atoi(string(raw))
+		if err != nil {
+			return fmt.Errorf("invalid schema version %q: %w", raw, err)
+		}
+		version = v
+	}
+
+	if version > SchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, SchemaVersion)
+	}
+
+	if version < 2 {
+		if err := migrateFlatKeys(tx, HashChainBucket, func(v []byte) (string, uint64, error) {
+			var entry HashEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return "", 0, err
+			}
+			return entry.TableName, entry.SequenceNum, nil
+		}); err != nil {
+			return fmt.Errorf("failed to migrate hash chain: %w", err)
+		}
+
+		if err := migrateFlatKeys(tx, MerkleCheckpointBucket, func(v []byte) (string, uint64, error) {
+			var checkpoint MerkleCheckpoint
+			if err := json.Unmarshal(v, &checkpoint); err != nil {
+				return "", 0, err
+			}
+			return checkpoint.TableName, checkpoint.SequenceNum, nil
+		}); err != nil {
+			return fmt.Errorf("failed to migrate checkpoints: %w", err)
+		}
+
+		if err := rebuildLatestPointers(tx); err != nil {
+			return err
+		}
+	}
+
+	if version < 3 {
+		if err := rebuildAllowanceIndex(tx); err != nil {
+			return fmt.Errorf("failed to index allowances: %w", err)
+		}
+	}
+
+	return metadata.Put([]byte(schemaVersionKey), []byte(strconv.Itoa(SchemaVersion)))
+}
+
+// migrateFlatKeys moves "table:seq" values of a bucket into per-table nested
+// buckets. Values that cannot be decoded are left in place.
+func migrateFlatKeys(tx *bolt.Tx, parent []byte, decode func(v []byte) (string, uint64, error)) error {
+	type legacyValue struct {
+		key, value []byte
+	}
+
+	var legacy []legacyValue
+	err := tx.Bucket(parent).ForEach(func(k, v []byte) error {
+		// Nested buckets have nil values
+		if v != nil {
+			legacy = append(legacy, legacyValue{key: bytes.Clone(k), value: bytes.Clone(v)})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range legacy {
+		tableName, seq, err := decode(item.value)
+		if err != nil || tableName == "" {
+			continue
+		}
+
+		bucket, err := createTableBucket(tx, parent, tableName)
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(seqKey(seq), item.value); err != nil {
+			return err
+		}
+		if err := tx.Bucket(parent).Delete(item.key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rebuildLatestPointers recomputes the latest sequence of every table
+func rebuildLatestPointers(tx *bolt.Tx) error {
+	latest := tx.Bucket(HashLatestBucket)
+
+	return tx.Bucket(HashChainBucket).ForEach(func(name, v []byte) error {
+		if v != nil {
+			return nil
+		}
+		k, _ := tx.Bucket(HashChainBucket).Bucket(name).Cursor().Last()
+		if k == nil {
+			return nil
+		}
+		return latest.Put(bytes.Clone(name), bytes.Clone(k))
+	})
+}
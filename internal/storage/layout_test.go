@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestNumericOrdering(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	storage, err := New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	for seq := uint64(1); seq <= 12; seq++ {
+		if err := storage.SaveHashEntry(&HashEntry{TableName: "audit_log", SequenceNum: seq, DataHash: fmt.Sprintf("hash%d", seq)}); err != nil {
+			t.Fatalf("SaveHashEntry failed: %v", err)
+		}
+		if err := storage.SaveMerkleCheckpoint(&MerkleCheckpoint{TableName: "audit_log", SequenceNum: seq, MerkleRoot: fmt.Sprintf("root%d", seq)}); err != nil {
+			t.Fatalf("SaveMerkleCheckpoint failed: %v", err)
+		}
+	}
+	if err := storage.SaveHashEntry(&HashEntry{TableName: "audit_log_archive", SequenceNum: 1, DataHash: "other"}); err != nil {
+		t.Fatalf("SaveHashEntry failed: %v", err)
+	}
+
+	t.Run("LatestHashEntry", func(t *testing.T) {
+		latest, err := storage.GetLatestHashEntry("audit_log")
+		if err != nil {
+			t.Fatalf("GetLatestHashEntry failed: %v", err)
+		}
+		if latest.SequenceNum != 12 {
+			t.Errorf("Expected sequence 12, got %d", latest.SequenceNum)
+		}
+	})
+
+	t.Run("LatestIgnoresLowerSequence", func(t *testing.T) {
+		if err := storage.SaveHashEntry(&HashEntry{TableName: "audit_log", SequenceNum: 5, DataHash: "hash5"}); err != nil {
+			t.Fatalf("SaveHashEntry failed: %v", err)
+		}
+		latest, err := storage.GetLatestHashEntry("audit_log")
+		if err != nil {
+			t.Fatalf("GetLatestHashEntry failed: %v", err)
+		}
+		if latest.SequenceNum != 12 {
+			t.Errorf("Expected sequence 12, got %d", latest.SequenceNum)
+		}
+	})
+
+	t.Run("AllHashEntriesOrdered", func(t *testing.T) {
+		entries, err := storage.GetAllHashEntries("audit_log")
+		if err != nil {
+			t.Fatalf("GetAllHashEntries failed: %v", err)
+		}
+		if len(entries) != 12 {
+			t.Fatalf("Expected 12 entries, got %d", len(entries))
+		}
+		for i, entry := range entries {
+			if entry.SequenceNum != uint64(i+1) {
+				t.Errorf("Expected sequence %d at position %d, got %d", i+1, i, entry.SequenceNum)
+			}
+		}
+	})
+
+	t.Run("LatestCheckpoint", func(t *testing.T) {
+		latest, err := storage.GetLatestMerkleCheckpoint("audit_log")
+		if err != nil {
+			t.Fatalf("GetLatestMerkleCheckpoint failed: %v", err)
+		}
+		if latest.SequenceNum != 12 {
+			t.Errorf("Expected checkpoint 12, got %d", latest.SequenceNum)
+		}
+	})
+}
+
+func TestMigrateFlatKeys(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	// Write a database in the version 1 layout
+	db, err := bolt.Open(tmpfile.Name(), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		chain, err := tx.CreateBucketIfNotExists(HashChainBucket)
+		if err != nil {
+			return err
+		}
+		checkpoints, err := tx.CreateBucketIfNotExists(MerkleCheckpointBucket)
+		if err != nil {
+			return err
+		}
+		for seq := uint64(1); seq <= 10; seq++ {
+			data, _ := json.Marshal(&HashEntry{TableName: "audit_log", SequenceNum: seq, DataHash: fmt.Sprintf("hash%d", seq)})
+			if err := chain.Put([]byte(fmt.Sprintf("audit_log:%d", seq)), data); err != nil {
+				return err
+			}
+		}
+		for _, seq := range []uint64{9, 10} {
+			data, _ := json.Marshal(&MerkleCheckpoint{TableName: "audit_log", SequenceNum: seq, MerkleRoot: fmt.Sprintf("root%d", seq)})
+			if err := checkpoints.Put([]byte(fmt.Sprintf("audit_log:%d", seq)), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to write legacy data: %v", err)
+	}
+	db.Close()
+
+	storage, err := New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open legacy database: %v", err)
+	}
+	defer storage.Close()
+
+	latest, err := storage.GetLatestHashEntry("audit_log")
+	if err != nil {
+		t.Fatalf("GetLatestHashEntry failed: %v", err)
+	}
+	if latest.SequenceNum != 10 {
+		t.Errorf("Expected latest sequence 10, got %d", latest.SequenceNum)
+	}
+
+	entries, err := storage.GetAllHashEntries("audit_log")
+	if err != nil {
+		t.Fatalf("GetAllHashEntries failed: %v", err)
+	}
+	if len(entries) != 10 {
+		t.Errorf("Expected 10 migrated entries, got %d", len(entries))
+	}
+
+	checkpoint, err := storage.GetLatestMerkleCheckpoint("audit_log")
+	if err != nil {
+		t.Fatalf("GetLatestMerkleCheckpoint failed: %v", err)
+	}
+	if checkpoint.MerkleRoot != "root10" {
+		t.Errorf("Expected root10, got %s", checkpoint.MerkleRoot)
+	}
+
+	version, err := storage.GetMetadata(schemaVersionKey)
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if version != fmt.Sprintf("%d", SchemaVersion) {
+		t.Errorf("Expected schema version %d, got %s", SchemaVersion, version)
+	}
+
+	appended, err := storage.AppendHashEntry(&HashEntry{TableName: "audit_log", DataHash: "hash11", RecordID: "11", LSN: 1100})
+	if err != nil || !appended {
+		t.Fatalf("AppendHashEntry failed: %v", err)
+	}
+	if latest, _ := storage.GetLatestHashEntry("audit_log"); latest.SequenceNum != 11 {
+		t.Errorf("Expected next sequence 11 after migration, got %d", latest.SequenceNum)
+	}
+}
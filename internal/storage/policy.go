@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// clusterPolicyKey is the metadata key of the committed cluster policy
+const clusterPolicyKey = "cluster_policy"
+
+// ClusterPolicy is the configuration every node of a cluster must share for
+// their hashes to agree. The bootstrap node commits it to the Raft log and
+// other nodes refuse to start when their own config differs.
+type ClusterPolicy struct {
+	HashAlgorithm   string   `json:"hash_algorithm"`
+	EncodingVersion int      `json:"encoding_version"`
+	ExcludedColumns []string `json:"excluded_columns"`
+	Tables          []string `json:"tables"`
+	// Patterns are the table patterns, whose matches change as tables are
+	// created and are not listed in Tables
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// Normalize sorts the column and table lists so equal policies have equal
+// digests
+func (p *ClusterPolicy) Normalize() {
+	p.ExcludedColumns = sortedCopy(p.ExcludedColumns)
+	p.Tables = sortedCopy(p.Tables)
+	if len(p.Patterns) > 0 {
+		p.Patterns = sortedCopy(p.Patterns)
+	}
+}
+
+// Digest returns the hex-encoded SHA-256 of the normalized policy
+func (p *ClusterPolicy) Digest() string {
+	normalized := *p
+	normalized.Normalize()
+
+	data, _ := json.Marshal(&normalized)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Diff describes how local differs from the policy, one line per setting.
+// It is empty when both have the same digest.
+func (p *ClusterPolicy) Diff(local *ClusterPolicy) []string {
+	var diff []string
+
+	if p.HashAlgorithm != local.HashAlgorithm {
+		diff = append(diff, fmt.Sprintf("hash.algorithm: cluster uses %q, local config has %q",
+			p.HashAlgorithm, local.HashAlgorithm))
+	}
+	if p.EncodingVersion != local.EncodingVersion {
+		diff = append(diff, fmt.Sprintf("encoding version: cluster uses %d, this binary uses %d",
+			p.EncodingVersion, local.EncodingVersion))
+	}
+	if missing, extra := compareLists(p.ExcludedColumns, local.ExcludedColumns); len(missing)+len(extra) > 0 {
+		diff = append(diff, fmt.Sprintf("excluded columns: cluster excludes [%s], this binary excludes [%s]",
+			strings.Join(sortedCopy(p.ExcludedColumns), ", "), strings.Join(sortedCopy(local.ExcludedColumns), ", ")))
+	}
+	missing, extra := compareLists(p.Tables, local.Tables)
+	if len(missing) > 0 {
+		diff = append(diff, fmt.Sprintf("protected_tables: missing from local config: %s", strings.Join(missing, ", ")))
+	}
+	if len(extra) > 0 {
+		diff = append(diff, fmt.Sprintf("protected_tables: not in cluster policy: %s", strings.Join(extra, ", ")))
+	}
+	missing, extra = compareLists(p.Patterns, local.Patterns)
+	if len(missing) > 0 {
+		diff = append(diff, fmt.Sprintf("protected_tables: patterns missing from local config: %s", strings.Join(missing, ", ")))
+	}
+	if len(extra) > 0 {
+		diff = append(diff, fmt.Sprintf("protected_tables: patterns not in cluster policy: %s", strings.Join(extra, ", ")))
+	}
+
+	return diff
+}
+
+// compareLists returns the items of want missing from got and the items of
+// got not in want, both sorted
+func compareLists(want, got []string) (missing, extra []string) {
+	inWant := make(map[string]bool, len(want))
+	for _, item := range want {
+		inWant[item] = true
+	}
+	inGot := make(map[string]bool, len(got))
+	for _, item := range got {
+		inGot[item] = true
+		if !inWant[item] {
+			extra = append(extra, item)
+		}
+	}
+	for _, item := range want {
+		if !inGot[item] {
+			missing = append(missing, item)
+		}
+	}
+
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return missing, extra
+}
+
+func sortedCopy(items []string) []string {
+	sorted := append([]string{}, items...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// SaveClusterPolicy stores the committed cluster policy
+func (s *Storage) SaveClusterPolicy(policy *ClusterPolicy) error {
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cluster policy: %w", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(MetadataBucket).Put([]byte(clusterPolicyKey), data)
+	})
+}
+
+// GetClusterPolicy returns the committed cluster policy, or nil if none was
+// committed yet
+func (s *Storage) GetClusterPolicy() (*ClusterPolicy, error) {
+	var policy *ClusterPolicy
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(MetadataBucket).Get([]byte(clusterPolicyKey))
+		if data == nil {
+			return nil
+		}
+		policy = &ClusterPolicy{}
+		if err := json.Unmarshal(data, policy); err != nil {
+			return fmt.Errorf("failed to unmarshal cluster policy: %w", err)
+		}
+		return nil
+	})
+
+	return policy, err
+}
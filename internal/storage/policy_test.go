@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClusterPolicy(t *testing.T) {
+	base := func() *ClusterPolicy {
+		return &ClusterPolicy{
+			HashAlgorithm:   "sha256",
+			EncodingVersion: 1,
+			ExcludedColumns: []string{"updated_at", "created_at"},
+			Tables:          []string{"payments", "audit_log"},
+		}
+	}
+
+	t.Run("DigestIgnoresOrder", func(t *testing.T) {
+		reordered := base()
+		reordered.Tables = []string{"audit_log", "payments"}
+
+		if base().Digest() != reordered.Digest() {
+			t.Error("Expected the same digest for reordered tables")
+		}
+		if diff := base().Diff(reordered); len(diff) != 0 {
+			t.Errorf("Expected no diff, got %v", diff)
+		}
+	})
+
+	t.Run("Diff", func(t *testing.T) {
+		local := base()
+		local.HashAlgorithm = "xxhash64"
+		local.Tables = []string{"audit_log", "orders"}
+
+		if base().Digest() == local.Digest() {
+			t.Error("Expected different digests")
+		}
+
+		diff := base().Diff(local)
+		if len(diff) != 3 {
+			t.Fatalf("Expected 3 differences, got %v", diff)
+		}
+		if !strings.Contains(diff[0], `"sha256"`) || !strings.Contains(diff[0], `"xxhash64"`) {
+			t.Errorf("Expected hash algorithm difference, got %s", diff[0])
+		}
+		if !strings.HasSuffix(diff[1], "missing from local config: payments") {
+			t.Errorf("Expected payments missing locally, got %s", diff[1])
+		}
+		if !strings.HasSuffix(diff[2], "not in cluster policy: orders") {
+			t.Errorf("Expected orders not in cluster policy, got %s", diff[2])
+		}
+	})
+
+	t.Run("Patterns", func(t *testing.T) {
+		if base().Digest() != (&ClusterPolicy{HashAlgorithm: "sha256", EncodingVersion: 1, ExcludedColumns: []string{"created_at", "updated_at"}, Tables: []string{"audit_log", "payments"}, Patterns: []string{}}).Digest() {
+			t.Error("Expected an empty pattern list to keep the digest")
+		}
+
+		local := base()
+		local.Patterns = []string{"*_log"}
+
+		diff := base().Diff(local)
+		if len(diff) != 1 || !strings.HasSuffix(diff[0], "patterns not in cluster policy: *_log") {
+			t.Errorf("Expected *_log not in cluster policy, got %v", diff)
+		}
+	})
+
+	t.Run("SaveAndGet", func(t *testing.T) {
+		store, err := New(filepath.Join(t.TempDir(), "witnz.db"))
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		defer store.Close()
+
+		if policy, err := store.GetClusterPolicy(); err != nil || policy != nil {
+			t.Fatalf("Expected no policy, got %+v (err=%v)", policy, err)
+		}
+
+		if err := store.SaveClusterPolicy(base()); err != nil {
+			t.Fatalf("SaveClusterPolicy failed: %v", err)
+		}
+
+		policy, err := store.GetClusterPolicy()
+		if err != nil {
+			t.Fatalf("GetClusterPolicy failed: %v", err)
+		}
+		if policy == nil || policy.Digest() != base().Digest() {
+			t.Errorf("Expected stored policy %+v, got %+v", base(), policy)
+		}
+	})
+}
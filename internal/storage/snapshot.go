@@ -0,0 +1,319 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// SnapshotVersion is the current snapshot format. Version 1 snapshots were a
+// single JSON document holding only hash entries.
+const SnapshotVersion = 2
+
+const snapshotMagic = "WITNZSNAP"
+
+// maxSnapshotField bounds a single key or value so a corrupt length prefix
+// cannot trigger a huge allocation
+const maxSnapshotField = 256 << 20
+
+// replicatedBuckets holds the state that is part of Raft snapshots
+var replicatedBuckets = [][]byte{
+	MetadataBucket,
+	HashChainBucket,
+	HashLatestBucket,
+	HashLSNBucket,
+	MerkleCheckpointBucket,
+	RowImageBucket,
+	FindingBucket,
+	AllowanceBucket,
+}
+
+// Snapshot is a point-in-time view of the replicated state. It holds a read
+// transaction open until Close is called.
+type Snapshot struct {
+	tx *bolt.Tx
+}
+
+func (s *Storage) Snapshot() (*Snapshot, error) {
+	tx, err := s.db.Begin(false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin snapshot transaction: %w", err)
+	}
+
+	return &Snapshot{tx: tx}, nil
+}
+
+// Write streams the snapshot to w as a gzip-compressed sequence of records
+// followed by a SHA-256 checksum of the uncompressed records
+func (s *Snapshot) Write(w io.Writer) error {
+	if _, err := w.Write(append([]byte(snapshotMagic), SnapshotVersion)); err != nil {
+		return fmt.Errorf("failed to write snapshot header: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	digest := sha256.New()
+	out := bufio.NewWriter(io.MultiWriter(gz, digest))
+
+	for _, name := range replicatedBuckets {
+		bucket := s.tx.Bucket(name)
+		if bucket == nil {
+			continue
+		}
+
+		err := bucket.ForEach(func(k, v []byte) error {
+			if v != nil {
+				return writeSnapshotRecord(out, name, nil, k, v)
+			}
+			return bucket.Bucket(k).ForEach(func(nk, nv []byte) error {
+				return writeSnapshotRecord(out, name, k, nk, nv)
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write bucket %s: %w", name, err)
+		}
+	}
+
+	// An empty bucket name marks the end of the records
+	if err := writeUvarint(out, 0); err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if _, err := gz.Write(digest.Sum(nil)); err != nil {
+		return fmt.Errorf("failed to write snapshot checksum: %w", err)
+	}
+
+	return gz.Close()
+}
+
+func (s *Snapshot) Close() error {
+	return s.tx.Rollback()
+}
+
+func writeSnapshotRecord(w *bufio.Writer, bucket, nested, key, value []byte) error {
+	for _, field := range [][]byte{bucket, nested, key, value} {
+		if err := writeUvarint(w, uint64(len(field))); err != nil {
+			return err
+		}
+		if _, err := w.Write(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeUvarint(w *bufio.Writer, v uint64) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, v)
+	_, err := w.Write(buf[:n])
+	return err
+}
+
+// RestoreSnapshot replaces the replicated state with the contents of a
+// snapshot. The snapshot is applied in a single transaction and is discarded
+// entirely if it is truncated or fails its checksum.
+func (s *Storage) RestoreSnapshot(r io.Reader) error {
+	br := bufio.NewReader(r)
+
+	header, err := br.Peek(len(snapshotMagic) + 1)
+	if err != nil && len(header) == 0 {
+		return fmt.Errorf("failed to read snapshot header: %w", err)
+	}
+
+	if !bytes.HasPrefix(header, []byte(snapshotMagic)) {
+		if bytes.HasPrefix(bytes.TrimSpace(header), []byte("{")) {
+			return s.restoreLegacySnapshot(br)
+		}
+		return fmt.Errorf("unrecognized snapshot format")
+	}
+
+	if version := header[len(snapshotMagic)]; version != SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", version)
+	}
+	if _, err := br.Discard(len(header)); err != nil {
+		return err
+	}
+
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot stream: %w", err)
+	}
+	defer gz.Close()
+
+	in := &hashingReader{r: bufio.NewReader(gz), digest: sha256.New()}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		preserved, err := localFindings(tx)
+		if err != nil {
+			return err
+		}
+
+		if err := resetBuckets(tx, replicatedBuckets); err != nil {
+			return err
+		}
+
+		for {
+			bucketName, err := in.readField()
+			if err != nil {
+				return fmt.Errorf("failed to read snapshot record: %w", err)
+			}
+			if len(bucketName) == 0 {
+				break
+			}
+
+			nested, err := in.readField()
+			if err != nil {
+				return fmt.Errorf("failed to read snapshot record: %w", err)
+			}
+			key, err := in.readField()
+			if err != nil {
+				return fmt.Errorf("failed to read snapshot record: %w", err)
+			}
+			value, err := in.readField()
+			if err != nil {
+				return fmt.Errorf("failed to read snapshot record: %w", err)
+			}
+
+			bucket := tx.Bucket(bucketName)
+			if bucket == nil {
+				return fmt.Errorf("snapshot references unknown bucket %s", bucketName)
+			}
+			if len(nested) > 0 {
+				if bucket, err = bucket.CreateBucketIfNotExists(nested); err != nil {
+					return fmt.Errorf("failed to create bucket %s/%s: %w", bucketName, nested, err)
+				}
+			}
+			if err := bucket.Put(key, value); err != nil {
+				return err
+			}
+		}
+
+		expected := in.digest.Sum(nil)
+		checksum := make([]byte, sha256.Size)
+		if _, err := io.ReadFull(in.r, checksum); err != nil {
+			return fmt.Errorf("failed to read snapshot checksum: %w", err)
+		}
+		if !bytes.Equal(checksum, expected) {
+			return fmt.Errorf("snapshot checksum mismatch")
+		}
+
+		if err := rebuildAllowanceIndex(tx); err != nil {
+			return fmt.Errorf("failed to index allowances: %w", err)
+		}
+
+		return restoreLocalFindings(tx, preserved)
+	})
+}
+
+// restoreLegacySnapshot loads a version 1 snapshot, which only carries hash
+// entries. Other state is left untouched.
+func (s *Storage) restoreLegacySnapshot(r io.Reader) error {
+	var snapshot struct {
+		HashEntries []HashEntry `json:"hash_entries"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := resetBuckets(tx, [][]byte{HashChainBucket, HashLatestBucket, HashLSNBucket}); err != nil {
+			return err
+		}
+
+		for i := range snapshot.HashEntries {
+			if err := putHashEntry(tx, &snapshot.HashEntries[i]); err != nil {
+				return fmt.Errorf("failed to restore hash entry: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func resetBuckets(tx *bolt.Tx, names [][]byte) error {
+	for _, name := range names {
+		if err := tx.DeleteBucket(name); err != nil && err != bolt.ErrBucketNotFound {
+			return fmt.Errorf("failed to clear bucket %s: %w", name, err)
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// localFindings returns findings this node recorded without replication.
+// They describe the leader and must survive a snapshot sent by that leader.
+func localFindings(tx *bolt.Tx) (map[string][]byte, error) {
+	preserved := make(map[string][]byte)
+
+	err := tx.Bucket(FindingBucket).ForEach(func(k, v []byte) error {
+		var finding Finding
+		if err := json.Unmarshal(v, &finding); err != nil {
+			return nil
+		}
+		if finding.IsLocal() {
+			preserved[string(k)] = bytes.Clone(v)
+		}
+		return nil
+	})
+
+	return preserved, err
+}
+
+func restoreLocalFindings(tx *bolt.Tx, preserved map[string][]byte) error {
+	bucket := tx.Bucket(FindingBucket)
+	for id, data := range preserved {
+		if bucket.Get([]byte(id)) != nil {
+			continue
+		}
+		if err := bucket.Put([]byte(id), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hashingReader reads length-prefixed fields and hashes every byte consumed
+type hashingReader struct {
+	r      *bufio.Reader
+	digest hash.Hash
+}
+
+func (h *hashingReader) ReadByte() (byte, error) {
+	b, err := h.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	h.digest.Write([]byte{b})
+	return b, nil
+}
+
+func (h *hashingReader) readField() ([]byte, error) {
+	n, err := binary.ReadUvarint(h)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxSnapshotField {
+		return nil, fmt.Errorf("snapshot field of %d bytes exceeds limit", n)
+	}
+
+	field := make([]byte, n)
+	if _, err := io.ReadFull(h.r, field); err != nil {
+		return nil, err
+	}
+	h.digest.Write(field)
+
+	return field, nil
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -12,6 +13,14 @@ var (
 	HashChainBucket        = []byte("hashchain")
 	MetadataBucket         = []byte("metadata")
 	MerkleCheckpointBucket = []byte("merkle_checkpoint")
+	RowImageBucket         = []byte("row_image")
+	FindingBucket          = []byte("finding")
+	AllowanceBucket        = []byte("allowance")
+	HashLSNBucket          = []byte("hash_lsn")
+	HashLatestBucket       = []byte("hash_latest")
+	// AllowanceRecordBucket indexes allowances by the record they cover. It
+	// is derived from AllowanceBucket and rebuilt rather than replicated.
+	AllowanceRecordBucket = []byte("allowance_record")
 )
 
 type Storage struct {
@@ -25,6 +34,8 @@ type HashEntry struct {
 	Timestamp     time.Time `json:"timestamp"`
 	OperationType string    `json:"operation_type"`
 	RecordID      string    `json:"record_id"`
+	AllowanceID   string    `json:"allowance_id,omitempty"`
+	LSN           uint64    `json:"lsn,omitempty"`
 }
 
 type MerkleCheckpoint struct {
@@ -36,6 +47,36 @@ type MerkleCheckpoint struct {
 	HashAlgorithm string            `json:"hash_algorithm"`
 	LeafMap       map[string]string `json:"leaf_map,omitempty"`
 	InternalNodes map[string]string `json:"internal_nodes,omitempty"`
+	// Partitions holds the subtree of each leaf partition when the table is
+	// partitioned, keyed by partition name
+	Partitions map[string]*PartitionCheckpoint `json:"partitions,omitempty"`
+	// Signatures maps node IDs to their signature over SigningPayload
+	Signatures map[string]string `json:"signatures,omitempty"`
+}
+
+// PartitionCheckpoint is the Merkle subtree of one partition of a
+// partitioned table
+type PartitionCheckpoint struct {
+	MerkleRoot string   `json:"merkle_root"`
+	RecordIDs  []string `json:"record_ids"`
+}
+
+// CheckpointSignature is a node's attestation that it verified a checkpoint
+type CheckpointSignature struct {
+	TableName   string `json:"table_name"`
+	SequenceNum uint64 `json:"sequence_num"`
+	MerkleRoot  string `json:"merkle_root"`
+	NodeID      string `json:"node_id"`
+	Signature   string `json:"signature"`
+}
+
+// SigningPayload returns the canonical bytes nodes sign to attest a checkpoint
+func (c *MerkleCheckpoint) SigningPayload() []byte {
+	return CheckpointSigningPayload(c.TableName, c.SequenceNum, c.MerkleRoot)
+}
+
+func CheckpointSigningPayload(tableName string, seqNum uint64, merkleRoot string) []byte {
+	return []byte(fmt.Sprintf("witnz-checkpoint-v1\n%s\n%d\n%s", tableName, seqNum, merkleRoot))
 }
 
 func New(path string) (*Storage, error) {
@@ -47,12 +88,12 @@ func New(path string) (*Storage, error) {
 	}
 
 	err = db.Update(func(tx *bolt.Tx) error {
-		for _, bucket := range [][]byte{HashChainBucket, MetadataBucket, MerkleCheckpointBucket} {
+		for _, bucket := range [][]byte{HashChainBucket, MetadataBucket, MerkleCheckpointBucket, RowImageBucket, FindingBucket, AllowanceBucket, AllowanceRecordBucket, HashLSNBucket, HashLatestBucket} {
 			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
 				return fmt.Errorf("failed to create bucket: %w", err)
 			}
 		}
-		return nil
+		return migrate(tx)
 	})
 	if err != nil {
 		db.Close()
@@ -68,59 +109,242 @@ func (s *Storage) Close() error {
 
 func (s *Storage) SaveHashEntry(entry *HashEntry) error {
 	return s.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket(HashChainBucket)
+		return putHashEntry(tx, entry)
+	})
+}
 
-		key := fmt.Sprintf("%s:%d", entry.TableName, entry.SequenceNum)
+// AppendHashEntry assigns the next sequence number of the table to entry and
+// stores it. Entries carrying an LSN are de-duplicated by (table, record, LSN):
+// re-applying the same change returns false with the original sequence number
+// set on entry, while a different hash for the same change is rejected.
+// Approved amendments consume their allowance in the same transaction.
+func (s *Storage) AppendHashEntry(entry *HashEntry) (bool, error) {
+	appended := false
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		appended, err = appendHashEntry(tx, entry)
+		return err
+	})
 
-		data, err := json.Marshal(entry)
-		if err != nil {
-			return fmt.Errorf("failed to marshal hash entry: %w", err)
+	return appended, err
+}
+
+// PlaceHashEntry stores an entry at the sequence number it already carries.
+// Re-applying an identical entry returns false, while an entry that differs
+// from the one already held at that sequence number is rejected, so the
+// chain is never rewritten. Approved amendments consume their allowance in
+// the same transaction.
+func (s *Storage) PlaceHashEntry(entry *HashEntry) (bool, error) {
+	placed := false
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if bucket := tableBucket(tx, HashChainBucket, entry.TableName); bucket != nil && bucket.Get(seqKey(entry.SequenceNum)) != nil {
+			existing, err := getHashEntry(tx, entry.TableName, entry.SequenceNum)
+			if err != nil {
+				return err
+			}
+			if existing.DataHash != entry.DataHash ||
+				existing.OperationType != entry.OperationType ||
+				existing.RecordID != entry.RecordID {
+				return fmt.Errorf("conflicting hash entry for %s at sequence %d: already holds %s",
+					entry.TableName, entry.SequenceNum, existing.DataHash)
+			}
+			return nil
 		}
 
-		return bucket.Put([]byte(key), data)
+		if entry.AllowanceID != "" {
+			if err := consumeAllowance(tx, entry); err != nil {
+				return err
+			}
+		}
+		if err := putHashEntry(tx, entry); err != nil {
+			return err
+		}
+		placed = true
+		return nil
 	})
+
+	return placed, err
 }
 
-func (s *Storage) GetHashEntry(tableName string, seqNum uint64) (*HashEntry, error) {
-	var entry HashEntry
+// HashEntryBatchItem is one entry of AppendHashEntries. The sealed row image,
+// if any, is stored alongside the entry when it is appended. Appended and Err
+// report the outcome for the entry.
+type HashEntryBatchItem struct {
+	Entry      *HashEntry
+	RowImageID string
+	RowImage   []byte
 
-	err := s.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket(HashChainBucket)
+	Appended bool
+	Err      error
+}
 
-		key := fmt.Sprintf("%s:%d", tableName, seqNum)
-		data := bucket.Get([]byte(key))
-		if data == nil {
-			return fmt.Errorf("hash entry not found")
+// AppendHashEntries appends a batch of hash entries in a single transaction.
+// An entry that is rejected, for example because it conflicts with an
+// existing entry, records its error and does not prevent the rest of the
+// batch from being stored.
+func (s *Storage) AppendHashEntries(items []*HashEntryBatchItem) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, item := range items {
+			item.Appended, item.Err = appendHashEntry(tx, item.Entry)
+			if !item.Appended || len(item.RowImage) == 0 {
+				continue
+			}
+
+			key := fmt.Sprintf("%s:%s", item.Entry.TableName, item.RowImageID)
+			if err := tx.Bucket(RowImageBucket).Put([]byte(key), item.RowImage); err != nil {
+				return fmt.Errorf("failed to save row image: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// appendHashEntry runs its checks before writing anything, so a rejected
+// entry leaves the transaction untouched
+func appendHashEntry(tx *bolt.Tx, entry *HashEntry) (bool, error) {
+	if entry.LSN != 0 {
+		if seq := tx.Bucket(HashLSNBucket).Get(hashLSNKey(entry)); seq != nil {
+			existing, err := getHashEntry(tx, entry.TableName, binary.BigEndian.Uint64(seq))
+			if err != nil {
+				return false, err
+			}
+			if existing.DataHash != entry.DataHash || existing.OperationType != entry.OperationType {
+				return false, fmt.Errorf("conflicting hash entry for %s record %s at LSN %d: sequence %d already holds %s",
+					entry.TableName, entry.RecordID, entry.LSN, existing.SequenceNum, existing.DataHash)
+			}
+			entry.SequenceNum = existing.SequenceNum
+			return false, nil
+		}
+	}
+
+	entry.SequenceNum = latestSequence(tx, entry.TableName) + 1
+
+	if entry.AllowanceID != "" {
+		if err := consumeAllowance(tx, entry); err != nil {
+			return false, err
+		}
+	}
+
+	if err := putHashEntry(tx, entry); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func putHashEntry(tx *bolt.Tx, entry *HashEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal hash entry: %w", err)
+	}
+
+	bucket, err := createTableBucket(tx, HashChainBucket, entry.TableName)
+	if err != nil {
+		return err
+	}
+
+	key := seqKey(entry.SequenceNum)
+	if err := bucket.Put(key, data); err != nil {
+		return err
+	}
+
+	if entry.SequenceNum > latestSequence(tx, entry.TableName) {
+		if err := tx.Bucket(HashLatestBucket).Put([]byte(entry.TableName), key); err != nil {
+			return err
 		}
+	}
+
+	if entry.LSN == 0 {
+		return nil
+	}
 
-		return json.Unmarshal(data, &entry)
+	return tx.Bucket(HashLSNBucket).Put(hashLSNKey(entry), key)
+}
+
+func getHashEntry(tx *bolt.Tx, tableName string, seqNum uint64) (*HashEntry, error) {
+	bucket := tableBucket(tx, HashChainBucket, tableName)
+	if bucket == nil {
+		return nil, fmt.Errorf("hash entry not found")
+	}
+
+	data := bucket.Get(seqKey(seqNum))
+	if data == nil {
+		return nil, fmt.Errorf("hash entry not found")
+	}
+
+	var entry HashEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal hash entry: %w", err)
+	}
+
+	return &entry, nil
+}
+
+// hashLSNKey identifies a single row change. Table names never contain a
+// colon, so the record ID can safely go last.
+func hashLSNKey(entry *HashEntry) []byte {
+	return []byte(fmt.Sprintf("%s:%d:%s", entry.TableName, entry.LSN, entry.RecordID))
+}
+
+// latestSequence returns the highest sequence number stored for a table
+func latestSequence(tx *bolt.Tx, tableName string) uint64 {
+	key := tx.Bucket(HashLatestBucket).Get([]byte(tableName))
+	if len(key) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(key)
+}
+
+func (s *Storage) GetHashEntry(tableName string, seqNum uint64) (*HashEntry, error) {
+	var entry *HashEntry
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		entry, err = getHashEntry(tx, tableName, seqNum)
+		return err
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &entry, nil
+	return entry, nil
 }
 
-func (s *Storage) GetLatestHashEntry(tableName string) (*HashEntry, error) {
-	var latestEntry *HashEntry
+// GetHashEntryByLSN returns the entry recorded for a change, identified by
+// table, record and LSN, or nil when the change has not been recorded
+func (s *Storage) GetHashEntryByLSN(tableName, recordID string, lsn uint64) (*HashEntry, error) {
+	var entry *HashEntry
 
 	err := s.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket(HashChainBucket)
-		cursor := bucket.Cursor()
+		key := hashLSNKey(&HashEntry{TableName: tableName, RecordID: recordID, LSN: lsn})
+		seq := tx.Bucket(HashLSNBucket).Get(key)
+		if seq == nil {
+			return nil
+		}
 
-		prefix := []byte(tableName + ":")
+		var err error
+		entry, err = getHashEntry(tx, tableName, binary.BigEndian.Uint64(seq))
+		return err
+	})
 
-		for k, v := cursor.Seek(prefix); k != nil && len(k) >= len(prefix) && string(k[:len(prefix)]) == string(prefix); k, v = cursor.Next() {
-			var entry HashEntry
-			if err := json.Unmarshal(v, &entry); err != nil {
-				continue
-			}
-			latestEntry = &entry
+	return entry, err
+}
+
+func (s *Storage) GetLatestHashEntry(tableName string) (*HashEntry, error) {
+	var latestEntry *HashEntry
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		seq := latestSequence(tx, tableName)
+		if seq == 0 {
+			return nil
 		}
 
-		return nil
+		var err error
+		latestEntry, err = getHashEntry(tx, tableName, seq)
+		return err
 	})
 
 	if err != nil {
@@ -134,6 +358,37 @@ func (s *Storage) GetLatestHashEntry(tableName string) (*HashEntry, error) {
 	return latestEntry, nil
 }
 
+// SaveRowImage stores an encrypted row image captured at insert time
+func (s *Storage) SaveRowImage(tableName, recordID string, sealed []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(RowImageBucket)
+		key := fmt.Sprintf("%s:%s", tableName, recordID)
+		return bucket.Put([]byte(key), sealed)
+	})
+}
+
+func (s *Storage) GetRowImage(tableName, recordID string) ([]byte, error) {
+	var sealed []byte
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(RowImageBucket)
+		key := fmt.Sprintf("%s:%s", tableName, recordID)
+		data := bucket.Get([]byte(key))
+		if data == nil {
+			return fmt.Errorf("row image not found for %s id=%s", tableName, recordID)
+		}
+		sealed = make([]byte, len(data))
+		copy(sealed, data)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sealed, nil
+}
+
 func (s *Storage) SetMetadata(key, value string) error {
 	return s.db.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(MetadataBucket)
@@ -157,18 +412,38 @@ func (s *Storage) GetMetadata(key string) (string, error) {
 	return value, err
 }
 
+// SaveMerkleCheckpoint stores a checkpoint. Signatures already collected for
+// the same sequence and root are kept.
 func (s *Storage) SaveMerkleCheckpoint(checkpoint *MerkleCheckpoint) error {
 	return s.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket(MerkleCheckpointBucket)
+		bucket, err := createTableBucket(tx, MerkleCheckpointBucket, checkpoint.TableName)
+		if err != nil {
+			return err
+		}
 
-		key := fmt.Sprintf("%s:%d", checkpoint.TableName, checkpoint.SequenceNum)
+		key := seqKey(checkpoint.SequenceNum)
+
+		if existingData := bucket.Get(key); existingData != nil {
+			var existing MerkleCheckpoint
+			if err := json.Unmarshal(existingData, &existing); err == nil &&
+				existing.MerkleRoot == checkpoint.MerkleRoot && len(existing.Signatures) > 0 {
+				merged := make(map[string]string, len(existing.Signatures)+len(checkpoint.Signatures))
+				for nodeID, sig := range existing.Signatures {
+					merged[nodeID] = sig
+				}
+				for nodeID, sig := range checkpoint.Signatures {
+					merged[nodeID] = sig
+				}
+				checkpoint.Signatures = merged
+			}
+		}
 
 		data, err := json.Marshal(checkpoint)
 		if err != nil {
 			return fmt.Errorf("failed to marshal checkpoint: %w", err)
 		}
 
-		return bucket.Put([]byte(key), data)
+		return bucket.Put(key, data)
 	})
 }
 
@@ -176,19 +451,21 @@ func (s *Storage) GetLatestMerkleCheckpoint(tableName string) (*MerkleCheckpoint
 	var latestCheckpoint *MerkleCheckpoint
 
 	err := s.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket(MerkleCheckpointBucket)
-		cursor := bucket.Cursor()
-
-		prefix := []byte(tableName + ":")
+		bucket := tableBucket(tx, MerkleCheckpointBucket, tableName)
+		if bucket == nil {
+			return nil
+		}
 
-		for k, v := cursor.Seek(prefix); k != nil && len(k) >= len(prefix) && string(k[:len(prefix)]) == string(prefix); k, v = cursor.Next() {
-			var checkpoint MerkleCheckpoint
-			if err := json.Unmarshal(v, &checkpoint); err != nil {
-				continue
-			}
-			latestCheckpoint = &checkpoint
+		_, v := bucket.Cursor().Last()
+		if v == nil {
+			return nil
 		}
 
+		var checkpoint MerkleCheckpoint
+		if err := json.Unmarshal(v, &checkpoint); err != nil {
+			return fmt.Errorf("failed to unmarshal checkpoint: %w", err)
+		}
+		latestCheckpoint = &checkpoint
 		return nil
 	})
 
@@ -203,38 +480,99 @@ func (s *Storage) GetLatestMerkleCheckpoint(tableName string) (*MerkleCheckpoint
 	return latestCheckpoint, nil
 }
 
-func (s *Storage) GetAllHashEntries(tableName string) ([]*HashEntry, error) {
-	entries := make([]*HashEntry, 0)
+func (s *Storage) GetMerkleCheckpoint(tableName string, seqNum uint64) (*MerkleCheckpoint, error) {
+	var checkpoint MerkleCheckpoint
 
 	err := s.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket(HashChainBucket)
-		cursor := bucket.Cursor()
+		var data []byte
+		if bucket := tableBucket(tx, MerkleCheckpointBucket, tableName); bucket != nil {
+			data = bucket.Get(seqKey(seqNum))
+		}
+		if data == nil {
+			return fmt.Errorf("checkpoint not found: %s:%d", tableName, seqNum)
+		}
 
-		prefix := []byte(tableName + ":")
+		return json.Unmarshal(data, &checkpoint)
+	})
 
-		for k, v := cursor.Seek(prefix); k != nil && len(k) >= len(prefix) && string(k[:len(prefix)]) == string(prefix); k, v = cursor.Next() {
-			var entry HashEntry
-			if err := json.Unmarshal(v, &entry); err != nil {
-				continue
-			}
-			entries = append(entries, &entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &checkpoint, nil
+}
+
+// GetMerkleCheckpoints returns all checkpoints for a table ordered by sequence number
+func (s *Storage) GetMerkleCheckpoints(tableName string) ([]*MerkleCheckpoint, error) {
+	checkpoints := make([]*MerkleCheckpoint, 0)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tableBucket(tx, MerkleCheckpointBucket, tableName)
+		if bucket == nil {
+			return nil
 		}
 
-		return nil
+		return bucket.ForEach(func(k, v []byte) error {
+			var checkpoint MerkleCheckpoint
+			if err := json.Unmarshal(v, &checkpoint); err != nil {
+				return nil
+			}
+			checkpoints = append(checkpoints, &checkpoint)
+			return nil
+		})
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return entries, nil
+	return checkpoints, nil
 }
 
-func (s *Storage) GetAllHashEntriesAllTables() ([]HashEntry, error) {
-	entries := make([]HashEntry, 0)
+// AddCheckpointSignature attaches a node signature to a stored checkpoint.
+// The signature is rejected if the checkpoint root does not match.
+func (s *Storage) AddCheckpointSignature(sig *CheckpointSignature) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		key := fmt.Sprintf("%s:%d", sig.TableName, sig.SequenceNum)
+
+		bucket := tableBucket(tx, MerkleCheckpointBucket, sig.TableName)
+		var data []byte
+		if bucket != nil {
+			data = bucket.Get(seqKey(sig.SequenceNum))
+		}
+		if data == nil {
+			return fmt.Errorf("checkpoint not found: %s", key)
+		}
+
+		var checkpoint MerkleCheckpoint
+		if err := json.Unmarshal(data, &checkpoint); err != nil {
+			return fmt.Errorf("failed to unmarshal checkpoint: %w", err)
+		}
+
+		if checkpoint.MerkleRoot != sig.MerkleRoot {
+			return fmt.Errorf("checkpoint %s root mismatch: signed %s, stored %s", key, sig.MerkleRoot, checkpoint.MerkleRoot)
+		}
+
+		if checkpoint.Signatures == nil {
+			checkpoint.Signatures = make(map[string]string)
+		}
+		checkpoint.Signatures[sig.NodeID] = sig.Signature
+
+		updated, err := json.Marshal(&checkpoint)
+		if err != nil {
+			return fmt.Errorf("failed to marshal checkpoint: %w", err)
+		}
+
+		return bucket.Put(seqKey(sig.SequenceNum), updated)
+	})
+}
+
+// GetAllHashEntries returns the hash entries of a table ordered by sequence number
+func (s *Storage) GetAllHashEntries(tableName string) ([]*HashEntry, error) {
+	entries := make([]*HashEntry, 0)
 
 	err := s.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket(HashChainBucket)
+		bucket := tableBucket(tx, HashChainBucket, tableName)
 		if bucket == nil {
 			return nil
 		}
@@ -244,7 +582,7 @@ func (s *Storage) GetAllHashEntriesAllTables() ([]HashEntry, error) {
 			if err := json.Unmarshal(v, &entry); err != nil {
 				return nil
 			}
-			entries = append(entries, entry)
+			entries = append(entries, &entry)
 			return nil
 		})
 	})
@@ -255,3 +593,28 @@ func (s *Storage) GetAllHashEntriesAllTables() ([]HashEntry, error) {
 
 	return entries, nil
 }
+
+func (s *Storage) GetAllHashEntriesAllTables() ([]HashEntry, error) {
+	entries := make([]HashEntry, 0)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		chain := tx.Bucket(HashChainBucket)
+
+		return chain.ForEachBucket(func(name []byte) error {
+			return chain.Bucket(name).ForEach(func(k, v []byte) error {
+				var entry HashEntry
+				if err := json.Unmarshal(v, &entry); err != nil {
+					return nil
+				}
+				entries = append(entries, entry)
+				return nil
+			})
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -66,6 +67,99 @@ func TestStorage(t *testing.T) {
 		}
 	})
 
+	t.Run("AppendHashEntry", func(t *testing.T) {
+		for i := 1; i <= 11; i++ {
+			entry := &HashEntry{
+				TableName:     "append_table",
+				DataHash:      fmt.Sprintf("hash%d", i),
+				Timestamp:     time.Now(),
+				OperationType: "INSERT",
+				RecordID:      fmt.Sprintf("%d", i),
+				LSN:           uint64(1000 + i),
+			}
+			appended, err := storage.AppendHashEntry(entry)
+			if err != nil {
+				t.Fatalf("AppendHashEntry failed: %v", err)
+			}
+			if !appended || entry.SequenceNum != uint64(i) {
+				t.Fatalf("Expected entry appended at sequence %d, got %d (appended=%v)", i, entry.SequenceNum, appended)
+			}
+		}
+
+		duplicate := &HashEntry{TableName: "append_table", DataHash: "hash9", OperationType: "INSERT", RecordID: "9", LSN: 1009}
+		appended, err := storage.AppendHashEntry(duplicate)
+		if err != nil {
+			t.Fatalf("AppendHashEntry failed for duplicate: %v", err)
+		}
+		if appended || duplicate.SequenceNum != 9 {
+			t.Errorf("Expected duplicate to resolve to sequence 9, got %d (appended=%v)", duplicate.SequenceNum, appended)
+		}
+
+		conflict := &HashEntry{TableName: "append_table", DataHash: "other", OperationType: "INSERT", RecordID: "9", LSN: 1009}
+		if _, err := storage.AppendHashEntry(conflict); err == nil {
+			t.Error("Expected conflicting entry to be rejected")
+		}
+
+		found, err := storage.GetHashEntryByLSN("append_table", "9", 1009)
+		if err != nil || found == nil || found.SequenceNum != 9 {
+			t.Errorf("Expected lookup by LSN to find sequence 9, got %+v (err=%v)", found, err)
+		}
+		if missing, err := storage.GetHashEntryByLSN("append_table", "9", 9999); err != nil || missing != nil {
+			t.Errorf("Expected no entry for unknown LSN, got %+v (err=%v)", missing, err)
+		}
+	})
+
+	t.Run("AppendHashEntries", func(t *testing.T) {
+		items := []*HashEntryBatchItem{
+			{Entry: &HashEntry{TableName: "batch_table", DataHash: "h1", OperationType: "INSERT", RecordID: "1", LSN: 2001}},
+			{Entry: &HashEntry{TableName: "batch_table", DataHash: "h2", OperationType: "INSERT", RecordID: "2", LSN: 2002}, RowImageID: "2", RowImage: []byte{0x02}},
+			{Entry: &HashEntry{TableName: "batch_table", DataHash: "other", OperationType: "INSERT", RecordID: "1", LSN: 2001}},
+			{Entry: &HashEntry{TableName: "batch_table", DataHash: "h3", OperationType: "INSERT", RecordID: "3", LSN: 2003}},
+		}
+
+		if err := storage.AppendHashEntries(items); err != nil {
+			t.Fatalf("AppendHashEntries failed: %v", err)
+		}
+
+		for i, want := range []uint64{1, 2, 0, 3} {
+			item := items[i]
+			if want == 0 {
+				if item.Err == nil || item.Appended {
+					t.Errorf("Expected item %d to be rejected as a conflict", i)
+				}
+				continue
+			}
+			if item.Err != nil || !item.Appended || item.Entry.SequenceNum != want {
+				t.Errorf("Expected item %d appended at sequence %d, got %d (err=%v)", i, want, item.Entry.SequenceNum, item.Err)
+			}
+		}
+
+		if image, err := storage.GetRowImage("batch_table", "2"); err != nil || image[0] != 0x02 {
+			t.Errorf("Expected row image to be stored with the batch, got %x (err=%v)", image, err)
+		}
+	})
+
+	t.Run("SaveAndGetRowImage", func(t *testing.T) {
+		sealed := []byte{0x01, 0x02, 0x03}
+
+		if err := storage.SaveRowImage("test_table", "42", sealed); err != nil {
+			t.Fatalf("SaveRowImage failed: %v", err)
+		}
+
+		retrieved, err := storage.GetRowImage("test_table", "42")
+		if err != nil {
+			t.Fatalf("GetRowImage failed: %v", err)
+		}
+
+		if string(retrieved) != string(sealed) {
+			t.Errorf("Expected row image %x, got %x", sealed, retrieved)
+		}
+
+		if _, err := storage.GetRowImage("test_table", "43"); err == nil {
+			t.Error("GetRowImage should fail for unknown record")
+		}
+	})
+
 	t.Run("SetAndGetMetadata", func(t *testing.T) {
 		key := "test_key"
 		value := "test_value"
@@ -83,4 +177,82 @@ func TestStorage(t *testing.T) {
 			t.Errorf("Expected value %s, got %s", value, retrieved)
 		}
 	})
+
+	t.Run("SaveAndGetProtectedTables", func(t *testing.T) {
+		if tables, err := storage.GetProtectedTables(); err != nil || tables != nil {
+			t.Fatalf("Expected no replicated table set, got %+v (err=%v)", tables, err)
+		}
+
+		want := []ProtectedTable{
+			{Name: "audit_log", VerifyInterval: "30m"},
+			{Name: "payments", StoreRowImages: true},
+		}
+		if err := storage.SaveProtectedTables(want); err != nil {
+			t.Fatalf("SaveProtectedTables failed: %v", err)
+		}
+
+		tables, err := storage.GetProtectedTables()
+		if err != nil {
+			t.Fatalf("GetProtectedTables failed: %v", err)
+		}
+		if len(tables) != 2 || tables[0] != want[0] || tables[1] != want[1] {
+			t.Errorf("Expected %+v, got %+v", want, tables)
+		}
+
+		if err := storage.SaveProtectedTables(nil); err != nil {
+			t.Fatalf("SaveProtectedTables failed: %v", err)
+		}
+		if tables, err := storage.GetProtectedTables(); err != nil || tables == nil || len(tables) != 0 {
+			t.Errorf("Expected an empty table set, got %+v (err=%v)", tables, err)
+		}
+	})
+}
+
+func TestQualifiedTable(t *testing.T) {
+	t.Run("DefaultSource", func(t *testing.T) {
+		name := QualifiedTable("", "audit_log")
+		if name != "audit_log" {
+			t.Errorf("Expected audit_log, got %s", name)
+		}
+		if source, table := SplitTable(name); source != "" || table != "audit_log" {
+			t.Errorf("Expected default source and audit_log, got %q and %q", source, table)
+		}
+	})
+
+	t.Run("NamedSource", func(t *testing.T) {
+		name := QualifiedTable("billing", "audit_log")
+		if name != "billing/audit_log" {
+			t.Errorf("Expected billing/audit_log, got %s", name)
+		}
+		if source, table := SplitTable(name); source != "billing" || table != "audit_log" {
+			t.Errorf("Expected billing and audit_log, got %q and %q", source, table)
+		}
+	})
+
+	t.Run("SchemaTable", func(t *testing.T) {
+		name := QualifiedTable("billing", "audit.events")
+		source, table := SplitTable(name)
+		if source != "billing" || table != "audit.events" {
+			t.Errorf("Expected billing and audit.events, got %q and %q", source, table)
+		}
+		if schema, table := SplitSchema(table); schema != "audit" || table != "events" {
+			t.Errorf("Expected audit and events, got %q and %q", schema, table)
+		}
+		if schema, table := SplitSchema("audit_log"); schema != "" || table != "audit_log" {
+			t.Errorf("Expected no schema and audit_log, got %q and %q", schema, table)
+		}
+	})
+
+	t.Run("ValidTableName", func(t *testing.T) {
+		for _, name := range []string{"audit_log", "billing/audit_log", "orders_v2/Payments", "audit.events", "billing/audit.events"} {
+			if !ValidTableName(name) {
+				t.Errorf("Expected %q to be valid", name)
+			}
+		}
+		for _, name := range []string{"", "audit log", "billing/", "/audit_log", "a/b/c", "Billing/audit_log", "a.b.c", ".audit_log", "audit.", "audit.events/log"} {
+			if ValidTableName(name) {
+				t.Errorf("Expected %q to be invalid", name)
+			}
+		}
+	})
 }
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// SourceSeparator joins a source name and a table name. Tables of a named
+// source are stored, replicated and reported as "source/table"; tables of
+// the default source keep their plain name.
+const SourceSeparator = "/"
+
+// SchemaSeparator joins a schema and a table name. A table whose plain name
+// does not resolve to it on the search path is referred to as
+// "schema.table".
+const SchemaSeparator = "."
+
+var validTableName = regexp.MustCompile(`^([a-z][a-z0-9_]*/)?([a-zA-Z_][a-zA-Z0-9_]*\.)?[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// QualifiedTable returns the name a table of source is stored under
+func QualifiedTable(source, table string) string {
+	if source == "" {
+		return table
+	}
+	return source + SourceSeparator + table
+}
+
+// SplitTable splits a stored table name into its source and its name in
+// that source's database
+func SplitTable(name string) (source, table string) {
+	if i := strings.Index(name, SourceSeparator); i >= 0 {
+		return name[:i], name[i+len(SourceSeparator):]
+	}
+	return "", name
+}
+
+// SplitSchema splits a table name in a source's database into its schema,
+// which is empty for plain names, and the table
+func SplitSchema(table string) (schema, name string) {
+	if i := strings.Index(table, SchemaSeparator); i >= 0 {
+		return table[:i], table[i+len(SchemaSeparator):]
+	}
+	return "", table
+}
+
+// ValidTableName reports whether name is a table name, optionally
+// qualified by a schema and a source
+func ValidTableName(name string) bool {
+	return validTableName.MatchString(name)
+}
+
+// protectedTablesKey is the metadata key of the replicated table set
+const protectedTablesKey = "protected_tables"
+
+// ProtectedTable is one entry of the table set replicated through Raft. It
+// mirrors the protected_tables section of the config. Pattern is set for
+// tables added because they matched a table pattern.
+type ProtectedTable struct {
+	Name           string `json:"name"`
+	Pattern        string `json:"pattern,omitempty"`
+	VerifyInterval string `json:"verify_interval,omitempty"`
+	StoreRowImages bool   `json:"store_row_images,omitempty"`
+}
+
+// SaveProtectedTables replaces the replicated table set
+func (s *Storage) SaveProtectedTables(tables []ProtectedTable) error {
+	data, err := json.Marshal(tables)
+	if err != nil {
+		return fmt.Errorf("failed to marshal protected tables: %w", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(MetadataBucket).Put([]byte(protectedTablesKey), data)
+	})
+}
+
+// GetProtectedTables returns the replicated table set, or nil if the set was
+// never changed through Raft and the config applies
+func (s *Storage) GetProtectedTables() ([]ProtectedTable, error) {
+	var tables []ProtectedTable
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(MetadataBucket).Get([]byte(protectedTablesKey))
+		if data == nil {
+			return nil
+		}
+		if err := json.Unmarshal(data, &tables); err != nil {
+			return fmt.Errorf("failed to unmarshal protected tables: %w", err)
+		}
+		if tables == nil {
+			tables = []ProtectedTable{}
+		}
+		return nil
+	})
+
+	return tables, err
+}
@@ -0,0 +1,130 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/witnz/witnz/internal/allowance"
+	"github.com/witnz/witnz/internal/cdc"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+// AllowanceReplicator replicates submitted allowances via Raft
+type AllowanceReplicator interface {
+	IsLeader() bool
+	ApplyAllowance(a *storage.Allowance) error
+}
+
+// AllowanceRegistry accepts signed allowances and matches them against
+// UPDATE and DELETE events on protected tables
+type AllowanceRegistry struct {
+	storage    *storage.Storage
+	verifier   *allowance.Verifier
+	replicator AllowanceReplicator
+	mu         sync.RWMutex
+}
+
+func NewAllowanceRegistry(store *storage.Storage, verifier *allowance.Verifier) *AllowanceRegistry {
+	return &AllowanceRegistry{
+		storage:  store,
+		verifier: verifier,
+	}
+}
+
+// SetRaftNode sets the Raft node used to replicate allowances
+func (r *AllowanceRegistry) SetRaftNode(node AllowanceReplicator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replicator = node
+}
+
+// Submit verifies an allowance and stores it. In Raft mode it must be
+// submitted to the leader.
+func (r *AllowanceRegistry) Submit(a *storage.Allowance) error {
+	now := time.Now()
+	if err := r.verifier.Verify(a, now); err != nil {
+		return err
+	}
+
+	if _, err := r.storage.GetAllowance(a.ID); err == nil {
+		return fmt.Errorf("allowance already exists: %s", a.ID)
+	} else if !errors.Is(err, storage.ErrAllowanceNotFound) {
+		return fmt.Errorf("failed to get allowance: %w", err)
+	}
+
+	a.SubmittedAt = now
+	a.ConsumedAt = time.Time{}
+	a.ConsumedLSN = 0
+
+	r.mu.RLock()
+	replicator := r.replicator
+	r.mu.RUnlock()
+
+	if replicator == nil {
+		return r.storage.SaveAllowance(a)
+	}
+
+	if !replicator.IsLeader() {
+		return fmt.Errorf("not the leader, cannot accept allowance")
+	}
+
+	if err := replicator.ApplyAllowance(a); err != nil {
+		return fmt.Errorf("failed to replicate allowance via raft: %w", err)
+	}
+
+	return nil
+}
+
+// Match returns the allowance covering a change to a record, or nil if the
+// change is not authorized. recordedHash returns the hash the chain holds
+// for the record; it is only called when an unconsumed allowance names the
+// record, so unauthorized changes are rejected without reading the chain.
+// An allowance already consumed by the same LSN is returned as well, so
+// nodes that see the event after the leader recorded the amendment do not
+// report it.
+func (r *AllowanceRegistry) Match(event *cdc.ChangeEvent, recordID string, recordedHash func() (string, bool, error)) (*storage.Allowance, error) {
+	if r == nil {
+		return nil, nil
+	}
+
+	operation := string(event.Operation)
+
+	allowances, err := r.storage.FindAllowances(event.TableName, recordID, operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find allowances: %w", err)
+	}
+
+	var pending []*storage.Allowance
+	for _, a := range allowances {
+		if !a.Consumed() {
+			pending = append(pending, a)
+			continue
+		}
+		if event.LSN != 0 && a.ConsumedLSN == event.LSN {
+			return a, nil
+		}
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	oldHash, ok, err := recordedHash()
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	// Allowances are checked in expiry order, like ListAllowances returns them
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].ExpiresAt.Before(pending[j].ExpiresAt)
+	})
+	for _, a := range pending {
+		if a.Matches(event.TableName, recordID, operation, oldHash, event.Timestamp) {
+			return a, nil
+		}
+	}
+
+	return nil, nil
+}
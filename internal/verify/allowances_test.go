@@ -0,0 +1,191 @@
+package verify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/witnz/witnz/internal/allowance"
+	"github.com/witnz/witnz/internal/cdc"
+	"github.com/witnz/witnz/internal/hash"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+func TestHandlerApprovedAmendment(t *testing.T) {
+	store := newFindingTestStore(t)
+
+	publicKey, privateKey, err := allowance.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	verifier, err := allowance.NewVerifier(map[string]string{"compliance": publicKey})
+	if err != nil {
+		t.Fatalf("NewVerifier failed: %v", err)
+	}
+
+	registry := NewAllowanceRegistry(store, verifier)
+	handler := NewHashChainHandler(store)
+	handler.SetFindingRecorder(NewFindingRecorder(store, "node1"))
+	handler.SetAllowanceRegistry(registry)
+	handler.AddTable(&TableConfig{Name: "users"})
+
+	original := map[string]interface{}{"id": "1", "email": "alice@example.com"}
+	insert := &cdc.ChangeEvent{
+		TableName:  "users",
+		Operation:  cdc.OperationInsert,
+		Timestamp:  time.Now(),
+		NewData:    original,
+		PrimaryKey: map[string]interface{}{"id": "1"},
+	}
+	if err := handler.HandleChange(insert); err != nil {
+		t.Fatalf("HandleChange(INSERT) failed: %v", err)
+	}
+
+	a := &storage.Allowance{
+		ID:           "al-1",
+		TableName:    "users",
+		RecordID:     "1",
+		Operation:    string(cdc.OperationUpdate),
+		ExpectedHash: hash.CalculateDataHash(original),
+		ExpiresAt:    time.Now().Add(time.Hour),
+		Approver:     "compliance",
+	}
+	if err := allowance.Sign(a, privateKey); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if err := registry.Submit(a); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	corrected := map[string]interface{}{"id": "1", "email": "alice@example.org"}
+	update := &cdc.ChangeEvent{
+		TableName:  "users",
+		Operation:  cdc.OperationUpdate,
+		Timestamp:  time.Now(),
+		NewData:    corrected,
+		PrimaryKey: map[string]interface{}{"id": "1"},
+		LSN:        100,
+	}
+
+	t.Run("MatchingChangeIsRecorded", func(t *testing.T) {
+		if err := handler.HandleChange(update); err != nil {
+			t.Fatalf("Expected approved update, got: %v", err)
+		}
+
+		entries, err := store.GetAllHashEntries("users")
+		if err != nil {
+			t.Fatalf("GetAllHashEntries failed: %v", err)
+		}
+
+		leafMap := make(map[string]string)
+		applyHashEntries(leafMap, entries, 0)
+		if leafMap["1"] != hash.CalculateDataHash(corrected) {
+			t.Error("Expected amendment to replace the recorded hash")
+		}
+
+		consumed, err := store.GetAllowance("al-1")
+		if err != nil {
+			t.Fatalf("GetAllowance failed: %v", err)
+		}
+		if !consumed.Consumed() || consumed.ConsumedLSN != 100 {
+			t.Errorf("Expected allowance consumed at LSN 100, got %d", consumed.ConsumedLSN)
+		}
+
+		findings, _ := store.ListFindings("")
+		if len(findings) != 0 {
+			t.Errorf("Expected no findings for an approved change, got %d", len(findings))
+		}
+	})
+
+	t.Run("SameEventIsNotRecordedTwice", func(t *testing.T) {
+		before, _ := store.GetAllHashEntries("users")
+		if err := handler.HandleChange(update); err != nil {
+			t.Fatalf("Expected redelivered event to be accepted, got: %v", err)
+		}
+		after, _ := store.GetAllHashEntries("users")
+		if len(after) != len(before) {
+			t.Errorf("Expected no new entries, got %d -> %d", len(before), len(after))
+		}
+	})
+
+	t.Run("AllowanceIsSingleUse", func(t *testing.T) {
+		again := *update
+		again.LSN = 200
+		if err := handler.HandleChange(&again); !IsTamperingError(err) {
+			t.Fatalf("Expected TamperingError after allowance was used, got: %v", err)
+		}
+	})
+
+	t.Run("UnsignedAllowanceRejected", func(t *testing.T) {
+		forged := *a
+		forged.ID = "al-2"
+		forged.Operation = string(cdc.OperationDelete)
+		if err := registry.Submit(&forged); err == nil {
+			t.Error("Expected forged allowance to be rejected")
+		}
+	})
+}
+
+func TestAllowanceRegistryMatch(t *testing.T) {
+	store := newFindingTestStore(t)
+	registry := NewAllowanceRegistry(store, nil)
+
+	if err := store.SaveAllowance(&storage.Allowance{
+		ID:           "al-1",
+		TableName:    "users",
+		RecordID:     "1",
+		Operation:    string(cdc.OperationDelete),
+		ExpectedHash: "abc",
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("SaveAllowance failed: %v", err)
+	}
+
+	event := &cdc.ChangeEvent{TableName: "users", Operation: cdc.OperationDelete, Timestamp: time.Now(), LSN: 100}
+
+	t.Run("UnapprovedRecordSkipsChain", func(t *testing.T) {
+		a, err := registry.Match(event, "2", func() (string, bool, error) {
+			t.Error("Expected the chain not to be read without a matching allowance")
+			return "", false, nil
+		})
+		if a != nil || err != nil {
+			t.Errorf("Expected no allowance, got %v (err=%v)", a, err)
+		}
+	})
+
+	t.Run("RecordedHashMustMatch", func(t *testing.T) {
+		a, _ := registry.Match(event, "1", func() (string, bool, error) { return "other", true, nil })
+		if a != nil {
+			t.Errorf("Expected a hash mismatch to reject the allowance, got %v", a)
+		}
+
+		a, err := registry.Match(event, "1", func() (string, bool, error) { return "abc", true, nil })
+		if err != nil || a == nil || a.ID != "al-1" {
+			t.Errorf("Expected al-1, got %v (err=%v)", a, err)
+		}
+	})
+}
+
+func TestApplyHashEntries(t *testing.T) {
+	entries := []*storage.HashEntry{
+		{SequenceNum: 3, RecordID: "map[id:1]", OperationType: "DELETE", AllowanceID: "al-1"},
+		{SequenceNum: 1, RecordID: "map[id:1]", OperationType: "INSERT", DataHash: "h1"},
+		{SequenceNum: 2, RecordID: "map[id:2]", OperationType: "INSERT", DataHash: "h2"},
+		{SequenceNum: 4, RecordID: "map[id:2]", OperationType: "UPDATE", DataHash: "h2b", AllowanceID: "al-2"},
+	}
+
+	leafMap := make(map[string]string)
+	applyHashEntries(leafMap, entries, 0)
+
+	if _, ok := leafMap["1"]; ok {
+		t.Error("Expected approved delete to remove record 1")
+	}
+	if leafMap["2"] != "h2b" {
+		t.Errorf("Expected record 2 hash h2b, got %s", leafMap["2"])
+	}
+
+	fromCheckpoint := map[string]string{"1": "h1", "2": "h2"}
+	applyHashEntries(fromCheckpoint, entries, 3)
+	if fromCheckpoint["2"] != "h2b" || fromCheckpoint["1"] != "h1" {
+		t.Errorf("Expected only entries after the checkpoint to apply, got %v", fromCheckpoint)
+	}
+}
@@ -0,0 +1,148 @@
+package verify
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/witnz/witnz/internal/cdc"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+// FindingReplicator replicates findings and their workflow changes via Raft
+type FindingReplicator interface {
+	IsLeader() bool
+	ApplyFinding(finding *storage.Finding) error
+	ApplyFindingStatus(id string, status storage.FindingStatus, actor, note string) error
+}
+
+// FindingRecorder persists tamper findings produced by the CDC handler and
+// the Merkle verifier. In Raft mode only the leader records findings so the
+// cluster keeps a single replicated copy of each.
+type FindingRecorder struct {
+	storage    *storage.Storage
+	nodeID     string
+	replicator FindingReplicator
+	mu         sync.RWMutex
+}
+
+func NewFindingRecorder(store *storage.Storage, nodeID string) *FindingRecorder {
+	return &FindingRecorder{
+		storage: store,
+		nodeID:  nodeID,
+	}
+}
+
+// SetRaftNode sets the Raft node used to replicate findings
+func (r *FindingRecorder) SetRaftNode(node FindingReplicator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replicator = node
+}
+
+// Record fills in the node, time and workflow state of a finding and stores it
+func (r *FindingRecorder) Record(finding *storage.Finding) error {
+	if finding.DetectedAt.IsZero() {
+		finding.DetectedAt = time.Now()
+	}
+	if finding.ID == "" {
+		finding.ID = storage.NewFindingID(finding.DetectedAt)
+	}
+	finding.NodeID = r.nodeID
+	finding.Status = storage.FindingOpen
+
+	r.mu.RLock()
+	replicator := r.replicator
+	r.mu.RUnlock()
+
+	if replicator == nil {
+		return r.storage.SaveFinding(finding)
+	}
+
+	if !replicator.IsLeader() {
+		logger.Debug("Finding detected on follower, leader records it via Raft",
+			"table", finding.TableName,
+			"kind", finding.Kind,
+			"record_id", finding.RecordID)
+		return nil
+	}
+
+	if err := replicator.ApplyFinding(finding); err != nil {
+		return fmt.Errorf("failed to replicate finding via raft: %w", err)
+	}
+
+	return nil
+}
+
+// RecordLocal stores a finding on this node only. It is used for findings
+// about the leader itself, which the leader cannot be trusted to replicate.
+func (r *FindingRecorder) RecordLocal(finding *storage.Finding) error {
+	if finding.DetectedAt.IsZero() {
+		finding.DetectedAt = time.Now()
+	}
+	if finding.ID == "" {
+		finding.ID = storage.NewFindingID(finding.DetectedAt)
+	}
+	finding.NodeID = r.nodeID
+	finding.Status = storage.FindingOpen
+	finding.Local = true
+
+	return r.storage.SaveFinding(finding)
+}
+
+// UpdateStatus acknowledges or resolves a finding. Local findings are
+// never replicated, so their status is updated on this node only.
+func (r *FindingRecorder) UpdateStatus(id string, status storage.FindingStatus, actor, note string) error {
+	r.mu.RLock()
+	replicator := r.replicator
+	r.mu.RUnlock()
+
+	if replicator == nil {
+		return r.storage.UpdateFindingStatus(id, status, actor, note, time.Now())
+	}
+
+	if finding, err := r.storage.GetFinding(id); err == nil && finding.IsLocal() {
+		return r.storage.UpdateFindingStatus(id, status, actor, note, time.Now())
+	}
+
+	if !replicator.IsLeader() {
+		return fmt.Errorf("not the leader, cannot update finding")
+	}
+
+	return replicator.ApplyFindingStatus(id, status, actor, note)
+}
+
+// ObserveDetectorTamper records a detector tamper found by the CDC
+// watchdog. Each node has its own replication slot, so slot findings are
+// kept on the node whose slot was tampered with.
+func (r *FindingRecorder) ObserveDetectorTamper(tamper *cdc.DetectorTamper) {
+	finding := &storage.Finding{
+		TableName:  tamper.Table,
+		Kind:       storage.FindingDetectorTamper,
+		DetectedBy: storage.DetectedByWatchdog,
+		Details:    fmt.Sprintf("%s: %s", tamper.Check, tamper.Details),
+	}
+
+	if tamper.Check != cdc.CheckSlot {
+		r.recordQuietly(finding)
+		return
+	}
+	if err := r.RecordLocal(finding); err != nil {
+		logger.Error("Failed to record finding",
+			"table", finding.TableName,
+			"kind", finding.Kind,
+			"error", err)
+	}
+}
+
+func (r *FindingRecorder) recordQuietly(finding *storage.Finding) {
+	if r == nil {
+		return
+	}
+	if err := r.Record(finding); err != nil {
+		logger.Error("Failed to record finding",
+			"table", finding.TableName,
+			"kind", finding.Kind,
+			"error", err)
+	}
+}
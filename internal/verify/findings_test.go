@@ -0,0 +1,196 @@
+package verify
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/witnz/witnz/internal/cdc"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+type mockFindingReplicator struct {
+	leader   bool
+	findings []*storage.Finding
+	statuses []storage.FindingStatus
+}
+
+func (m *mockFindingReplicator) IsLeader() bool {
+	return m.leader
+}
+
+func (m *mockFindingReplicator) ApplyFinding(finding *storage.Finding) error {
+	m.findings = append(m.findings, finding)
+	return nil
+}
+
+func (m *mockFindingReplicator) ApplyFindingStatus(id string, status storage.FindingStatus, actor, note string) error {
+	if !m.leader {
+		return fmt.Errorf("not the leader")
+	}
+	m.statuses = append(m.statuses, status)
+	return nil
+}
+
+func newFindingTestStore(t *testing.T) *storage.Storage {
+	tmpfile, err := os.CreateTemp("", "witnz-verify-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	t.Cleanup(func() { os.Remove(tmpfile.Name()) })
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+func TestHandlerRecordsFindings(t *testing.T) {
+	store := newFindingTestStore(t)
+
+	recorder := NewFindingRecorder(store, "node1")
+	handler := NewHashChainHandler(store)
+	handler.SetFindingRecorder(recorder)
+	handler.AddTable(&TableConfig{Name: "audit_log"})
+
+	event := &cdc.ChangeEvent{
+		TableName:  "audit_log",
+		Operation:  cdc.OperationUpdate,
+		Timestamp:  time.Now(),
+		OldData:    map[string]interface{}{"id": "5", "action": "login"},
+		NewData:    map[string]interface{}{"id": "5", "action": "logout"},
+		PrimaryKey: map[string]interface{}{"id": "5"},
+		LSN:        1234,
+	}
+
+	if err := handler.HandleChange(event); !IsTamperingError(err) {
+		t.Fatalf("Expected TamperingError, got: %v", err)
+	}
+
+	truncate := &cdc.ChangeEvent{
+		TableName: "audit_log",
+		Operation: cdc.OperationTruncate,
+		Timestamp: time.Now(),
+	}
+	if err := handler.HandleChange(truncate); !IsTamperingError(err) {
+		t.Fatalf("Expected TamperingError for TRUNCATE, got: %v", err)
+	}
+
+	findings, err := store.ListFindings(storage.FindingOpen)
+	if err != nil {
+		t.Fatalf("ListFindings failed: %v", err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("Expected 2 findings, got %d", len(findings))
+	}
+
+	update := findings[0]
+	if update.Kind != storage.FindingUpdate || update.DetectedBy != storage.DetectedByCDC {
+		t.Errorf("Unexpected finding kind=%s detected_by=%s", update.Kind, update.DetectedBy)
+	}
+	if update.RecordID != "5" || update.LSN != 1234 || update.NodeID != "node1" {
+		t.Errorf("Unexpected finding record=%s lsn=%d node=%s", update.RecordID, update.LSN, update.NodeID)
+	}
+	if update.ExpectedHash == "" || update.ActualHash == "" || update.ExpectedHash == update.ActualHash {
+		t.Error("Expected distinct evidence hashes for the old and new row")
+	}
+	if findings[1].Kind != storage.FindingTruncate {
+		t.Errorf("Expected truncate finding, got %s", findings[1].Kind)
+	}
+}
+
+func TestFindingRecorderRaft(t *testing.T) {
+	store := newFindingTestStore(t)
+
+	t.Run("LeaderReplicates", func(t *testing.T) {
+		replicator := &mockFindingReplicator{leader: true}
+		recorder := NewFindingRecorder(store, "node1")
+		recorder.SetRaftNode(replicator)
+
+		if err := recorder.Record(&storage.Finding{TableName: "audit_log", Kind: storage.FindingDeleted}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+		if len(replicator.findings) != 1 {
+			t.Fatalf("Expected finding to be replicated, got %d", len(replicator.findings))
+		}
+		if replicator.findings[0].ID == "" || replicator.findings[0].Status != storage.FindingOpen {
+			t.Error("Expected finding to have an ID and open status")
+		}
+	})
+
+	t.Run("FollowerSkips", func(t *testing.T) {
+		replicator := &mockFindingReplicator{leader: false}
+		recorder := NewFindingRecorder(store, "node2")
+		recorder.SetRaftNode(replicator)
+
+		if err := recorder.Record(&storage.Finding{TableName: "audit_log", Kind: storage.FindingDeleted}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+		if len(replicator.findings) != 0 {
+			t.Error("Follower should not replicate findings")
+		}
+		if err := recorder.UpdateStatus("any", storage.FindingAcknowledged, "alice", ""); err == nil {
+			t.Error("Follower should not update finding status")
+		}
+	})
+
+	t.Run("DetectorTamper", func(t *testing.T) {
+		store := newFindingTestStore(t)
+		replicator := &mockFindingReplicator{leader: false}
+		recorder := NewFindingRecorder(store, "node2")
+		recorder.SetRaftNode(replicator)
+
+		recorder.ObserveDetectorTamper(&cdc.DetectorTamper{Table: "audit_log", Check: cdc.CheckPublication, Details: "table was removed from publication witnz_publication"})
+		recorder.ObserveDetectorTamper(&cdc.DetectorTamper{Table: "*", Check: cdc.CheckSlot, Details: "replication slot witnz_node2 was dropped"})
+
+		if len(replicator.findings) != 0 {
+			t.Errorf("Follower should leave publication findings to the leader, got %d", len(replicator.findings))
+		}
+		findings, _ := store.ListFindings("")
+		if len(findings) != 1 {
+			t.Fatalf("Expected the slot finding to be kept locally, got %d", len(findings))
+		}
+		f := findings[0]
+		if f.Kind != storage.FindingDetectorTamper || f.DetectedBy != storage.DetectedByWatchdog || f.Details != "slot: replication slot witnz_node2 was dropped" || !f.Local {
+			t.Errorf("Unexpected finding: %+v", f)
+		}
+	})
+
+	t.Run("LocalStatus", func(t *testing.T) {
+		store := newFindingTestStore(t)
+		replicator := &mockFindingReplicator{leader: true}
+		recorder := NewFindingRecorder(store, "node1")
+		recorder.SetRaftNode(replicator)
+
+		recorder.ObserveDetectorTamper(&cdc.DetectorTamper{Table: "*", Check: cdc.CheckSlot, Details: "replication slot witnz_node1 was dropped"})
+		findings, _ := store.ListFindings("")
+		if len(findings) != 1 {
+			t.Fatalf("Expected 1 local finding, got %d", len(findings))
+		}
+		id := findings[0].ID
+
+		if err := recorder.UpdateStatus(id, storage.FindingAcknowledged, "alice", "slot recreated"); err != nil {
+			t.Fatalf("UpdateStatus on leader failed: %v", err)
+		}
+		replicator.leader = false
+		if err := recorder.UpdateStatus(id, storage.FindingResolved, "alice", ""); err != nil {
+			t.Fatalf("UpdateStatus on follower failed: %v", err)
+		}
+
+		if len(replicator.statuses) != 0 {
+			t.Errorf("Expected local finding status to stay local, got %d replicated", len(replicator.statuses))
+		}
+		f, err := store.GetFinding(id)
+		if err != nil {
+			t.Fatalf("GetFinding failed: %v", err)
+		}
+		if f.Status != storage.FindingResolved || f.StatusBy != "alice" {
+			t.Errorf("Expected resolved by alice, got %s by %s", f.Status, f.StatusBy)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/witnz/witnz/internal/forensics"
+	"github.com/witnz/witnz/internal/hash"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+// ForensicReport compares the row image recorded at insert time with the
+// current row in PostgreSQL. Row images are only available for tables
+// configured with store_row_images; without one the report still contains
+// the hash comparison.
+func (v *MerkleVerifier) ForensicReport(ctx context.Context, tableName, recordID string, c *forensics.Cipher) (*forensics.Report, error) {
+	if !storage.ValidTableName(tableName) {
+		return nil, fmt.Errorf("invalid table name: %s", tableName)
+	}
+
+	entry, err := v.findHashEntry(tableName, recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	var original map[string]string
+	if c != nil {
+		sealed, err := v.storage.GetRowImage(tableName, recordID)
+		if err == nil {
+			original, err = c.Open(tableName, recordID, sealed)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	currentData, err := v.fetchRecord(ctx, tableName, recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := &forensics.RecordedEntry{
+		SequenceNum: entry.SequenceNum,
+		DataHash:    entry.DataHash,
+		Timestamp:   entry.Timestamp,
+	}
+
+	var current map[string]string
+	if currentData != nil {
+		current = hash.NormalizeRow(currentData)
+		recorded.CurrentHash = hash.CalculateDataHash(currentData)
+	}
+
+	return forensics.NewReport(tableName, recordID, recorded, original, current), nil
+}
+
+// findHashEntry returns the most recent hash entry recorded for a record
+func (v *MerkleVerifier) findHashEntry(tableName, recordID string) (*storage.HashEntry, error) {
+	entries, err := v.storage.GetAllHashEntries(tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hash entries: %w", err)
+	}
+
+	var found *storage.HashEntry
+	for _, entry := range entries {
+		if extractIDFromRecordID(entry.RecordID) == recordID {
+			found = entry
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("no hash entry recorded for %s id=%s", tableName, recordID)
+	}
+
+	return found, nil
+}
+
+// fetchRecord returns the current row, or nil if it no longer exists
+func (v *MerkleVerifier) fetchRecord(ctx context.Context, tableName, recordID string) (map[string]interface{}, error) {
+	scanner, relation, err := v.connect(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer scanner.Close(ctx)
+
+	return scanner.Fetch(ctx, relation, recordID)
+}
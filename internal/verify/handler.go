@@ -2,26 +2,34 @@ package verify
 
 import (
 	"fmt"
-	"regexp"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/witnz/witnz/internal/alert"
 	"github.com/witnz/witnz/internal/cdc"
+	"github.com/witnz/witnz/internal/forensics"
 	"github.com/witnz/witnz/internal/hash"
+	"github.com/witnz/witnz/internal/logging"
 	"github.com/witnz/witnz/internal/storage"
 )
 
-var validTableName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+var logger = logging.Logger("verify")
 
 type TableConfig struct {
 	Name           string
 	VerifyInterval string
+	StoreRowImages bool
 }
 
 type HashChainHandler struct {
 	storage      *storage.Storage
+	tablesMu     sync.RWMutex
 	tableConfigs map[string]*TableConfig
 	alertManager *alert.Manager
+	rowCipher    *forensics.Cipher
+	findings     *FindingRecorder
+	allowances   *AllowanceRegistry
 }
 
 func NewHashChainHandler(store *storage.Storage) *HashChainHandler {
@@ -35,49 +43,248 @@ func (h *HashChainHandler) SetAlertManager(am *alert.Manager) {
 	h.alertManager = am
 }
 
+// SetFindingRecorder sets the recorder used to persist tamper findings
+func (h *HashChainHandler) SetFindingRecorder(r *FindingRecorder) {
+	h.findings = r
+}
+
+// SetAllowanceRegistry sets the registry used to approve UPDATE and DELETE
+// events covered by a signed allowance
+func (h *HashChainHandler) SetAllowanceRegistry(r *AllowanceRegistry) {
+	h.allowances = r
+}
+
+// SetRowImageCipher sets the cipher used to encrypt row images for tables
+// configured with StoreRowImages
+func (h *HashChainHandler) SetRowImageCipher(c *forensics.Cipher) {
+	h.rowCipher = c
+}
+
 func (h *HashChainHandler) AddTable(config *TableConfig) error {
-	if !validTableName.MatchString(config.Name) {
+	if !storage.ValidTableName(config.Name) {
 		return fmt.Errorf("invalid table name: %s", config.Name)
 	}
+	if config.StoreRowImages && h.rowCipher == nil {
+		return fmt.Errorf("table %s stores row images but no encryption key is configured", config.Name)
+	}
 
+	h.tablesMu.Lock()
+	defer h.tablesMu.Unlock()
 	h.tableConfigs[config.Name] = config
 	return nil
 }
 
+// RemoveTable stops protecting a table. Hash entries already recorded for it
+// are kept.
+func (h *HashChainHandler) RemoveTable(name string) {
+	h.tablesMu.Lock()
+	defer h.tablesMu.Unlock()
+	delete(h.tableConfigs, name)
+}
+
+// Tables returns the names of the protected tables in sorted order
+func (h *HashChainHandler) Tables() []string {
+	h.tablesMu.RLock()
+	defer h.tablesMu.RUnlock()
+
+	names := make([]string, 0, len(h.tableConfigs))
+	for name := range h.tableConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (h *HashChainHandler) tableConfig(name string) (*TableConfig, bool) {
+	h.tablesMu.RLock()
+	defer h.tablesMu.RUnlock()
+	config, ok := h.tableConfigs[name]
+	return config, ok
+}
+
 func (h *HashChainHandler) HandleChange(event *cdc.ChangeEvent) error {
-	_, ok := h.tableConfigs[event.TableName]
+	config, ok := h.tableConfig(event.TableName)
 	if !ok {
 		return nil
 	}
 
-	if event.Operation == cdc.OperationUpdate || event.Operation == cdc.OperationDelete {
-		return NewTamperingError(event.TableName, string(event.Operation))
+	if isModification(event.Operation) {
+		allowance, err := h.matchAllowance(event)
+		if err != nil {
+			return err
+		}
+		if allowance == nil {
+			return h.rejectModification(event)
+		}
+		return h.recordAmendment(config, event, allowance)
 	}
 
-	dataHash := calculateDataHash(event.NewData)
+	entry := &storage.HashEntry{
+		TableName:     event.TableName,
+		DataHash:      calculateDataHash(event.NewData),
+		Timestamp:     time.Now(),
+		OperationType: string(event.Operation),
+		RecordID:      fmt.Sprintf("%v", event.PrimaryKey),
+		LSN:           event.LSN,
+	}
+
+	if _, err := h.storage.AppendHashEntry(entry); err != nil {
+		return err
+	}
 
-	latestEntry, _ := h.storage.GetLatestHashEntry(event.TableName)
-	var seqNum uint64 = 1
-	if latestEntry != nil {
-		seqNum = latestEntry.SequenceNum + 1
+	sealed, err := h.sealRowImage(config, event)
+	if err != nil {
+		return err
+	}
+	if sealed != nil {
+		return h.storage.SaveRowImage(event.TableName, recordKey(event), sealed)
 	}
 
+	return nil
+}
+
+func isModification(op cdc.OperationType) bool {
+	return op == cdc.OperationUpdate || op == cdc.OperationDelete || op == cdc.OperationTruncate
+}
+
+// rejectModification records a finding for an UPDATE, DELETE or TRUNCATE on
+// a protected table and returns the corresponding TamperingError
+func (h *HashChainHandler) rejectModification(event *cdc.ChangeEvent) error {
+	finding := &storage.Finding{
+		TableName:  event.TableName,
+		DetectedBy: storage.DetectedByCDC,
+		LSN:        event.LSN,
+		DetectedAt: event.Timestamp,
+	}
+
+	switch event.Operation {
+	case cdc.OperationUpdate:
+		finding.Kind = storage.FindingUpdate
+		finding.ActualHash = calculateDataHash(event.NewData)
+	case cdc.OperationDelete:
+		finding.Kind = storage.FindingDelete
+	case cdc.OperationTruncate:
+		finding.Kind = storage.FindingTruncate
+	}
+
+	if len(event.PrimaryKey) > 0 {
+		finding.RecordID = recordKey(event)
+	}
+	if len(event.OldData) > 0 {
+		finding.ExpectedHash = calculateDataHash(event.OldData)
+	}
+
+	h.findings.recordQuietly(finding)
+
+	return NewTamperingError(event.TableName, string(event.Operation))
+}
+
+// matchAllowance returns the allowance authorizing an UPDATE or DELETE, or
+// nil if the change is not pre-approved
+func (h *HashChainHandler) matchAllowance(event *cdc.ChangeEvent) (*storage.Allowance, error) {
+	if h.allowances == nil || event.Operation == cdc.OperationTruncate || len(event.PrimaryKey) == 0 {
+		return nil, nil
+	}
+
+	recordID := recordKey(event)
+	return h.allowances.Match(event, recordID, func() (string, bool, error) {
+		return h.recordedHash(event.TableName, recordID)
+	})
+}
+
+// recordedHash returns the hash the chain holds for a record, and false if
+// the record is not in the expected state
+func (h *HashChainHandler) recordedHash(tableName, recordID string) (string, bool, error) {
+	entries, err := h.storage.GetAllHashEntries(tableName)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get hash entries: %w", err)
+	}
+
+	leafMap := make(map[string]string)
+	applyHashEntries(leafMap, entries, 0)
+
+	oldHash, ok := leafMap[recordID]
+	return oldHash, ok, nil
+}
+
+// recordAmendment appends an approved UPDATE or DELETE to the hash chain and
+// consumes its allowance
+func (h *HashChainHandler) recordAmendment(config *TableConfig, event *cdc.ChangeEvent, allowance *storage.Allowance) error {
+	if allowance.Consumed() {
+		return nil
+	}
+
+	entry := h.amendmentEntry(event, allowance)
+	if _, err := h.storage.AppendHashEntry(entry); err != nil {
+		return fmt.Errorf("failed to record approved amendment: %w", err)
+	}
+
+	logger.Info("Approved amendment recorded",
+		"table", event.TableName,
+		"operation", event.Operation,
+		"record_id", allowance.RecordID,
+		"allowance", allowance.ID,
+		"approver", allowance.Approver,
+		"lsn", event.LSN)
+
+	if event.Operation != cdc.OperationUpdate {
+		return nil
+	}
+
+	sealed, err := h.sealRowImage(config, event)
+	if err != nil {
+		return err
+	}
+	if sealed != nil {
+		return h.storage.SaveRowImage(event.TableName, recordKey(event), sealed)
+	}
+
+	return nil
+}
+
+// amendmentEntry builds the hash entry for an approved change. Deletes carry
+// no data hash and remove the record from the expected state.
+func (h *HashChainHandler) amendmentEntry(event *cdc.ChangeEvent, allowance *storage.Allowance) *storage.HashEntry {
 	entry := &storage.HashEntry{
 		TableName:     event.TableName,
-		SequenceNum:   seqNum,
-		DataHash:      dataHash,
 		Timestamp:     time.Now(),
 		OperationType: string(event.Operation),
 		RecordID:      fmt.Sprintf("%v", event.PrimaryKey),
+		AllowanceID:   allowance.ID,
+		LSN:           event.LSN,
+	}
+
+	if event.Operation == cdc.OperationUpdate {
+		entry.DataHash = calculateDataHash(event.NewData)
 	}
 
-	return h.storage.SaveHashEntry(entry)
+	return entry
+}
+
+// sealRowImage encrypts the inserted row if the table keeps row images.
+// Returns nil when row images are disabled for the table.
+func (h *HashChainHandler) sealRowImage(config *TableConfig, event *cdc.ChangeEvent) ([]byte, error) {
+	if !config.StoreRowImages || h.rowCipher == nil {
+		return nil, nil
+	}
+
+	sealed, err := h.rowCipher.Seal(event.TableName, recordKey(event), hash.NormalizeRow(event.NewData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal row image: %w", err)
+	}
+
+	return sealed, nil
+}
+
+// recordKey returns the record identifier used to look up row images
+func recordKey(event *cdc.ChangeEvent) string {
+	return extractIDFromRecordID(fmt.Sprintf("%v", event.PrimaryKey))
 }
 
 // VerifyHashChain validates that hash entries exist for the table
 // Hash chain verification is no longer needed as we only store DataHash
 func (h *HashChainHandler) VerifyHashChain(tableName string) error {
-	_, ok := h.tableConfigs[tableName]
+	_, ok := h.tableConfig(tableName)
 	if !ok {
 		return fmt.Errorf("table not configured: %s", tableName)
 	}
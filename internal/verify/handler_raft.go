@@ -1,79 +1,548 @@
 package verify
 
 import (
+	"context"
 	"fmt"
-	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/witnz/witnz/internal/cdc"
 	"github.com/witnz/witnz/internal/consensus"
+	"github.com/witnz/witnz/internal/storage"
 )
 
 type RaftHashChainHandler struct {
 	*HashChainHandler
-	raftNode *consensus.Node
+	raftNode  *consensus.Node
+	witness   *Witness
+	batcher   *consensus.Batcher
+	forwarder *consensus.Forwarder
+	// waits report when everything handed to the batcher and forwarder so
+	// far has been applied or forwarded
+	waits []func(func(error))
+
+	// pending holds the hash of every change the leader is proposing, keyed
+	// by (table, record, LSN), until the FSM has applied it
+	mu      sync.Mutex
+	pending map[changeKey]string
+	// streams holds the acknowledgement state of each source
+	streams map[string]*streamState
+
+	// catchUp returns a channel that is closed while the Raft node is caught
+	// up with the leader. Until then only the latest commit's ack of each
+	// source is kept and run afterwards.
+	catchUp func() <-chan struct{}
+}
+
+// streamState tracks whether a source's transactions may be acknowledged.
+// Once a transaction fails to replicate, no later transaction of the source
+// is acknowledged, so PostgreSQL keeps the WAL that was not recorded. The
+// source is then streamed again from the acknowledged position; epoch counts
+// these replays, and the first transaction of a later epoch that replicates
+// clears the stall.
+type streamState struct {
+	epoch      uint64
+	stalled    bool
+	stallEpoch uint64
+	// deferredAck is the latest ack held back while the node catches up,
+	// and ackWaiting is set while a goroutine waits to run it
+	deferredAck func()
+	ackWaiting  bool
+}
+
+type changeKey struct {
+	table    string
+	recordID string
+	lsn      uint64
 }
 
 func NewRaftHashChainHandler(handler *HashChainHandler, raftNode *consensus.Node) *RaftHashChainHandler {
 	return &RaftHashChainHandler{
 		HashChainHandler: handler,
 		raftNode:         raftNode,
+		pending:          make(map[changeKey]string),
+		streams:          make(map[string]*streamState),
 	}
 }
 
+// SetWitness sets the witness that cross-checks leader-proposed hashes
+// against the hashes this node computes while it is a follower
+func (h *RaftHashChainHandler) SetWitness(w *Witness) {
+	h.witness = w
+}
+
+// SetBatcher routes hash entries through a proposal batcher instead of one
+// synchronous Raft round per change
+func (h *RaftHashChainHandler) SetBatcher(b *consensus.Batcher) {
+	h.batcher = b
+	h.waits = append(h.waits, b.OnApplied)
+}
+
+// SetForwarder makes followers forward the changes they observe to the
+// leader, so a single healthy CDC stream is enough to record every change
+func (h *RaftHashChainHandler) SetForwarder(f *consensus.Forwarder) {
+	h.forwarder = f
+	h.waits = append(h.waits, f.OnForwarded)
+}
+
+// SetCatchUp holds back WAL acknowledgements while the channel gate returns
+// is open, so a node that is replaying the Raft log does not let PostgreSQL
+// discard WAL. gate is called again for every ack, since a node that falls
+// behind later is given a new channel.
+func (h *RaftHashChainHandler) SetCatchUp(gate func() <-chan struct{}) {
+	h.catchUp = gate
+}
+
 func (h *RaftHashChainHandler) HandleChange(event *cdc.ChangeEvent) error {
-	_, ok := h.tableConfigs[event.TableName]
+	config, ok := h.tableConfig(event.TableName)
 	if !ok {
 		return nil
 	}
 
-	if event.Operation == cdc.OperationUpdate || event.Operation == cdc.OperationDelete {
-		return NewTamperingError(event.TableName, string(event.Operation))
-	}
-
 	if h.raftNode == nil {
 		return h.HashChainHandler.HandleChange(event)
 	}
 
-	if _, ok := h.tableConfigs[event.TableName]; !ok {
-		return fmt.Errorf("table not configured: %s", event.TableName)
+	if isModification(event.Operation) {
+		allowance, err := h.matchAllowance(event)
+		if err != nil {
+			return err
+		}
+		if allowance == nil {
+			return h.rejectModification(event)
+		}
+		return h.replicateAmendment(config, event, allowance)
 	}
 
 	dataHash := calculateDataHash(event.NewData)
 
-	latestEntry, _ := h.storage.GetLatestHashEntry(event.TableName)
-	var seqNum uint64 = 1
-	if latestEntry != nil {
-		seqNum = latestEntry.SequenceNum + 1
+	// The FSM assigns the sequence number when the entry is applied
+	payload := &consensus.HashChainPayload{
+		DataHash:      dataHash,
+		OperationType: string(event.Operation),
+		RecordID:      fmt.Sprintf("%v", event.PrimaryKey),
+		LSN:           event.LSN,
+	}
+
+	sealed, err := h.sealRowImage(config, event)
+	if err != nil {
+		return err
+	}
+	if sealed != nil {
+		payload.RowImage = sealed
+		payload.RowImageID = recordKey(event)
 	}
 
 	logEntry := &consensus.LogEntry{
 		Type:      consensus.LogEntryHashChain,
 		TableName: event.TableName,
-		Data: map[string]interface{}{
-			"sequence_num":   float64(seqNum),
-			"data_hash":      dataHash,
-			"operation_type": string(event.Operation),
-			"record_id":      fmt.Sprintf("%v", event.PrimaryKey),
-		},
+		HashChain: payload,
 		Timestamp: time.Now(),
 	}
 
-	// Only the leader can apply logs to Raft
-	// Followers will receive "not the leader" error and skip replication
-	if err := h.raftNode.ApplyLog(logEntry); err != nil {
-		if !h.raftNode.IsLeader() {
-			// Follower: hash is calculated but not replicated (will receive via Raft)
-			slog.Debug("CDC event processed on follower, waiting for Raft replication",
+	return h.replicate(logEntry, event, dataHash, func(seq uint64) {
+		logger.Debug("Raft consensus: hash chain entry replicated",
+			"table", event.TableName,
+			"seq", seq)
+	})
+}
+
+// replicateAmendment replicates an approved UPDATE or DELETE. The FSM
+// consumes the allowance when the entry is applied.
+func (h *RaftHashChainHandler) replicateAmendment(config *TableConfig, event *cdc.ChangeEvent, allowance *storage.Allowance) error {
+	if allowance.Consumed() {
+		return nil
+	}
+
+	entry := h.amendmentEntry(event, allowance)
+
+	payload := &consensus.HashChainPayload{
+		DataHash:      entry.DataHash,
+		OperationType: entry.OperationType,
+		RecordID:      entry.RecordID,
+		LSN:           event.LSN,
+		AllowanceID:   allowance.ID,
+	}
+
+	if event.Operation == cdc.OperationUpdate {
+		sealed, err := h.sealRowImage(config, event)
+		if err != nil {
+			return err
+		}
+		if sealed != nil {
+			payload.RowImage = sealed
+			payload.RowImageID = recordKey(event)
+		}
+	}
+
+	logEntry := &consensus.LogEntry{
+		Type:      consensus.LogEntryHashChain,
+		TableName: event.TableName,
+		HashChain: payload,
+		Timestamp: entry.Timestamp,
+	}
+
+	return h.replicate(logEntry, event, entry.DataHash, func(seq uint64) {
+		logger.Info("Raft consensus: approved amendment replicated",
+			"table", event.TableName,
+			"operation", event.Operation,
+			"record_id", allowance.RecordID,
+			"seq", seq,
+			"allowance", allowance.ID,
+			"approver", allowance.Approver)
+	})
+}
+
+// replicate proposes a hash chain entry and calls applied with the sequence
+// number the FSM assigned. Only the leader proposes; followers record their
+// own hash for the witness and forward the entry to the leader.
+func (h *RaftHashChainHandler) replicate(logEntry *consensus.LogEntry, event *cdc.ChangeEvent, dataHash string, applied func(seq uint64)) error {
+	if !h.raftNode.IsLeader() {
+		return h.recordOnFollower(logEntry, event, dataHash)
+	}
+
+	_, err := h.propose(logEntry, func(seq uint64, err error) {
+		if err != nil {
+			if !h.raftNode.IsLeader() {
+				if err := h.recordOnFollower(logEntry, event, dataHash); err != nil {
+					logger.Error("Failed to forward hash entry", "table", event.TableName, "error", err)
+				}
+				return
+			}
+			logger.Error("Failed to replicate hash entry via raft",
 				"table", event.TableName,
-				"seq", seqNum)
-			return nil
+				"lsn", event.LSN,
+				"error", err)
+			return
 		}
-		return fmt.Errorf("failed to replicate via raft: %w", err)
+		applied(seq)
+	})
+	return err
+}
+
+// propose submits an entry unless the same change is already recorded or
+// being proposed, and reports whether it did. done is not called for
+// skipped entries.
+func (h *RaftHashChainHandler) propose(logEntry *consensus.LogEntry, done consensus.ProposalCallback) (bool, error) {
+	key, ok, err := h.claim(logEntry)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	finish := func(seq uint64, err error) {
+		h.release(key)
+		done(seq, err)
+	}
+
+	if h.batcher == nil {
+		finish(h.raftNode.ApplyHashEntry(logEntry))
+		return true, nil
+	}
+
+	if err := h.batcher.Propose(logEntry, finish); err != nil {
+		h.release(key)
+		return false, err
+	}
+	return true, nil
+}
+
+// claim reserves a change for proposal. It returns false when the change was
+// already recorded or is being proposed, so each change is proposed once no
+// matter how many nodes observed it.
+func (h *RaftHashChainHandler) claim(logEntry *consensus.LogEntry) (changeKey, bool, error) {
+	p := logEntry.HashChain
+	key := changeKey{table: logEntry.TableName, recordID: p.RecordID, lsn: p.LSN}
+
+	// Changes without an LSN cannot be matched across nodes
+	if p.LSN == 0 {
+		return key, true, nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if hash, ok := h.pending[key]; ok {
+		h.logDuplicate(key, hash, p.DataHash)
+		return key, false, nil
 	}
 
-	slog.Info("Raft consensus: hash chain entry replicated",
+	existing, err := h.storage.GetHashEntryByLSN(key.table, key.recordID, key.lsn)
+	if err != nil {
+		return key, false, fmt.Errorf("failed to look up change: %w", err)
+	}
+	if existing != nil {
+		h.logDuplicate(key, existing.DataHash, p.DataHash)
+		return key, false, nil
+	}
+
+	h.pending[key] = p.DataHash
+	return key, true, nil
+}
+
+func (h *RaftHashChainHandler) release(key changeKey) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.pending, key)
+}
+
+func (h *RaftHashChainHandler) logDuplicate(key changeKey, recorded, observed string) {
+	if recorded == observed {
+		return
+	}
+
+	logger.Warn("Change observed with a different hash than the one recorded",
+		"table", key.table,
+		"record_id", key.recordID,
+		"lsn", key.lsn,
+		"recorded_hash", recorded,
+		"observed_hash", observed)
+}
+
+func (h *RaftHashChainHandler) recordOnFollower(logEntry *consensus.LogEntry, event *cdc.ChangeEvent, dataHash string) error {
+	h.witness.RecordLocal(event.TableName, recordKey(event), event.LSN, dataHash)
+	logger.Debug("CDC event processed on follower, waiting for Raft replication",
 		"table", event.TableName,
-		"seq", seqNum)
+		"lsn", event.LSN)
+
+	if h.forwarder == nil {
+		return nil
+	}
+	return h.forwarder.Forward(logEntry)
+}
+
+// HandleForwarded proposes the entries a follower observed. Changes the
+// leader already recorded or is proposing are skipped. It returns once every
+// proposed entry has been applied.
+func (h *RaftHashChainHandler) HandleForwarded(from string, entries []*consensus.LogEntry) (int, error) {
+	proposed := 0
+
+	for _, entry := range entries {
+		entry := entry
+		ok, err := h.propose(entry, func(seq uint64, err error) {
+			if err != nil {
+				logger.Error("Failed to replicate forwarded hash entry",
+					"from", from,
+					"table", entry.TableName,
+					"lsn", entry.HashChain.LSN,
+					"error", err)
+				return
+			}
+			logger.Debug("Raft consensus: forwarded hash entry replicated",
+				"from", from,
+				"table", entry.TableName,
+				"seq", seq)
+		})
+		if err != nil {
+			return proposed, err
+		}
+		if ok {
+			proposed++
+		}
+	}
+
+	if h.batcher == nil {
+		return proposed, nil
+	}
+
+	applied := make(chan error, 1)
+	h.batcher.OnApplied(func(err error) { applied <- err })
+	return proposed, <-applied
+}
+
+// HandleCommit acknowledges a source transaction once every entry proposed
+// or forwarded before its commit has been applied or accepted by the leader.
+// After a failure no further transactions of the source are acknowledged
+// until it has been replayed, so the WAL that was not recorded is retained.
+func (h *RaftHashChainHandler) HandleCommit(commit *cdc.CommitEvent, ack func()) error {
+	if len(h.waits) == 0 {
+		h.acknowledge(commit.Source, ack)
+		return nil
+	}
+
+	h.mu.Lock()
+	epoch := h.stream(commit.Source).epoch
+	h.mu.Unlock()
+
+	var remaining atomic.Int32
+	remaining.Store(int32(len(h.waits)))
+	var failed atomic.Bool
+
+	for _, wait := range h.waits {
+		wait(func(err error) {
+			if err != nil && failed.CompareAndSwap(false, true) {
+				h.stall(commit, epoch, err)
+			}
+			if remaining.Add(-1) == 0 && !failed.Load() {
+				h.settle(commit, epoch, ack)
+			}
+		})
+	}
+
 	return nil
 }
+
+// ReplayRequested reports once per stall that a source has to be streamed
+// again from its acknowledged position
+func (h *RaftHashChainHandler) ReplayRequested(source string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	st := h.streams[source]
+	if st == nil || !st.stalled || st.epoch > st.stallEpoch {
+		return false
+	}
+	st.epoch++
+	return true
+}
+
+// stream returns the state of a source. The caller holds h.mu.
+func (h *RaftHashChainHandler) stream(source string) *streamState {
+	st, ok := h.streams[source]
+	if !ok {
+		st = &streamState{}
+		h.streams[source] = st
+	}
+	return st
+}
+
+// stall stops acknowledgements after a transaction failed to replicate.
+// Every source is stalled, since the batcher does not tell which source a
+// failed entry came from, and each is replayed before it acknowledges again.
+func (h *RaftHashChainHandler) stall(commit *cdc.CommitEvent, epoch uint64, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stream(commit.Source)
+	for source, st := range h.streams {
+		at := st.epoch
+		if source == commit.Source {
+			at = epoch
+		}
+		if st.stalled && at <= st.stallEpoch {
+			continue
+		}
+		st.stalled = true
+		st.stallEpoch = at
+		logger.Error("Hash entries failed to replicate, holding back WAL acknowledgements until replayed",
+			"source", source,
+			"xid", commit.TransactionID,
+			"commit_lsn", commit.CommitLSN,
+			"error", err)
+	}
+}
+
+// settle acknowledges a replicated transaction unless its source is stalled.
+// A transaction streamed after the stall was replayed clears it.
+func (h *RaftHashChainHandler) settle(commit *cdc.CommitEvent, epoch uint64, ack func()) {
+	h.mu.Lock()
+	st := h.stream(commit.Source)
+	if st.stalled {
+		if epoch <= st.stallEpoch {
+			h.mu.Unlock()
+			return
+		}
+		st.stalled = false
+		logger.Info("Hash entries replicate again, resuming WAL acknowledgements",
+			"source", commit.Source,
+			"commit_lsn", commit.CommitLSN)
+	}
+	h.mu.Unlock()
+
+	h.acknowledge(commit.Source, ack)
+}
+
+// Flush waits until every change handed to the handler has been applied or
+// forwarded, so the acks of all completed transactions have run
+func (h *RaftHashChainHandler) Flush(ctx context.Context) error {
+	results := make(chan error, len(h.waits))
+	for _, wait := range h.waits {
+		wait(func(err error) { results <- err })
+	}
+
+	var firstErr error
+	for range h.waits {
+		select {
+		case err := <-results:
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("timed out flushing hash entries: %w", ctx.Err())
+		}
+	}
+
+	return firstErr
+}
+
+// acknowledge runs ack once the node has caught up. Acks of one source
+// confirm increasing LSNs, so while catching up each deferred ack replaces
+// the previous one of the same source.
+func (h *RaftHashChainHandler) acknowledge(source string, ack func()) {
+	if h.caughtUp() {
+		h.flushDeferredAck(source)
+		ack()
+		return
+	}
+
+	h.mu.Lock()
+	st := h.stream(source)
+	st.deferredAck = ack
+	waiting := st.ackWaiting
+	st.ackWaiting = true
+	h.mu.Unlock()
+
+	if !waiting {
+		go h.awaitCatchUp(source)
+	}
+}
+
+func (h *RaftHashChainHandler) caughtUp() bool {
+	return h.catchUp == nil || isClosed(h.catchUp())
+}
+
+// awaitCatchUp runs the deferred ack of a source once the node has caught
+// up. The gate is read again after each wait in case the node fell behind
+// meanwhile.
+func (h *RaftHashChainHandler) awaitCatchUp(source string) {
+	for {
+		<-h.catchUp()
+
+		h.mu.Lock()
+		if !h.caughtUp() {
+			h.mu.Unlock()
+			continue
+		}
+		st := h.stream(source)
+		ack := st.deferredAck
+		st.deferredAck = nil
+		st.ackWaiting = false
+		h.mu.Unlock()
+
+		if ack != nil {
+			ack()
+		}
+		return
+	}
+}
+
+func (h *RaftHashChainHandler) flushDeferredAck(source string) {
+	h.mu.Lock()
+	st := h.stream(source)
+	ack := st.deferredAck
+	st.deferredAck = nil
+	h.mu.Unlock()
+
+	if ack != nil {
+		ack()
+	}
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
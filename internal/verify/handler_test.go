@@ -1,11 +1,15 @@
 package verify
 
 import (
+	"fmt"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/witnz/witnz/internal/cdc"
+	"github.com/witnz/witnz/internal/consensus"
+	"github.com/witnz/witnz/internal/forensics"
 	"github.com/witnz/witnz/internal/storage"
 )
 
@@ -163,6 +167,70 @@ func TestVerifyHashChain(t *testing.T) {
 	}
 }
 
+func TestHashChainHandlerStoresRowImages(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-verify-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	handler := NewHashChainHandler(store)
+
+	if err := handler.AddTable(&TableConfig{Name: "images", StoreRowImages: true}); err == nil {
+		t.Fatal("AddTable should fail without a row image cipher")
+	}
+
+	rowCipher, err := forensics.NewCipher("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	if err != nil {
+		t.Fatalf("NewCipher failed: %v", err)
+	}
+	handler.SetRowImageCipher(rowCipher)
+
+	if err := handler.AddTable(&TableConfig{Name: "images", StoreRowImages: true}); err != nil {
+		t.Fatalf("Failed to add table: %v", err)
+	}
+	if err := handler.AddTable(&TableConfig{Name: "plain"}); err != nil {
+		t.Fatalf("Failed to add table: %v", err)
+	}
+
+	for _, table := range []string{"images", "plain"} {
+		event := &cdc.ChangeEvent{
+			TableName:  table,
+			Operation:  cdc.OperationInsert,
+			Timestamp:  time.Now(),
+			NewData:    map[string]interface{}{"id": 7, "action": "login"},
+			PrimaryKey: map[string]interface{}{"id": 7},
+		}
+		if err := handler.HandleChange(event); err != nil {
+			t.Fatalf("HandleChange failed: %v", err)
+		}
+	}
+
+	sealed, err := store.GetRowImage("images", "7")
+	if err != nil {
+		t.Fatalf("GetRowImage failed: %v", err)
+	}
+
+	row, err := rowCipher.Open("images", "7", sealed)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if row["action"] != "login" {
+		t.Errorf("Expected action=login, got %s", row["action"])
+	}
+
+	if _, err := store.GetRowImage("plain", "7"); err == nil {
+		t.Error("Row image should not be stored for tables without store_row_images")
+	}
+}
+
 func TestAddTableValidation(t *testing.T) {
 	tmpfile, err := os.CreateTemp("", "witnz-verify-test-*.db")
 	if err != nil {
@@ -204,3 +272,248 @@ func TestAddTableValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestHashChainHandlerRemoveTable(t *testing.T) {
+	store := newFindingTestStore(t)
+	handler := NewHashChainHandler(store)
+
+	for _, name := range []string{"payments", "audit_log"} {
+		if err := handler.AddTable(&TableConfig{Name: name}); err != nil {
+			t.Fatalf("AddTable failed: %v", err)
+		}
+	}
+	if tables := handler.Tables(); len(tables) != 2 || tables[0] != "audit_log" {
+		t.Errorf("Expected sorted tables [audit_log payments], got %v", tables)
+	}
+
+	handler.RemoveTable("payments")
+
+	event := &cdc.ChangeEvent{
+		TableName:  "payments",
+		Operation:  cdc.OperationInsert,
+		PrimaryKey: map[string]interface{}{"id": 1},
+		NewData:    map[string]interface{}{"id": 1},
+	}
+	if err := handler.HandleChange(event); err != nil {
+		t.Fatalf("HandleChange failed: %v", err)
+	}
+	if _, err := store.GetLatestHashEntry("payments"); err == nil {
+		t.Error("Expected changes to a removed table to be ignored")
+	}
+	if err := handler.VerifyHashChain("payments"); err == nil {
+		t.Error("Expected a removed table to be reported as not configured")
+	}
+}
+
+func TestRaftHandlerClaim(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "witnz-verify-test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	store, err := storage.New(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	handler := NewRaftHashChainHandler(NewHashChainHandler(store), nil)
+
+	entry := &consensus.LogEntry{
+		Type:      consensus.LogEntryHashChain,
+		TableName: "audit_log",
+		HashChain: &consensus.HashChainPayload{DataHash: "h1", OperationType: "INSERT", RecordID: "map[id:1]", LSN: 100},
+	}
+
+	key, ok, err := handler.claim(entry)
+	if err != nil || !ok {
+		t.Fatalf("Expected first observation to be claimed, got ok=%v err=%v", ok, err)
+	}
+
+	t.Run("PendingChange", func(t *testing.T) {
+		if _, ok, _ := handler.claim(entry); ok {
+			t.Error("Expected change being proposed to be skipped")
+		}
+	})
+
+	t.Run("RecordedChange", func(t *testing.T) {
+		handler.release(key)
+		if _, err := store.AppendHashEntry(&storage.HashEntry{
+			TableName: "audit_log", DataHash: "h1", OperationType: "INSERT", RecordID: "map[id:1]", LSN: 100,
+		}); err != nil {
+			t.Fatalf("AppendHashEntry failed: %v", err)
+		}
+
+		if _, ok, _ := handler.claim(entry); ok {
+			t.Error("Expected recorded change to be skipped")
+		}
+	})
+
+	t.Run("WithoutLSN", func(t *testing.T) {
+		noLSN := *entry
+		noLSN.HashChain = &consensus.HashChainPayload{DataHash: "h2", OperationType: "INSERT", RecordID: "map[id:2]"}
+		for i := 0; i < 2; i++ {
+			if _, ok, _ := handler.claim(&noLSN); !ok {
+				t.Error("Expected changes without an LSN to always be proposed")
+			}
+		}
+	})
+}
+
+func TestRaftHandlerDefersAckUntilCaughtUp(t *testing.T) {
+	handler := NewRaftHashChainHandler(NewHashChainHandler(nil), nil)
+
+	var mu sync.Mutex
+	catchUp := make(chan struct{})
+	handler.SetCatchUp(func() <-chan struct{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return catchUp
+	})
+
+	acked := make(chan int, 3)
+	for i := 1; i <= 2; i++ {
+		i := i
+		if err := handler.HandleCommit(&cdc.CommitEvent{EndLSN: uint64(i)}, func() { acked <- i }); err != nil {
+			t.Fatalf("HandleCommit failed: %v", err)
+		}
+	}
+
+	select {
+	case i := <-acked:
+		t.Fatalf("Expected no ack while catching up, got ack for commit %d", i)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(catchUp)
+
+	select {
+	case i := <-acked:
+		if i != 2 {
+			t.Errorf("Expected only the latest commit to be acked, got %d", i)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for deferred ack")
+	}
+
+	if err := handler.HandleCommit(&cdc.CommitEvent{EndLSN: 3}, func() { acked <- 3 }); err != nil {
+		t.Fatalf("HandleCommit failed: %v", err)
+	}
+	if i := <-acked; i != 3 {
+		t.Errorf("Expected commit 3 acked immediately, got %d", i)
+	}
+
+	// The node falls behind the leader again
+	mu.Lock()
+	behind := make(chan struct{})
+	catchUp = behind
+	mu.Unlock()
+
+	if err := handler.HandleCommit(&cdc.CommitEvent{EndLSN: 4}, func() { acked <- 4 }); err != nil {
+		t.Fatalf("HandleCommit failed: %v", err)
+	}
+	select {
+	case i := <-acked:
+		t.Fatalf("Expected no ack after falling behind, got ack for commit %d", i)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(behind)
+	select {
+	case i := <-acked:
+		if i != 4 {
+			t.Errorf("Expected commit 4 acked after catching up again, got %d", i)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the ack after catching up again")
+	}
+}
+
+func TestRaftHandlerDefersAckPerSource(t *testing.T) {
+	handler := NewRaftHashChainHandler(NewHashChainHandler(nil), nil)
+
+	catchUp := make(chan struct{})
+	handler.SetCatchUp(func() <-chan struct{} { return catchUp })
+
+	acked := make(chan string, 4)
+	commits := []*cdc.CommitEvent{
+		{Source: "", EndLSN: 10},
+		{Source: "billing", EndLSN: 5},
+		{Source: "", EndLSN: 20},
+	}
+	for _, commit := range commits {
+		label := fmt.Sprintf("%s@%d", commit.Source, commit.EndLSN)
+		if err := handler.HandleCommit(commit, func() { acked <- label }); err != nil {
+			t.Fatalf("HandleCommit failed: %v", err)
+		}
+	}
+
+	close(catchUp)
+
+	// The billing ack must not be replaced by the default source's
+	got := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		select {
+		case label := <-acked:
+			got[label] = true
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for deferred acks, got %v", got)
+		}
+	}
+	if !got["@20"] || !got["billing@5"] {
+		t.Errorf("Expected the latest ack of each source, got %v", got)
+	}
+
+	select {
+	case label := <-acked:
+		t.Errorf("Expected superseded acks to be dropped, got %s", label)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRaftHandlerResumesAckAfterReplay(t *testing.T) {
+	handler := NewRaftHashChainHandler(NewHashChainHandler(nil), nil)
+
+	// A batcher stand-in whose next completion reports result
+	var result error
+	handler.waits = []func(func(error)){func(fn func(error)) { fn(result) }}
+
+	acked := make([]uint64, 0)
+	commit := func(lsn uint64) {
+		event := &cdc.CommitEvent{Source: "billing", EndLSN: lsn}
+		if err := handler.HandleCommit(event, func() { acked = append(acked, lsn) }); err != nil {
+			t.Fatalf("HandleCommit failed: %v", err)
+		}
+	}
+
+	commit(100)
+	result = fmt.Errorf("leadership lost")
+	commit(200)
+	result = nil
+	commit(300)
+
+	if len(acked) != 1 || acked[0] != 100 {
+		t.Fatalf("Expected only the commit before the failure to be acked, got %v", acked)
+	}
+	if handler.ReplayRequested("sales") {
+		t.Error("Expected no replay for a source that never failed")
+	}
+	if !handler.ReplayRequested("billing") {
+		t.Fatal("Expected a replay after the failed batch")
+	}
+	if handler.ReplayRequested("billing") {
+		t.Error("Expected the replay to be requested once")
+	}
+
+	// The replayed stream delivers the failed transaction again
+	commit(200)
+	commit(300)
+	if len(acked) != 3 || acked[1] != 200 || acked[2] != 300 {
+		t.Errorf("Expected acks to resume after the replay, got %v", acked)
+	}
+	if handler.ReplayRequested("billing") {
+		t.Error("Expected no replay once transactions replicate again")
+	}
+}
@@ -0,0 +1,118 @@
+package verify
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/witnz/witnz/internal/alert"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+// IdentityReplicator replicates source database identities via Raft
+type IdentityReplicator interface {
+	IsLeader() bool
+	ApplyDatabaseIdentity(identity *storage.DatabaseIdentity) error
+}
+
+// IdentityGuard checks the identity a source database reports each time
+// CDC connects against the identity recorded before. A restore from backup
+// or pg_resetwal would otherwise go unnoticed until the lost rows show up
+// as deleted records. In Raft mode only the leader records the new
+// identity, so every node compares against the same one.
+type IdentityGuard struct {
+	storage      *storage.Storage
+	nodeID       string
+	findings     *FindingRecorder
+	alertManager *alert.Manager
+	replicator   IdentityReplicator
+	mu           sync.RWMutex
+}
+
+func NewIdentityGuard(store *storage.Storage, nodeID string, findings *FindingRecorder, am *alert.Manager) *IdentityGuard {
+	return &IdentityGuard{
+		storage:      store,
+		nodeID:       nodeID,
+		findings:     findings,
+		alertManager: am,
+	}
+}
+
+// SetRaftNode sets the Raft node used to replicate identities
+func (g *IdentityGuard) SetRaftNode(node IdentityReplicator) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.replicator = node
+}
+
+// ObserveIdentity raises a database rollback finding when the identity
+// differs from the recorded one, then records it
+func (g *IdentityGuard) ObserveIdentity(identity *storage.DatabaseIdentity, acknowledged uint64) error {
+	identity.NodeID = g.nodeID
+	identity.AcknowledgedLSN = acknowledged
+	if identity.ObservedAt.IsZero() {
+		identity.ObservedAt = time.Now()
+	}
+
+	previous, err := g.storage.GetDatabaseIdentity(identity.Source)
+	if err != nil {
+		return fmt.Errorf("failed to get recorded identity: %w", err)
+	}
+
+	if previous == nil {
+		logger.Info("Recorded source database identity",
+			"source", identity.Source,
+			"system_id", identity.SystemID,
+			"timeline", identity.Timeline,
+			"xlogpos", storage.FormatLSN(identity.XLogPos))
+	} else if changes := previous.Changes(identity, acknowledged); len(changes) > 0 {
+		g.report(previous, identity, changes)
+	}
+
+	g.mu.RLock()
+	replicator := g.replicator
+	g.mu.RUnlock()
+
+	if replicator == nil {
+		return g.storage.SaveDatabaseIdentity(identity)
+	}
+	if !replicator.IsLeader() {
+		return nil
+	}
+	if err := replicator.ApplyDatabaseIdentity(identity); err != nil {
+		return fmt.Errorf("failed to replicate database identity via raft: %w", err)
+	}
+	return nil
+}
+
+// report records a critical finding for a database that no longer matches
+// its recorded identity. The finding covers every table of the source.
+func (g *IdentityGuard) report(previous, current *storage.DatabaseIdentity, changes []string) {
+	logger.Error("Source database was replaced or rolled back",
+		"source", current.Source,
+		"previous_system_id", previous.SystemID,
+		"system_id", current.SystemID,
+		"previous_timeline", previous.Timeline,
+		"timeline", current.Timeline,
+		"xlogpos", storage.FormatLSN(current.XLogPos),
+		"changes", changes)
+
+	g.findings.recordQuietly(&storage.Finding{
+		TableName:  storage.QualifiedTable(current.Source, "*"),
+		Kind:       storage.FindingDatabaseRollback,
+		DetectedBy: storage.DetectedByCDC,
+		LSN:        current.XLogPos,
+		Details:    strings.Join(changes, "; "),
+	})
+
+	if g.alertManager != nil {
+		source := current.Source
+		if source == "" {
+			source = "default"
+		}
+		if err := g.alertManager.SendDatabaseRollbackAlert(source, current.SystemID, current.Timeline, changes); err != nil {
+			logger.Error("Failed to send database rollback alert", "source", current.Source, "error", err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package verify
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/witnz/witnz/internal/storage"
+)
+
+type mockIdentityReplicator struct {
+	leader     bool
+	identities []*storage.DatabaseIdentity
+}
+
+func (m *mockIdentityReplicator) IsLeader() bool {
+	return m.leader
+}
+
+func (m *mockIdentityReplicator) ApplyDatabaseIdentity(identity *storage.DatabaseIdentity) error {
+	m.identities = append(m.identities, identity)
+	return nil
+}
+
+func TestIdentityGuard(t *testing.T) {
+	newIdentity := func(systemID string, timeline int32, xlogpos uint64) *storage.DatabaseIdentity {
+		return &storage.DatabaseIdentity{Source: "billing", SystemID: systemID, Timeline: timeline, XLogPos: xlogpos}
+	}
+
+	t.Run("Standalone", func(t *testing.T) {
+		store := newFindingTestStore(t)
+		guard := NewIdentityGuard(store, "node1", NewFindingRecorder(store, "node1"), nil)
+
+		if err := guard.ObserveIdentity(newIdentity("7300000000000000001", 1, 0x1000), 0); err != nil {
+			t.Fatalf("ObserveIdentity failed: %v", err)
+		}
+		if err := guard.ObserveIdentity(newIdentity("7300000000000000001", 1, 0x2000), 0x1800); err != nil {
+			t.Fatalf("ObserveIdentity failed: %v", err)
+		}
+
+		findings, _ := store.ListFindings("")
+		if len(findings) != 0 {
+			t.Fatalf("Expected no findings for a database moving forward, got %d", len(findings))
+		}
+		recorded, _ := store.GetDatabaseIdentity("billing")
+		if recorded == nil || recorded.XLogPos != 0x2000 || recorded.AcknowledgedLSN != 0x1800 || recorded.NodeID != "node1" {
+			t.Errorf("Expected the latest identity to be recorded, got %+v", recorded)
+		}
+
+		// A restore from an older backup on a new timeline
+		if err := guard.ObserveIdentity(newIdentity("7300000000000000001", 2, 0x1400), 0); err != nil {
+			t.Fatalf("ObserveIdentity failed: %v", err)
+		}
+
+		findings, _ = store.ListFindings("")
+		if len(findings) != 1 {
+			t.Fatalf("Expected one rollback finding, got %d", len(findings))
+		}
+		f := findings[0]
+		if f.Kind != storage.FindingDatabaseRollback || f.TableName != "billing/*" || f.DetectedBy != storage.DetectedByCDC {
+			t.Errorf("Unexpected finding: %+v", f)
+		}
+		if !strings.Contains(f.Details, "timeline switched from 1 to 2") || !strings.Contains(f.Details, "0/1400 is behind 0/2000") {
+			t.Errorf("Expected timeline and rollback details, got %q", f.Details)
+		}
+	})
+
+	t.Run("Raft", func(t *testing.T) {
+		store := newFindingTestStore(t)
+		if err := store.SaveDatabaseIdentity(newIdentity("7300000000000000001", 1, 0x1000)); err != nil {
+			t.Fatalf("SaveDatabaseIdentity failed: %v", err)
+		}
+
+		findingReplicator := &mockFindingReplicator{leader: true}
+		recorder := NewFindingRecorder(store, "node1")
+		recorder.SetRaftNode(findingReplicator)
+		replicator := &mockIdentityReplicator{leader: true}
+		guard := NewIdentityGuard(store, "node1", recorder, nil)
+		guard.SetRaftNode(replicator)
+
+		if err := guard.ObserveIdentity(newIdentity("7300000000000000002", 1, 0x3000), 0); err != nil {
+			t.Fatalf("ObserveIdentity failed: %v", err)
+		}
+		if len(findingReplicator.findings) != 1 || !strings.Contains(findingReplicator.findings[0].Details, "system identifier changed") {
+			t.Errorf("Expected a replicated replacement finding, got %+v", findingReplicator.findings)
+		}
+		if len(replicator.identities) != 1 || replicator.identities[0].SystemID != "7300000000000000002" {
+			t.Errorf("Expected the new identity to be replicated, got %+v", replicator.identities)
+		}
+
+		replicator.leader = false
+		if err := guard.ObserveIdentity(newIdentity("7300000000000000002", 1, 0x4000), 0); err != nil {
+			t.Fatalf("ObserveIdentity failed: %v", err)
+		}
+		if len(replicator.identities) != 1 {
+			t.Errorf("Expected a follower to leave recording to the leader, got %d", len(replicator.identities))
+		}
+	})
+}
@@ -3,42 +3,105 @@ package verify
 import (
 	"context"
 	"fmt"
-	"regexp"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/witnz/witnz/internal/alert"
+	"github.com/witnz/witnz/internal/cdc"
+	"github.com/witnz/witnz/internal/discovery"
 	"github.com/witnz/witnz/internal/hash"
+	"github.com/witnz/witnz/internal/signing"
 	"github.com/witnz/witnz/internal/storage"
 )
 
-var validTableNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
-
 type MerkleVerifier struct {
 	storage   *storage.Storage
 	dbConnStr string
-	tables    []*TableConfig
-	raftNode  RaftNode
-	mu        sync.RWMutex
-	stopCh    chan struct{}
-	wg        sync.WaitGroup
+	// sources maps the name of each named source to its connection string
+	sources map[string]string
+	// dialers open the scanners of sources that are not PostgreSQL
+	dialers      map[string]Dialer
+	tables       []*TableConfig
+	raftNode     RaftNode
+	findings     *FindingRecorder
+	alertManager *alert.Manager
+	signer       *signing.Signer
+	keyRing      *signing.KeyRing
+	catchUp      func() <-chan struct{}
+	mu           sync.RWMutex
+	stopCh       chan struct{}
+	wg           sync.WaitGroup
+
+	// Set once verification starts so tables added later are verified in
+	// the same context
+	ctx        context.Context
+	cancel     context.CancelFunc
+	running    bool
+	tableStops map[string]chan struct{}
 }
 
 // RaftNode interface for checkpoint replication
 type RaftNode interface {
 	IsLeader() bool
 	ApplyCheckpoint(checkpoint *storage.MerkleCheckpoint) error
+	SubmitCheckpointSignature(sig *storage.CheckpointSignature) error
 }
 
 func NewMerkleVerifier(store *storage.Storage, dbConnStr string) *MerkleVerifier {
 	return &MerkleVerifier{
-		storage:   store,
-		dbConnStr: dbConnStr,
-		tables:    make([]*TableConfig, 0),
-		stopCh:    make(chan struct{}),
+		storage:    store,
+		dbConnStr:  dbConnStr,
+		sources:    make(map[string]string),
+		dialers:    make(map[string]Dialer),
+		tables:     make([]*TableConfig, 0),
+		stopCh:     make(chan struct{}),
+		tableStops: make(map[string]chan struct{}),
 	}
 }
 
+// SetSource registers the database of a named source. Tables qualified by
+// the source are verified against it.
+func (v *MerkleVerifier) SetSource(name, connStr string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.sources[name] = connStr
+}
+
+// SetDialer sets how the tables of a source are read. Sources without a
+// dialer are PostgreSQL databases; the default source is named "".
+func (v *MerkleVerifier) SetDialer(source string, dial Dialer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.dialers[source] = dial
+}
+
+// connect opens a scanner on the database holding a table and returns the
+// table's name in that database
+func (v *MerkleVerifier) connect(ctx context.Context, tableName string) (TableScanner, string, error) {
+	source, relation := storage.SplitTable(tableName)
+
+	v.mu.RLock()
+	connStr, ok := v.sources[source]
+	dial := v.dialers[source]
+	v.mu.RUnlock()
+	if source == "" {
+		connStr, ok = v.dbConnStr, v.dbConnStr != ""
+	}
+	if !ok {
+		return nil, "", fmt.Errorf("no database configured for source %q of table %s", source, tableName)
+	}
+	if dial == nil {
+		dial = DialPostgres
+	}
+
+	scanner, err := dial(ctx, connStr)
+	if err != nil {
+		return nil, "", err
+	}
+	return scanner, relation, nil
+}
+
 // SetRaftNode sets the Raft node for checkpoint replication
 func (v *MerkleVerifier) SetRaftNode(node RaftNode) {
 	v.mu.Lock()
@@ -46,51 +109,236 @@ func (v *MerkleVerifier) SetRaftNode(node RaftNode) {
 	v.raftNode = node
 }
 
+// SetFindingRecorder sets the recorder used to persist tamper findings
+func (v *MerkleVerifier) SetFindingRecorder(r *FindingRecorder) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.findings = r
+}
+
+// SetAlertManager sets the manager used to alert on partitions leaving a
+// protected table
+func (v *MerkleVerifier) SetAlertManager(am *alert.Manager) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.alertManager = am
+}
+
+// SetSigner enables checkpoint signing. With a key ring set, only
+// checkpoints signed by a quorum of nodes are trusted as a baseline.
+func (v *MerkleVerifier) SetSigner(signer *signing.Signer, ring *signing.KeyRing) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.signer = signer
+	v.keyRing = ring
+}
+
+// SetCatchUp defers verification while the channel gate returns is open.
+// A node replaying the Raft log would otherwise compare PostgreSQL against
+// a partial hash chain. Periodic runs are skipped while the node is behind.
+func (v *MerkleVerifier) SetCatchUp(gate func() <-chan struct{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.catchUp = gate
+}
+
+// caughtUp reports whether the node is caught up with the Raft leader
+func (v *MerkleVerifier) caughtUp() bool {
+	v.mu.RLock()
+	gate := v.catchUp
+	v.mu.RUnlock()
+	return gate == nil || isClosed(gate())
+}
+
+// AddTable protects a table. Once verification has started, the table is
+// verified right away and its periodic verification is started.
 func (v *MerkleVerifier) AddTable(config *TableConfig) error {
-	if !validTableNameRegex.MatchString(config.Name) {
+	if !storage.ValidTableName(config.Name) {
 		return fmt.Errorf("invalid table name: %s", config.Name)
 	}
+	interval, err := parseVerifyInterval(config)
+	if err != nil {
+		return err
+	}
+
 	v.mu.Lock()
 	defer v.mu.Unlock()
+
+	for _, table := range v.tables {
+		if table.Name == config.Name {
+			return fmt.Errorf("table already added: %s", config.Name)
+		}
+	}
 	v.tables = append(v.tables, config)
+
+	if v.running && !isClosed(v.stopCh) {
+		v.wg.Add(1)
+		go func() {
+			defer v.wg.Done()
+			v.verifyAtStartup(v.ctx, config.Name)
+		}()
+		v.startPeriodicLocked(config.Name, interval)
+	}
 	return nil
 }
 
+// RemoveTable stops verifying a table. Checkpoints already recorded for it
+// are kept.
+func (v *MerkleVerifier) RemoveTable(name string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for i, table := range v.tables {
+		if table.Name == name {
+			v.tables = append(v.tables[:i:i], v.tables[i+1:]...)
+			break
+		}
+	}
+
+	if stop, ok := v.tableStops[name]; ok {
+		close(stop)
+		delete(v.tableStops, name)
+	}
+}
+
+func parseVerifyInterval(table *TableConfig) (time.Duration, error) {
+	if table.VerifyInterval == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(table.VerifyInterval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid verify_interval for %s: %w", table.Name, err)
+	}
+	return interval, nil
+}
+
 func quoteIdentifier(name string) string {
-	return `"` + name + `"`
+	return discovery.Identifier(name).Sanitize()
 }
 
 func (v *MerkleVerifier) Start(ctx context.Context) error {
-	fmt.Println("Running startup Merkle Root verification...")
+	v.mu.RLock()
 	for _, table := range v.tables {
-		if err := v.VerifyTable(ctx, table.Name); err != nil {
-			fmt.Printf("⚠️  VERIFICATION WARNING for %s: %v\n", table.Name, err)
-		} else {
-			fmt.Printf("✅ Merkle Root verified for %s\n", table.Name)
+		if _, err := parseVerifyInterval(table); err != nil {
+			v.mu.RUnlock()
+			return err
 		}
 	}
+	catchUp := v.catchUp
+	v.mu.RUnlock()
 
-	for _, table := range v.tables {
-		if table.VerifyInterval != "" {
-			interval, err := time.ParseDuration(table.VerifyInterval)
-			if err != nil {
-				return fmt.Errorf("invalid verify_interval for %s: %w", table.Name, err)
+	// Stop cancels verification runs still in progress
+	ctx, cancel := context.WithCancel(ctx)
+	v.mu.Lock()
+	v.cancel = cancel
+	v.mu.Unlock()
+
+	if !v.caughtUp() {
+		logger.Info("Deferring Merkle Root verification until this node catches up with the Raft leader")
+		v.wg.Add(1)
+		go func() {
+			defer v.wg.Done()
+			for !v.caughtUp() {
+				select {
+				case <-catchUp():
+				case <-v.stopCh:
+					return
+				case <-ctx.Done():
+					return
+				}
 			}
+			v.startVerification(ctx)
+		}()
+		return nil
+	}
+
+	v.startVerification(ctx)
+	return nil
+}
+
+func (v *MerkleVerifier) startVerification(ctx context.Context) {
+	v.mu.Lock()
+	v.ctx = ctx
+	v.running = true
+	tables := append([]*TableConfig(nil), v.tables...)
+	v.mu.Unlock()
+
+	logger.Info("Running startup Merkle Root verification", "tables", len(tables))
+	for _, table := range tables {
+		v.verifyAtStartup(ctx, table.Name)
+	}
 
-			v.wg.Add(1)
-			go v.runPeriodicVerification(ctx, table.Name, interval)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, table := range tables {
+		if !v.hasTableLocked(table.Name) {
+			continue
 		}
+		// Intervals were validated when the table was added
+		interval, _ := parseVerifyInterval(table)
+		v.startPeriodicLocked(table.Name, interval)
 	}
+}
 
-	return nil
+func (v *MerkleVerifier) verifyAtStartup(ctx context.Context, tableName string) {
+	if err := v.VerifyTable(ctx, tableName); err != nil {
+		logger.Warn("Startup verification failed", "table", tableName, "error", err)
+	} else {
+		logger.Info("Merkle Root verified", "table", tableName)
+	}
+}
+
+func (v *MerkleVerifier) hasTableLocked(name string) bool {
+	for _, table := range v.tables {
+		if table.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *MerkleVerifier) startPeriodicLocked(tableName string, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	if _, ok := v.tableStops[tableName]; ok {
+		return
+	}
+
+	stop := make(chan struct{})
+	v.tableStops[tableName] = stop
+
+	v.wg.Add(1)
+	go v.runPeriodicVerification(v.ctx, tableName, interval, stop)
 }
 
-func (v *MerkleVerifier) Stop() {
+// Stop ends verification, cancels runs in progress and waits for them to
+// return until ctx is done
+func (v *MerkleVerifier) Stop(ctx context.Context) error {
 	close(v.stopCh)
-	v.wg.Wait()
+
+	v.mu.RLock()
+	cancel := v.cancel
+	v.mu.RUnlock()
+	if cancel != nil {
+		cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		v.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for verification to stop: %w", ctx.Err())
+	}
 }
 
-func (v *MerkleVerifier) runPeriodicVerification(ctx context.Context, tableName string, interval time.Duration) {
+func (v *MerkleVerifier) runPeriodicVerification(ctx context.Context, tableName string, interval time.Duration, stop <-chan struct{}) {
 	defer v.wg.Done()
 
 	ticker := time.NewTicker(interval)
@@ -100,17 +348,37 @@ func (v *MerkleVerifier) runPeriodicVerification(ctx context.Context, tableName
 		select {
 		case <-v.stopCh:
 			return
+		case <-stop:
+			return
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			if !v.caughtUp() {
+				logger.Info("Skipping periodic verification until this node catches up with the Raft leader", "table", tableName)
+				continue
+			}
 			if err := v.VerifyTable(ctx, tableName); err != nil {
-				fmt.Printf("🚨 TAMPERING DETECTED during verification of %s: %v\n", tableName, err)
+				logger.Error("Periodic verification failed", "table", tableName, "error", err)
 			}
 		}
 	}
 }
 
 func (v *MerkleVerifier) VerifyTable(ctx context.Context, tableName string) error {
+	scanner, relation, err := v.connect(ctx, tableName)
+	if err != nil {
+		return err
+	}
+	partitions, partitioned, err := scanner.Partitions(ctx, relation)
+	if err == nil && partitioned {
+		defer scanner.Close(ctx)
+		return v.verifyPartitionedTable(ctx, scanner, tableName, partitions)
+	}
+	scanner.Close(ctx)
+	if err != nil {
+		return err
+	}
+
 	actualMerkleRoot, pgRecordCount, pgTree, err := v.calculateCurrentMerkleRootFromPG(ctx, tableName)
 	if err != nil {
 		return fmt.Errorf("failed to calculate actual Merkle Root from PostgreSQL: %w", err)
@@ -122,26 +390,21 @@ func (v *MerkleVerifier) VerifyTable(ctx context.Context, tableName string) erro
 	}
 
 	if boltdbRecordCount == 0 {
-		fmt.Printf("No hash entries in BoltDB for %s, creating initial checkpoint...\n", tableName)
+		logger.Info("No hash entries in BoltDB, creating initial checkpoint", "table", tableName, "records", pgRecordCount)
 		return v.createCheckpointWithTree(tableName, actualMerkleRoot, pgRecordCount, pgTree)
 	}
 
 	if actualMerkleRoot == expectedMerkleRoot && pgRecordCount == boltdbRecordCount {
-		fmt.Printf("✅ Merkle Root match for %s (PostgreSQL matches BoltDB)\n", tableName)
+		logger.Info("Merkle Root matches BoltDB", "table", tableName, "records", pgRecordCount)
 		return v.createCheckpointWithTree(tableName, actualMerkleRoot, pgRecordCount, pgTree)
 	}
 
-	fmt.Printf("⚠️  Merkle Root mismatch for %s, performing detailed verification...\n", tableName)
-	expectedShort := expectedMerkleRoot
-	if len(expectedShort) > 16 {
-		expectedShort = expectedShort[:16] + "..."
-	}
-	actualShort := actualMerkleRoot
-	if len(actualShort) > 16 {
-		actualShort = actualShort[:16] + "..."
-	}
-	fmt.Printf("   Expected (BoltDB): %s (%d records)\n", expectedShort, boltdbRecordCount)
-	fmt.Printf("   Actual (PostgreSQL): %s (%d records)\n", actualShort, pgRecordCount)
+	logger.Warn("Merkle Root mismatch, performing detailed verification",
+		"table", tableName,
+		"expected_root", expectedMerkleRoot,
+		"expected_records", boltdbRecordCount,
+		"actual_root", actualMerkleRoot,
+		"actual_records", pgRecordCount)
 	return v.performDetailedVerification(ctx, tableName)
 }
 
@@ -164,9 +427,9 @@ func (v *MerkleVerifier) performDetailedVerification(ctx context.Context, tableN
 	for id := range pgIDs {
 		if !boltdbIDs[id] {
 			phantomInserts = append(phantomInserts, id)
-			msg := fmt.Sprintf("found extra record in DB not in hash chain (Phantom Insert): id=%s", id)
-			tamperedRecords = append(tamperedRecords, msg)
-			fmt.Printf("  TAMPERING: %s\n", msg)
+			tamperedRecords = append(tamperedRecords, id)
+			logger.Error("Tampering detected", "table", tableName, "kind", storage.FindingPhantom, "record_id", id)
+			v.recordFinding(tableName, id, storage.FindingPhantom, "", actualLeafMap[id])
 		}
 	}
 
@@ -174,9 +437,9 @@ func (v *MerkleVerifier) performDetailedVerification(ctx context.Context, tableN
 	for id := range boltdbIDs {
 		if !pgIDs[id] {
 			deletedRecords = append(deletedRecords, id)
-			msg := fmt.Sprintf("record deleted: id=%s", id)
-			tamperedRecords = append(tamperedRecords, msg)
-			fmt.Printf("  TAMPERING: %s\n", msg)
+			tamperedRecords = append(tamperedRecords, id)
+			logger.Error("Tampering detected", "table", tableName, "kind", storage.FindingDeleted, "record_id", id)
+			v.recordFinding(tableName, id, storage.FindingDeleted, expectedLeafMap[id], "")
 		}
 	}
 
@@ -237,28 +500,43 @@ func (v *MerkleVerifier) performDetailedVerification(ctx context.Context, tableN
 		}
 
 		if diff.Type == "modified" {
-			hashLen := min(16, len(diff.ExpectedHash), len(diff.ActualHash))
-			msg := fmt.Sprintf("data modified: id=%s, expected hash=%s..., actual hash=%s...",
-				id, diff.ExpectedHash[:hashLen], diff.ActualHash[:hashLen])
-			tamperedRecords = append(tamperedRecords, msg)
-			fmt.Printf("  TAMPERING: %s\n", msg)
+			tamperedRecords = append(tamperedRecords, id)
+			logger.Error("Tampering detected",
+				"table", tableName,
+				"kind", storage.FindingModified,
+				"record_id", id,
+				"expected_hash", diff.ExpectedHash,
+				"actual_hash", diff.ActualHash)
+			v.recordFinding(tableName, id, storage.FindingModified, diff.ExpectedHash, diff.ActualHash)
 		}
 	}
 
 	if len(tamperedRecords) > 0 {
-		errMsg := fmt.Sprintf("🚨 CRITICAL: PostgreSQL tampering detected! Found %d tampered records", len(tamperedRecords))
-		fmt.Println(errMsg)
-		fmt.Println("Tampered records:")
-		for _, record := range tamperedRecords {
-			fmt.Printf("  - %s\n", record)
-		}
+		logger.Error("PostgreSQL tampering detected, run 'witnz forensics <table> <id>' to inspect a record",
+			"table", tableName,
+			"tampered_records", len(tamperedRecords))
 
-		return fmt.Errorf("%s", errMsg)
+		return fmt.Errorf("PostgreSQL tampering detected in %s: found %d tampered records", tableName, len(tamperedRecords))
 	}
 
 	return v.createCheckpoint(tableName, newMerkleRoot, len(actualLeafMap))
 }
 
+func (v *MerkleVerifier) recordFinding(tableName, recordID string, kind storage.FindingKind, expectedHash, actualHash string) {
+	v.mu.RLock()
+	findings := v.findings
+	v.mu.RUnlock()
+
+	findings.recordQuietly(&storage.Finding{
+		TableName:    tableName,
+		RecordID:     recordID,
+		Kind:         kind,
+		DetectedBy:   storage.DetectedByMerkle,
+		ExpectedHash: expectedHash,
+		ActualHash:   actualHash,
+	})
+}
+
 func (v *MerkleVerifier) buildLeafMapFromBoltDB(tableName string) (map[string]string, map[string]bool, error) {
 	entries, err := v.storage.GetAllHashEntries(tableName)
 	if err != nil {
@@ -266,11 +544,10 @@ func (v *MerkleVerifier) buildLeafMapFromBoltDB(tableName string) (map[string]st
 	}
 
 	leafMap := make(map[string]string)
-	ids := make(map[string]bool)
+	applyHashEntries(leafMap, entries, 0)
 
-	for _, entry := range entries {
-		id := extractIDFromRecordID(entry.RecordID)
-		leafMap[id] = entry.DataHash
+	ids := make(map[string]bool, len(leafMap))
+	for id := range leafMap {
 		ids[id] = true
 	}
 
@@ -278,39 +555,18 @@ func (v *MerkleVerifier) buildLeafMapFromBoltDB(tableName string) (map[string]st
 }
 
 func (v *MerkleVerifier) buildLeafMapFromPostgreSQL(ctx context.Context, tableName string) (map[string]string, map[string]bool, string, error) {
-	conn, err := pgx.Connect(ctx, v.dbConnStr)
-	if err != nil {
-		return nil, nil, "", fmt.Errorf("failed to connect to database: %w", err)
-	}
-	defer conn.Close(ctx)
-
-	rows, err := conn.Query(ctx, fmt.Sprintf("SELECT * FROM %s ORDER BY id", quoteIdentifier(tableName)))
+	scanner, relation, err := v.connect(ctx, tableName)
 	if err != nil {
-		return nil, nil, "", fmt.Errorf("failed to query table: %w", err)
+		return nil, nil, "", err
 	}
-	defer rows.Close()
+	defer scanner.Close(ctx)
 
 	leafMap := make(map[string]string)
 	ids := make(map[string]bool)
 	builder := hash.NewMerkleTreeBuilder()
 
-	for rows.Next() {
-		fieldDescs := rows.FieldDescriptions()
-		values, err := rows.Values()
-		if err != nil {
-			return nil, nil, "", fmt.Errorf("failed to scan row: %w", err)
-		}
-
-		recordData := make(map[string]interface{})
-		var idValue string
-		for i, fd := range fieldDescs {
-			fieldName := string(fd.Name)
-			recordData[fieldName] = values[i]
-			if fieldName == "id" {
-				idValue = fmt.Sprintf("%v", values[i])
-			}
-		}
-
+	err = scanner.Scan(ctx, relation, func(recordData map[string]interface{}) error {
+		idValue := rowID(recordData)
 		dataHash := hash.CalculateDataHash(recordData)
 		leafMap[idValue] = dataHash
 		ids[idValue] = true
@@ -318,6 +574,10 @@ func (v *MerkleVerifier) buildLeafMapFromPostgreSQL(ctx context.Context, tableNa
 		// For Merkle tree construction, use full record representation
 		recordID := fmt.Sprintf("%v", recordData)
 		builder.AddLeafHash(recordID, dataHash)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to scan table: %w", err)
 	}
 
 	var merkleRoot string
@@ -332,42 +592,24 @@ func (v *MerkleVerifier) buildLeafMapFromPostgreSQL(ctx context.Context, tableNa
 }
 
 func (v *MerkleVerifier) calculateCurrentMerkleRootFromPG(ctx context.Context, tableName string) (string, int, *hash.MerkleTreeBuilder, error) {
-	conn, err := pgx.Connect(ctx, v.dbConnStr)
-	if err != nil {
-		return "", 0, nil, fmt.Errorf("failed to connect to database: %w", err)
-	}
-	defer conn.Close(ctx)
-
-	rows, err := conn.Query(ctx, fmt.Sprintf("SELECT * FROM %s ORDER BY id", quoteIdentifier(tableName)))
+	scanner, relation, err := v.connect(ctx, tableName)
 	if err != nil {
-		return "", 0, nil, fmt.Errorf("failed to query table: %w", err)
+		return "", 0, nil, err
 	}
-	defer rows.Close()
+	defer scanner.Close(ctx)
 
 	builder := hash.NewMerkleTreeBuilder()
 	recordCount := 0
 
-	for rows.Next() {
-		fieldDescs := rows.FieldDescriptions()
-		values, err := rows.Values()
-		if err != nil {
-			return "", 0, nil, fmt.Errorf("failed to scan row: %w", err)
-		}
-
-		recordData := make(map[string]interface{})
-		var recordID string
-		for i, fd := range fieldDescs {
-			fieldName := string(fd.Name)
-			recordData[fieldName] = values[i]
-			if fieldName == "id" {
-				recordID = fmt.Sprintf("%v", values[i])
-			}
-		}
-
-		if err := builder.AddLeaf(recordID, recordData); err != nil {
-			return "", 0, nil, fmt.Errorf("failed to add leaf: %w", err)
+	err = scanner.Scan(ctx, relation, func(recordData map[string]interface{}) error {
+		if err := builder.AddLeaf(rowID(recordData), recordData); err != nil {
+			return fmt.Errorf("failed to add leaf: %w", err)
 		}
 		recordCount++
+		return nil
+	})
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("failed to scan table: %w", err)
 	}
 
 	if recordCount == 0 {
@@ -383,7 +625,7 @@ func (v *MerkleVerifier) calculateCurrentMerkleRootFromPG(ctx context.Context, t
 
 func (v *MerkleVerifier) calculateMerkleRootFromBoltDB(tableName string) (string, int, error) {
 	// Try to use checkpoint optimization for O(k log n) complexity
-	checkpoint, err := v.storage.GetLatestMerkleCheckpoint(tableName)
+	checkpoint, err := v.latestTrustedCheckpoint(tableName)
 	if err == nil && checkpoint != nil && len(checkpoint.LeafMap) > 0 {
 		// Use checkpoint-based reconstruction (O(k log n) where k = new entries)
 		return v.calculateMerkleRootFromCheckpoint(tableName, checkpoint)
@@ -395,21 +637,24 @@ func (v *MerkleVerifier) calculateMerkleRootFromBoltDB(tableName string) (string
 		return "", 0, fmt.Errorf("failed to get hash entries from BoltDB: %w", err)
 	}
 
-	if len(entries) == 0 {
+	leafMap := make(map[string]string)
+	applyHashEntries(leafMap, entries, 0)
+
+	if len(leafMap) == 0 {
 		return "", 0, nil
 	}
 
 	builder := hash.NewMerkleTreeBuilder()
 
-	for _, entry := range entries {
-		builder.AddLeafHash(entry.RecordID, entry.DataHash)
+	for recordID, dataHash := range leafMap {
+		builder.AddLeafHash(recordID, dataHash)
 	}
 
 	if err := builder.Build(); err != nil {
 		return "", 0, fmt.Errorf("failed to build Merkle Tree from BoltDB: %w", err)
 	}
 
-	return builder.GetRoot(), len(entries), nil
+	return builder.GetRoot(), len(leafMap), nil
 }
 
 // calculateMerkleRootFromCheckpoint uses checkpoint data to optimize tree construction
@@ -428,12 +673,10 @@ func (v *MerkleVerifier) calculateMerkleRootFromCheckpoint(tableName string, che
 	}
 
 	// Update leaf map with new entries (those with SequenceNum > checkpoint.SequenceNum)
-	newEntryCount := 0
-	for _, entry := range entries {
-		if entry.SequenceNum > checkpoint.SequenceNum {
-			leafMap[entry.RecordID] = entry.DataHash
-			newEntryCount++
-		}
+	applyHashEntries(leafMap, entries, checkpoint.SequenceNum)
+
+	if len(leafMap) == 0 {
+		return "", 0, nil
 	}
 
 	// Build Merkle tree from updated leaf map
@@ -449,12 +692,44 @@ func (v *MerkleVerifier) calculateMerkleRootFromCheckpoint(tableName string, che
 	return builder.GetRoot(), len(leafMap), nil
 }
 
+// latestTrustedCheckpoint returns the newest checkpoint usable as a baseline.
+// When signing is enabled a checkpoint needs valid signatures from a quorum
+// of nodes, so a single compromised node cannot forge one.
+func (v *MerkleVerifier) latestTrustedCheckpoint(tableName string) (*storage.MerkleCheckpoint, error) {
+	v.mu.RLock()
+	ring := v.keyRing
+	v.mu.RUnlock()
+
+	if ring == nil {
+		return v.storage.GetLatestMerkleCheckpoint(tableName)
+	}
+
+	checkpoints, err := v.storage.GetMerkleCheckpoints(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(checkpoints) - 1; i >= 0; i-- {
+		checkpoint := checkpoints[i]
+		if ring.CountValid(checkpoint.SigningPayload(), checkpoint.Signatures) >= ring.Quorum() {
+			return checkpoint, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no quorum-signed checkpoint for table %s", tableName)
+}
+
 func (v *MerkleVerifier) createCheckpoint(tableName, merkleRoot string, recordCount int) error {
 	return v.createCheckpointWithTree(tableName, merkleRoot, recordCount, nil)
 }
 
 // createCheckpointWithTree creates a checkpoint with optional Merkle tree data
 func (v *MerkleVerifier) createCheckpointWithTree(tableName, merkleRoot string, recordCount int, tree *hash.MerkleTreeBuilder) error {
+	return v.commitCheckpoint(v.newCheckpoint(tableName, merkleRoot, recordCount, tree))
+}
+
+// newCheckpoint describes the table at its latest hash chain sequence
+func (v *MerkleVerifier) newCheckpoint(tableName, merkleRoot string, recordCount int, tree *hash.MerkleTreeBuilder) *storage.MerkleCheckpoint {
 	latestEntry, err := v.storage.GetLatestHashEntry(tableName)
 	var seqNum uint64 = 0
 	if err == nil {
@@ -476,39 +751,124 @@ func (v *MerkleVerifier) createCheckpointWithTree(tableName, merkleRoot string,
 		checkpoint.InternalNodes = tree.GetInternalNodes()
 	}
 
+	return checkpoint
+}
+
+// commitCheckpoint signs a checkpoint and replicates it when this node leads,
+// or saves it locally otherwise
+func (v *MerkleVerifier) commitCheckpoint(checkpoint *storage.MerkleCheckpoint) error {
+	tableName := checkpoint.TableName
+	seqNum := checkpoint.SequenceNum
+	merkleRoot := checkpoint.MerkleRoot
+	recordCount := checkpoint.RecordCount
+
 	// If this node is the Raft leader, replicate checkpoint to all followers
 	v.mu.RLock()
 	raftNode := v.raftNode
+	signer := v.signer
 	v.mu.RUnlock()
 
+	if signer != nil {
+		checkpoint.Signatures = map[string]string{
+			signer.NodeID(): signer.Sign(checkpoint.SigningPayload()),
+		}
+	}
+
+	if raftNode != nil && !raftNode.IsLeader() {
+		existing, err := v.storage.GetMerkleCheckpoint(tableName, seqNum)
+		if err == nil {
+			return v.attestCheckpoint(raftNode, existing, checkpoint)
+		}
+	}
+
 	if raftNode != nil && raftNode.IsLeader() {
 		// Leader: replicate via Raft consensus
 		if err := raftNode.ApplyCheckpoint(checkpoint); err != nil {
 			return fmt.Errorf("failed to replicate checkpoint via Raft: %w", err)
 		}
 
-		if len(merkleRoot) >= 16 {
-			fmt.Printf("📍 Created and replicated Merkle checkpoint for %s (root: %s..., records: %d, algorithm: %s)\n",
-				tableName, merkleRoot[:16], recordCount, checkpoint.HashAlgorithm)
-		} else {
-			fmt.Printf("📍 Created and replicated Merkle checkpoint for %s (root: %s, records: %d, algorithm: %s)\n",
-				tableName, merkleRoot, recordCount, checkpoint.HashAlgorithm)
-		}
+		logger.Info("Created and replicated Merkle checkpoint",
+			"table", tableName,
+			"seq", seqNum,
+			"root", merkleRoot,
+			"records", recordCount,
+			"algorithm", checkpoint.HashAlgorithm)
 	} else {
 		// Follower or non-Raft mode: save locally only
 		if err := v.storage.SaveMerkleCheckpoint(checkpoint); err != nil {
 			return fmt.Errorf("failed to save checkpoint: %w", err)
 		}
 
-		if len(merkleRoot) >= 16 {
-			fmt.Printf("📍 Created Merkle checkpoint for %s (root: %s..., records: %d, algorithm: %s)\n",
-				tableName, merkleRoot[:16], recordCount, checkpoint.HashAlgorithm)
+		logger.Info("Created Merkle checkpoint",
+			"table", tableName,
+			"seq", seqNum,
+			"root", merkleRoot,
+			"records", recordCount,
+			"algorithm", checkpoint.HashAlgorithm)
+	}
+
+	return nil
+}
+
+// applyHashEntries replays hash entries newer than afterSeq onto leafMap in
+// sequence order. Approved updates replace the hash of a record and approved
+// deletes remove it.
+func applyHashEntries(leafMap map[string]string, entries []*storage.HashEntry, afterSeq uint64) {
+	sorted := make([]*storage.HashEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].SequenceNum < sorted[j].SequenceNum
+	})
+
+	for _, entry := range sorted {
+		if entry.SequenceNum <= afterSeq {
+			continue
+		}
+
+		id := extractIDFromRecordID(entry.RecordID)
+		if entry.OperationType == string(cdc.OperationDelete) {
+			delete(leafMap, id)
 		} else {
-			fmt.Printf("📍 Created Merkle checkpoint for %s (root: %s, records: %d, algorithm: %s)\n",
-				tableName, merkleRoot, recordCount, checkpoint.HashAlgorithm)
+			leafMap[id] = entry.DataHash
 		}
 	}
+}
+
+// attestCheckpoint handles a follower verification that reached a sequence
+// the leader already checkpointed. The replicated checkpoint is kept, and if
+// this node computed the same root its signature is sent to the leader.
+func (v *MerkleVerifier) attestCheckpoint(raftNode RaftNode, existing, local *storage.MerkleCheckpoint) error {
+	if existing.MerkleRoot != local.MerkleRoot {
+		logger.Warn("Local Merkle Root differs from the replicated checkpoint, not signing",
+			"table", local.TableName,
+			"seq", local.SequenceNum)
+		return nil
+	}
+
+	v.mu.RLock()
+	signer := v.signer
+	v.mu.RUnlock()
+
+	if signer == nil {
+		return nil
+	}
+	if _, signed := existing.Signatures[signer.NodeID()]; signed {
+		return nil
+	}
+
+	sig := &storage.CheckpointSignature{
+		TableName:   local.TableName,
+		SequenceNum: local.SequenceNum,
+		MerkleRoot:  local.MerkleRoot,
+		NodeID:      signer.NodeID(),
+		Signature:   local.Signatures[signer.NodeID()],
+	}
+
+	if err := raftNode.SubmitCheckpointSignature(sig); err != nil {
+		return fmt.Errorf("failed to submit checkpoint signature: %w", err)
+	}
 
+	logger.Info("Signed Merkle checkpoint", "table", local.TableName, "seq", local.SequenceNum)
 	return nil
 }
 
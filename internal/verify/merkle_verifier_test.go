@@ -0,0 +1,241 @@
+package verify
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/witnz/witnz/internal/signing"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+func TestLatestTrustedCheckpoint(t *testing.T) {
+	store := newFindingTestStore(t)
+
+	signers := make(map[string]*signing.Signer)
+	publicKeys := make(map[string]string)
+	for _, id := range []string{"node1", "node2", "node3"} {
+		key, err := signing.LoadOrCreateKey(filepath.Join(t.TempDir(), "node.key"))
+		if err != nil {
+			t.Fatalf("LoadOrCreateKey failed: %v", err)
+		}
+		signers[id] = signing.NewSigner(id, key)
+		publicKeys[id] = signers[id].PublicKey()
+	}
+
+	ring, err := signing.NewKeyRing(publicKeys)
+	if err != nil {
+		t.Fatalf("NewKeyRing failed: %v", err)
+	}
+
+	newCheckpoint := func(seq uint64, signedBy ...string) *storage.MerkleCheckpoint {
+		checkpoint := &storage.MerkleCheckpoint{
+			TableName:   "audit_log",
+			SequenceNum: seq,
+			MerkleRoot:  "root",
+			Timestamp:   time.Now(),
+			Signatures:  make(map[string]string),
+		}
+		for _, id := range signedBy {
+			checkpoint.Signatures[id] = signers[id].Sign(checkpoint.SigningPayload())
+		}
+		return checkpoint
+	}
+
+	for _, checkpoint := range []*storage.MerkleCheckpoint{
+		newCheckpoint(10, "node1", "node2"),
+		newCheckpoint(20, "node1"),
+	} {
+		if err := store.SaveMerkleCheckpoint(checkpoint); err != nil {
+			t.Fatalf("SaveMerkleCheckpoint failed: %v", err)
+		}
+	}
+
+	verifier := NewMerkleVerifier(store, "")
+	verifier.SetSigner(signers["node1"], ring)
+
+	trusted, err := verifier.latestTrustedCheckpoint("audit_log")
+	if err != nil {
+		t.Fatalf("latestTrustedCheckpoint failed: %v", err)
+	}
+	if trusted.SequenceNum != 10 {
+		t.Errorf("Expected quorum-signed checkpoint 10, got %d", trusted.SequenceNum)
+	}
+
+	if err := store.AddCheckpointSignature(&storage.CheckpointSignature{
+		TableName:   "audit_log",
+		SequenceNum: 20,
+		MerkleRoot:  "root",
+		NodeID:      "node3",
+		Signature:   signers["node3"].Sign(storage.CheckpointSigningPayload("audit_log", 20, "root")),
+	}); err != nil {
+		t.Fatalf("AddCheckpointSignature failed: %v", err)
+	}
+
+	trusted, err = verifier.latestTrustedCheckpoint("audit_log")
+	if err != nil {
+		t.Fatalf("latestTrustedCheckpoint failed: %v", err)
+	}
+	if trusted.SequenceNum != 20 {
+		t.Errorf("Expected checkpoint 20 once it reached quorum, got %d", trusted.SequenceNum)
+	}
+}
+
+func TestMerkleVerifierTableChanges(t *testing.T) {
+	store := newFindingTestStore(t)
+
+	// Nothing listens on port 1, so verification fails fast and is only
+	// reported
+	verifier := NewMerkleVerifier(store, "host=127.0.0.1 port=1 dbname=witnz connect_timeout=1")
+	if err := verifier.AddTable(&TableConfig{Name: "audit_log", VerifyInterval: "bogus"}); err == nil {
+		t.Error("Expected an invalid verify_interval to be rejected")
+	}
+	if err := verifier.AddTable(&TableConfig{Name: "audit_log"}); err != nil {
+		t.Fatalf("AddTable failed: %v", err)
+	}
+	if err := verifier.AddTable(&TableConfig{Name: "audit_log"}); err == nil {
+		t.Error("Expected a duplicate table to be rejected")
+	}
+
+	if err := verifier.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if err := verifier.AddTable(&TableConfig{Name: "payments", VerifyInterval: "1h"}); err != nil {
+		t.Fatalf("AddTable after Start failed: %v", err)
+	}
+
+	verifier.mu.RLock()
+	_, running := verifier.tableStops["payments"]
+	verifier.mu.RUnlock()
+	if !running {
+		t.Error("Expected periodic verification to start for a table added after Start")
+	}
+
+	verifier.RemoveTable("payments")
+
+	verifier.mu.RLock()
+	_, running = verifier.tableStops["payments"]
+	tables := len(verifier.tables)
+	verifier.mu.RUnlock()
+	if running || tables != 1 {
+		t.Errorf("Expected payments to be removed, got %d tables (running=%v)", tables, running)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		verifier.Stop(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the verifier to stop")
+	}
+}
+
+func TestMerkleVerifierSources(t *testing.T) {
+	store := newFindingTestStore(t)
+	verifier := NewMerkleVerifier(store, "")
+	verifier.SetSource("billing", "host=127.0.0.1 port=1 dbname=billing connect_timeout=1")
+
+	if err := verifier.AddTable(&TableConfig{Name: "billing/audit_log"}); err != nil {
+		t.Fatalf("Expected a table of a named source to be accepted, got %v", err)
+	}
+
+	ctx := context.Background()
+	if _, _, err := verifier.connect(ctx, "audit_log"); err == nil || !strings.Contains(err.Error(), "no database configured") {
+		t.Errorf("Expected the default source to be unconfigured, got %v", err)
+	}
+	if _, _, err := verifier.connect(ctx, "orders/audit_log"); err == nil || !strings.Contains(err.Error(), "no database configured") {
+		t.Errorf("Expected an unknown source to be rejected, got %v", err)
+	}
+	// Nothing listens on port 1, so reaching the dial shows the source's
+	// connection string was used
+	if _, _, err := verifier.connect(ctx, "billing/audit_log"); err == nil || !strings.Contains(err.Error(), "failed to connect") {
+		t.Errorf("Expected a connection attempt to the billing database, got %v", err)
+	}
+}
+
+// memoryScanner is a TableScanner over rows held in memory
+type memoryScanner struct {
+	tables map[string][]map[string]interface{}
+}
+
+func (s *memoryScanner) Scan(_ context.Context, table string, fn func(map[string]interface{}) error) error {
+	for _, row := range s.tables[table] {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memoryScanner) Partitions(context.Context, string) ([]string, bool, error) {
+	return nil, false, nil
+}
+
+func (s *memoryScanner) ScanPartition(ctx context.Context, partition string, fn func(map[string]interface{}) error) error {
+	return s.Scan(ctx, partition, fn)
+}
+
+func (s *memoryScanner) PartitionExists(context.Context, string) (bool, error) {
+	return false, nil
+}
+
+func (s *memoryScanner) Fetch(_ context.Context, table, recordID string) (map[string]interface{}, error) {
+	for _, row := range s.tables[table] {
+		if rowID(row) == recordID {
+			return row, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryScanner) Close(context.Context) error {
+	return nil
+}
+
+func TestMerkleVerifierDialer(t *testing.T) {
+	store := newFindingTestStore(t)
+	scanner := &memoryScanner{tables: map[string][]map[string]interface{}{
+		"audit_log": {
+			{"id": int64(1), "action": "login"},
+			{"id": int64(2), "action": "logout"},
+		},
+	}}
+
+	var dialed string
+	verifier := NewMerkleVerifier(store, "")
+	verifier.SetSource("ledger", "ledger-dsn")
+	verifier.SetDialer("ledger", func(_ context.Context, connStr string) (TableScanner, error) {
+		dialed = connStr
+		return scanner, nil
+	})
+
+	ctx := context.Background()
+	if err := verifier.VerifyTable(ctx, "ledger/audit_log"); err != nil {
+		t.Fatalf("VerifyTable failed: %v", err)
+	}
+	if dialed != "ledger-dsn" {
+		t.Errorf("Expected the dialer to get the ledger connection string, got %q", dialed)
+	}
+
+	checkpoint, err := store.GetLatestMerkleCheckpoint("ledger/audit_log")
+	if err != nil {
+		t.Fatalf("GetLatestMerkleCheckpoint failed: %v", err)
+	}
+	if checkpoint.RecordCount != 2 || len(checkpoint.LeafMap) != 2 {
+		t.Errorf("Expected a checkpoint of 2 records, got %d records and %d leaves", checkpoint.RecordCount, len(checkpoint.LeafMap))
+	}
+
+	record, err := verifier.fetchRecord(ctx, "ledger/audit_log", "2")
+	if err != nil {
+		t.Fatalf("fetchRecord failed: %v", err)
+	}
+	if record["action"] != "logout" {
+		t.Errorf("Expected record 2 to be fetched through the scanner, got %v", record)
+	}
+}
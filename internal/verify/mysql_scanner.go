@@ -0,0 +1,99 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/witnz/witnz/internal/mysql"
+)
+
+// mysqlConn is the part of a MySQL connection the scanner uses
+type mysqlConn interface {
+	QueryEach(ctx context.Context, query string, fn func(columns []mysql.Column, row [][]byte) error) error
+	Close() error
+}
+
+// mysqlScanner is the TableScanner of MySQL sources. Rows are converted to
+// the values the binary log decodes to, so a scanned row hashes the same
+// as the change that wrote it.
+type mysqlScanner struct {
+	conn mysqlConn
+}
+
+// DialMySQL opens a TableScanner on a MySQL database. The connection
+// string has the same fields as for PostgreSQL.
+func DialMySQL(ctx context.Context, connStr string) (TableScanner, error) {
+	config, err := mysql.ParseConnString(connStr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := mysql.Connect(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	// Return strings in the charset of their column and TIMESTAMPs in UTC,
+	// as the binary log has them
+	err = conn.QueryEach(ctx, "SET character_set_results = NULL, time_zone = '+00:00'",
+		func([]mysql.Column, [][]byte) error { return nil })
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to configure session: %w", err)
+	}
+	return &mysqlScanner{conn: conn}, nil
+}
+
+func (s *mysqlScanner) Scan(ctx context.Context, table string, fn func(map[string]interface{}) error) error {
+	query := fmt.Sprintf("SELECT * FROM %s ORDER BY id", mysql.QuoteIdentifier(table))
+	if err := s.query(ctx, query, fn); err != nil {
+		return fmt.Errorf("failed to query %s: %w", table, err)
+	}
+	return nil
+}
+
+func (s *mysqlScanner) query(ctx context.Context, query string, fn func(map[string]interface{}) error) error {
+	return s.conn.QueryEach(ctx, query, func(columns []mysql.Column, values [][]byte) error {
+		row := make(map[string]interface{}, len(columns))
+		for i := range columns {
+			value, err := columns[i].Value(values[i])
+			if err != nil {
+				return fmt.Errorf("failed to scan column %s: %w", columns[i].Name, err)
+			}
+			row[columns[i].Name] = value
+		}
+		return fn(row)
+	})
+}
+
+// Partitions reports every table as unpartitioned: a MySQL table is read
+// as a whole, across all of its partitions
+func (s *mysqlScanner) Partitions(ctx context.Context, table string) ([]string, bool, error) {
+	return nil, false, nil
+}
+
+func (s *mysqlScanner) ScanPartition(ctx context.Context, partition string, fn func(map[string]interface{}) error) error {
+	return fmt.Errorf("MySQL partitions are scanned with their table")
+}
+
+func (s *mysqlScanner) PartitionExists(ctx context.Context, partition string) (bool, error) {
+	return false, nil
+}
+
+func (s *mysqlScanner) Fetch(ctx context.Context, table, recordID string) (map[string]interface{}, error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE CAST(id AS CHAR) = %s",
+		mysql.QuoteIdentifier(table), mysql.Literal(recordID))
+
+	var record map[string]interface{}
+	err := s.query(ctx, query, func(row map[string]interface{}) error {
+		record = row
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query record: %w", err)
+	}
+	return record, nil
+}
+
+func (s *mysqlScanner) Close(ctx context.Context) error {
+	return s.conn.Close()
+}
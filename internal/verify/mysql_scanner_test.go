@@ -0,0 +1,110 @@
+package verify
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/witnz/witnz/internal/hash"
+	"github.com/witnz/witnz/internal/mysql"
+)
+
+// fakeMySQLConn answers every query with the same rows
+type fakeMySQLConn struct {
+	columns []mysql.Column
+	rows    [][][]byte
+	queries []string
+}
+
+func (c *fakeMySQLConn) QueryEach(ctx context.Context, query string, fn func([]mysql.Column, [][]byte) error) error {
+	c.queries = append(c.queries, query)
+	for _, row := range c.rows {
+		if err := fn(c.columns, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *fakeMySQLConn) Close() error { return nil }
+
+func TestMySQLScanner(t *testing.T) {
+	ctx := context.Background()
+	conn := &fakeMySQLConn{
+		columns: []mysql.Column{
+			{Name: "id", Type: mysql.TypeLong},
+			{Name: "name", Type: mysql.TypeVarString, Charset: 255},
+			{Name: "digest", Type: mysql.TypeString, Charset: 63},
+			{Name: "doc", Type: mysql.TypeJSON, Charset: 63},
+			{Name: "note", Type: mysql.TypeVarString, Charset: 255},
+		},
+		rows: [][][]byte{
+			{[]byte("5"), []byte("Alice"), {1, 2, 0, 0}, []byte(`{"b": "x", "a": 7}`), nil},
+		},
+	}
+	scanner := &mysqlScanner{conn: conn}
+
+	t.Run("Scan", func(t *testing.T) {
+		var rows []map[string]interface{}
+		err := scanner.Scan(ctx, "shop.orders", func(row map[string]interface{}) error {
+			rows = append(rows, row)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+
+		if conn.queries[len(conn.queries)-1] != "SELECT * FROM `shop`.`orders` ORDER BY id" {
+			t.Errorf("Unexpected query %s", conn.queries[len(conn.queries)-1])
+		}
+		if len(rows) != 1 {
+			t.Fatalf("Expected 1 row, got %d", len(rows))
+		}
+
+		// The row as the binary log decodes it
+		changed := map[string]interface{}{
+			"id":     int64(5),
+			"name":   "Alice",
+			"digest": []byte{1, 2, 0, 0},
+			"doc":    `{"a":7,"b":"x"}`,
+			"note":   nil,
+		}
+		if !reflect.DeepEqual(rows[0], changed) {
+			t.Errorf("Expected %v, got %v", changed, rows[0])
+		}
+		if hash.CalculateDataHash(rows[0]) != hash.CalculateDataHash(changed) {
+			t.Error("Expected the scanned row to hash like the changed row")
+		}
+		if rowID(rows[0]) != "5" {
+			t.Errorf("Expected record ID 5, got %s", rowID(rows[0]))
+		}
+	})
+
+	t.Run("Fetch", func(t *testing.T) {
+		record, err := scanner.Fetch(ctx, "orders", "5")
+		if err != nil {
+			t.Fatalf("Fetch failed: %v", err)
+		}
+		if record["name"] != "Alice" {
+			t.Errorf("Expected Alice, got %v", record["name"])
+		}
+		if want := "SELECT * FROM `orders` WHERE CAST(id AS CHAR) = X'35'"; conn.queries[len(conn.queries)-1] != want {
+			t.Errorf("Expected %s, got %s", want, conn.queries[len(conn.queries)-1])
+		}
+	})
+
+	t.Run("FetchMissing", func(t *testing.T) {
+		empty := &mysqlScanner{conn: &fakeMySQLConn{}}
+		record, err := empty.Fetch(ctx, "orders", "6")
+		if err != nil || record != nil {
+			t.Errorf("Expected no record, got %v (%v)", record, err)
+		}
+	})
+
+	t.Run("Partitions", func(t *testing.T) {
+		partitions, partitioned, err := scanner.Partitions(ctx, "orders")
+		if err != nil || partitioned || partitions != nil {
+			t.Errorf("Expected an unpartitioned table, got %v %v %v", partitions, partitioned, err)
+		}
+	})
+}
@@ -0,0 +1,319 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/witnz/witnz/internal/hash"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+// A partitioned table is protected as one logical table: with
+// publish_via_partition_root its changes arrive under the parent's name and
+// form a single hash chain. Verification compares each leaf partition's
+// subtree on its own, so a mismatch points at the partition that changed.
+
+// partitionIssue is a record that differs between the hash chain and one
+// partition
+type partitionIssue struct {
+	Partition    string
+	RecordID     string
+	Kind         storage.FindingKind
+	ExpectedHash string
+	ActualHash   string
+}
+
+// partitionComparison is the outcome of comparing a partitioned table's
+// hash chain with its partitions
+type partitionComparison struct {
+	// Attached and Removed are partitions added or gone since the previous
+	// checkpoint. Records of a removed partition are not reported as deleted.
+	Attached []string
+	Removed  []string
+	Issues   []partitionIssue
+	// Leaves is the expected leaf map after the records of removed
+	// partitions are dropped
+	Leaves map[string]string
+}
+
+// listPartitions returns the leaf partitions of a partitioned table, or
+// false if the table is not partitioned. Names are as PostgreSQL prints them
+// and can be used in queries as they are.
+func listPartitions(ctx context.Context, conn *pgx.Conn, tableName string) ([]string, bool, error) {
+	var partitioned bool
+	err := conn.QueryRow(ctx, "SELECT relkind = 'p' FROM pg_class WHERE oid = to_regclass($1)",
+		quoteIdentifier(tableName)).Scan(&partitioned)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("table %s does not exist", tableName)
+	}
+	if err != nil || !partitioned {
+		return nil, false, err
+	}
+
+	rows, err := conn.Query(ctx, `SELECT relid::regclass::text FROM pg_partition_tree(to_regclass($1))
+		WHERE isleaf ORDER BY 1`, quoteIdentifier(tableName))
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to list partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, true, fmt.Errorf("failed to scan partition: %w", err)
+		}
+		partitions = append(partitions, name)
+	}
+	return partitions, true, rows.Err()
+}
+
+// readLeaves hashes every row of a partition, keyed by record ID
+func readLeaves(ctx context.Context, scanner TableScanner, partition string) (map[string]string, error) {
+	leaves := make(map[string]string)
+	err := scanner.ScanPartition(ctx, partition, func(row map[string]interface{}) error {
+		leaves[rowID(row)] = hash.CalculateDataHash(row)
+		return nil
+	})
+	return leaves, err
+}
+
+// comparePartitions checks the expected leaves of the hash chain against
+// the leaves read from each partition. previous holds the partitions of the
+// last checkpoint and may be nil.
+func comparePartitions(expected map[string]string, previous map[string]*storage.PartitionCheckpoint, actual map[string]map[string]string) *partitionComparison {
+	result := &partitionComparison{Leaves: make(map[string]string, len(expected))}
+	for id, h := range expected {
+		result.Leaves[id] = h
+	}
+
+	location := make(map[string]string)
+	for partition, leaves := range actual {
+		for id := range leaves {
+			location[id] = partition
+		}
+	}
+
+	if previous != nil {
+		for partition := range actual {
+			if _, ok := previous[partition]; !ok {
+				result.Attached = append(result.Attached, partition)
+			}
+		}
+		for partition, subtree := range previous {
+			if _, ok := actual[partition]; ok {
+				continue
+			}
+			result.Removed = append(result.Removed, partition)
+			for _, id := range subtree.RecordIDs {
+				if _, moved := location[id]; !moved {
+					delete(result.Leaves, id)
+				}
+			}
+		}
+	}
+	sort.Strings(result.Attached)
+	sort.Strings(result.Removed)
+
+	for id, h := range result.Leaves {
+		if _, ok := location[id]; !ok {
+			result.Issues = append(result.Issues, partitionIssue{RecordID: id, Kind: storage.FindingDeleted, ExpectedHash: h})
+		}
+	}
+
+	partitions := make([]string, 0, len(actual))
+	for partition := range actual {
+		partitions = append(partitions, partition)
+	}
+	sort.Strings(partitions)
+
+	for _, partition := range partitions {
+		leaves := actual[partition]
+
+		expectedSub := make(map[string]string)
+		for id := range leaves {
+			if h, ok := result.Leaves[id]; ok {
+				expectedSub[id] = h
+			}
+		}
+		if len(expectedSub) == len(leaves) && leafRoot(expectedSub) == leafRoot(leaves) {
+			continue
+		}
+
+		for id, h := range leaves {
+			want, ok := expectedSub[id]
+			switch {
+			case !ok:
+				result.Issues = append(result.Issues, partitionIssue{Partition: partition, RecordID: id, Kind: storage.FindingPhantom, ActualHash: h})
+			case want != h:
+				result.Issues = append(result.Issues, partitionIssue{Partition: partition, RecordID: id, Kind: storage.FindingModified, ExpectedHash: want, ActualHash: h})
+			}
+		}
+	}
+
+	sort.Slice(result.Issues, func(i, j int) bool {
+		if result.Issues[i].Partition != result.Issues[j].Partition {
+			return result.Issues[i].Partition < result.Issues[j].Partition
+		}
+		return result.Issues[i].RecordID < result.Issues[j].RecordID
+	})
+	return result
+}
+
+// leafRoot returns the Merkle root over a leaf map, or "" when it is empty
+func leafRoot(leaves map[string]string) string {
+	if len(leaves) == 0 {
+		return ""
+	}
+	builder := hash.NewMerkleTreeBuilder()
+	for id, h := range leaves {
+		builder.AddLeafHash(id, h)
+	}
+	if err := builder.Build(); err != nil {
+		return ""
+	}
+	return builder.GetRoot()
+}
+
+// expectedLeaves replays the hash chain of a table onto its latest trusted
+// checkpoint, or from the start when there is none
+func (v *MerkleVerifier) expectedLeaves(tableName string) (map[string]string, *storage.MerkleCheckpoint, error) {
+	entries, err := v.storage.GetAllHashEntries(tableName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get hash entries: %w", err)
+	}
+
+	leaves := make(map[string]string)
+	checkpoint, err := v.latestTrustedCheckpoint(tableName)
+	if err != nil || checkpoint == nil || (len(checkpoint.LeafMap) == 0 && checkpoint.Partitions == nil) {
+		applyHashEntries(leaves, entries, 0)
+		return leaves, nil, nil
+	}
+
+	for id, h := range checkpoint.LeafMap {
+		leaves[id] = h
+	}
+	applyHashEntries(leaves, entries, checkpoint.SequenceNum)
+	return leaves, checkpoint, nil
+}
+
+// verifyPartitionedTable verifies each leaf partition of a partitioned
+// table against the table's hash chain
+func (v *MerkleVerifier) verifyPartitionedTable(ctx context.Context, scanner TableScanner, tableName string, partitions []string) error {
+	expected, checkpoint, err := v.expectedLeaves(tableName)
+	if err != nil {
+		return fmt.Errorf("failed to calculate expected leaves from BoltDB: %w", err)
+	}
+
+	actual := make(map[string]map[string]string, len(partitions))
+	for _, partition := range partitions {
+		leaves, err := readLeaves(ctx, scanner, partition)
+		if err != nil {
+			return fmt.Errorf("failed to read partition %s: %w", partition, err)
+		}
+		actual[partition] = leaves
+	}
+
+	if checkpoint == nil && len(expected) == 0 {
+		logger.Info("No hash entries in BoltDB, creating initial checkpoint", "table", tableName, "partitions", len(partitions))
+		return v.commitPartitionCheckpoint(tableName, actual)
+	}
+
+	var previous map[string]*storage.PartitionCheckpoint
+	if checkpoint != nil {
+		previous = checkpoint.Partitions
+	}
+	result := comparePartitions(expected, previous, actual)
+
+	for _, partition := range result.Attached {
+		logger.Info("Partition attached, verifying it from now on", "table", tableName, "partition", partition)
+	}
+	for _, partition := range result.Removed {
+		v.reportRemovedPartition(ctx, scanner, tableName, partition, len(previous[partition].RecordIDs))
+	}
+
+	if len(result.Issues) > 0 {
+		for _, issue := range result.Issues {
+			logger.Error("Tampering detected",
+				"table", tableName,
+				"partition", issue.Partition,
+				"kind", issue.Kind,
+				"record_id", issue.RecordID)
+			v.recordFinding(tableName, issue.RecordID, issue.Kind, issue.ExpectedHash, issue.ActualHash)
+		}
+		logger.Error("PostgreSQL tampering detected, run 'witnz forensics <table> <id>' to inspect a record",
+			"table", tableName,
+			"tampered_records", len(result.Issues))
+		return fmt.Errorf("PostgreSQL tampering detected in %s: found %d tampered records", tableName, len(result.Issues))
+	}
+
+	logger.Info("Merkle Root matches BoltDB", "table", tableName, "partitions", len(partitions))
+	return v.commitPartitionCheckpoint(tableName, actual)
+}
+
+// reportRemovedPartition alerts that a partition left a protected table.
+// Its rows are no longer part of the table even though no DELETE was
+// replicated for them.
+func (v *MerkleVerifier) reportRemovedPartition(ctx context.Context, scanner TableScanner, tableName, partition string, records int) {
+	detached, err := scanner.PartitionExists(ctx, partition)
+	if err != nil {
+		logger.Warn("Failed to look up removed partition", "table", tableName, "partition", partition, "error", err)
+	}
+
+	action := "dropped"
+	if detached {
+		action = "detached"
+	}
+	logger.Warn("Partition removed, its protected records left with it",
+		"table", tableName,
+		"partition", partition,
+		"action", action,
+		"records", records)
+
+	v.mu.RLock()
+	alerts := v.alertManager
+	v.mu.RUnlock()
+	if alerts != nil {
+		_ = alerts.SendSystemAlert(
+			"Protected Partition Removed",
+			fmt.Sprintf("Partition %s was %s from protected table %s; %d records it held are no longer verified", partition, action, tableName, records),
+			"danger",
+		)
+	}
+}
+
+// commitPartitionCheckpoint checkpoints the whole table together with the
+// subtree of every partition
+func (v *MerkleVerifier) commitPartitionCheckpoint(tableName string, actual map[string]map[string]string) error {
+	tree := hash.NewMerkleTreeBuilder()
+	subtrees := make(map[string]*storage.PartitionCheckpoint, len(actual))
+	count := 0
+
+	for partition, leaves := range actual {
+		ids := make([]string, 0, len(leaves))
+		for id, h := range leaves {
+			tree.AddLeafHash(id, h)
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		subtrees[partition] = &storage.PartitionCheckpoint{MerkleRoot: leafRoot(leaves), RecordIDs: ids}
+		count += len(leaves)
+	}
+
+	var root string
+	if count > 0 {
+		if err := tree.Build(); err != nil {
+			return fmt.Errorf("failed to build tree: %w", err)
+		}
+		root = tree.GetRoot()
+	} else {
+		tree = nil
+	}
+
+	checkpoint := v.newCheckpoint(tableName, root, count, tree)
+	checkpoint.Partitions = subtrees
+	return v.commitCheckpoint(checkpoint)
+}
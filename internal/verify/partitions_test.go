@@ -0,0 +1,83 @@
+package verify
+
+import (
+	"testing"
+
+	"github.com/witnz/witnz/internal/storage"
+)
+
+func TestComparePartitions(t *testing.T) {
+	expected := map[string]string{"1": "h1", "2": "h2", "3": "h3", "4": "h4"}
+	previous := map[string]*storage.PartitionCheckpoint{
+		"events_2025_01": {RecordIDs: []string{"1", "2"}},
+		"events_2025_02": {RecordIDs: []string{"3"}},
+		"events_2025_03": {RecordIDs: []string{"4"}},
+	}
+
+	t.Run("Unchanged", func(t *testing.T) {
+		actual := map[string]map[string]string{
+			"events_2025_01": {"1": "h1", "2": "h2"},
+			"events_2025_02": {"3": "h3"},
+			"events_2025_03": {"4": "h4"},
+		}
+		result := comparePartitions(expected, previous, actual)
+		if len(result.Issues) != 0 || len(result.Attached) != 0 || len(result.Removed) != 0 {
+			t.Errorf("Expected no changes, got %+v", result)
+		}
+	})
+
+	t.Run("TamperedPartition", func(t *testing.T) {
+		actual := map[string]map[string]string{
+			"events_2025_01": {"1": "h1", "2": "tampered"},
+			"events_2025_02": {"3": "h3", "5": "h5"},
+			"events_2025_03": {},
+		}
+		result := comparePartitions(expected, previous, actual)
+
+		want := []partitionIssue{
+			{RecordID: "4", Kind: storage.FindingDeleted, ExpectedHash: "h4"},
+			{Partition: "events_2025_01", RecordID: "2", Kind: storage.FindingModified, ExpectedHash: "h2", ActualHash: "tampered"},
+			{Partition: "events_2025_02", RecordID: "5", Kind: storage.FindingPhantom, ActualHash: "h5"},
+		}
+		if len(result.Issues) != len(want) {
+			t.Fatalf("Expected %d issues, got %+v", len(want), result.Issues)
+		}
+		for i := range want {
+			if result.Issues[i] != want[i] {
+				t.Errorf("Expected issue %+v, got %+v", want[i], result.Issues[i])
+			}
+		}
+	})
+
+	t.Run("AttachAndRemove", func(t *testing.T) {
+		actual := map[string]map[string]string{
+			"events_2025_01": {"1": "h1", "2": "h2"},
+			"events_2025_03": {"4": "h4"},
+			"events_2025_04": {},
+		}
+		result := comparePartitions(expected, previous, actual)
+
+		if len(result.Attached) != 1 || result.Attached[0] != "events_2025_04" {
+			t.Errorf("Expected events_2025_04 attached, got %v", result.Attached)
+		}
+		if len(result.Removed) != 1 || result.Removed[0] != "events_2025_02" {
+			t.Errorf("Expected events_2025_02 removed, got %v", result.Removed)
+		}
+		if len(result.Issues) != 0 {
+			t.Errorf("Expected records of a removed partition not to be reported as deleted, got %+v", result.Issues)
+		}
+		if _, ok := result.Leaves["3"]; ok || len(result.Leaves) != 3 {
+			t.Errorf("Expected record 3 to leave the expected leaves, got %v", result.Leaves)
+		}
+	})
+
+	t.Run("WithoutPreviousPartitions", func(t *testing.T) {
+		actual := map[string]map[string]string{
+			"events_2025_01": {"1": "h1", "2": "h2", "3": "h3"},
+		}
+		result := comparePartitions(expected, nil, actual)
+		if len(result.Issues) != 1 || result.Issues[0].Kind != storage.FindingDeleted || result.Issues[0].RecordID != "4" {
+			t.Errorf("Expected record 4 reported as deleted, got %+v", result.Issues)
+		}
+	})
+}
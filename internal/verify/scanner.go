@@ -0,0 +1,126 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TableScanner reads the current rows of protected tables over one
+// connection to a source database. Table names are as the database knows
+// them, without the source prefix. Rows are keyed by column name and carry
+// the record ID in the id column.
+type TableScanner interface {
+	// Scan calls fn for every row of a table, ordered by id
+	Scan(ctx context.Context, table string, fn func(row map[string]interface{}) error) error
+	// Partitions returns the leaf partitions of a table, or false if the
+	// table is not partitioned
+	Partitions(ctx context.Context, table string) ([]string, bool, error)
+	// ScanPartition calls fn for every row of a partition returned by
+	// Partitions
+	ScanPartition(ctx context.Context, partition string, fn func(row map[string]interface{}) error) error
+	// PartitionExists reports whether a partition is still a table of its
+	// own, e.g. after it was detached
+	PartitionExists(ctx context.Context, partition string) (bool, error)
+	// Fetch returns the row with the given record ID, or nil if it does not
+	// exist
+	Fetch(ctx context.Context, table, recordID string) (map[string]interface{}, error)
+	Close(ctx context.Context) error
+}
+
+// Dialer opens a TableScanner on the database of a connection string
+type Dialer func(ctx context.Context, connStr string) (TableScanner, error)
+
+// rowID returns the record ID of a scanned row
+func rowID(row map[string]interface{}) string {
+	id, ok := row["id"]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%v", id)
+}
+
+// pgScanner is the TableScanner of PostgreSQL sources
+type pgScanner struct {
+	conn *pgx.Conn
+}
+
+// DialPostgres opens a TableScanner on a PostgreSQL database
+func DialPostgres(ctx context.Context, connStr string) (TableScanner, error) {
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return &pgScanner{conn: conn}, nil
+}
+
+func (s *pgScanner) Scan(ctx context.Context, table string, fn func(map[string]interface{}) error) error {
+	return s.scan(ctx, quoteIdentifier(table), fn)
+}
+
+// ScanPartition takes the partition name as PostgreSQL prints it, which can
+// be used in queries as it is
+func (s *pgScanner) ScanPartition(ctx context.Context, partition string, fn func(map[string]interface{}) error) error {
+	return s.scan(ctx, partition, fn)
+}
+
+func (s *pgScanner) scan(ctx context.Context, relation string, fn func(map[string]interface{}) error) error {
+	rows, err := s.conn.Query(ctx, fmt.Sprintf("SELECT * FROM %s ORDER BY id", relation))
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", relation, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		row := make(map[string]interface{}, len(values))
+		for i, fd := range rows.FieldDescriptions() {
+			row[fd.Name] = values[i]
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *pgScanner) Partitions(ctx context.Context, table string) ([]string, bool, error) {
+	return listPartitions(ctx, s.conn, table)
+}
+
+func (s *pgScanner) PartitionExists(ctx context.Context, partition string) (bool, error) {
+	var exists bool
+	if err := s.conn.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", partition).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look up partition: %w", err)
+	}
+	return exists, nil
+}
+
+func (s *pgScanner) Fetch(ctx context.Context, table, recordID string) (map[string]interface{}, error) {
+	rows, err := s.conn.Query(ctx,
+		fmt.Sprintf("SELECT * FROM %s WHERE id::text = $1", quoteIdentifier(table)),
+		recordID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query record: %w", err)
+	}
+
+	record, err := pgx.CollectOneRow(rows, pgx.RowToMap)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read record: %w", err)
+	}
+
+	return record, nil
+}
+
+func (s *pgScanner) Close(ctx context.Context) error {
+	return s.conn.Close(ctx)
+}
@@ -0,0 +1,175 @@
+package verify
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/witnz/witnz/internal/alert"
+	"github.com/witnz/witnz/internal/storage"
+)
+
+const defaultWitnessRetention = 10 * time.Minute
+
+// LeaderInfo reports the current Raft role of this node
+type LeaderInfo interface {
+	IsLeader() bool
+	Leader() string
+}
+
+type witnessKey struct {
+	table    string
+	recordID string
+	lsn      uint64
+}
+
+type witnessObservation struct {
+	hash   string
+	seenAt time.Time
+}
+
+// Witness compares the hashes a follower computes from its own WAL stream
+// with the hash entries proposed by the leader. Observations are keyed by
+// (table, record, LSN) and matched in whichever order they arrive.
+type Witness struct {
+	findings     *FindingRecorder
+	alertManager *alert.Manager
+	node         LeaderInfo
+	retention    time.Duration
+	mu           sync.Mutex
+	local        map[witnessKey]witnessObservation
+	proposed     map[witnessKey]witnessObservation
+}
+
+func NewWitness(findings *FindingRecorder, am *alert.Manager) *Witness {
+	return &Witness{
+		findings:     findings,
+		alertManager: am,
+		retention:    defaultWitnessRetention,
+		local:        make(map[witnessKey]witnessObservation),
+		proposed:     make(map[witnessKey]witnessObservation),
+	}
+}
+
+// SetRaftNode sets the node used to skip entries this node proposed itself
+func (w *Witness) SetRaftNode(node LeaderInfo) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.node = node
+}
+
+// RecordLocal stores the hash this node computed for a change
+func (w *Witness) RecordLocal(table, recordID string, lsn uint64, dataHash string) {
+	if w == nil || lsn == 0 {
+		return
+	}
+
+	key := witnessKey{table: table, recordID: recordID, lsn: lsn}
+	now := time.Now()
+
+	w.mu.Lock()
+	w.prune(now)
+	proposed, ok := w.proposed[key]
+	if ok {
+		delete(w.proposed, key)
+	} else {
+		w.local[key] = witnessObservation{hash: dataHash, seenAt: now}
+	}
+	w.mu.Unlock()
+
+	if ok {
+		w.compare(key, proposed.hash, dataHash)
+	}
+}
+
+// ObserveHashEntry is called by the FSM after it applies a hash entry
+func (w *Witness) ObserveHashEntry(entry *storage.HashEntry) {
+	if entry.LSN == 0 {
+		return
+	}
+
+	key := witnessKey{
+		table:    entry.TableName,
+		recordID: extractIDFromRecordID(entry.RecordID),
+		lsn:      entry.LSN,
+	}
+	now := time.Now()
+
+	w.mu.Lock()
+	if w.node != nil && w.node.IsLeader() {
+		w.mu.Unlock()
+		return
+	}
+	w.prune(now)
+	local, ok := w.local[key]
+	if ok {
+		delete(w.local, key)
+	} else {
+		w.proposed[key] = witnessObservation{hash: entry.DataHash, seenAt: now}
+	}
+	w.mu.Unlock()
+
+	if ok {
+		w.compare(key, entry.DataHash, local.hash)
+	}
+}
+
+func (w *Witness) compare(key witnessKey, leaderHash, localHash string) {
+	if leaderHash == localHash {
+		return
+	}
+
+	leader := ""
+	w.mu.Lock()
+	if w.node != nil {
+		leader = w.node.Leader()
+	}
+	w.mu.Unlock()
+
+	logger.Error("Leader divergence: proposed hash differs from local observation",
+		"table", key.table,
+		"record_id", key.recordID,
+		"lsn", key.lsn,
+		"leader", leader,
+		"leader_hash", leaderHash,
+		"local_hash", localHash)
+
+	if w.findings != nil {
+		if err := w.findings.RecordLocal(&storage.Finding{
+			TableName:    key.table,
+			RecordID:     key.recordID,
+			Kind:         storage.FindingLeaderDivergence,
+			DetectedBy:   storage.DetectedByWitness,
+			LSN:          key.lsn,
+			ExpectedHash: localHash,
+			ActualHash:   leaderHash,
+			Details:      fmt.Sprintf("hash proposed by leader %s differs from the hash computed locally", leader),
+		}); err != nil {
+			logger.Error("Failed to record leader divergence finding", "error", err)
+		}
+	}
+
+	if w.alertManager != nil {
+		go func() {
+			if err := w.alertManager.SendLeaderDivergenceAlert(key.table, key.recordID, key.lsn, leader, leaderHash, localHash); err != nil {
+				logger.Error("Failed to send leader divergence alert", "error", err)
+			}
+		}()
+	}
+}
+
+// prune drops observations that were never matched, e.g. entries replayed
+// from the Raft log after a restart. Callers must hold w.mu.
+func (w *Witness) prune(now time.Time) {
+	cutoff := now.Add(-w.retention)
+	for key, obs := range w.local {
+		if obs.seenAt.Before(cutoff) {
+			delete(w.local, key)
+		}
+	}
+	for key, obs := range w.proposed {
+		if obs.seenAt.Before(cutoff) {
+			delete(w.proposed, key)
+		}
+	}
+}
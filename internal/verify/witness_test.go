@@ -0,0 +1,117 @@
+package verify
+
+import (
+	"testing"
+
+	"github.com/witnz/witnz/internal/storage"
+)
+
+type mockLeaderInfo struct {
+	leader bool
+}
+
+func (m *mockLeaderInfo) IsLeader() bool {
+	return m.leader
+}
+
+func (m *mockLeaderInfo) Leader() string {
+	return "node1:7000"
+}
+
+func TestWitness(t *testing.T) {
+	proposed := func(recordID string, lsn uint64, hash string) *storage.HashEntry {
+		return &storage.HashEntry{
+			TableName: "audit_log",
+			RecordID:  "map[id:" + recordID + "]",
+			DataHash:  hash,
+			LSN:       lsn,
+		}
+	}
+
+	t.Run("Matching hashes record nothing", func(t *testing.T) {
+		store := newFindingTestStore(t)
+		w := NewWitness(NewFindingRecorder(store, "node2"), nil)
+		w.SetRaftNode(&mockLeaderInfo{})
+
+		w.RecordLocal("audit_log", "1", 100, "hash-a")
+		w.ObserveHashEntry(proposed("1", 100, "hash-a"))
+
+		findings, _ := store.ListFindings("")
+		if len(findings) != 0 {
+			t.Errorf("Expected 0 findings, got %d", len(findings))
+		}
+		if len(w.local) != 0 || len(w.proposed) != 0 {
+			t.Errorf("Expected matched observations to be cleared, got %d local and %d proposed", len(w.local), len(w.proposed))
+		}
+	})
+
+	t.Run("Divergence with local hash first", func(t *testing.T) {
+		store := newFindingTestStore(t)
+		w := NewWitness(NewFindingRecorder(store, "node2"), nil)
+		w.SetRaftNode(&mockLeaderInfo{})
+
+		w.RecordLocal("audit_log", "1", 100, "hash-local")
+		w.ObserveHashEntry(proposed("1", 100, "hash-leader"))
+
+		findings, _ := store.ListFindings("")
+		if len(findings) != 1 {
+			t.Fatalf("Expected 1 finding, got %d", len(findings))
+		}
+		f := findings[0]
+		if f.Kind != storage.FindingLeaderDivergence {
+			t.Errorf("Expected kind %s, got %s", storage.FindingLeaderDivergence, f.Kind)
+		}
+		if f.DetectedBy != storage.DetectedByWitness {
+			t.Errorf("Expected detected_by %s, got %s", storage.DetectedByWitness, f.DetectedBy)
+		}
+		if f.ExpectedHash != "hash-local" || f.ActualHash != "hash-leader" {
+			t.Errorf("Expected hashes hash-local/hash-leader, got %s/%s", f.ExpectedHash, f.ActualHash)
+		}
+		if f.LSN != 100 || f.RecordID != "1" || f.NodeID != "node2" {
+			t.Errorf("Expected LSN 100, record 1 on node2, got %d, %s on %s", f.LSN, f.RecordID, f.NodeID)
+		}
+	})
+
+	t.Run("Divergence with proposed hash first", func(t *testing.T) {
+		store := newFindingTestStore(t)
+		w := NewWitness(NewFindingRecorder(store, "node2"), nil)
+		w.SetRaftNode(&mockLeaderInfo{})
+
+		w.ObserveHashEntry(proposed("1", 100, "hash-leader"))
+		w.RecordLocal("audit_log", "1", 100, "hash-local")
+
+		findings, _ := store.ListFindings("")
+		if len(findings) != 1 {
+			t.Fatalf("Expected 1 finding, got %d", len(findings))
+		}
+		if findings[0].ExpectedHash != "hash-local" || findings[0].ActualHash != "hash-leader" {
+			t.Errorf("Expected hashes hash-local/hash-leader, got %s/%s", findings[0].ExpectedHash, findings[0].ActualHash)
+		}
+	})
+
+	t.Run("Different LSNs are not compared", func(t *testing.T) {
+		store := newFindingTestStore(t)
+		w := NewWitness(NewFindingRecorder(store, "node2"), nil)
+		w.SetRaftNode(&mockLeaderInfo{})
+
+		w.RecordLocal("audit_log", "1", 100, "hash-a")
+		w.ObserveHashEntry(proposed("1", 101, "hash-b"))
+
+		findings, _ := store.ListFindings("")
+		if len(findings) != 0 {
+			t.Errorf("Expected 0 findings, got %d", len(findings))
+		}
+	})
+
+	t.Run("Leader skips its own entries", func(t *testing.T) {
+		store := newFindingTestStore(t)
+		w := NewWitness(NewFindingRecorder(store, "node1"), nil)
+		w.SetRaftNode(&mockLeaderInfo{leader: true})
+
+		w.ObserveHashEntry(proposed("1", 100, "hash-leader"))
+
+		if len(w.proposed) != 0 {
+			t.Errorf("Expected leader to ignore proposed entries, got %d", len(w.proposed))
+		}
+	})
+}